@@ -0,0 +1,230 @@
+package webdav
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+// Privilege is the name of an access control privilege, as defined in RFC
+// 3744 section 3. Privileges not listed below may also be used.
+type Privilege xml.Name
+
+var (
+	PrivilegeRead                        = Privilege(internal.PrivilegeReadName)
+	PrivilegeWrite                       = Privilege(internal.PrivilegeWriteName)
+	PrivilegeWriteProperties             = Privilege(internal.PrivilegeWritePropertiesName)
+	PrivilegeWriteContent                = Privilege(internal.PrivilegeWriteContentName)
+	PrivilegeUnlock                      = Privilege(internal.PrivilegeUnlockName)
+	PrivilegeReadACL                     = Privilege(internal.PrivilegeReadACLName)
+	PrivilegeReadCurrentUserPrivilegeSet = Privilege(internal.PrivilegeReadCurrentUserPrivilegeSetName)
+	PrivilegeWriteACL                    = Privilege(internal.PrivilegeWriteACLName)
+	PrivilegeBind                        = Privilege(internal.PrivilegeBindName)
+	PrivilegeUnbind                      = Privilege(internal.PrivilegeUnbindName)
+	PrivilegeAll                         = Privilege(internal.PrivilegeAllName)
+)
+
+// ACEPrincipal identifies the principals an access control entry applies to.
+// Exactly one field should be set.
+type ACEPrincipal struct {
+	// Href is the URL of a principal.
+	Href string
+	// All matches every user.
+	All bool
+	// Authenticated matches authenticated users.
+	Authenticated bool
+	// Unauthenticated matches unauthenticated users.
+	Unauthenticated bool
+	// Property matches users whose principal URL is the value of this
+	// property of the resource.
+	Property xml.Name
+	// Self matches the principal resource itself.
+	Self bool
+}
+
+// ACE is an access control entry, as defined in RFC 3744 section 5.5.
+type ACE struct {
+	Principal ACEPrincipal
+	// Invert applies the entry to all principals except Principal.
+	Invert bool
+	Grant  []Privilege
+	Deny   []Privilege
+	// Protected indicates that the entry cannot be modified or removed.
+	Protected bool
+	// Inherited is the URL of the resource this entry is inherited from, if
+	// any.
+	Inherited string
+}
+
+// ACLRestrictions describes the restrictions on the ACEs of a resource, as
+// defined in RFC 3744 section 5.6.
+type ACLRestrictions struct {
+	GrantOnly       bool
+	NoInvert        bool
+	DenyBeforeGrant bool
+}
+
+// ACL is an access control list.
+type ACL struct {
+	ACEs []ACE
+	// Restrictions is only used when reading the ACL of a resource.
+	Restrictions ACLRestrictions
+}
+
+func newInternalPrivileges(privs []Privilege) []internal.Privilege {
+	l := make([]internal.Privilege, len(privs))
+	for i, priv := range privs {
+		l[i] = internal.NewPrivilege(xml.Name(priv))
+	}
+	return l
+}
+
+func newInternalACEPrincipal(p *ACEPrincipal) (*internal.ACEPrincipal, error) {
+	var ip internal.ACEPrincipal
+	switch {
+	case p.Href != "":
+		u, err := url.Parse(p.Href)
+		if err != nil {
+			return nil, err
+		}
+		ip.Href = (*internal.Href)(u)
+	case p.All:
+		ip.All = &struct{}{}
+	case p.Authenticated:
+		ip.Authenticated = &struct{}{}
+	case p.Unauthenticated:
+		ip.Unauthenticated = &struct{}{}
+	case p.Property != xml.Name{}:
+		ip.Property = &internal.PrincipalProperty{
+			Raw: []internal.RawXMLValue{*internal.NewRawXMLElement(p.Property, nil, nil)},
+		}
+	case p.Self:
+		ip.Self = &struct{}{}
+	default:
+		return nil, fmt.Errorf("webdav: empty ACE principal")
+	}
+	return &ip, nil
+}
+
+func newInternalACL(acl *ACL) (*internal.ACL, error) {
+	iacl := internal.ACL{ACEs: make([]internal.ACE, len(acl.ACEs))}
+	for i, ace := range acl.ACEs {
+		principal, err := newInternalACEPrincipal(&ace.Principal)
+		if err != nil {
+			return nil, err
+		}
+
+		iace := &iacl.ACEs[i]
+		if ace.Invert {
+			iace.Invert = &internal.Invert{Principal: *principal}
+		} else {
+			iace.Principal = principal
+		}
+		if len(ace.Grant) > 0 {
+			iace.Grant = &internal.Grant{Privileges: newInternalPrivileges(ace.Grant)}
+		}
+		if len(ace.Deny) > 0 {
+			iace.Deny = &internal.Deny{Privileges: newInternalPrivileges(ace.Deny)}
+		}
+		if ace.Protected {
+			iace.Protected = &struct{}{}
+		}
+		if ace.Inherited != "" {
+			u, err := url.Parse(ace.Inherited)
+			if err != nil {
+				return nil, err
+			}
+			iace.Inherited = &internal.Inherited{Href: internal.Href(*u)}
+		}
+	}
+	return &iacl, nil
+}
+
+func newInternalACLRestrictions(r *ACLRestrictions) *internal.ACLRestrictions {
+	var ir internal.ACLRestrictions
+	if r.GrantOnly {
+		ir.GrantOnly = &struct{}{}
+	}
+	if r.NoInvert {
+		ir.NoInvert = &struct{}{}
+	}
+	if r.DenyBeforeGrant {
+		ir.DenyBeforeGrant = &struct{}{}
+	}
+	return &ir
+}
+
+func decodePrivileges(l []internal.Privilege) []Privilege {
+	privs := make([]Privilege, 0, len(l))
+	for _, p := range l {
+		if name, ok := p.Name(); ok {
+			privs = append(privs, Privilege(name))
+		}
+	}
+	return privs
+}
+
+func decodeACEPrincipal(ip *internal.ACEPrincipal) (*ACEPrincipal, error) {
+	var p ACEPrincipal
+	switch {
+	case ip.Href != nil:
+		p.Href = ip.Href.String()
+	case ip.All != nil:
+		p.All = true
+	case ip.Authenticated != nil:
+		p.Authenticated = true
+	case ip.Unauthenticated != nil:
+		p.Unauthenticated = true
+	case ip.Property != nil:
+		for _, raw := range ip.Property.Raw {
+			if name, ok := raw.XMLName(); ok {
+				p.Property = name
+				break
+			}
+		}
+	case ip.Self != nil:
+		p.Self = true
+	default:
+		return nil, fmt.Errorf("webdav: empty ACE principal")
+	}
+	return &p, nil
+}
+
+func decodeACL(iacl *internal.ACL) (*ACL, error) {
+	acl := ACL{ACEs: make([]ACE, len(iacl.ACEs))}
+	for i, iace := range iacl.ACEs {
+		ace := &acl.ACEs[i]
+
+		var (
+			principal *ACEPrincipal
+			err       error
+		)
+		switch {
+		case iace.Principal != nil:
+			principal, err = decodeACEPrincipal(iace.Principal)
+		case iace.Invert != nil:
+			ace.Invert = true
+			principal, err = decodeACEPrincipal(&iace.Invert.Principal)
+		default:
+			err = fmt.Errorf("webdav: ACE missing principal")
+		}
+		if err != nil {
+			return nil, err
+		}
+		ace.Principal = *principal
+
+		if iace.Grant != nil {
+			ace.Grant = decodePrivileges(iace.Grant.Privileges)
+		}
+		if iace.Deny != nil {
+			ace.Deny = decodePrivileges(iace.Deny.Privileges)
+		}
+		ace.Protected = iace.Protected != nil
+		if iace.Inherited != nil {
+			ace.Inherited = iace.Inherited.Href.String()
+		}
+	}
+	return &acl, nil
+}
@@ -0,0 +1,173 @@
+package webdav
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+var testACL = &ACL{
+	ACEs: []ACE{
+		{
+			Principal: ACEPrincipal{Href: "/principals/alice/"},
+			Grant:     []Privilege{PrivilegeRead, PrivilegeWrite},
+		},
+		{
+			Principal: ACEPrincipal{Property: xml.Name{"DAV:", "owner"}},
+			Grant:     []Privilege{PrivilegeAll},
+		},
+		{
+			Principal: ACEPrincipal{Self: true},
+			Invert:    true,
+			Deny:      []Privilege{PrivilegeWriteACL},
+		},
+		{
+			Principal: ACEPrincipal{Authenticated: true},
+			Grant:     []Privilege{Privilege{"urn:example", "custom"}},
+		},
+		{
+			Principal: ACEPrincipal{Unauthenticated: true},
+			Deny:      []Privilege{PrivilegeAll},
+			Protected: true,
+		},
+		{
+			Principal: ACEPrincipal{All: true},
+			Grant:     []Privilege{PrivilegeReadCurrentUserPrivilegeSet},
+			Inherited: "/",
+		},
+	},
+}
+
+func TestACL_roundTrip(t *testing.T) {
+	iacl, err := newInternalACL(testACL)
+	if err != nil {
+		t.Fatalf("newInternalACL() = %v", err)
+	}
+	b, err := xml.Marshal(iacl)
+	if err != nil {
+		t.Fatalf("xml.Marshal() = %v", err)
+	}
+
+	var got internal.ACL
+	if err := xml.Unmarshal(b, &got); err != nil {
+		t.Fatalf("xml.Unmarshal() = %v", err)
+	}
+	acl, err := decodeACL(&got)
+	if err != nil {
+		t.Fatalf("decodeACL() = %v", err)
+	}
+	if !reflect.DeepEqual(acl.ACEs, testACL.ACEs) {
+		t.Errorf("decodeACL() = %+v, want %+v", acl.ACEs, testACL.ACEs)
+	}
+}
+
+func TestACL_emptyPrincipal(t *testing.T) {
+	if _, err := newInternalACL(&ACL{ACEs: []ACE{{Grant: []Privilege{PrivilegeRead}}}}); err == nil {
+		t.Errorf("newInternalACL() succeeded with an empty principal")
+	}
+	if _, err := decodeACL(&internal.ACL{ACEs: []internal.ACE{{}}}); err == nil {
+		t.Errorf("decodeACL() succeeded with an empty principal")
+	}
+}
+
+const testACLRequest = `<?xml version="1.0" encoding="utf-8" ?>
+<D:acl xmlns:D="DAV:">
+	<D:ace>
+		<D:principal><D:href>/principals/alice/</D:href></D:principal>
+		<D:grant><D:privilege><D:read/></D:privilege><D:privilege><D:write/></D:privilege></D:grant>
+	</D:ace>
+	<D:ace>
+		<D:invert><D:principal><D:self/></D:principal></D:invert>
+		<D:deny><D:privilege><D:write-acl/></D:privilege></D:deny>
+	</D:ace>
+</D:acl>`
+
+func TestHandler_acl(t *testing.T) {
+	fs := &aclFileSystem{FileSystem: newTestTree(t)}
+	h := &Handler{FileSystem: fs}
+
+	w := serveTestRequest(h, "ACL", "/a/1.txt", testACLRequest, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ACL status = %v, want %v", w.Code, http.StatusOK)
+	}
+	want := []ACE{
+		{Principal: ACEPrincipal{Href: "/principals/alice/"}, Grant: []Privilege{PrivilegeRead, PrivilegeWrite}},
+		{Principal: ACEPrincipal{Self: true}, Invert: true, Deny: []Privilege{PrivilegeWriteACL}},
+	}
+	if acl := fs.acls["/a/1.txt"]; acl == nil || !reflect.DeepEqual(acl.ACEs, want) {
+		t.Errorf("SetACL() called with %+v, want %+v", acl, want)
+	}
+
+	fs.acls["/a/1.txt"].Restrictions = ACLRestrictions{GrantOnly: true, NoInvert: true}
+	w = serveTestRequest(h, "PROPFIND", "/a/1.txt", `<D:propfind xmlns:D="DAV:"><D:prop><D:acl/><D:acl-restrictions/></D:prop></D:propfind>`, map[string]string{"Depth": "0"})
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("PROPFIND status = %v, want %v", w.Code, http.StatusMultiStatus)
+	}
+	var ms internal.MultiStatus
+	if err := xml.NewDecoder(w.Body).Decode(&ms); err != nil {
+		t.Fatalf("failed to decode multistatus: %v", err)
+	}
+	var iacl internal.ACL
+	if err := ms.Responses[0].DecodeProp(&iacl); err != nil {
+		t.Fatalf("DecodeProp(ACL) = %v", err)
+	}
+	if acl, err := decodeACL(&iacl); err != nil {
+		t.Fatalf("decodeACL() = %v", err)
+	} else if !reflect.DeepEqual(acl.ACEs, want) {
+		t.Errorf("DAV:acl = %+v, want %+v", acl.ACEs, want)
+	}
+	var restrictions internal.ACLRestrictions
+	if err := ms.Responses[0].DecodeProp(&restrictions); err != nil {
+		t.Fatalf("DecodeProp(ACLRestrictions) = %v", err)
+	} else if restrictions.GrantOnly == nil || restrictions.NoInvert == nil || restrictions.DenyBeforeGrant != nil {
+		t.Errorf("DAV:acl-restrictions = %+v, want grant-only and no-invert", restrictions)
+	}
+
+	w = serveTestRequest(h, http.MethodOptions, "/a/1.txt", "", nil)
+	if dav := w.Header().Get("DAV"); !strings.Contains(dav, "access-control") {
+		t.Errorf("DAV header = %q, want access-control", dav)
+	}
+}
+
+func TestHandler_aclErrors(t *testing.T) {
+	fs := &aclFileSystem{FileSystem: newTestTree(t)}
+
+	for _, tc := range []struct {
+		name string
+		h    *Handler
+		body string
+		want int
+	}{
+		{"unsupported", &Handler{FileSystem: fs.FileSystem}, testACLRequest, http.StatusMethodNotAllowed},
+		{"protected", &Handler{FileSystem: fs}, `<D:acl xmlns:D="DAV:"><D:ace><D:principal><D:all/></D:principal><D:grant><D:privilege><D:read/></D:privilege></D:grant><D:protected/></D:ace></D:acl>`, http.StatusForbidden},
+		{"inherited", &Handler{FileSystem: fs}, `<D:acl xmlns:D="DAV:"><D:ace><D:principal><D:all/></D:principal><D:grant><D:privilege><D:read/></D:privilege></D:grant><D:inherited><D:href>/</D:href></D:inherited></D:ace></D:acl>`, http.StatusForbidden},
+		{"missing principal", &Handler{FileSystem: fs}, `<D:acl xmlns:D="DAV:"><D:ace><D:grant><D:privilege><D:read/></D:privilege></D:grant></D:ace></D:acl>`, http.StatusBadRequest},
+		{"locked", &Handler{FileSystem: fs, LockBackend: lockedBackend(t, fs, "/a/1.txt")}, testACLRequest, http.StatusLocked},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serveTestRequest(tc.h, "ACL", "/a/1.txt", tc.body, nil)
+			if w.Code != tc.want {
+				t.Errorf("ACL status = %v, want %v", w.Code, tc.want)
+			}
+		})
+	}
+	if len(fs.acls) != 0 {
+		t.Errorf("SetACL() called for rejected requests: %v", fs.acls)
+	}
+}
+
+// lockedBackend returns a lock backend holding an exclusive lock on name.
+func lockedBackend(t *testing.T, fs FileSystem, name string) LockBackend {
+	lb := &MemLockBackend{}
+	h := &Handler{FileSystem: fs, LockBackend: lb}
+	w := serveTestRequest(h, "LOCK", name, fmt.Sprintf(lockInfoRequest, "exclusive"), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("LOCK status = %v, want %v", w.Code, http.StatusOK)
+	}
+	return lb
+}
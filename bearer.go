@@ -0,0 +1,97 @@
+package webdav
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// bearerRetryKey is the context key used to mark requests which have already
+// been retried after a 401 Unauthorized response.
+type bearerRetryKey struct{}
+
+type bearerAuthHTTPClient struct {
+	c     HTTPClient
+	token func(ctx context.Context) (string, error)
+	// retry is set if requests rejected with 401 Unauthorized should be
+	// retried, i.e. if the token may have changed
+	retry bool
+}
+
+func (c *bearerAuthHTTPClient) do(req *http.Request) (*http.Response, error) {
+	token, err := c.token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return c.c.Do(req)
+}
+
+func (c *bearerAuthHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.do(req.Clone(req.Context()))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !c.retry {
+		return resp, err
+	}
+
+	// Only retry once, and only if the request body can be re-created
+	ctx := req.Context()
+	if ctx.Value(bearerRetryKey{}) != nil {
+		return resp, nil
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	retryReq := req.Clone(context.WithValue(ctx, bearerRetryKey{}, true))
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retryReq.Body = body
+	}
+	return c.do(retryReq)
+}
+
+// HTTPClientWithBearerAuth returns an HTTP client that adds bearer token
+// authentication (RFC 6750) to all outgoing requests. If c is nil,
+// http.DefaultClient is used.
+func HTTPClientWithBearerAuth(c HTTPClient, token string) HTTPClient {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return &bearerAuthHTTPClient{c: c, token: func(ctx context.Context) (string, error) {
+		return token, nil
+	}}
+}
+
+// HTTPClientWithBearerAuthFunc is like HTTPClientWithBearerAuth, but calls
+// token before each request to obtain the token to use. This allows
+// short-lived tokens to be refreshed lazily.
+//
+// If the server replies with 401 Unauthorized, token is called once more and
+// the request is retried, unless its body cannot be re-created (see
+// http.Request.GetBody).
+func HTTPClientWithBearerAuthFunc(c HTTPClient, token func(ctx context.Context) (string, error)) HTTPClient {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return &bearerAuthHTTPClient{c: c, token: token, retry: true}
+}
+
+// BearerAuth returns an http.RoundTripper adding bearer token authentication
+// to requests sent with http.DefaultTransport. See HTTPClientWithBearerAuth.
+func BearerAuth(token string) http.RoundTripper {
+	return BearerAuthTransport(nil, token)
+}
+
+// BearerAuthTransport is like BearerAuth, but sends requests with rt. If rt
+// is nil, http.DefaultTransport is used.
+func BearerAuthTransport(rt http.RoundTripper, token string) http.RoundTripper {
+	c := HTTPClientWithBearerAuth(newRoundTripperHTTPClient(rt), token)
+	return httpClientRoundTripper{c}
+}
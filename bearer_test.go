@@ -0,0 +1,127 @@
+package webdav
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// bearerTestServer accepts requests authenticated with the current token.
+type bearerTestServer struct {
+	token  string
+	tokens []string
+}
+
+func (s *bearerTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	s.tokens = append(s.tokens, strings.TrimPrefix(auth, "Bearer "))
+	if auth != "Bearer "+s.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestHTTPClientWithBearerAuth(t *testing.T) {
+	s := &bearerTestServer{token: "secret"}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c := &http.Client{Transport: BearerAuthTransport(ts.Client().Transport, "secret")}
+	resp, err := c.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("Get() = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %v, want %v", resp.StatusCode, http.StatusNoContent)
+	}
+
+	// A static token can't change: the request isn't retried
+	s.token = "expired"
+	s.tokens = nil
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+	resp, err = HTTPClientWithBearerAuth(ts.Client(), "secret").Do(req)
+	if err != nil {
+		t.Fatalf("Do() = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %v, want %v", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if len(s.tokens) != 1 {
+		t.Errorf("server received %v requests, want 1", len(s.tokens))
+	}
+}
+
+func TestHTTPClientWithBearerAuthFunc(t *testing.T) {
+	s := &bearerTestServer{token: "token1"}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	n := 0
+	c := HTTPClientWithBearerAuthFunc(ts.Client(), func(ctx context.Context) (string, error) {
+		n++
+		return fmt.Sprintf("token%v", n), nil
+	})
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodPut, ts.URL, strings.NewReader("hello"))
+		return req
+	}
+
+	resp, err := c.Do(newRequest())
+	if err != nil {
+		t.Fatalf("Do() = %v", err)
+	}
+	resp.Body.Close()
+
+	// The token has expired on the server: it's refreshed and the request
+	// is retried once
+	s.token = "token3"
+	resp, err = c.Do(newRequest())
+	if err != nil {
+		t.Fatalf("Do() = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %v, want %v", resp.StatusCode, http.StatusNoContent)
+	}
+
+	s.token = "revoked"
+	resp, err = c.Do(newRequest())
+	if err != nil {
+		t.Fatalf("Do() = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %v, want %v", resp.StatusCode, http.StatusUnauthorized)
+	}
+
+	// Requests whose body can't be re-created aren't retried
+	req := newRequest()
+	req.GetBody = nil
+	resp, err = c.Do(req)
+	if err != nil {
+		t.Fatalf("Do() = %v", err)
+	}
+	resp.Body.Close()
+
+	want := "token1,token2,token3,token4,token5,token6"
+	if got := strings.Join(s.tokens, ","); got != want {
+		t.Errorf("tokens = %v, want %v", got, want)
+	}
+}
+
+func TestHTTPClientWithBearerAuthFunc_error(t *testing.T) {
+	errToken := fmt.Errorf("token unavailable")
+	c := HTTPClientWithBearerAuthFunc(nil, func(ctx context.Context) (string, error) {
+		return "", errToken
+	})
+	req, _ := http.NewRequest(http.MethodGet, "http://example.invalid", nil)
+	if _, err := c.Do(req); err != errToken {
+		t.Errorf("Do() = %v, want %v", err, errToken)
+	}
+}
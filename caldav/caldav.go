@@ -4,7 +4,11 @@
 package caldav
 
 import (
+	"bytes"
 	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/emersion/go-ical"
@@ -69,6 +73,19 @@ type Calendar struct {
 	Description           string
 	MaxResourceSize       int64
 	SupportedComponentSet []string
+	// Timezone is the VTIMEZONE component used to interpret floating times
+	// in the calendar, or nil if unset.
+	Timezone *ical.Component
+	// Color is the color used by clients to display the calendar, or nil if
+	// unset. It is stored in the Apple calendar-color property.
+	Color color.Color
+	// Order is the position of the calendar when clients sort calendars, or
+	// zero if unset. It is stored in the Apple calendar-order property.
+	Order int
+	// Privileges contains the privileges granted to the current user on the
+	// calendar, or nil if the server doesn't report them. It's only populated
+	// by Client.
+	Privileges []webdav.Privilege
 }
 
 type CalendarCompRequest struct {
@@ -79,6 +96,31 @@ type CalendarCompRequest struct {
 
 	AllComps bool
 	Comps    []CalendarCompRequest
+
+	// Expand requests recurring components to be expanded into their
+	// individual instances. It's only used for the top-level request.
+	Expand *CalendarExpandRequest
+	// LimitRecurrenceSet restricts the overridden instances of recurring
+	// components returned to the ones overlapping a time range, without
+	// expanding the recurrence rules. It's only used for the top-level
+	// request.
+	LimitRecurrenceSet *CalendarLimitRecurrenceSet
+}
+
+// CalendarExpandRequest requests recurring components to be expanded into
+// their individual instances within a time range, as defined in RFC 4791
+// section 9.6.5. Each instance is returned as a separate component with a
+// RECURRENCE-ID property and without recurrence rules.
+type CalendarExpandRequest struct {
+	Start, End time.Time
+}
+
+// CalendarLimitRecurrenceSet limits the overridden instances of recurring
+// components to the ones overlapping a time range, as defined in RFC 4791
+// section 9.6.6. Master components and their recurrence rules are left
+// untouched.
+type CalendarLimitRecurrenceSet struct {
+	Start, End time.Time
 }
 
 type CompFilter struct {
@@ -111,6 +153,33 @@ type TextMatch struct {
 type CalendarQuery struct {
 	CompRequest CalendarCompRequest
 	CompFilter  CompFilter
+	// Timezone is a VTIMEZONE component used to resolve floating date and
+	// date-time values when matching time ranges, as defined in RFC 4791
+	// section 9.8. If nil, the server uses the calendar's time zone. Filter
+	// only supports time zones whose TZID is a known IANA time zone.
+	Timezone *ical.Component
+}
+
+// TaskFilter holds filters for Client.QueryTasks. Zero values match all tasks.
+type TaskFilter struct {
+	// Status matches the STATUS property of tasks, e.g. "NEEDS-ACTION" or
+	// "COMPLETED".
+	Status string
+	// DueStart and DueEnd match tasks whose DUE property is within the range.
+	DueStart, DueEnd time.Time
+	// CompletedStart and CompletedEnd match tasks whose COMPLETED property
+	// is within the range.
+	CompletedStart, CompletedEnd time.Time
+}
+
+// MultiGetError is returned by Client.MultiGetCalendar when some of the
+// requested calendar objects couldn't be fetched.
+type MultiGetError struct {
+	Errors []webdav.ResourceError
+}
+
+func (err *MultiGetError) Error() string {
+	return fmt.Sprintf("caldav: failed to fetch %v calendar objects (first error: %v)", len(err.Errors), &err.Errors[0])
 }
 
 type CalendarMultiGet struct {
@@ -118,6 +187,52 @@ type CalendarMultiGet struct {
 	CompRequest CalendarCompRequest
 }
 
+// SyncQuery is the query struct represents a sync-collection request
+type SyncQuery struct {
+	CompRequest CalendarCompRequest
+	SyncToken   string
+	Limit       int // <= 0 means unlimited
+}
+
+// SyncResponse contains the returned sync-token for next time
+type SyncResponse struct {
+	SyncToken string
+	Updated   []CalendarObject
+	Deleted   []string
+}
+
+// SchedulingResponse is the response to an iTIP message sent to a scheduling
+// Outbox, as defined in RFC 6638 section 5.
+type SchedulingResponse struct {
+	Recipients []RecipientStatus
+}
+
+// RecipientStatus is the delivery status of an iTIP message for a single
+// recipient.
+type RecipientStatus struct {
+	// Recipient is the calendar user address, e.g. "mailto:alice@example.org".
+	Recipient string
+	// RequestStatus is an iTIP REQUEST-STATUS value, e.g. "2.0;Success".
+	RequestStatus string
+	// Data is the calendar data returned for the recipient, e.g. for VFREEBUSY
+	// requests.
+	Data        *ical.Calendar
+	Description string
+}
+
+// Attachment is a managed attachment of a calendar object, as defined in
+// RFC 8607.
+type Attachment struct {
+	// ManagedID is the MANAGED-ID parameter of the ATTACH property, which
+	// identifies the attachment in update and remove requests.
+	ManagedID string
+	// URL is the location of the attachment data.
+	URL         string
+	ContentType string
+	Filename    string
+	Size        int64
+}
+
 type CalendarObject struct {
 	Path          string
 	ModTime       time.Time
@@ -125,3 +240,59 @@ type CalendarObject struct {
 	ETag          string
 	Data          *ical.Calendar
 }
+
+// encodeCalendarTimezone wraps a VTIMEZONE component into an iCalendar object,
+// as required by the CALDAV:calendar-timezone property.
+func encodeCalendarTimezone(tz *ical.Component) (*calendarTimezone, error) {
+	if tz.Name != ical.CompTimezone {
+		return nil, fmt.Errorf("caldav: expected VTIMEZONE component, got %v", tz.Name)
+	}
+
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, "-//emersion//go-webdav//EN")
+	cal.Children = []*ical.Component{tz}
+
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(cal); err != nil {
+		return nil, err
+	}
+	return &calendarTimezone{Data: buf.Bytes()}, nil
+}
+
+// decodeCalendarTimezone extracts the VTIMEZONE component of a
+// CALDAV:calendar-timezone property.
+func decodeCalendarTimezone(prop *calendarTimezone) (*ical.Component, error) {
+	cal, err := ical.NewDecoder(bytes.NewReader(prop.Data)).Decode()
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range cal.Children {
+		if child.Name == ical.CompTimezone {
+			return child, nil
+		}
+	}
+	return nil, fmt.Errorf("caldav: calendar-timezone is missing a VTIMEZONE component")
+}
+
+// formatCalendarColor formats a color as "#RRGGBBAA".
+func formatCalendarColor(c color.Color) string {
+	nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02X%02X%02X%02X", nrgba.R, nrgba.G, nrgba.B, nrgba.A)
+}
+
+// parseCalendarColor parses a color formatted as "#RRGGBB" or "#RRGGBBAA".
+func parseCalendarColor(s string) (color.Color, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(hex) == 6 {
+		hex += "FF"
+	}
+	if len(hex) != 8 {
+		return nil, fmt.Errorf("caldav: malformed calendar color %q", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("caldav: malformed calendar color %q", s)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
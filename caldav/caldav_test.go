@@ -0,0 +1,33 @@
+package caldav
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseCalendarColor(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		want color.Color
+	}{
+		{"#FF8000", color.NRGBA{R: 0xFF, G: 0x80, B: 0x00, A: 0xFF}},
+		{"#ff800080", color.NRGBA{R: 0xFF, G: 0x80, B: 0x00, A: 0x80}},
+		{" #0000FFFF\n", color.NRGBA{R: 0x00, G: 0x00, B: 0xFF, A: 0xFF}},
+	} {
+		got, err := parseCalendarColor(tc.s)
+		if err != nil {
+			t.Errorf("parseCalendarColor(%q) = %v", tc.s, err)
+		} else if got != tc.want {
+			t.Errorf("parseCalendarColor(%q) = %v, want %v", tc.s, got, tc.want)
+		}
+		if s := formatCalendarColor(got); len(s) != len("#RRGGBBAA") {
+			t.Errorf("formatCalendarColor(%v) = %q", got, s)
+		}
+	}
+
+	for _, s := range []string{"", "#FFF", "#GGGGGG", "#FF00FF0"} {
+		if _, err := parseCalendarColor(s); err == nil {
+			t.Errorf("parseCalendarColor(%q) = nil, want an error", s)
+		}
+	}
+}
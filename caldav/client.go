@@ -3,7 +3,11 @@ package caldav
 import (
 	"bytes"
 	"context"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"image/color"
+	"io"
 	"mime"
 	"net/http"
 	"net/url"
@@ -16,10 +20,23 @@ import (
 	"github.com/emersion/go-webdav/internal"
 )
 
-// DiscoverContextURL performs a DNS-based CardDAV service discovery as
-// described in RFC 6352 section 11. It returns the URL to the CardDAV server.
+// DiscoverContextURL performs a DNS-based CalDAV service discovery as
+// described in RFC 6764 section 3. It returns the URL to the CalDAV server.
 func DiscoverContextURL(ctx context.Context, domain string) (string, error) {
-	return internal.DiscoverContextURL(ctx, "caldavs", domain)
+	return internal.DiscoverContextURL(ctx, "caldav", domain)
+}
+
+// Discover locates the CalDAV service root of a domain, as described in RFC
+// 6764. See webdav.Discover. If c is nil, http.DefaultClient is used.
+func Discover(ctx context.Context, c webdav.HTTPClient, domain string) (string, error) {
+	res, err := webdav.Discover(ctx, c, domain)
+	if err != nil {
+		return "", err
+	}
+	if res.CalDAV == nil {
+		return "", fmt.Errorf("caldav: no CalDAV service found for %q", domain)
+	}
+	return res.CalDAV.String(), nil
 }
 
 // Client provides access to a remote CardDAV server.
@@ -53,7 +70,7 @@ func (c *Client) FindCalendarHomeSet(ctx context.Context, principal string) (str
 		return "", err
 	}
 
-	return prop.Href.Path, nil
+	return c.ic.ResolveRelativeHref(principal, &prop.Href), nil
 }
 
 func (c *Client) FindCalendars(ctx context.Context, calendarHomeSet string) ([]Calendar, error) {
@@ -63,6 +80,10 @@ func (c *Client) FindCalendars(ctx context.Context, calendarHomeSet string) ([]C
 		calendarDescriptionName,
 		maxResourceSizeName,
 		supportedCalendarComponentSetName,
+		calendarTimezoneName,
+		calendarColorName,
+		calendarOrderName,
+		internal.CurrentUserPrivilegeSetName,
 	)
 	ms, err := c.ic.PropFind(ctx, calendarHomeSet, internal.DepthOne, propfind)
 	if err != nil {
@@ -112,18 +133,186 @@ func (c *Client) FindCalendars(ctx context.Context, calendarHomeSet string) ([]C
 			compNames = append(compNames, comp.Name)
 		}
 
+		var tz calendarTimezone
+		if err := resp.DecodeProp(&tz); err != nil && !internal.IsNotFound(err) {
+			return nil, err
+		}
+		// A malformed time zone shouldn't prevent listing the calendar
+		var tzComp *ical.Component
+		if len(tz.Data) > 0 {
+			tzComp, _ = decodeCalendarTimezone(&tz)
+		}
+
+		var calColor calendarColor
+		if err := resp.DecodeProp(&calColor); err != nil && !internal.IsNotFound(err) {
+			return nil, err
+		}
+		// Clients and servers use various color formats: ignore the ones we
+		// don't understand
+		var col color.Color
+		if calColor.Color != "" {
+			col, _ = parseCalendarColor(calColor.Color)
+		}
+
+		var calOrder calendarOrder
+		if err := resp.DecodeProp(&calOrder); err != nil && !internal.IsNotFound(err) {
+			return nil, err
+		}
+
+		privs, err := resp.DecodeCurrentUserPrivileges()
+		if err != nil {
+			return nil, err
+		}
+
 		l = append(l, Calendar{
 			Path:                  path,
 			Name:                  dispName.Name,
 			Description:           desc.Description,
 			MaxResourceSize:       maxResSize.Size,
 			SupportedComponentSet: compNames,
+			Timezone:              tzComp,
+			Color:                 col,
+			Order:                 calOrder.Order,
+			Privileges:            decodePrivileges(privs),
 		})
 	}
 
 	return l, nil
 }
 
+// ErrCalendarExists is returned by Client.MakeCalendar when a resource
+// already exists at the requested path.
+var ErrCalendarExists = errors.New("caldav: calendar already exists")
+
+// MakeCalendar creates a new calendar collection with a MKCALENDAR request.
+// The calendar's path is ignored, path is used instead.
+//
+// If a resource already exists at path, ErrCalendarExists is returned.
+func (c *Client) MakeCalendar(ctx context.Context, path string, cal *Calendar) error {
+	props, err := encodeCalendarProps(cal)
+	if err != nil {
+		return err
+	}
+
+	var mkcal mkcalendarReq
+	if len(props) > 0 {
+		prop, err := internal.EncodeProp(props...)
+		if err != nil {
+			return err
+		}
+		mkcal.Set = &internal.Set{Prop: *prop}
+	}
+
+	req, err := c.ic.NewXMLRequest("MKCALENDAR", path, &mkcal)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.ic.Do(req.WithContext(ctx))
+	var httpErr *internal.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Code == http.StatusMethodNotAllowed {
+		return ErrCalendarExists
+	} else if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("caldav: unexpected MKCALENDAR response status: %v", resp.Status)
+	}
+	return nil
+}
+
+// UpdateCalendar sets the properties of an existing calendar with a PROPPATCH
+// request. Only the non-zero fields of cal are updated; cal.Path is ignored,
+// path is used instead.
+//
+// MaxResourceSize and SupportedComponentSet are protected properties which
+// can only be set by MakeCalendar, they are ignored.
+func (c *Client) UpdateCalendar(ctx context.Context, path string, cal *Calendar) error {
+	update := *cal
+	update.MaxResourceSize = 0
+	update.SupportedComponentSet = nil
+
+	props, err := encodeCalendarProps(&update)
+	if err != nil {
+		return err
+	}
+	if len(props) == 0 {
+		return nil
+	}
+	return c.Patch(ctx, path, props, nil)
+}
+
+// encodeCalendarProps returns the properties describing the non-zero fields
+// of cal.
+func encodeCalendarProps(cal *Calendar) ([]interface{}, error) {
+	var props []interface{}
+	if cal.Name != "" {
+		props = append(props, &internal.DisplayName{Name: cal.Name})
+	}
+	if cal.Description != "" {
+		props = append(props, &calendarDescription{Description: cal.Description})
+	}
+	if len(cal.SupportedComponentSet) > 0 {
+		compSet := &supportedCalendarComponentSet{}
+		for _, name := range cal.SupportedComponentSet {
+			compSet.Comp = append(compSet.Comp, comp{Name: name})
+		}
+		props = append(props, compSet)
+	}
+	if cal.MaxResourceSize > 0 {
+		props = append(props, &maxResourceSize{Size: cal.MaxResourceSize})
+	}
+	if cal.Timezone != nil {
+		tz, err := encodeCalendarTimezone(cal.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, tz)
+	}
+	if cal.Color != nil {
+		props = append(props, &calendarColor{Color: formatCalendarColor(cal.Color)})
+	}
+	if cal.Order != 0 {
+		props = append(props, &calendarOrder{Order: cal.Order})
+	}
+	return props, nil
+}
+
+// GetCalendarTimezone returns the VTIMEZONE component of a calendar, as
+// defined by the CALDAV:calendar-timezone property. It returns nil if the
+// calendar has no time zone.
+func (c *Client) GetCalendarTimezone(ctx context.Context, path string) (*ical.Component, error) {
+	propfind := internal.NewPropNamePropFind(calendarTimezoneName)
+	resp, err := c.ic.PropFindFlat(ctx, path, propfind)
+	if err != nil {
+		return nil, err
+	}
+
+	var tz calendarTimezone
+	if err := resp.DecodeProp(&tz); internal.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return decodeCalendarTimezone(&tz)
+}
+
+// SetCalendarTimezone sets the VTIMEZONE component of a calendar. If tz is
+// nil, the time zone is removed.
+func (c *Client) SetCalendarTimezone(ctx context.Context, path string, tz *ical.Component) error {
+	if tz == nil {
+		return c.Patch(ctx, path, nil, []xml.Name{calendarTimezoneName})
+	}
+
+	prop, err := encodeCalendarTimezone(tz)
+	if err != nil {
+		return err
+	}
+	return c.Patch(ctx, path, []interface{}{prop}, nil)
+}
+
 func encodeCalendarCompReq(c *CalendarCompRequest) (*comp, error) {
 	encoded := comp{Name: c.Name}
 
@@ -155,6 +344,18 @@ func encodeCalendarReq(c *CalendarCompRequest) (*internal.Prop, error) {
 	}
 
 	calDataReq := calendarDataReq{Comp: compReq}
+	if c.Expand != nil {
+		calDataReq.Expand = &expand{
+			Start: dateWithUTCTime(c.Expand.Start),
+			End:   dateWithUTCTime(c.Expand.End),
+		}
+	}
+	if c.LimitRecurrenceSet != nil {
+		calDataReq.LimitRecurrenceSet = &limitRecurrenceSet{
+			Start: dateWithUTCTime(c.LimitRecurrenceSet.Start),
+			End:   dateWithUTCTime(c.LimitRecurrenceSet.End),
+		}
+	}
 
 	getLastModReq := internal.NewRawXMLElement(internal.GetLastModifiedName, nil, nil)
 	getETagReq := internal.NewRawXMLElement(internal.GetETagName, nil, nil)
@@ -163,62 +364,114 @@ func encodeCalendarReq(c *CalendarCompRequest) (*internal.Prop, error) {
 
 func encodeCompFilter(filter *CompFilter) *compFilter {
 	encoded := compFilter{Name: filter.Name}
+	if filter.IsNotDefined {
+		encoded.IsNotDefined = &struct{}{}
+	}
 	if !filter.Start.IsZero() || !filter.End.IsZero() {
 		encoded.TimeRange = &timeRange{
 			Start: dateWithUTCTime(filter.Start),
 			End:   dateWithUTCTime(filter.End),
 		}
 	}
+	for _, pf := range filter.Props {
+		encoded.PropFilters = append(encoded.PropFilters, *encodePropFilter(&pf))
+	}
 	for _, child := range filter.Comps {
 		encoded.CompFilters = append(encoded.CompFilters, *encodeCompFilter(&child))
 	}
 	return &encoded
 }
 
+func encodePropFilter(filter *PropFilter) *propFilter {
+	encoded := propFilter{Name: filter.Name}
+	if filter.IsNotDefined {
+		encoded.IsNotDefined = &struct{}{}
+	}
+	if !filter.Start.IsZero() || !filter.End.IsZero() {
+		encoded.TimeRange = &timeRange{
+			Start: dateWithUTCTime(filter.Start),
+			End:   dateWithUTCTime(filter.End),
+		}
+	}
+	if filter.TextMatch != nil {
+		encoded.TextMatch = encodeTextMatch(filter.TextMatch)
+	}
+	for _, pf := range filter.ParamFilter {
+		encoded.ParamFilter = append(encoded.ParamFilter, *encodeParamFilter(&pf))
+	}
+	return &encoded
+}
+
+func encodeParamFilter(filter *ParamFilter) *paramFilter {
+	encoded := paramFilter{Name: filter.Name}
+	if filter.IsNotDefined {
+		encoded.IsNotDefined = &struct{}{}
+	}
+	if filter.TextMatch != nil {
+		encoded.TextMatch = encodeTextMatch(filter.TextMatch)
+	}
+	return &encoded
+}
+
+func encodeTextMatch(tm *TextMatch) *textMatch {
+	return &textMatch{
+		Text:            tm.Text,
+		NegateCondition: negateCondition(tm.NegateCondition),
+	}
+}
+
 func decodeCalendarObjectList(ms *internal.MultiStatus) ([]CalendarObject, error) {
 	addrs := make([]CalendarObject, 0, len(ms.Responses))
 	for _, resp := range ms.Responses {
-		path, err := resp.Path()
+		co, err := decodeCalendarObject(&resp)
 		if err != nil {
 			return nil, err
 		}
+		addrs = append(addrs, *co)
+	}
 
-		var calData calendarDataResp
-		if err := resp.DecodeProp(&calData); err != nil {
-			return nil, err
-		}
+	return addrs, nil
+}
 
-		var getLastMod internal.GetLastModified
-		if err := resp.DecodeProp(&getLastMod); err != nil && !internal.IsNotFound(err) {
-			return nil, err
-		}
+func decodeCalendarObject(resp *internal.Response) (*CalendarObject, error) {
+	path, err := resp.Path()
+	if err != nil {
+		return nil, err
+	}
 
-		var getETag internal.GetETag
-		if err := resp.DecodeProp(&getETag); err != nil && !internal.IsNotFound(err) {
-			return nil, err
-		}
+	var calData calendarDataResp
+	if err := resp.DecodeProp(&calData); err != nil {
+		return nil, err
+	}
 
-		var getContentLength internal.GetContentLength
-		if err := resp.DecodeProp(&getContentLength); err != nil && !internal.IsNotFound(err) {
-			return nil, err
-		}
+	var getLastMod internal.GetLastModified
+	if err := resp.DecodeProp(&getLastMod); err != nil && !internal.IsNotFound(err) {
+		return nil, err
+	}
 
-		r := bytes.NewReader(calData.Data)
-		data, err := ical.NewDecoder(r).Decode()
-		if err != nil {
-			return nil, err
-		}
+	var getETag internal.GetETag
+	if err := resp.DecodeProp(&getETag); err != nil && !internal.IsNotFound(err) {
+		return nil, err
+	}
 
-		addrs = append(addrs, CalendarObject{
-			Path:          path,
-			ModTime:       time.Time(getLastMod.LastModified),
-			ContentLength: getContentLength.Length,
-			ETag:          string(getETag.ETag),
-			Data:          data,
-		})
+	var getContentLength internal.GetContentLength
+	if err := resp.DecodeProp(&getContentLength); err != nil && !internal.IsNotFound(err) {
+		return nil, err
 	}
 
-	return addrs, nil
+	r := bytes.NewReader(calData.Data)
+	data, err := ical.NewDecoder(r).Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	return &CalendarObject{
+		Path:          path,
+		ModTime:       time.Time(getLastMod.LastModified),
+		ContentLength: getContentLength.Length,
+		ETag:          string(getETag.ETag),
+		Data:          data,
+	}, nil
 }
 
 func (c *Client) QueryCalendar(ctx context.Context, calendar string, query *CalendarQuery) ([]CalendarObject, error) {
@@ -229,6 +482,13 @@ func (c *Client) QueryCalendar(ctx context.Context, calendar string, query *Cale
 
 	calendarQuery := calendarQuery{Prop: propReq}
 	calendarQuery.Filter.CompFilter = *encodeCompFilter(&query.CompFilter)
+	if query.Timezone != nil {
+		tz, err := encodeCalendarTimezone(query.Timezone)
+		if err != nil {
+			return nil, err
+		}
+		calendarQuery.Timezone = &timezone{Data: tz.Data}
+	}
 	req, err := c.ic.NewXMLRequest("REPORT", calendar, &calendarQuery)
 	if err != nil {
 		return nil, err
@@ -243,6 +503,54 @@ func (c *Client) QueryCalendar(ctx context.Context, calendar string, query *Cale
 	return decodeCalendarObjectList(ms)
 }
 
+// QueryTasks returns the VTODO components of a calendar matching filter. If
+// filter is nil, all tasks are returned.
+func (c *Client) QueryTasks(ctx context.Context, calendar string, filter *TaskFilter) ([]CalendarObject, error) {
+	if filter == nil {
+		filter = new(TaskFilter)
+	}
+
+	todoFilter := CompFilter{Name: ical.CompToDo}
+	if filter.Status != "" {
+		todoFilter.Props = append(todoFilter.Props, PropFilter{
+			Name:      ical.PropStatus,
+			TextMatch: &TextMatch{Text: filter.Status},
+		})
+	}
+	if !filter.DueStart.IsZero() || !filter.DueEnd.IsZero() {
+		todoFilter.Props = append(todoFilter.Props, PropFilter{
+			Name:  ical.PropDue,
+			Start: filter.DueStart,
+			End:   filter.DueEnd,
+		})
+	}
+	if !filter.CompletedStart.IsZero() || !filter.CompletedEnd.IsZero() {
+		todoFilter.Props = append(todoFilter.Props, PropFilter{
+			Name:  ical.PropCompleted,
+			Start: filter.CompletedStart,
+			End:   filter.CompletedEnd,
+		})
+	}
+
+	query := CalendarQuery{
+		CompRequest: CalendarCompRequest{
+			Name:     ical.CompCalendar,
+			AllProps: true,
+			AllComps: true,
+		},
+		CompFilter: CompFilter{
+			Name:  ical.CompCalendar,
+			Comps: []CompFilter{todoFilter},
+		},
+	}
+	return c.QueryCalendar(ctx, calendar, &query)
+}
+
+// MultiGetCalendar fetches the calendar objects listed in multiGet with a
+// single calendar-multiget REPORT.
+//
+// If some of the objects couldn't be fetched (e.g. because they have been
+// deleted), the other objects are returned alongside a *MultiGetError.
 func (c *Client) MultiGetCalendar(ctx context.Context, path string, multiGet *CalendarMultiGet) ([]CalendarObject, error) {
 	propReq, err := encodeCalendarReq(&multiGet.CompRequest)
 	if err != nil {
@@ -272,7 +580,25 @@ func (c *Client) MultiGetCalendar(ctx context.Context, path string, multiGet *Ca
 		return nil, err
 	}
 
-	return decodeCalendarObjectList(ms)
+	cos := make([]CalendarObject, 0, len(ms.Responses))
+	var errs []webdav.ResourceError
+	for _, resp := range ms.Responses {
+		co, err := decodeCalendarObject(&resp)
+		if err != nil {
+			var httpErr *internal.HTTPError
+			if !errors.As(err, &httpErr) || len(resp.Hrefs) != 1 {
+				return nil, err
+			}
+			errs = append(errs, webdav.ResourceError{Path: resp.Hrefs[0].Path, Err: err})
+			continue
+		}
+		cos = append(cos, *co)
+	}
+
+	if len(errs) > 0 {
+		return cos, &MultiGetError{Errors: errs}
+	}
+	return cos, nil
 }
 
 func populateCalendarObject(co *CalendarObject, h http.Header) error {
@@ -308,6 +634,56 @@ func populateCalendarObject(co *CalendarObject, h http.Header) error {
 	return nil
 }
 
+// FreeBusyQuery requests the busy time of a calendar between start and end,
+// as defined in RFC 4791 section 7.10. The returned calendar contains a
+// VFREEBUSY component.
+func (c *Client) FreeBusyQuery(ctx context.Context, calendar string, start, end time.Time) (*ical.Calendar, error) {
+	query := freeBusyQuery{
+		TimeRange: timeRange{
+			Start: dateWithUTCTime(start),
+			End:   dateWithUTCTime(end),
+		},
+	}
+	req, err := c.ic.NewXMLRequest("REPORT", calendar, &query)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Depth", "1")
+
+	resp, err := c.ic.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Some servers wrap the result in a multi-status response instead of
+	// returning it directly
+	if resp.StatusCode == http.StatusMultiStatus {
+		var ms internal.MultiStatus
+		if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+			return nil, err
+		}
+		if len(ms.Responses) != 1 {
+			return nil, fmt.Errorf("caldav: free-busy-query returned %d responses", len(ms.Responses))
+		}
+		var calData calendarDataResp
+		if err := ms.Responses[0].DecodeProp(&calData); err != nil {
+			return nil, err
+		}
+		return ical.NewDecoder(bytes.NewReader(calData.Data)).Decode()
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(mediaType, ical.MIMEType) {
+		return nil, fmt.Errorf("caldav: expected Content-Type %q, got %q", ical.MIMEType, mediaType)
+	}
+
+	return ical.NewDecoder(resp.Body).Decode()
+}
+
 func (c *Client) GetCalendarObject(ctx context.Context, path string) (*CalendarObject, error) {
 	req, err := c.ic.NewRequest(http.MethodGet, path, nil)
 	if err != nil {
@@ -374,3 +750,277 @@ func (c *Client) PutCalendarObject(ctx context.Context, path string, cal *ical.C
 	}
 	return co, nil
 }
+
+// FindManagedAttachmentsServerURL returns the URL of the server storing the
+// managed attachments of a calendar home, as defined in RFC 8607 section 6.1.
+// An empty string is returned if the server doesn't support managed
+// attachments.
+func (c *Client) FindManagedAttachmentsServerURL(ctx context.Context, calendarHomeSet string) (string, error) {
+	propfind := internal.NewPropNamePropFind(managedAttachmentsServerURLName)
+	resp, err := c.ic.PropFindFlat(ctx, calendarHomeSet, propfind)
+	if err != nil {
+		return "", err
+	}
+
+	var prop managedAttachmentsServerURL
+	if err := resp.DecodeProp(&prop); internal.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	base := c.ic.ResolveHref(calendarHomeSet)
+	href := (*url.URL)(&prop.Href)
+	if href.String() == "" {
+		// Attachments are stored on the CalDAV server
+		return base.ResolveReference(&url.URL{Path: "/"}).String(), nil
+	}
+	return base.ResolveReference(href).String(), nil
+}
+
+// AddAttachment adds a managed attachment to a calendar object, as defined in
+// RFC 8607 section 3.4.1. The server stores the attachment data and adds an
+// ATTACH property referencing it to the calendar object.
+func (c *Client) AddAttachment(ctx context.Context, eventPath string, r io.Reader, contentType string) (*Attachment, error) {
+	query := url.Values{"action": {"attachment-add"}}
+	return c.postAttachment(ctx, eventPath, query, r, contentType)
+}
+
+// UpdateAttachment replaces the data of a managed attachment, as defined in
+// RFC 8607 section 3.4.2. The server assigns a new managed ID to the
+// attachment.
+func (c *Client) UpdateAttachment(ctx context.Context, eventPath, managedID string, r io.Reader, contentType string) (*Attachment, error) {
+	query := url.Values{"action": {"attachment-update"}, "managed-id": {managedID}}
+	return c.postAttachment(ctx, eventPath, query, r, contentType)
+}
+
+// RemoveAttachment removes a managed attachment from a calendar object, as
+// defined in RFC 8607 section 3.4.3.
+func (c *Client) RemoveAttachment(ctx context.Context, eventPath, managedID string) error {
+	req, err := c.ic.NewRequest(http.MethodPost, eventPath, nil)
+	if err != nil {
+		return err
+	}
+	req.URL.RawQuery = url.Values{"action": {"attachment-remove"}, "managed-id": {managedID}}.Encode()
+
+	resp, err := c.ic.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (c *Client) postAttachment(ctx context.Context, eventPath string, query url.Values, r io.Reader, contentType string) (*Attachment, error) {
+	req, err := c.ic.NewRequest(http.MethodPost, eventPath, r)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = query.Encode()
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Prefer", "return=representation")
+
+	resp, err := c.ic.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	managedID := resp.Header.Get("Cal-Managed-ID")
+	if managedID == "" {
+		return nil, fmt.Errorf("caldav: missing Cal-Managed-ID header in attachment response")
+	}
+
+	// The updated calendar object is only returned if the server honors the
+	// Prefer header
+	var cal *ical.Calendar
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode != http.StatusNoContent && strings.EqualFold(mediaType, ical.MIMEType) {
+		cal, err = ical.NewDecoder(resp.Body).Decode()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		co, err := c.GetCalendarObject(ctx, eventPath)
+		if err != nil {
+			return nil, err
+		}
+		cal = co.Data
+	}
+
+	att, err := findManagedAttachment(cal, managedID)
+	if err != nil {
+		return nil, err
+	}
+	if att == nil {
+		return nil, fmt.Errorf("caldav: attachment %q not found in calendar object", managedID)
+	}
+	return att, nil
+}
+
+// findManagedAttachment looks up the ATTACH property with the provided
+// MANAGED-ID parameter in a calendar object.
+func findManagedAttachment(cal *ical.Calendar, managedID string) (*Attachment, error) {
+	for _, comp := range cal.Children {
+		for _, prop := range comp.Props.Values(ical.PropAttach) {
+			if prop.Params.Get("MANAGED-ID") != managedID {
+				continue
+			}
+
+			att := &Attachment{
+				ManagedID:   managedID,
+				URL:         prop.Value,
+				ContentType: prop.Params.Get(ical.ParamFormatType),
+				Filename:    prop.Params.Get("FILENAME"),
+			}
+			if s := prop.Params.Get("SIZE"); s != "" {
+				size, err := strconv.ParseInt(s, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("caldav: invalid attachment SIZE: %v", err)
+				}
+				att.Size = size
+			}
+			return att, nil
+		}
+	}
+	return nil, nil
+}
+
+// SendSchedulingRequest sends an iTIP message to a scheduling Outbox, as
+// defined in RFC 6638 section 5. The recipients are the attendees (or the
+// organizer) listed in the message.
+func (c *Client) SendSchedulingRequest(ctx context.Context, outbox string, msg []byte) (*SchedulingResponse, error) {
+	req, err := c.ic.NewRequest(http.MethodPost, outbox, bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", ical.MIMEType)
+
+	return c.doSchedulingRequest(ctx, req)
+}
+
+// ScheduleSend sends an iTIP message to a scheduling Outbox, e.g. to invite
+// attendees to an event or to look up their free/busy time. The delivery
+// status of the message is returned for each recipient.
+//
+// The originator and recipients are calendar user addresses, e.g.
+// "mailto:alice@example.org". They are sent in the Originator and Recipient
+// header fields required by servers implementing drafts of RFC 6638; other
+// servers derive them from the message.
+func (c *Client) ScheduleSend(ctx context.Context, outboxPath string, msg *ical.Calendar, originator string, recipients []string) ([]RecipientStatus, error) {
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(msg); err != nil {
+		return nil, err
+	}
+
+	req, err := c.ic.NewRequest(http.MethodPost, outboxPath, &buf)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", ical.MIMEType)
+	if originator != "" {
+		req.Header.Set("Originator", originator)
+	}
+	for _, recipient := range recipients {
+		req.Header.Add("Recipient", recipient)
+	}
+
+	sr, err := c.doSchedulingRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return sr.Recipients, nil
+}
+
+func (c *Client) doSchedulingRequest(ctx context.Context, req *http.Request) (*SchedulingResponse, error) {
+	resp, err := c.ic.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var sr scheduleResponse
+	if err := xml.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		return nil, err
+	}
+
+	result := SchedulingResponse{Recipients: make([]RecipientStatus, len(sr.Responses))}
+	for i, rr := range sr.Responses {
+		rs := &result.Recipients[i]
+		rs.Recipient = rr.Recipient.Href.String()
+		rs.RequestStatus = strings.TrimSpace(rr.RequestStatus)
+		rs.Description = rr.ResponseDescription
+		if rr.CalendarData != nil && len(bytes.TrimSpace(rr.CalendarData.Data)) > 0 {
+			rs.Data, err = ical.NewDecoder(bytes.NewReader(rr.CalendarData.Data)).Decode()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &result, nil
+}
+
+// SyncCollection performs a collection synchronization operation on the
+// specified resource, as defined in RFC 6578.
+func (c *Client) SyncCollection(ctx context.Context, path string, query *SyncQuery) (*SyncResponse, error) {
+	var limit *internal.Limit
+	if query.Limit > 0 {
+		limit = &internal.Limit{NResults: uint(query.Limit)}
+	}
+
+	propReq, err := encodeCalendarReq(&query.CompRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	ms, err := c.ic.SyncCollection(ctx, path, query.SyncToken, internal.DepthOne, limit, propReq)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &SyncResponse{SyncToken: ms.SyncToken}
+	for _, resp := range ms.Responses {
+		p, err := resp.Path()
+		if err != nil {
+			if err, ok := err.(*internal.HTTPError); ok && err.Code == http.StatusNotFound {
+				ret.Deleted = append(ret.Deleted, p)
+				continue
+			}
+			return nil, err
+		}
+
+		if p == path || path == fmt.Sprintf("%s/", p) {
+			continue
+		}
+
+		var getLastMod internal.GetLastModified
+		if err := resp.DecodeProp(&getLastMod); err != nil && !internal.IsNotFound(err) {
+			return nil, err
+		}
+
+		var getETag internal.GetETag
+		if err := resp.DecodeProp(&getETag); err != nil && !internal.IsNotFound(err) {
+			return nil, err
+		}
+
+		o := CalendarObject{
+			Path:    p,
+			ModTime: time.Time(getLastMod.LastModified),
+			ETag:    string(getETag.ETag),
+		}
+		ret.Updated = append(ret.Updated, o)
+	}
+
+	return ret, nil
+}
+
+func decodePrivileges(names []xml.Name) []webdav.Privilege {
+	if names == nil {
+		return nil
+	}
+	privs := make([]webdav.Privilege, len(names))
+	for i, name := range names {
+		privs[i] = webdav.Privilege(name)
+	}
+	return privs
+}
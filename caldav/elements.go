@@ -10,6 +10,10 @@ import (
 
 const namespace = "urn:ietf:params:xml:ns:caldav"
 
+// appleNamespace is used by the calendar properties introduced by Apple and
+// supported by most clients.
+const appleNamespace = "http://apple.com/ns/ical/"
+
 var (
 	calendarHomeSetName = xml.Name{namespace, "calendar-home-set"}
 
@@ -17,12 +21,22 @@ var (
 	supportedCalendarDataName         = xml.Name{namespace, "supported-calendar-data"}
 	supportedCalendarComponentSetName = xml.Name{namespace, "supported-calendar-component-set"}
 	maxResourceSizeName               = xml.Name{namespace, "max-resource-size"}
+	calendarTimezoneName              = xml.Name{namespace, "calendar-timezone"}
+
+	calendarColorName = xml.Name{appleNamespace, "calendar-color"}
+	calendarOrderName = xml.Name{appleNamespace, "calendar-order"}
 
 	calendarQueryName    = xml.Name{namespace, "calendar-query"}
 	calendarMultigetName = xml.Name{namespace, "calendar-multiget"}
+	freeBusyQueryName    = xml.Name{namespace, "free-busy-query"}
 
 	calendarName     = xml.Name{namespace, "calendar"}
 	calendarDataName = xml.Name{namespace, "calendar-data"}
+
+	scheduleInboxURLName  = xml.Name{namespace, "schedule-inbox-URL"}
+	scheduleOutboxURLName = xml.Name{namespace, "schedule-outbox-URL"}
+
+	managedAttachmentsServerURLName = xml.Name{namespace, "managed-attachments-server-URL"}
 )
 
 // https://tools.ietf.org/html/rfc4791#section-6.2.1
@@ -41,6 +55,22 @@ type calendarDescription struct {
 	Description string   `xml:",chardata"`
 }
 
+// https://tools.ietf.org/html/rfc4791#section-5.2.2
+type calendarTimezone struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:caldav calendar-timezone"`
+	Data    []byte   `xml:",chardata"`
+}
+
+type calendarColor struct {
+	XMLName xml.Name `xml:"http://apple.com/ns/ical/ calendar-color"`
+	Color   string   `xml:",chardata"`
+}
+
+type calendarOrder struct {
+	XMLName xml.Name `xml:"http://apple.com/ns/ical/ calendar-order"`
+	Order   int      `xml:",chardata"`
+}
+
 // https://tools.ietf.org/html/rfc4791#section-5.2.4
 type supportedCalendarData struct {
 	XMLName xml.Name           `xml:"urn:ietf:params:xml:ns:caldav supported-calendar-data"`
@@ -73,7 +103,13 @@ type calendarQuery struct {
 	AllProp  *struct{}      `xml:"DAV: allprop,omitempty"`
 	PropName *struct{}      `xml:"DAV: propname,omitempty"`
 	Filter   filter         `xml:"filter"`
-	// TODO: timezone
+	Timezone *timezone      `xml:"timezone,omitempty"`
+}
+
+// https://tools.ietf.org/html/rfc4791#section-9.8
+type timezone struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:caldav timezone"`
+	Data    []byte   `xml:",chardata"`
 }
 
 // https://tools.ietf.org/html/rfc4791#section-9.10
@@ -148,6 +184,12 @@ func (nc negateCondition) MarshalText() ([]byte, error) {
 	return nil, nil
 }
 
+// https://tools.ietf.org/html/rfc4791#section-9.11
+type freeBusyQuery struct {
+	XMLName   xml.Name  `xml:"urn:ietf:params:xml:ns:caldav free-busy-query"`
+	TimeRange timeRange `xml:"time-range"`
+}
+
 // https://tools.ietf.org/html/rfc4791#section-9.9
 type timeRange struct {
 	XMLName xml.Name        `xml:"urn:ietf:params:xml:ns:caldav time-range"`
@@ -171,15 +213,44 @@ func (t *dateWithUTCTime) UnmarshalText(b []byte) error {
 }
 
 func (t *dateWithUTCTime) MarshalText() ([]byte, error) {
-	s := time.Time(*t).Format(dateWithUTCTimeLayout)
+	s := time.Time(*t).UTC().Format(dateWithUTCTimeLayout)
 	return []byte(s), nil
 }
 
+// MarshalXMLAttr omits the attribute for the zero time, so that open-ended
+// time ranges can be expressed.
+func (t *dateWithUTCTime) MarshalXMLAttr(name xml.Name) (xml.Attr, error) {
+	if time.Time(*t).IsZero() {
+		return xml.Attr{}, nil
+	}
+	b, err := t.MarshalText()
+	if err != nil {
+		return xml.Attr{}, err
+	}
+	return xml.Attr{Name: name, Value: string(b)}, nil
+}
+
 // Request variant of https://tools.ietf.org/html/rfc4791#section-9.6
 type calendarDataReq struct {
-	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
-	Comp    *comp    `xml:"comp,omitempty"`
-	// TODO: expand, limit-recurrence-set, limit-freebusy-set
+	XMLName            xml.Name            `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+	Comp               *comp               `xml:"comp,omitempty"`
+	Expand             *expand             `xml:"expand,omitempty"`
+	LimitRecurrenceSet *limitRecurrenceSet `xml:"limit-recurrence-set,omitempty"`
+	// TODO: limit-freebusy-set
+}
+
+// https://tools.ietf.org/html/rfc4791#section-9.6.6
+type limitRecurrenceSet struct {
+	XMLName xml.Name        `xml:"urn:ietf:params:xml:ns:caldav limit-recurrence-set"`
+	Start   dateWithUTCTime `xml:"start,attr"`
+	End     dateWithUTCTime `xml:"end,attr"`
+}
+
+// https://tools.ietf.org/html/rfc4791#section-9.6.5
+type expand struct {
+	XMLName xml.Name        `xml:"urn:ietf:params:xml:ns:caldav expand"`
+	Start   dateWithUTCTime `xml:"start,attr"`
+	End     dateWithUTCTime `xml:"end,attr"`
 }
 
 // https://tools.ietf.org/html/rfc4791#section-9.6.1
@@ -207,9 +278,49 @@ type calendarDataResp struct {
 	Data    []byte   `xml:",chardata"`
 }
 
+// https://datatracker.ietf.org/doc/html/rfc6638#section-2.2
+type scheduleInboxURL struct {
+	XMLName xml.Name      `xml:"urn:ietf:params:xml:ns:caldav schedule-inbox-URL"`
+	Href    internal.Href `xml:"DAV: href"`
+}
+
+// https://datatracker.ietf.org/doc/html/rfc6638#section-2.1
+type scheduleOutboxURL struct {
+	XMLName xml.Name      `xml:"urn:ietf:params:xml:ns:caldav schedule-outbox-URL"`
+	Href    internal.Href `xml:"DAV: href"`
+}
+
+// https://datatracker.ietf.org/doc/html/rfc8607#section-6.1
+type managedAttachmentsServerURL struct {
+	XMLName xml.Name      `xml:"urn:ietf:params:xml:ns:caldav managed-attachments-server-URL"`
+	Href    internal.Href `xml:"DAV: href"`
+}
+
+// https://datatracker.ietf.org/doc/html/rfc6638#section-10.2
+type scheduleResponse struct {
+	XMLName   xml.Name                    `xml:"urn:ietf:params:xml:ns:caldav schedule-response"`
+	Responses []scheduleRecipientResponse `xml:"response"`
+}
+
+// https://datatracker.ietf.org/doc/html/rfc6638#section-10.3
+type scheduleRecipientResponse struct {
+	XMLName             xml.Name          `xml:"urn:ietf:params:xml:ns:caldav response"`
+	Recipient           scheduleRecipient `xml:"recipient"`
+	RequestStatus       string            `xml:"request-status"`
+	CalendarData        *calendarDataResp `xml:"calendar-data,omitempty"`
+	ResponseDescription string            `xml:"DAV: responsedescription,omitempty"`
+}
+
+// https://datatracker.ietf.org/doc/html/rfc6638#section-10.4
+type scheduleRecipient struct {
+	XMLName xml.Name      `xml:"urn:ietf:params:xml:ns:caldav recipient"`
+	Href    internal.Href `xml:"DAV: href"`
+}
+
 type reportReq struct {
-	Query    *calendarQuery
-	Multiget *calendarMultiget
+	Query          *calendarQuery
+	Multiget       *calendarMultiget
+	SyncCollection *internal.SyncCollectionQuery
 	// TODO: CALDAV:free-busy-query
 }
 
@@ -222,6 +333,9 @@ func (r *reportReq) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	case calendarMultigetName:
 		r.Multiget = &calendarMultiget{}
 		v = r.Multiget
+	case internal.SyncCollectionName:
+		r.SyncCollection = &internal.SyncCollectionQuery{}
+		v = r.SyncCollection
 	default:
 		return fmt.Errorf("caldav: unsupported REPORT root %q %q", start.Name.Space, start.Name.Local)
 	}
@@ -229,6 +343,12 @@ func (r *reportReq) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	return d.DecodeElement(v, &start)
 }
 
+// https://tools.ietf.org/html/rfc4791#section-5.3.1.1
+type mkcalendarReq struct {
+	XMLName xml.Name      `xml:"urn:ietf:params:xml:ns:caldav mkcalendar"`
+	Set     *internal.Set `xml:"DAV: set,omitempty"`
+}
+
 type mkcolReq struct {
 	XMLName      xml.Name              `xml:"DAV: mkcol"`
 	ResourceType internal.ResourceType `xml:"set>prop>resourcetype"`
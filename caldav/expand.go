@@ -0,0 +1,427 @@
+package caldav
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-ical"
+)
+
+// ExpandRecurrences expands the recurring components of an iCalendar object
+// into their individual instances overlapping the time range [start, end), as
+// described in RFC 4791 section 9.6.5. Overridden instances, including
+// THISANDFUTURE ones, are taken into account.
+//
+// If no instance overlaps the time range, nil is returned.
+func ExpandRecurrences(data []byte, start, end time.Time) ([]byte, error) {
+	cal, err := ical.NewDecoder(bytes.NewReader(data)).Decode()
+	if err != nil {
+		return nil, err
+	}
+	expanded, err := expandCalendar(cal, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if len(expanded.Children) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(expanded); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// expandCalendar returns a copy of cal where recurring components are
+// replaced with their individual instances overlapping the time range
+// [start, end), as described in RFC 4791 section 9.6.5.
+//
+// Instances carry a RECURRENCE-ID property and no recurrence rule. Overridden
+// instances are taken from the matching component with a RECURRENCE-ID.
+// Date-time values are converted to UTC, so time zone components are dropped.
+func expandCalendar(cal *ical.Calendar, start, end time.Time) (*ical.Calendar, error) {
+	expanded := ical.NewCalendar()
+	for name, props := range cal.Props {
+		expanded.Props[name] = props
+	}
+
+	overrides, err := newRecurrenceOverrides(cal)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, child := range cal.Children {
+		switch child.Name {
+		case ical.CompTimezone:
+			continue
+		case ical.CompEvent, ical.CompToDo, ical.CompJournal:
+			// Expanded below
+		default:
+			expanded.Children = append(expanded.Children, child)
+			continue
+		}
+
+		instances, err := expandComponent(child, start, end, overrides)
+		if err != nil {
+			return nil, err
+		}
+		expanded.Children = append(expanded.Children, instances...)
+	}
+
+	return expanded, nil
+}
+
+// limitRecurrenceSetCalendar returns a copy of cal where overridden instances
+// of recurring components which don't overlap the time range [start, end) are
+// removed, as described in RFC 4791 section 9.6.6.
+func limitRecurrenceSetCalendar(cal *ical.Calendar, start, end time.Time) (*ical.Calendar, error) {
+	limited := ical.NewCalendar()
+	for name, props := range cal.Props {
+		limited.Props[name] = props
+	}
+
+	for _, child := range cal.Children {
+		if child.Props.Get(ical.PropRecurrenceID) == nil {
+			limited.Children = append(limited.Children, child)
+			continue
+		}
+
+		instances, err := expandComponent(child, start, end, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(instances) > 0 {
+			limited.Children = append(limited.Children, child)
+		}
+	}
+
+	return limited, nil
+}
+
+// recurrenceOverrides indexes the overridden instances of recurring
+// components by UID.
+type recurrenceOverrides struct {
+	ids map[string]map[int64]bool
+	// ranges contains the overrides with a THISANDFUTURE range, sorted by
+	// RECURRENCE-ID
+	ranges map[string][]rangeOverride
+}
+
+// rangeOverride is an overridden instance which also applies to all the
+// following instances, as defined in RFC 5545 section 3.8.4.4.
+type rangeOverride struct {
+	recurrenceID time.Time
+	// offset is the difference between the start of the overridden instance
+	// and its RECURRENCE-ID
+	offset   time.Duration
+	duration time.Duration
+	comp     *ical.Component
+}
+
+func newRecurrenceOverrides(cal *ical.Calendar) (*recurrenceOverrides, error) {
+	overrides := &recurrenceOverrides{
+		ids:    make(map[string]map[int64]bool),
+		ranges: make(map[string][]rangeOverride),
+	}
+	for _, child := range cal.Children {
+		recurrenceID := child.Props.Get(ical.PropRecurrenceID)
+		if recurrenceID == nil {
+			continue
+		}
+		t, err := recurrenceID.DateTime(time.UTC)
+		if err != nil {
+			return nil, fmt.Errorf("caldav: failed to parse RECURRENCE-ID: %v", err)
+		}
+		uid, _ := child.Props.Text(ical.PropUID)
+		if overrides.ids[uid] == nil {
+			overrides.ids[uid] = make(map[int64]bool)
+		}
+		overrides.ids[uid][t.Unix()] = true
+
+		if !strings.EqualFold(recurrenceID.Params.Get(ical.ParamRange), "THISANDFUTURE") {
+			continue
+		}
+		dtstart, err := child.Props.DateTime(ical.PropDateTimeStart, time.UTC)
+		if err != nil {
+			return nil, err
+		}
+		if dtstart.IsZero() {
+			dtstart = t
+		}
+		duration, err := componentDuration(child, dtstart, time.UTC)
+		if err != nil {
+			return nil, err
+		}
+		overrides.ranges[uid] = append(overrides.ranges[uid], rangeOverride{
+			recurrenceID: t,
+			offset:       dtstart.Sub(t),
+			duration:     duration,
+			comp:         child,
+		})
+	}
+	for _, l := range overrides.ranges {
+		sort.Slice(l, func(i, j int) bool {
+			return l[i].recurrenceID.Before(l[j].recurrenceID)
+		})
+	}
+	return overrides, nil
+}
+
+// has checks whether the instance of the component uid starting at t has
+// been overridden.
+func (o *recurrenceOverrides) has(uid string, t time.Time) bool {
+	return o != nil && o.ids[uid][t.Unix()]
+}
+
+// rangeOverride returns the THISANDFUTURE override applying to the instance
+// of the component uid starting at t, if any.
+func (o *recurrenceOverrides) rangeOverride(uid string, t time.Time) *rangeOverride {
+	if o == nil {
+		return nil
+	}
+	var found *rangeOverride
+	for i, ro := range o.ranges[uid] {
+		if ro.recurrenceID.After(t) {
+			break
+		}
+		found = &o.ranges[uid][i]
+	}
+	return found
+}
+
+// maxShift returns the maximum amount of time an instance of the component
+// uid can be moved by a THISANDFUTURE override.
+func (o *recurrenceOverrides) maxShift(uid string) time.Duration {
+	if o == nil {
+		return 0
+	}
+	var max time.Duration
+	for _, ro := range o.ranges[uid] {
+		shift := ro.offset
+		if shift < 0 {
+			shift = -shift
+		}
+		if shift+ro.duration > max {
+			max = shift + ro.duration
+		}
+	}
+	return max
+}
+
+func expandComponent(comp *ical.Component, start, end time.Time, overrides *recurrenceOverrides) ([]*ical.Component, error) {
+	dtstart, err := comp.Props.DateTime(ical.PropDateTimeStart, time.UTC)
+	if err != nil {
+		return nil, err
+	}
+	// Components without a start time can't be placed in the time range
+	if dtstart.IsZero() {
+		return []*ical.Component{comp}, nil
+	}
+	duration, err := componentDuration(comp, dtstart, time.UTC)
+	if err != nil {
+		return nil, err
+	}
+
+	overlaps := func(t time.Time, duration time.Duration) bool {
+		if duration == 0 {
+			return !t.Before(start) && t.Before(end)
+		}
+		return t.Before(end) && t.Add(duration).After(start)
+	}
+
+	if comp.Props.Get(ical.PropRecurrenceID) != nil {
+		if !overlaps(dtstart, duration) {
+			return nil, nil
+		}
+		return []*ical.Component{newInstance(comp, dtstart, duration, time.Time{})}, nil
+	}
+
+	uid, _ := comp.Props.Text(ical.PropUID)
+	shift := overrides.maxShift(uid)
+	times, recurring, err := recurrenceTimes(comp, dtstart, start.Add(-duration-shift), end.Add(shift), time.UTC)
+	if err != nil {
+		return nil, err
+	}
+	if !recurring {
+		if !overlaps(dtstart, duration) {
+			return nil, nil
+		}
+		return []*ical.Component{newInstance(comp, dtstart, duration, time.Time{})}, nil
+	}
+
+	var instances []*ical.Component
+	for _, t := range times {
+		if overrides.has(uid, t) {
+			continue
+		}
+
+		inst, instStart, instDuration := comp, t, duration
+		if ro := overrides.rangeOverride(uid, t); ro != nil {
+			inst, instStart, instDuration = ro.comp, t.Add(ro.offset), ro.duration
+		}
+		if !overlaps(instStart, instDuration) {
+			continue
+		}
+		instances = append(instances, newInstance(inst, instStart, instDuration, t))
+	}
+	return instances, nil
+}
+
+// recurrenceTimes returns the start times of the instances of comp in the
+// time range [from, to], as defined by its RRULE, RDATE and EXDATE properties.
+// Floating values are resolved in loc. recurring is false if comp doesn't
+// recur.
+//
+// The recurrence set built by go-ical is only used for the RRULE: it ignores
+// RDATE and doesn't support multiple values per property.
+func recurrenceTimes(comp *ical.Component, dtstart, from, to time.Time, loc *time.Location) (times []time.Time, recurring bool, err error) {
+	ruleComp := &ical.Component{Name: comp.Name, Props: make(ical.Props, len(comp.Props))}
+	for name, props := range comp.Props {
+		if name != ical.PropRecurrenceDates && name != ical.PropExceptionDates {
+			ruleComp.Props[name] = props
+		}
+	}
+	rset, err := ruleComp.RecurrenceSet(loc)
+	if err != nil {
+		return nil, false, err
+	}
+	rdates, err := parseDateList(comp.Props[ical.PropRecurrenceDates], loc)
+	if err != nil {
+		return nil, false, fmt.Errorf("caldav: failed to parse RDATE: %v", err)
+	}
+	exdates, err := parseDateList(comp.Props[ical.PropExceptionDates], loc)
+	if err != nil {
+		return nil, false, fmt.Errorf("caldav: failed to parse EXDATE: %v", err)
+	}
+	if rset == nil && len(rdates) == 0 {
+		return nil, false, nil
+	}
+
+	var candidates []time.Time
+	if rset != nil {
+		candidates = rset.Between(from, to, true)
+	} else {
+		// DTSTART is always the first instance of the recurrence set
+		candidates = []time.Time{dtstart}
+	}
+	candidates = append(candidates, rdates...)
+
+	excluded := make(map[int64]bool, len(exdates))
+	for _, t := range exdates {
+		excluded[t.Unix()] = true
+	}
+	seen := make(map[int64]bool, len(candidates))
+	for _, t := range candidates {
+		if t.Before(from) || t.After(to) || excluded[t.Unix()] || seen[t.Unix()] {
+			continue
+		}
+		seen[t.Unix()] = true
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].Before(times[j])
+	})
+	return times, true, nil
+}
+
+// parseDateList parses RDATE or EXDATE properties, which may contain multiple
+// comma-separated values. Only the start of PERIOD values is returned.
+func parseDateList(props []ical.Prop, loc *time.Location) ([]time.Time, error) {
+	var l []time.Time
+	for _, prop := range props {
+		isPeriod := prop.ValueType() == ical.ValuePeriod
+		for _, v := range strings.Split(prop.Value, ",") {
+			p := ical.Prop{Name: prop.Name, Params: make(ical.Params), Value: v}
+			if tzid := prop.Params.Get(ical.ParamTimezoneID); tzid != "" {
+				p.Params.Set(ical.ParamTimezoneID, tzid)
+			}
+			if isPeriod {
+				p.Value = strings.SplitN(v, "/", 2)[0]
+			} else if t := prop.ValueType(); t != ical.ValueDefault {
+				p.SetValueType(t)
+			}
+
+			t, err := p.DateTime(loc)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, t)
+		}
+	}
+	return l, nil
+}
+
+// componentDuration returns the duration of a component, computed from its
+// DTEND, DUE or DURATION property. Floating values are resolved in loc.
+func componentDuration(comp *ical.Component, dtstart time.Time, loc *time.Location) (time.Duration, error) {
+	if prop := comp.Props.Get(ical.PropDuration); prop != nil {
+		return prop.Duration()
+	}
+	for _, name := range []string{ical.PropDateTimeEnd, ical.PropDue} {
+		if comp.Props.Get(name) == nil {
+			continue
+		}
+		t, err := comp.Props.DateTime(name, loc)
+		if err != nil {
+			return 0, err
+		}
+		return t.Sub(dtstart), nil
+	}
+	// All-day events without an end last for one day
+	if prop := comp.Props.Get(ical.PropDateTimeStart); prop.ValueType() == ical.ValueDate || len(prop.Value) == len("20060102") {
+		if comp.Name == ical.CompEvent {
+			return 24 * time.Hour, nil
+		}
+	}
+	return 0, nil
+}
+
+// newInstance creates a copy of comp starting at t. If recurrenceID isn't
+// zero, the recurrence properties are replaced with a RECURRENCE-ID property.
+func newInstance(comp *ical.Component, t time.Time, duration time.Duration, recurrenceID time.Time) *ical.Component {
+	inst := &ical.Component{
+		Name:     comp.Name,
+		Props:    make(ical.Props, len(comp.Props)),
+		Children: comp.Children,
+	}
+	for name, props := range comp.Props {
+		inst.Props[name] = append([]ical.Prop(nil), props...)
+	}
+
+	isDate := comp.Props.Get(ical.PropDateTimeStart).ValueType() == ical.ValueDate
+	setTime := func(name string, t time.Time) {
+		prop := ical.NewProp(name)
+		if isDate {
+			prop.SetDate(t)
+		} else {
+			prop.SetDateTime(t.UTC())
+		}
+		inst.Props.Set(prop)
+	}
+
+	setTime(ical.PropDateTimeStart, t)
+	if inst.Props.Get(ical.PropDateTimeEnd) != nil {
+		setTime(ical.PropDateTimeEnd, t.Add(duration))
+	}
+	if inst.Props.Get(ical.PropDue) != nil {
+		setTime(ical.PropDue, t.Add(duration))
+	}
+	if prop := inst.Props.Get(ical.PropRecurrenceID); prop != nil && !isDate {
+		if rid, err := prop.DateTime(time.UTC); err == nil {
+			setTime(ical.PropRecurrenceID, rid)
+		}
+	}
+
+	if !recurrenceID.IsZero() {
+		inst.Props.Del(ical.PropRecurrenceRule)
+		inst.Props.Del(ical.PropRecurrenceDates)
+		inst.Props.Del(ical.PropExceptionDates)
+		setTime(ical.PropRecurrenceID, recurrenceID)
+	}
+
+	return inst
+}
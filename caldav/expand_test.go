@@ -0,0 +1,174 @@
+package caldav
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-ical"
+)
+
+func TestExpandCalendar(t *testing.T) {
+	cal, err := ical.NewDecoder(strings.NewReader(`BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VEVENT
+DTSTAMP:20060206T001121Z
+DTSTART:20060102T170000Z
+DURATION:PT1H
+RRULE:FREQ=DAILY;COUNT=5
+EXDATE:20060103T170000Z
+SUMMARY:Event #2
+UID:00959BC664CA650E933C892C@example.com
+END:VEVENT
+BEGIN:VEVENT
+DTSTAMP:20060206T001121Z
+DTSTART:20060104T190000Z
+DURATION:PT1H
+RECURRENCE-ID:20060104T170000Z
+SUMMARY:Event #2 bis
+UID:00959BC664CA650E933C892C@example.com
+END:VEVENT
+END:VCALENDAR`)).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expanded, err := expandCalendar(cal, toDate(t, "20060102T000000Z"), toDate(t, "20060105T000000Z"))
+	if err != nil {
+		t.Fatalf("expandCalendar() = %v", err)
+	}
+
+	type instance struct {
+		start, recurrenceID, summary string
+	}
+	var got []instance
+	for _, child := range expanded.Children {
+		if child.Props.Get(ical.PropRecurrenceRule) != nil || child.Props.Get(ical.PropExceptionDates) != nil {
+			t.Errorf("expanded instance contains recurrence properties")
+		}
+		summary, _ := child.Props.Text(ical.PropSummary)
+		got = append(got, instance{
+			start:        child.Props.Get(ical.PropDateTimeStart).Value,
+			recurrenceID: child.Props.Get(ical.PropRecurrenceID).Value,
+			summary:      summary,
+		})
+	}
+
+	want := []instance{
+		{"20060102T170000Z", "20060102T170000Z", "Event #2"},
+		{"20060104T190000Z", "20060104T170000Z", "Event #2 bis"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expandCalendar() = %+v, want %+v", got, want)
+	}
+}
+
+func TestExpandRecurrences(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data string
+		want []string // DTSTART, RECURRENCE-ID and SUMMARY of each instance
+	}{
+		{
+			name: "rdate-exdate",
+			data: `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VEVENT
+DTSTAMP:20060206T001121Z
+DTSTART:20060102T170000Z
+DURATION:PT1H
+RRULE:FREQ=DAILY;COUNT=3
+RDATE:20060110T170000Z,20060111T170000Z
+EXDATE:20060103T170000Z,20060111T170000Z
+SUMMARY:Event
+UID:rdate@example.com
+END:VEVENT
+END:VCALENDAR
+`,
+			want: []string{
+				"20060102T170000Z 20060102T170000Z Event",
+				"20060104T170000Z 20060104T170000Z Event",
+				"20060110T170000Z 20060110T170000Z Event",
+			},
+		},
+		{
+			name: "rdate-only",
+			data: `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VEVENT
+DTSTAMP:20060206T001121Z
+DTSTART:20060102T170000Z
+DURATION:PT1H
+RDATE;VALUE=PERIOD:20060105T170000Z/PT1H
+SUMMARY:Event
+UID:rdate-only@example.com
+END:VEVENT
+END:VCALENDAR
+`,
+			want: []string{
+				"20060102T170000Z 20060102T170000Z Event",
+				"20060105T170000Z 20060105T170000Z Event",
+			},
+		},
+		{
+			name: "thisandfuture",
+			data: `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VEVENT
+DTSTAMP:20060206T001121Z
+DTSTART:20060102T170000Z
+DURATION:PT1H
+RRULE:FREQ=DAILY;COUNT=4
+SUMMARY:Event
+UID:range@example.com
+END:VEVENT
+BEGIN:VEVENT
+DTSTAMP:20060206T001121Z
+DTSTART:20060103T180000Z
+DURATION:PT1H
+RECURRENCE-ID;RANGE=THISANDFUTURE:20060103T170000Z
+SUMMARY:Moved
+UID:range@example.com
+END:VEVENT
+END:VCALENDAR
+`,
+			want: []string{
+				"20060102T170000Z 20060102T170000Z Event",
+				"20060103T180000Z 20060103T170000Z Moved",
+				"20060104T180000Z 20060104T170000Z Moved",
+				"20060105T180000Z 20060105T170000Z Moved",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := ExpandRecurrences([]byte(tc.data), toDate(t, "20060101T000000Z"), toDate(t, "20060201T000000Z"))
+			if err != nil {
+				t.Fatalf("ExpandRecurrences() = %v", err)
+			}
+			cal, err := ical.NewDecoder(bytes.NewReader(data)).Decode()
+			if err != nil {
+				t.Fatalf("failed to decode expanded calendar: %v", err)
+			}
+
+			var got []string
+			for _, child := range cal.Children {
+				summary, _ := child.Props.Text(ical.PropSummary)
+				got = append(got, strings.Join([]string{
+					child.Props.Get(ical.PropDateTimeStart).Value,
+					child.Props.Get(ical.PropRecurrenceID).Value,
+					summary,
+				}, " "))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ExpandRecurrences() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package caldav
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
@@ -15,9 +16,17 @@ func Filter(query *CalendarQuery, cos []CalendarObject) ([]CalendarObject, error
 		return cos, nil
 	}
 
+	loc, err := timezoneLocation(query.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
 	var out []CalendarObject
 	for _, co := range cos {
-		ok, err := Match(query.CompFilter, &co)
+		if co.Data == nil || co.Data.Component == nil {
+			panic("request to process empty calendar object")
+		}
+		ok, err := match(query.CompFilter, co.Data.Component, loc)
 		if err != nil {
 			return nil, err
 		}
@@ -32,21 +41,43 @@ func Filter(query *CalendarQuery, cos []CalendarObject) ([]CalendarObject, error
 }
 
 // Match reports whether the provided CalendarObject matches the query.
+// Floating date and date-time values are interpreted as UTC.
 func Match(query CompFilter, co *CalendarObject) (matched bool, err error) {
 	if co.Data == nil || co.Data.Component == nil {
 		panic("request to process empty calendar object")
 	}
-	return match(query, co.Data.Component)
+	return match(query, co.Data.Component, time.UTC)
 }
 
-func match(filter CompFilter, comp *ical.Component) (bool, error) {
+// timezoneLocation returns the location of a VTIMEZONE component, used to
+// resolve floating date and date-time values. UTC is returned if tz is nil.
+//
+// Only VTIMEZONE components whose TZID is a known IANA time zone are
+// supported: the observances defined in the component aren't evaluated.
+func timezoneLocation(tz *ical.Component) (*time.Location, error) {
+	if tz == nil {
+		return time.UTC, nil
+	}
+	tzid, err := tz.Props.Text(ical.PropTimezoneID)
+	if err != nil {
+		return nil, err
+	} else if tzid == "" {
+		return nil, fmt.Errorf("caldav: VTIMEZONE is missing a TZID")
+	}
+	loc, err := time.LoadLocation(tzid)
+	if err != nil {
+		return nil, fmt.Errorf("caldav: unsupported time zone %q: %v", tzid, err)
+	}
+	return loc, nil
+}
+
+func match(filter CompFilter, comp *ical.Component, loc *time.Location) (bool, error) {
 	if comp.Name != filter.Name {
 		return filter.IsNotDefined, nil
 	}
 
-	var zeroDate time.Time
-	if filter.Start != zeroDate {
-		match, err := matchCompTimeRange(filter.Start, filter.End, comp)
+	if !filter.Start.IsZero() || !filter.End.IsZero() {
+		match, err := matchCompTimeRange(filter.Start, filter.End, comp, loc)
 		if err != nil {
 			return false, err
 		}
@@ -55,7 +86,7 @@ func match(filter CompFilter, comp *ical.Component) (bool, error) {
 		}
 	}
 	for _, compFilter := range filter.Comps {
-		match, err := matchCompFilter(compFilter, comp)
+		match, err := matchCompFilter(compFilter, comp, loc)
 		if err != nil {
 			return false, err
 		}
@@ -64,7 +95,7 @@ func match(filter CompFilter, comp *ical.Component) (bool, error) {
 		}
 	}
 	for _, propFilter := range filter.Props {
-		match, err := matchPropFilter(propFilter, comp)
+		match, err := matchPropFilter(propFilter, comp, loc)
 		if err != nil {
 			return false, err
 		}
@@ -75,11 +106,11 @@ func match(filter CompFilter, comp *ical.Component) (bool, error) {
 	return true, nil
 }
 
-func matchCompFilter(filter CompFilter, comp *ical.Component) (bool, error) {
+func matchCompFilter(filter CompFilter, comp *ical.Component, loc *time.Location) (bool, error) {
 	var matches []*ical.Component
 
 	for _, child := range comp.Children {
-		match, err := match(filter, child)
+		match, err := match(filter, child, loc)
 		if err != nil {
 			return false, err
 		} else if match {
@@ -92,7 +123,7 @@ func matchCompFilter(filter CompFilter, comp *ical.Component) (bool, error) {
 	return true, nil
 }
 
-func matchPropFilter(filter PropFilter, comp *ical.Component) (bool, error) {
+func matchPropFilter(filter PropFilter, comp *ical.Component, loc *time.Location) (bool, error) {
 	// TODO: this only matches first field, there can be multiple
 	field := comp.Props.Get(filter.Name)
 	if field == nil {
@@ -105,9 +136,8 @@ func matchPropFilter(filter PropFilter, comp *ical.Component) (bool, error) {
 		}
 	}
 
-	var zeroDate time.Time
-	if filter.Start != zeroDate {
-		match, err := matchPropTimeRange(filter.Start, filter.End, field)
+	if !filter.Start.IsZero() || !filter.End.IsZero() {
+		match, err := matchPropTimeRange(filter.Start, filter.End, field, loc)
 		if err != nil {
 			return false, err
 		}
@@ -124,61 +154,71 @@ func matchPropFilter(filter PropFilter, comp *ical.Component) (bool, error) {
 	return true, nil
 }
 
-func matchCompTimeRange(start, end time.Time, comp *ical.Component) (bool, error) {
+// openTimeRangeHorizon limits the search for instances of recurring
+// components when a time range has no end.
+const openTimeRangeHorizon = 10 * 365 * 24 * time.Hour
+
+// matchCompTimeRange checks whether a component overlaps the time range
+// [start, end). Floating date and date-time values are resolved in loc, so
+// that all-day events span their whole local day.
+func matchCompTimeRange(start, end time.Time, comp *ical.Component, loc *time.Location) (bool, error) {
 	// See https://datatracker.ietf.org/doc/html/rfc4791#section-9.9
 
-	// evaluate recurring components
-	rset, err := comp.RecurrenceSet(start.Location())
+	dtstart, err := comp.Props.DateTime(ical.PropDateTimeStart, loc)
 	if err != nil {
 		return false, err
 	}
-	if rset != nil {
-		// TODO we can only set inclusive to true or false, but really the
-		// start time is inclusive while the end time is not :/
-		return len(rset.Between(start, end, true)) > 0, nil
-	}
-
-	// TODO handle more than just events
-	if comp.Name != ical.CompEvent {
+	if dtstart.IsZero() {
 		return false, nil
 	}
-	event := ical.Event{comp}
-
-	eventStart, err := event.DateTimeStart(start.Location())
+	duration, err := componentDuration(comp, dtstart, loc)
 	if err != nil {
 		return false, err
 	}
-	eventEnd, err := event.DateTimeEnd(end.Location())
-	if err != nil {
-		return false, err
+
+	overlaps := func(t time.Time) bool {
+		if duration == 0 {
+			return (start.IsZero() || !t.Before(start)) && (end.IsZero() || t.Before(end))
+		}
+		return (start.IsZero() || t.Add(duration).After(start)) && (end.IsZero() || t.Before(end))
 	}
 
-	// Event starts in time range
-	if eventStart.After(start) && (end.IsZero() || eventStart.Before(end)) {
-		return true, nil
+	// evaluate recurring components
+	from, to := start.Add(-duration), end
+	if start.IsZero() {
+		from = dtstart
 	}
-	// Event ends in time range
-	if eventEnd.After(start) && (end.IsZero() || eventEnd.Before(end)) {
-		return true, nil
+	if end.IsZero() {
+		to = from.Add(openTimeRangeHorizon)
 	}
-	// Event covers entire time range plus some
-	if eventStart.Before(start) && (!end.IsZero() && eventEnd.After(end)) {
-		return true, nil
+	times, recurring, err := recurrenceTimes(comp, dtstart, from, to, loc)
+	if err != nil {
+		return false, err
 	}
-	return false, nil
+	if recurring {
+		for _, t := range times {
+			if overlaps(t) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	// TODO handle more than just events
+	if comp.Name != ical.CompEvent {
+		return false, nil
+	}
+	return overlaps(dtstart), nil
 }
 
-func matchPropTimeRange(start, end time.Time, field *ical.Prop) (bool, error) {
+func matchPropTimeRange(start, end time.Time, field *ical.Prop, loc *time.Location) (bool, error) {
 	// See https://datatracker.ietf.org/doc/html/rfc4791#section-9.9
 
-	ptime, err := field.DateTime(start.Location())
+	ptime, err := field.DateTime(loc)
 	if err != nil {
 		return false, err
 	}
-	if ptime.After(start) && (end.IsZero() || ptime.Before(end)) {
-		return true, nil
-	}
-	return false, nil
+	return (start.IsZero() || !ptime.Before(start)) && (end.IsZero() || ptime.Before(end)), nil
 }
 
 func matchParamFilter(filter ParamFilter, field *ical.Prop) bool {
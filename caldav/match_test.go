@@ -288,6 +288,48 @@ END:VCALENDAR`)
 			addrs: []CalendarObject{event1, event2, event3, todo1},
 			want:  []CalendarObject{event2},
 		},
+		{
+			name: "tasks by status and due date",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{
+						CompFilter{
+							Name: "VTODO",
+							Props: []PropFilter{{
+								Name: "STATUS",
+								TextMatch: &TextMatch{
+									Text: "NEEDS-ACTION",
+								},
+							}, {
+								Name: "DUE",
+								End:  toDate(t, "20060105T000000Z"),
+							}},
+						},
+					},
+				},
+			},
+			addrs: []CalendarObject{event1, event2, event3, todo1},
+			want:  []CalendarObject{todo1},
+		},
+		{
+			name: "tasks due before date",
+			query: &CalendarQuery{
+				CompFilter: CompFilter{
+					Name: "VCALENDAR",
+					Comps: []CompFilter{
+						CompFilter{
+							Name: "VTODO",
+							Props: []PropFilter{{
+								Name: "DUE",
+								End:  toDate(t, "20060103T000000Z"),
+							}},
+						},
+					},
+				},
+			},
+			addrs: []CalendarObject{event1, event2, event3, todo1},
+		},
 		// TODO add more examples
 	} {
 		t.Run(tc.name, func(t *testing.T) {
@@ -309,3 +351,99 @@ END:VCALENDAR`)
 		})
 	}
 }
+
+func TestFilterTimeRangeTimezone(t *testing.T) {
+	newCO := func(event string) CalendarObject {
+		cal, err := ical.NewDecoder(strings.NewReader(`BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VEVENT
+DTSTAMP:20240101T000000Z
+UID:event@example.com
+` + event + `
+END:VEVENT
+END:VCALENDAR
+`)).Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return CalendarObject{Data: cal}
+	}
+
+	newYork := ical.NewComponent(ical.CompTimezone)
+	newYork.Props.SetText(ical.PropTimezoneID, "America/New_York")
+
+	allDay := newCO("DTSTART;VALUE=DATE:20240115")
+	floating := newCO("DTSTART:20240115T230000\nDURATION:PT30M")
+	zoned := newCO("DTSTART;TZID=America/New_York:20240115T230000\nDURATION:PT30M")
+
+	for _, tc := range []struct {
+		name       string
+		co         CalendarObject
+		tz         *ical.Component
+		start, end string
+		want       bool
+	}{
+		{"all-day-utc", allDay, nil, "20240115T000000Z", "20240116T000000Z", true},
+		{"all-day-local-day", allDay, newYork, "20240115T050000Z", "20240116T050000Z", true},
+		{"all-day-before-local-day", allDay, newYork, "20240115T000000Z", "20240115T050000Z", false},
+		{"all-day-next-local-day", allDay, newYork, "20240116T050000Z", "20240117T050000Z", false},
+		{"floating-utc", floating, nil, "20240115T000000Z", "20240116T000000Z", true},
+		{"floating-local-next-utc-day", floating, newYork, "20240116T000000Z", "20240117T000000Z", true},
+		{"floating-local-same-utc-day", floating, newYork, "20240115T000000Z", "20240116T000000Z", false},
+		{"zoned-next-utc-day", zoned, nil, "20240116T000000Z", "20240117T000000Z", true},
+		{"zoned-same-utc-day", zoned, nil, "20240115T000000Z", "20240116T000000Z", false},
+		{"ends-at-start", zoned, nil, "20240116T043000Z", "20240117T000000Z", false},
+		{"starts-at-start", zoned, nil, "20240116T040000Z", "20240117T000000Z", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			query := CalendarQuery{
+				CompFilter: CompFilter{
+					Name: ical.CompCalendar,
+					Comps: []CompFilter{{
+						Name:  ical.CompEvent,
+						Start: toDate(t, tc.start),
+						End:   toDate(t, tc.end),
+					}},
+				},
+				Timezone: tc.tz,
+			}
+			got, err := Filter(&query, []CalendarObject{tc.co})
+			if err != nil {
+				t.Fatalf("Filter() = %v", err)
+			}
+			if matched := len(got) == 1; matched != tc.want {
+				t.Errorf("Filter() matched = %v, want %v", matched, tc.want)
+			}
+		})
+	}
+}
+
+func TestFilterUnsupportedTimezone(t *testing.T) {
+	custom := ical.NewComponent(ical.CompTimezone)
+	custom.Props.SetText(ical.PropTimezoneID, "/example.org/Custom Standard Time")
+	noTZID := ical.NewComponent(ical.CompTimezone)
+
+	cal := ical.NewCalendar()
+	event := ical.NewEvent()
+	event.Props.SetText(ical.PropUID, "event@example.com")
+	event.Props.SetDateTime(ical.PropDateTimeStart, time.Date(2024, 1, 15, 23, 0, 0, 0, time.UTC))
+	cal.Children = []*ical.Component{event.Component}
+
+	for _, tz := range []*ical.Component{custom, noTZID} {
+		query := CalendarQuery{
+			CompFilter: CompFilter{
+				Name: ical.CompCalendar,
+				Comps: []CompFilter{{
+					Name:  ical.CompEvent,
+					Start: toDate(t, "20240115T000000Z"),
+					End:   toDate(t, "20240116T000000Z"),
+				}},
+			},
+			Timezone: tz,
+		}
+		if _, err := Filter(&query, []CalendarObject{{Data: cal}}); err == nil {
+			t.Errorf("Filter() with VTIMEZONE %v succeeded, want an error", tz.Props)
+		}
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"context"
 	"encoding/xml"
 	"fmt"
+	"io/ioutil"
 	"mime"
 	"net/http"
+	"net/url"
 	"path"
 	"strconv"
 	"strings"
@@ -44,11 +46,49 @@ type Backend interface {
 	webdav.UserPrincipalBackend
 }
 
+// MultiGetBackend is an optional interface a Backend can implement to load
+// multiple calendar objects at once when serving calendar-multiget requests,
+// instead of calling GetCalendarObject for each of them.
+type MultiGetBackend interface {
+	// GetCalendarObjectsByHref returns the calendar objects found at the
+	// provided paths. Paths which don't exist must be omitted from the
+	// result.
+	GetCalendarObjectsByHref(ctx context.Context, hrefs []string, req *CalendarCompRequest) ([]CalendarObject, error)
+}
+
+// SyncBackend is an optional interface a Backend can implement to support
+// sync-collection REPORT requests, as defined in RFC 6578.
+type SyncBackend interface {
+	// GetChangedObjects returns the calendar objects of a calendar which have
+	// changed since query.SyncToken, along with a new sync token. An empty
+	// sync token requests all calendar objects. If the sync token is invalid,
+	// an HTTP 403 error should be returned.
+	GetChangedObjects(ctx context.Context, path string, query *SyncQuery) (*SyncResponse, error)
+}
+
+// SchedulingBackend is an optional interface a Backend can implement to
+// support scheduling, as defined in RFC 6638.
+type SchedulingBackend interface {
+	// ScheduleInboxPath returns the path to the current user's scheduling
+	// Inbox.
+	ScheduleInboxPath(ctx context.Context) (string, error)
+	// ScheduleOutboxPath returns the path to the current user's scheduling
+	// Outbox.
+	ScheduleOutboxPath(ctx context.Context) (string, error)
+	// HandleItip delivers an iTIP message POSTed to a scheduling Outbox.
+	HandleItip(ctx context.Context, outbox string, msg []byte) (*SchedulingResponse, error)
+}
+
 // Handler handles CalDAV HTTP requests. It can be used to create a CalDAV
 // server.
 type Handler struct {
 	Backend Backend
 	Prefix  string
+
+	// WellKnownRedirect is the path requests to /.well-known/caldav are
+	// permanently redirected to, e.g. "/dav/". If empty, they're redirected
+	// to the current user's principal.
+	WellKnownRedirect string
 }
 
 // ServeHTTP implements http.Handler.
@@ -59,6 +99,11 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.URL.Path == "/.well-known/caldav" {
+		if h.WellKnownRedirect != "" {
+			http.Redirect(w, r, h.WellKnownRedirect, http.StatusMovedPermanently)
+			return
+		}
+
 		principalPath, err := h.Backend.CurrentUserPrincipal(r.Context())
 		if err != nil {
 			http.Error(w, "caldav: failed to determine current user principal", http.StatusInternalServerError)
@@ -73,6 +118,10 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "REPORT":
 		err = h.handleReport(w, r)
+	case "MKCALENDAR":
+		err = h.handleMkcalendar(w, r)
+	case http.MethodPost:
+		err = h.handlePost(w, r)
 	default:
 		b := backend{
 			Backend: h.Backend,
@@ -83,7 +132,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != nil {
-		internal.ServeError(w, err)
+		internal.ServeRequestError(w, r, err)
 	}
 }
 
@@ -97,8 +146,165 @@ func (h *Handler) handleReport(w http.ResponseWriter, r *http.Request) error {
 		return h.handleQuery(r, w, report.Query)
 	} else if report.Multiget != nil {
 		return h.handleMultiget(r.Context(), w, report.Multiget)
+	} else if report.SyncCollection != nil {
+		return h.handleSyncCollection(r, w, report.SyncCollection)
+	}
+	return internal.HTTPErrorf(http.StatusBadRequest, "caldav: expected calendar-query, calendar-multiget or sync-collection element in REPORT request")
+}
+
+func (h *Handler) handlePost(w http.ResponseWriter, r *http.Request) error {
+	sb, ok := h.Backend.(SchedulingBackend)
+	if !ok {
+		return internal.HTTPErrorf(http.StatusMethodNotAllowed, "caldav: scheduling not supported")
+	}
+
+	outbox, err := sb.ScheduleOutboxPath(r.Context())
+	if err != nil {
+		return err
+	}
+	if path.Clean(r.URL.Path) != path.Clean(outbox) {
+		return internal.HTTPErrorf(http.StatusMethodNotAllowed, "caldav: POST is only supported on the scheduling outbox")
+	}
+
+	t, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if t != ical.MIMEType {
+		return internal.HTTPErrorf(http.StatusUnsupportedMediaType, "caldav: unsupported iTIP message media type %q", t)
+	}
+
+	msg, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := sb.HandleItip(r.Context(), r.URL.Path, msg)
+	if err != nil {
+		return err
+	}
+
+	sr, err := encodeSchedulingResponse(resp)
+	if err != nil {
+		return err
+	}
+	return internal.ServeXML(w).Encode(sr)
+}
+
+func encodeSchedulingResponse(resp *SchedulingResponse) (*scheduleResponse, error) {
+	sr := scheduleResponse{Responses: make([]scheduleRecipientResponse, len(resp.Recipients))}
+	for i, rs := range resp.Recipients {
+		u, err := url.Parse(rs.Recipient)
+		if err != nil {
+			return nil, err
+		}
+
+		rr := &sr.Responses[i]
+		rr.Recipient.Href = internal.Href(*u)
+		rr.RequestStatus = rs.RequestStatus
+		rr.ResponseDescription = rs.Description
+		if rs.Data != nil {
+			var buf bytes.Buffer
+			if err := ical.NewEncoder(&buf).Encode(rs.Data); err != nil {
+				return nil, err
+			}
+			rr.CalendarData = &calendarDataResp{Data: buf.Bytes()}
+		}
+	}
+	return &sr, nil
+}
+
+func (h *Handler) handleMkcalendar(w http.ResponseWriter, r *http.Request) error {
+	b := backend{
+		Backend: h.Backend,
+		Prefix:  strings.TrimSuffix(h.Prefix, "/"),
+	}
+	if b.resourceTypeAtPath(r.URL.Path) != resourceTypeCalendar {
+		return internal.HTTPErrorf(http.StatusForbidden, "caldav: calendar creation not allowed at given location")
+	}
+
+	if _, err := h.Backend.GetCalendar(r.Context(), r.URL.Path); err == nil {
+		return internal.HTTPErrorf(http.StatusMethodNotAllowed, "caldav: resource already exists")
+	} else if !internal.IsNotFound(err) {
+		return err
+	}
+
+	cal := Calendar{Path: r.URL.Path}
+	if !internal.IsRequestBodyEmpty(r) {
+		var m mkcalendarReq
+		if err := internal.DecodeXMLRequest(r, &m); err != nil {
+			return internal.HTTPErrorf(http.StatusBadRequest, "caldav: error parsing mkcalendar request: %s", err.Error())
+		}
+		if m.Set != nil {
+			if err := decodeCalendarProps(&m.Set.Prop, &cal); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := h.Backend.CreateCalendar(r.Context(), &cal); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+func decodeCalendarProps(prop *internal.Prop, cal *Calendar) error {
+	var dispName internal.DisplayName
+	if err := prop.Decode(&dispName); err != nil && !internal.IsNotFound(err) {
+		return &internal.HTTPError{http.StatusBadRequest, err}
+	}
+	cal.Name = dispName.Name
+
+	var desc calendarDescription
+	if err := prop.Decode(&desc); err != nil && !internal.IsNotFound(err) {
+		return &internal.HTTPError{http.StatusBadRequest, err}
+	}
+	cal.Description = desc.Description
+
+	var maxResSize maxResourceSize
+	if err := prop.Decode(&maxResSize); err != nil && !internal.IsNotFound(err) {
+		return &internal.HTTPError{http.StatusBadRequest, err}
+	}
+	cal.MaxResourceSize = maxResSize.Size
+
+	var tz calendarTimezone
+	if err := prop.Decode(&tz); err != nil && !internal.IsNotFound(err) {
+		return &internal.HTTPError{http.StatusBadRequest, err}
+	}
+	if len(tz.Data) > 0 {
+		tzComp, err := decodeCalendarTimezone(&tz)
+		if err != nil {
+			return &internal.HTTPError{http.StatusBadRequest, err}
+		}
+		cal.Timezone = tzComp
 	}
-	return internal.HTTPErrorf(http.StatusBadRequest, "caldav: expected calendar-query or calendar-multiget element in REPORT request")
+
+	var calColor calendarColor
+	if err := prop.Decode(&calColor); err != nil && !internal.IsNotFound(err) {
+		return &internal.HTTPError{http.StatusBadRequest, err}
+	}
+	if calColor.Color != "" {
+		col, err := parseCalendarColor(calColor.Color)
+		if err != nil {
+			return &internal.HTTPError{http.StatusBadRequest, err}
+		}
+		cal.Color = col
+	}
+
+	var calOrder calendarOrder
+	if err := prop.Decode(&calOrder); err != nil && !internal.IsNotFound(err) {
+		return &internal.HTTPError{http.StatusBadRequest, err}
+	}
+	cal.Order = calOrder.Order
+
+	var compSet supportedCalendarComponentSet
+	if err := prop.Decode(&compSet); err != nil && !internal.IsNotFound(err) {
+		return &internal.HTTPError{http.StatusBadRequest, err}
+	}
+	for _, comp := range compSet.Comp {
+		cal.SupportedComponentSet = append(cal.SupportedComponentSet, comp.Name)
+	}
+
+	return nil
 }
 
 func decodeParamFilter(el *paramFilter) (*ParamFilter, error) {
@@ -181,6 +387,7 @@ func decodeComp(comp *comp) (*CalendarCompRequest, error) {
 	}
 
 	req := &CalendarCompRequest{
+		Name:     comp.Name,
 		AllProps: comp.Allprop != nil,
 		AllComps: comp.Allcomp != nil,
 	}
@@ -198,24 +405,135 @@ func decodeComp(comp *comp) (*CalendarCompRequest, error) {
 }
 
 func decodeCalendarDataReq(calendarData *calendarDataReq) (*CalendarCompRequest, error) {
+	var req *CalendarCompRequest
 	if calendarData.Comp == nil {
-		return &CalendarCompRequest{
+		req = &CalendarCompRequest{
 			AllProps: true,
 			AllComps: true,
-		}, nil
+		}
+	} else {
+		var err error
+		req, err = decodeComp(calendarData.Comp)
+		if err != nil {
+			return nil, err
+		}
 	}
-	return decodeComp(calendarData.Comp)
+
+	if calendarData.Expand != nil {
+		start, end := time.Time(calendarData.Expand.Start), time.Time(calendarData.Expand.End)
+		if start.IsZero() || end.IsZero() || !start.Before(end) {
+			return nil, internal.HTTPErrorf(http.StatusBadRequest, "caldav: invalid expand time range")
+		}
+		req.Expand = &CalendarExpandRequest{Start: start, End: end}
+	}
+	if calendarData.LimitRecurrenceSet != nil {
+		start, end := time.Time(calendarData.LimitRecurrenceSet.Start), time.Time(calendarData.LimitRecurrenceSet.End)
+		if start.IsZero() || end.IsZero() || !start.Before(end) {
+			return nil, internal.HTTPErrorf(http.StatusBadRequest, "caldav: invalid limit-recurrence-set time range")
+		}
+		req.LimitRecurrenceSet = &CalendarLimitRecurrenceSet{Start: start, End: end}
+	}
+	return req, nil
+}
+
+// requiredCompProps lists the properties which can't be omitted from a
+// component without making it invalid, as defined in RFC 5545 section 3.6.
+var requiredCompProps = map[string][]string{
+	ical.CompCalendar:         {ical.PropProductID, ical.PropVersion},
+	ical.CompEvent:            {ical.PropDateTimeStamp, ical.PropUID},
+	ical.CompToDo:             {ical.PropDateTimeStamp, ical.PropUID},
+	ical.CompJournal:          {ical.PropDateTimeStamp, ical.PropUID},
+	ical.CompFreeBusy:         {ical.PropDateTimeStamp, ical.PropUID},
+	ical.CompTimezone:         {ical.PropTimezoneID},
+	ical.CompTimezoneStandard: {ical.PropDateTimeStart, ical.PropTimezoneOffsetTo, ical.PropTimezoneOffsetFrom},
+	ical.CompTimezoneDaylight: {ical.PropDateTimeStart, ical.PropTimezoneOffsetTo, ical.PropTimezoneOffsetFrom},
+	ical.CompAlarm:            {ical.PropAction, ical.PropTrigger},
+}
+
+// filterCalendar returns a copy of cal only containing the components and
+// properties listed in req, as described in RFC 4791 section 9.6.1.
+// Properties required by RFC 5545 are always kept.
+func filterCalendar(cal *ical.Calendar, req *CalendarCompRequest) *ical.Calendar {
+	return &ical.Calendar{Component: filterComponent(cal.Component, req)}
+}
+
+func filterComponent(comp *ical.Component, req *CalendarCompRequest) *ical.Component {
+	// An empty comp element requests the whole component
+	if !req.AllProps && len(req.Props) == 0 && !req.AllComps && len(req.Comps) == 0 {
+		return comp
+	}
+
+	filtered := &ical.Component{Name: comp.Name, Props: make(ical.Props)}
+	if req.AllProps {
+		for name, props := range comp.Props {
+			filtered.Props[name] = props
+		}
+	} else {
+		// Copy the required properties, to avoid appending to the slice
+		// shared by all requests
+		required := requiredCompProps[comp.Name]
+		names := make([]string, 0, len(required)+len(req.Props))
+		names = append(names, required...)
+		names = append(names, req.Props...)
+		for _, name := range names {
+			name = strings.ToUpper(name)
+			if props, ok := comp.Props[name]; ok {
+				filtered.Props[name] = props
+			}
+		}
+	}
+
+	for _, child := range comp.Children {
+		if req.AllComps {
+			filtered.Children = append(filtered.Children, child)
+			continue
+		}
+		for i := range req.Comps {
+			if strings.EqualFold(req.Comps[i].Name, child.Name) {
+				filtered.Children = append(filtered.Children, filterComponent(child, &req.Comps[i]))
+				break
+			}
+		}
+	}
+	if comp.Name == ical.CompTimezone && len(filtered.Children) == 0 {
+		// A time zone is useless without its observances
+		filtered.Children = comp.Children
+	}
+	return filtered
 }
 
 func (h *Handler) handleQuery(r *http.Request, w http.ResponseWriter, query *calendarQuery) error {
 	var q CalendarQuery
-	// TODO: calendar-data in query.Prop
+	if query.Prop != nil {
+		var calendarData calendarDataReq
+		if err := query.Prop.Decode(&calendarData); err != nil && !internal.IsNotFound(err) {
+			return err
+		}
+		req, err := decodeCalendarDataReq(&calendarData)
+		if err != nil {
+			return err
+		}
+		q.CompRequest = *req
+	}
 	cf, err := decodeCompFilter(&query.Filter.CompFilter)
 	if err != nil {
 		return err
 	}
 	q.CompFilter = *cf
 
+	if query.Timezone != nil {
+		q.Timezone, err = decodeCalendarTimezone(&calendarTimezone{Data: query.Timezone.Data})
+		if err != nil {
+			return &internal.HTTPError{Code: http.StatusBadRequest, Err: err}
+		}
+		if _, err := timezoneLocation(q.Timezone); err != nil {
+			return &internal.HTTPError{Code: http.StatusBadRequest, Err: err}
+		}
+	} else if cal, err := h.Backend.GetCalendar(r.Context(), r.URL.Path); err == nil {
+		// Errors are reported by QueryCalendarObjects below
+		q.Timezone = cal.Timezone
+	}
+
 	cos, err := h.Backend.QueryCalendarObjects(r.Context(), r.URL.Path, &q)
 	if err != nil {
 		return err
@@ -258,9 +576,34 @@ func (h *Handler) handleMultiget(ctx context.Context, w http.ResponseWriter, mul
 		dataReq = *decoded
 	}
 
+	getCalendarObject := func(path string) (*CalendarObject, error) {
+		return h.Backend.GetCalendarObject(ctx, path, &dataReq)
+	}
+	if mb, ok := h.Backend.(MultiGetBackend); ok {
+		paths := make([]string, len(multiget.Hrefs))
+		for i, href := range multiget.Hrefs {
+			paths[i] = href.Path
+		}
+		cos, err := mb.GetCalendarObjectsByHref(ctx, paths, &dataReq)
+		if err != nil {
+			return err
+		}
+		byPath := make(map[string]*CalendarObject, len(cos))
+		for i := range cos {
+			byPath[cos[i].Path] = &cos[i]
+		}
+		getCalendarObject = func(path string) (*CalendarObject, error) {
+			co, ok := byPath[path]
+			if !ok {
+				return nil, internal.HTTPErrorf(http.StatusNotFound, "caldav: calendar object not found")
+			}
+			return co, nil
+		}
+	}
+
 	var resps []internal.Response
 	for _, href := range multiget.Hrefs {
-		co, err := h.Backend.GetCalendarObject(ctx, href.Path, &dataReq)
+		co, err := getCalendarObject(href.Path)
 		if err != nil {
 			resp := internal.NewErrorResponse(href.Path, err)
 			resps = append(resps, *resp)
@@ -287,6 +630,66 @@ func (h *Handler) handleMultiget(ctx context.Context, w http.ResponseWriter, mul
 	return internal.ServeMultiStatus(w, ms)
 }
 
+func (h *Handler) handleSyncCollection(r *http.Request, w http.ResponseWriter, sync *internal.SyncCollectionQuery) error {
+	sb, ok := h.Backend.(SyncBackend)
+	if !ok {
+		return internal.HTTPErrorf(http.StatusForbidden, "caldav: sync-collection REPORT not supported")
+	}
+
+	switch sync.SyncLevel {
+	case internal.SyncLevelOne, internal.SyncLevelInfinite:
+		// Calendars cannot contain collections, both levels are equivalent
+	default:
+		return internal.HTTPErrorf(http.StatusBadRequest, "caldav: invalid sync-level %q", sync.SyncLevel)
+	}
+
+	q := SyncQuery{SyncToken: sync.SyncToken}
+	if sync.Limit != nil {
+		q.Limit = int(sync.Limit.NResults)
+	}
+	if sync.Prop != nil {
+		var calendarData calendarDataReq
+		if err := sync.Prop.Decode(&calendarData); err != nil && !internal.IsNotFound(err) {
+			return err
+		}
+		req, err := decodeCalendarDataReq(&calendarData)
+		if err != nil {
+			return err
+		}
+		q.CompRequest = *req
+	}
+
+	res, err := sb.GetChangedObjects(r.Context(), r.URL.Path, &q)
+	if err != nil {
+		return err
+	}
+
+	b := backend{
+		Backend: h.Backend,
+		Prefix:  strings.TrimSuffix(h.Prefix, "/"),
+	}
+	propfind := internal.PropFind{Prop: sync.Prop}
+
+	var resps []internal.Response
+	for _, co := range res.Updated {
+		resp, err := b.propFindCalendarObject(r.Context(), &propfind, &co)
+		if err != nil {
+			return err
+		}
+		resps = append(resps, *resp)
+	}
+	for _, p := range res.Deleted {
+		resps = append(resps, internal.Response{
+			Hrefs:  []internal.Href{{Path: p}},
+			Status: &internal.Status{Code: http.StatusNotFound},
+		})
+	}
+
+	ms := internal.NewMultiStatus(resps...)
+	ms.SyncToken = res.SyncToken
+	return internal.ServeMultiStatus(w, ms)
+}
+
 type backend struct {
 	Backend Backend
 	Prefix  string
@@ -315,10 +718,12 @@ func (b *backend) resourceTypeAtPath(reqPath string) resourceType {
 }
 
 func (b *backend) Options(r *http.Request) (caps []string, allow []string, err error) {
+	// "calendar-auto-schedule" isn't advertised even if the backend supports
+	// scheduling: implicit scheduling isn't implemented
 	caps = []string{"calendar-access"}
 
 	if b.resourceTypeAtPath(r.URL.Path) != resourceTypeCalendarObject {
-		return caps, []string{http.MethodOptions, "PROPFIND", "REPORT", "DELETE", "MKCOL"}, nil
+		return caps, []string{http.MethodOptions, "PROPFIND", "REPORT", "DELETE", "MKCOL", "MKCALENDAR"}, nil
 	}
 
 	var dataReq CalendarCompRequest
@@ -496,6 +901,24 @@ func (b *backend) propFindUserPrincipal(ctx context.Context, propfind *internal.
 			return internal.NewResourceType(internal.CollectionName), nil
 		},
 	}
+
+	if sb, ok := b.Backend.(SchedulingBackend); ok {
+		props[scheduleInboxURLName] = func(*internal.RawXMLValue) (interface{}, error) {
+			p, err := sb.ScheduleInboxPath(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return &scheduleInboxURL{Href: internal.Href{Path: p}}, nil
+		}
+		props[scheduleOutboxURLName] = func(*internal.RawXMLValue) (interface{}, error) {
+			p, err := sb.ScheduleOutboxPath(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return &scheduleOutboxURL{Href: internal.Href{Path: p}}, nil
+		}
+	}
+
 	return internal.NewPropFindResponse(principalPath, propfind, props)
 }
 
@@ -573,11 +996,33 @@ func (b *backend) propFindCalendar(ctx context.Context, propfind *internal.PropF
 			return &maxResourceSize{Size: cal.MaxResourceSize}, nil
 		}
 	}
+	if cal.Timezone != nil {
+		props[calendarTimezoneName] = func(*internal.RawXMLValue) (interface{}, error) {
+			return encodeCalendarTimezone(cal.Timezone)
+		}
+	}
+	if cal.Color != nil {
+		props[calendarColorName] = func(*internal.RawXMLValue) (interface{}, error) {
+			return &calendarColor{Color: formatCalendarColor(cal.Color)}, nil
+		}
+	}
+	if cal.Order != 0 {
+		props[calendarOrderName] = func(*internal.RawXMLValue) (interface{}, error) {
+			return &calendarOrder{Order: cal.Order}, nil
+		}
+	}
 	props[internal.CurrentUserPrivilegeSetName] = func(*internal.RawXMLValue) (interface{}, error) {
 		return &internal.CurrentUserPrivilegeSet{Privilege: internal.NewAllPrivileges()}, nil
 	}
+	props[internal.SupportedReportSetName] = func(*internal.RawXMLValue) (interface{}, error) {
+		reports := []xml.Name{calendarQueryName, calendarMultigetName}
+		if _, ok := b.Backend.(SyncBackend); ok {
+			reports = append(reports, internal.SyncCollectionName)
+		}
+		return internal.NewSupportedReportSet(reports...), nil
+	}
 
-	// TODO: CALDAV:calendar-timezone, CALDAV:supported-calendar-component-set, CALDAV:min-date-time, CALDAV:max-date-time, CALDAV:max-instances, CALDAV:max-attendees-per-instance
+	// TODO: CALDAV:supported-calendar-component-set, CALDAV:min-date-time, CALDAV:max-date-time, CALDAV:max-instances, CALDAV:max-attendees-per-instance
 
 	return internal.NewPropFindResponse(cal.Path, propfind, props)
 }
@@ -619,9 +1064,36 @@ func (b *backend) propFindCalendarObject(ctx context.Context, propfind *internal
 			return &internal.GetContentType{Type: ical.MIMEType}, nil
 		},
 		// TODO: calendar-data can only be used in REPORT requests
-		calendarDataName: func(*internal.RawXMLValue) (interface{}, error) {
+		calendarDataName: func(raw *internal.RawXMLValue) (interface{}, error) {
+			var dataReq calendarDataReq
+			if err := raw.Decode(&dataReq); err != nil {
+				return nil, &internal.HTTPError{Code: http.StatusBadRequest, Err: err}
+			}
+
+			cal := co.Data
+			if dataReq.Expand != nil {
+				var err error
+				cal, err = expandCalendar(cal, time.Time(dataReq.Expand.Start), time.Time(dataReq.Expand.End))
+				if err != nil {
+					return nil, err
+				}
+			} else if dataReq.LimitRecurrenceSet != nil {
+				var err error
+				cal, err = limitRecurrenceSetCalendar(cal, time.Time(dataReq.LimitRecurrenceSet.Start), time.Time(dataReq.LimitRecurrenceSet.End))
+				if err != nil {
+					return nil, err
+				}
+			}
+			if dataReq.Comp != nil {
+				compReq, err := decodeComp(dataReq.Comp)
+				if err != nil {
+					return nil, err
+				}
+				cal = filterCalendar(cal, compReq)
+			}
+
 			var buf bytes.Buffer
-			if err := ical.NewEncoder(&buf).Encode(co.Data); err != nil {
+			if err := ical.NewEncoder(&buf).Encode(cal); err != nil {
 				return nil, err
 			}
 
@@ -3,14 +3,18 @@ package caldav
 import (
 	"context"
 	"fmt"
+	"image/color"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/emersion/go-ical"
+	"github.com/emersion/go-webdav"
 )
 
 var propFindSupportedCalendarComponentRequest = `
@@ -196,7 +200,7 @@ func (t testBackend) GetCalendar(ctx context.Context, path string) (*Calendar, e
 			return &cal, nil
 		}
 	}
-	return nil, fmt.Errorf("Calendar for path: %s not found", path)
+	return nil, webdav.NewHTTPError(http.StatusNotFound, fmt.Errorf("Calendar for path: %s not found", path))
 }
 
 func (t testBackend) CalendarHomeSetPath(ctx context.Context) (string, error) {
@@ -233,3 +237,764 @@ func (t testBackend) ListCalendarObjects(ctx context.Context, path string, req *
 func (t testBackend) QueryCalendarObjects(ctx context.Context, path string, query *CalendarQuery) ([]CalendarObject, error) {
 	return nil, nil
 }
+
+var mkcalendarRequest = `
+<?xml version="1.0" encoding="UTF-8"?>
+<C:mkcalendar xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:set>
+    <D:prop>
+      <D:displayname>Lisa's Events</D:displayname>
+      <C:supported-calendar-component-set>
+        <C:comp name="VEVENT"/>
+      </C:supported-calendar-component-set>
+    </D:prop>
+  </D:set>
+</C:mkcalendar>
+`
+
+func TestMkcalendar(t *testing.T) {
+	handler := Handler{Backend: testBackend{
+		calendars: []Calendar{{Path: "/user/calendars/existing"}},
+	}}
+
+	for _, tc := range []struct {
+		path string
+		want int
+	}{
+		{"/user/calendars/new", http.StatusCreated},
+		{"/user/calendars/existing", http.StatusMethodNotAllowed},
+	} {
+		req := httptest.NewRequest("MKCALENDAR", tc.path, strings.NewReader(mkcalendarRequest))
+		req.Header.Set("Content-Type", "application/xml")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if got := w.Result().StatusCode; got != tc.want {
+			t.Errorf("MKCALENDAR %v: got status %v, want %v", tc.path, got, tc.want)
+		}
+	}
+
+	handler = Handler{Backend: failingCalendarBackend{}}
+	req := httptest.NewRequest("MKCALENDAR", "/user/calendars/new", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if got := w.Result().StatusCode; got != http.StatusServiceUnavailable {
+		t.Errorf("MKCALENDAR with a failing backend: got status %v, want %v", got, http.StatusServiceUnavailable)
+	}
+}
+
+type failingCalendarBackend struct {
+	testBackend
+}
+
+func (failingCalendarBackend) GetCalendar(ctx context.Context, path string) (*Calendar, error) {
+	return nil, webdav.NewHTTPError(http.StatusServiceUnavailable, fmt.Errorf("backend unavailable"))
+}
+
+func (failingCalendarBackend) CreateCalendar(ctx context.Context, calendar *Calendar) error {
+	return fmt.Errorf("CreateCalendar() called despite GetCalendar() failing")
+}
+
+func TestClientGetCalendarTimezone(t *testing.T) {
+	tz := ical.NewComponent(ical.CompTimezone)
+	tz.Props.SetText(ical.PropTimezoneID, "Europe/Paris")
+	standard := ical.NewComponent(ical.CompTimezoneStandard)
+	standard.Props.SetText(ical.PropDateTimeStart, "19701025T030000")
+	standard.Props.SetText(ical.PropTimezoneOffsetFrom, "+0200")
+	standard.Props.SetText(ical.PropTimezoneOffsetTo, "+0100")
+	tz.Children = []*ical.Component{standard}
+
+	srv := httptest.NewServer(&Handler{Backend: testBackend{
+		calendars: []Calendar{
+			{Path: "/user/calendars/a", Timezone: tz},
+			{Path: "/user/calendars/b"},
+		},
+	}})
+	defer srv.Close()
+
+	client, err := NewClient(nil, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := client.GetCalendarTimezone(context.Background(), "/user/calendars/a")
+	if err != nil {
+		t.Fatalf("GetCalendarTimezone() = %v", err)
+	}
+	if tzid, _ := got.Props.Text(ical.PropTimezoneID); got.Name != ical.CompTimezone || tzid != "Europe/Paris" {
+		t.Errorf("GetCalendarTimezone() = %v %q, want VTIMEZONE Europe/Paris", got.Name, tzid)
+	}
+
+	got, err = client.GetCalendarTimezone(context.Background(), "/user/calendars/b")
+	if err != nil || got != nil {
+		t.Errorf("GetCalendarTimezone() = %v, %v, want nil", got, err)
+	}
+}
+
+func TestClientMakeCalendar(t *testing.T) {
+	srv := httptest.NewServer(&Handler{Backend: testBackend{
+		calendars: []Calendar{{Path: "/user/calendars/existing"}},
+	}})
+	defer srv.Close()
+
+	client, err := NewClient(nil, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cal := &Calendar{Name: "Tasks", SupportedComponentSet: []string{ical.CompToDo}}
+	if err := client.MakeCalendar(context.Background(), "/user/calendars/new", cal); err != nil {
+		t.Errorf("MakeCalendar() = %v", err)
+	}
+	if err := client.MakeCalendar(context.Background(), "/user/calendars/existing", cal); err != ErrCalendarExists {
+		t.Errorf("MakeCalendar() = %v, want ErrCalendarExists", err)
+	}
+}
+
+type testMultiGetBackend struct {
+	testBackend
+	calls int
+}
+
+func (t *testMultiGetBackend) GetCalendarObjectsByHref(ctx context.Context, hrefs []string, req *CalendarCompRequest) ([]CalendarObject, error) {
+	t.calls++
+	var l []CalendarObject
+	for _, href := range hrefs {
+		if co, err := t.GetCalendarObject(ctx, href, req); err == nil {
+			l = append(l, *co)
+		}
+	}
+	return l, nil
+}
+
+func TestMultiGetBackend(t *testing.T) {
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, "-//xyz Corp//NONSGML PDA Calendar Version 1.0//EN")
+	event := ical.NewEvent()
+	event.Props.SetText(ical.PropUID, "46bbf47a-1861-41a3-ae06-8d8268c6d41e")
+	event.Props.SetDateTime(ical.PropDateTimeStamp, time.Now())
+	cal.Children = []*ical.Component{event.Component}
+	object := CalendarObject{Path: "/user/calendars/a/test.ics", Data: cal}
+
+	backend := &testMultiGetBackend{testBackend: testBackend{
+		calendars: []Calendar{{Path: "/user/calendars/a"}},
+		objectMap: map[string][]CalendarObject{"/user/calendars/a": {object}},
+	}}
+	handler := Handler{Backend: backend}
+
+	body := `<?xml version="1.0" encoding="UTF-8"?>
+<B:calendar-multiget xmlns:A="DAV:" xmlns:B="urn:ietf:params:xml:ns:caldav">
+  <A:prop><B:calendar-data/></A:prop>
+  <A:href>/user/calendars/a/test.ics</A:href>
+  <A:href>/user/calendars/a/missing.ics</A:href>
+</B:calendar-multiget>`
+	req := httptest.NewRequest("REPORT", "/user/calendars/a", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/xml")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if backend.calls != 1 {
+		t.Errorf("GetCalendarObjectsByHref called %v times, want 1", backend.calls)
+	}
+	resp := w.Body.String()
+	if !strings.Contains(resp, "PRODID:-//xyz Corp") {
+		t.Errorf("calendar data not returned in response:\n%v", resp)
+	}
+	if !strings.Contains(resp, "404 Not Found") {
+		t.Errorf("missing calendar object not reported in response:\n%v", resp)
+	}
+}
+
+func TestClientMultiGetCalendar(t *testing.T) {
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, "-//xyz Corp//NONSGML PDA Calendar Version 1.0//EN")
+	event := ical.NewEvent()
+	event.Props.SetText(ical.PropUID, "46bbf47a-1861-41a3-ae06-8d8268c6d41e")
+	event.Props.SetDateTime(ical.PropDateTimeStamp, time.Now())
+	cal.Children = []*ical.Component{event.Component}
+	object := CalendarObject{Path: "/user/calendars/a/test.ics", Data: cal}
+
+	backend := &testMultiGetBackend{testBackend: testBackend{
+		calendars: []Calendar{{Path: "/user/calendars/a"}},
+		objectMap: map[string][]CalendarObject{"/user/calendars/a": {object}},
+	}}
+	srv := httptest.NewServer(&Handler{Backend: backend})
+	defer srv.Close()
+
+	client, err := NewClient(nil, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cos, err := client.MultiGetCalendar(context.Background(), "/user/calendars/a", &CalendarMultiGet{
+		Paths:       []string{"/user/calendars/a/test.ics", "/user/calendars/a/missing.ics"},
+		CompRequest: CalendarCompRequest{AllProps: true, AllComps: true},
+	})
+	multiGetErr, ok := err.(*MultiGetError)
+	if !ok {
+		t.Fatalf("MultiGetCalendar() = %v, want a *MultiGetError", err)
+	}
+	if len(multiGetErr.Errors) != 1 || multiGetErr.Errors[0].Path != "/user/calendars/a/missing.ics" {
+		t.Errorf("MultiGetError.Errors = %+v, want missing.ics", multiGetErr.Errors)
+	}
+	if len(cos) != 1 || cos[0].Path != "/user/calendars/a/test.ics" {
+		t.Errorf("MultiGetCalendar() = %+v, want test.ics", cos)
+	}
+}
+
+type testSyncBackend struct {
+	testBackend
+}
+
+func (t testSyncBackend) GetChangedObjects(ctx context.Context, path string, query *SyncQuery) (*SyncResponse, error) {
+	if query.SyncToken == "" {
+		return &SyncResponse{SyncToken: "token-1", Updated: t.objectMap[path]}, nil
+	}
+	return &SyncResponse{SyncToken: "token-2", Deleted: []string{path + "/deleted.ics"}}, nil
+}
+
+func TestSyncCollection(t *testing.T) {
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, "-//xyz Corp//NONSGML PDA Calendar Version 1.0//EN")
+	event := ical.NewEvent()
+	event.Props.SetText(ical.PropUID, "46bbf47a-1861-41a3-ae06-8d8268c6d41e")
+	event.Props.SetDateTime(ical.PropDateTimeStamp, time.Now())
+	cal.Children = []*ical.Component{event.Component}
+
+	calendar := Calendar{Path: "/user/calendars/a"}
+	object := CalendarObject{Path: "/user/calendars/a/test.ics", ETag: "abc", Data: cal}
+	h := Handler{Backend: testSyncBackend{testBackend{
+		calendars: []Calendar{calendar},
+		objectMap: map[string][]CalendarObject{calendar.Path: {object}},
+	}}}
+	ts := httptest.NewServer(&h)
+	defer ts.Close()
+
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := client.SyncCollection(context.Background(), calendar.Path, &SyncQuery{})
+	if err != nil {
+		t.Fatalf("SyncCollection() = %v", err)
+	}
+	if res.SyncToken != "token-1" || len(res.Updated) != 1 || res.Updated[0].Path != object.Path || res.Updated[0].ETag != object.ETag {
+		t.Errorf("SyncCollection() = %+v, want a single updated object", res)
+	}
+
+	res, err = client.SyncCollection(context.Background(), calendar.Path, &SyncQuery{SyncToken: res.SyncToken})
+	if err != nil {
+		t.Fatalf("SyncCollection() = %v", err)
+	}
+	if res.SyncToken != "token-2" || len(res.Updated) != 0 || len(res.Deleted) != 1 {
+		t.Errorf("SyncCollection() = %+v, want a single deleted object", res)
+	}
+}
+
+type testSchedulingBackend struct {
+	testBackend
+	outbox string
+	msg    []byte
+}
+
+func (t *testSchedulingBackend) ScheduleInboxPath(ctx context.Context) (string, error) {
+	return "/user/inbox/", nil
+}
+
+func (t *testSchedulingBackend) ScheduleOutboxPath(ctx context.Context) (string, error) {
+	return "/user/outbox/", nil
+}
+
+func (t *testSchedulingBackend) HandleItip(ctx context.Context, outbox string, msg []byte) (*SchedulingResponse, error) {
+	t.outbox = outbox
+	t.msg = msg
+	return &SchedulingResponse{Recipients: []RecipientStatus{
+		{Recipient: "mailto:bob@example.org", RequestStatus: "2.0;Success"},
+		{Recipient: "mailto:eve@example.org", RequestStatus: "3.7;Invalid calendar user"},
+	}}, nil
+}
+
+var itipRequest = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+METHOD:REQUEST
+BEGIN:VEVENT
+UID:4FD3AD926350
+DTSTAMP:20090602T190221Z
+DTSTART:20090602T160000Z
+DTEND:20090602T170000Z
+SUMMARY:Design meeting
+ORGANIZER:mailto:alice@example.org
+ATTENDEE;PARTSTAT=NEEDS-ACTION:mailto:bob@example.org
+ATTENDEE;PARTSTAT=NEEDS-ACTION:mailto:eve@example.org
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestClientSendSchedulingRequest(t *testing.T) {
+	backend := &testSchedulingBackend{}
+	srv := httptest.NewServer(&Handler{Backend: backend})
+	defer srv.Close()
+
+	client, err := NewClient(nil, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.SendSchedulingRequest(context.Background(), "/user/outbox/", []byte(itipRequest))
+	if err != nil {
+		t.Fatalf("SendSchedulingRequest() = %v", err)
+	}
+	if backend.outbox != "/user/outbox/" || string(backend.msg) != itipRequest {
+		t.Errorf("HandleItip() called with outbox %q and message:\n%s", backend.outbox, backend.msg)
+	}
+	if len(resp.Recipients) != 2 {
+		t.Fatalf("SchedulingResponse.Recipients = %+v, want 2 recipients", resp.Recipients)
+	}
+	if rs := resp.Recipients[1]; rs.Recipient != "mailto:eve@example.org" || rs.RequestStatus != "3.7;Invalid calendar user" {
+		t.Errorf("SchedulingResponse.Recipients[1] = %+v", rs)
+	}
+
+	if _, err := client.SendSchedulingRequest(context.Background(), "/user/calendars/", []byte(itipRequest)); err == nil {
+		t.Errorf("SendSchedulingRequest() to a calendar = nil, want an error")
+	}
+
+	propfind := `<?xml version="1.0" encoding="UTF-8"?>
+<D:propfind xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><C:schedule-outbox-URL/></D:prop>
+</D:propfind>`
+	req := httptest.NewRequest("PROPFIND", "/user/", strings.NewReader(propfind))
+	req.Header.Set("Content-Type", "application/xml")
+	w := httptest.NewRecorder()
+	(&Handler{Backend: backend}).ServeHTTP(w, req)
+	if body := w.Body.String(); !strings.Contains(body, `<href xmlns="DAV:">/user/outbox/</href>`) {
+		t.Errorf("schedule-outbox-URL missing from the principal PROPFIND response:\n%s", body)
+	}
+}
+
+func TestClientScheduleSend(t *testing.T) {
+	backend := &testSchedulingBackend{}
+	srv := httptest.NewServer(&Handler{Backend: backend})
+	defer srv.Close()
+
+	client, err := NewClient(nil, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := ical.NewDecoder(strings.NewReader(strings.ReplaceAll(itipRequest, "\n", "\r\n"))).Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recipients := []string{"mailto:bob@example.org", "mailto:eve@example.org"}
+	statuses, err := client.ScheduleSend(context.Background(), "/user/outbox/", msg, "mailto:alice@example.org", recipients)
+	if err != nil {
+		t.Fatalf("ScheduleSend() = %v", err)
+	}
+	if len(statuses) != 2 || statuses[0].RequestStatus != "2.0;Success" || statuses[1].RequestStatus != "3.7;Invalid calendar user" {
+		t.Errorf("ScheduleSend() = %+v", statuses)
+	}
+
+	sent, err := ical.NewDecoder(strings.NewReader(string(backend.msg))).Decode()
+	if err != nil {
+		t.Fatalf("failed to decode the iTIP message received by the server: %v", err)
+	}
+	if method, _ := sent.Props.Text(ical.PropMethod); method != "REQUEST" {
+		t.Errorf("METHOD = %q, want %q", method, "REQUEST")
+	}
+}
+
+func TestClientMultiGetCalendarCompRequest(t *testing.T) {
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, "-//xyz Corp//NONSGML PDA Calendar Version 1.0//EN")
+	event := ical.NewEvent()
+	event.Props.SetText(ical.PropUID, "46bbf47a-1861-41a3-ae06-8d8268c6d41e")
+	event.Props.SetDateTime(ical.PropDateTimeStamp, time.Now())
+	event.Props.SetText(ical.PropSummary, "Design meeting")
+	event.Props.SetText(ical.PropDescription, "A very long description")
+	alarm := ical.NewComponent(ical.CompAlarm)
+	alarm.Props.SetText(ical.PropAction, "DISPLAY")
+	event.Children = []*ical.Component{alarm}
+	todo := ical.NewComponent(ical.CompToDo)
+	todo.Props.SetText(ical.PropUID, "46bbf47a-1861-41a3-ae06-8d8268c6d41e")
+	cal.Children = []*ical.Component{event.Component, todo}
+	object := CalendarObject{Path: "/user/calendars/a/test.ics", Data: cal}
+
+	srv := httptest.NewServer(&Handler{Backend: testBackend{
+		calendars: []Calendar{{Path: "/user/calendars/a"}},
+		objectMap: map[string][]CalendarObject{"/user/calendars/a": {object}},
+	}})
+	defer srv.Close()
+
+	client, err := NewClient(nil, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cos, err := client.MultiGetCalendar(context.Background(), "/user/calendars/a", &CalendarMultiGet{
+		Paths: []string{"/user/calendars/a/test.ics"},
+		CompRequest: CalendarCompRequest{
+			Name: ical.CompCalendar,
+			Comps: []CalendarCompRequest{{
+				Name:  ical.CompEvent,
+				Props: []string{ical.PropSummary},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("MultiGetCalendar() = %v", err)
+	}
+	if len(cos) != 1 {
+		t.Fatalf("MultiGetCalendar() = %+v, want a single calendar object", cos)
+	}
+
+	got := cos[0].Data
+	if len(got.Children) != 1 || got.Children[0].Name != ical.CompEvent {
+		t.Fatalf("calendar children = %+v, want a single VEVENT", got.Children)
+	}
+	gotEvent := got.Children[0]
+	if len(gotEvent.Children) != 0 {
+		t.Errorf("VEVENT children = %+v, want none", gotEvent.Children)
+	}
+	if gotEvent.Props.Get(ical.PropSummary) == nil {
+		t.Errorf("VEVENT is missing SUMMARY")
+	}
+	if gotEvent.Props.Get(ical.PropDescription) != nil {
+		t.Errorf("VEVENT has an unrequested DESCRIPTION")
+	}
+	if gotEvent.Props.Get(ical.PropUID) == nil {
+		t.Errorf("VEVENT is missing the required UID")
+	}
+}
+
+func TestWellKnownRedirect(t *testing.T) {
+	for _, tc := range []struct {
+		handler Handler
+		code    int
+		target  string
+	}{
+		{Handler{Backend: testBackend{}}, http.StatusPermanentRedirect, "/user/"},
+		{Handler{Backend: testBackend{}, WellKnownRedirect: "/dav/"}, http.StatusMovedPermanently, "/dav/"},
+	} {
+		req := httptest.NewRequest("PROPFIND", "/.well-known/caldav", nil)
+		w := httptest.NewRecorder()
+		tc.handler.ServeHTTP(w, req)
+
+		if w.Code != tc.code {
+			t.Errorf("status = %v, want %v", w.Code, tc.code)
+		}
+		if loc := w.Header().Get("Location"); loc != tc.target {
+			t.Errorf("Location = %q, want %q", loc, tc.target)
+		}
+	}
+}
+
+const managedAttachmentEvent = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VEVENT
+UID:20010712T182145Z-123401@example.com
+DTSTAMP:20060206T001220Z
+DTSTART:20060104T140000Z
+SUMMARY:Event with attachment
+ATTACH;MANAGED-ID=97S;FMTTYPE=image/png;SIZE=4;FILENAME=image.png:https://attachments.example.com/97S
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestClientManagedAttachments(t *testing.T) {
+	var actions []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PROPFIND":
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusMultiStatus)
+			io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?>
+<D:multistatus xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:response>
+    <D:href>/user/calendars/</D:href>
+    <D:propstat>
+      <D:prop><C:managed-attachments-server-URL><D:href>https://attachments.example.com/</D:href></C:managed-attachments-server-URL></D:prop>
+      <D:status>HTTP/1.1 200 OK</D:status>
+    </D:propstat>
+  </D:response>
+</D:multistatus>`)
+		case http.MethodGet:
+			w.Header().Set("Content-Type", ical.MIMEType)
+			io.WriteString(w, strings.ReplaceAll(managedAttachmentEvent, "\n", "\r\n"))
+		case http.MethodPost:
+			b, _ := ioutil.ReadAll(r.Body)
+			q := r.URL.Query()
+			actions = append(actions, fmt.Sprintf("%v %v %v %s", q.Get("action"), q.Get("managed-id"), r.Header.Get("Content-Type"), b))
+			switch q.Get("action") {
+			case "attachment-add":
+				w.Header().Set("Cal-Managed-ID", "97S")
+				w.Header().Set("Content-Type", ical.MIMEType)
+				w.WriteHeader(http.StatusCreated)
+				io.WriteString(w, strings.ReplaceAll(managedAttachmentEvent, "\n", "\r\n"))
+			case "attachment-update":
+				// Doesn't honor Prefer: return=representation
+				w.Header().Set("Cal-Managed-ID", "97S")
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				w.WriteHeader(http.StatusNoContent)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(nil, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	serverURL, err := client.FindManagedAttachmentsServerURL(ctx, "/user/calendars/")
+	if err != nil {
+		t.Fatalf("FindManagedAttachmentsServerURL() = %v", err)
+	} else if serverURL != "https://attachments.example.com/" {
+		t.Errorf("FindManagedAttachmentsServerURL() = %q", serverURL)
+	}
+
+	want := Attachment{
+		ManagedID:   "97S",
+		URL:         "https://attachments.example.com/97S",
+		ContentType: "image/png",
+		Filename:    "image.png",
+		Size:        4,
+	}
+	att, err := client.AddAttachment(ctx, "/user/calendars/event.ics", strings.NewReader("data"), "image/png")
+	if err != nil {
+		t.Fatalf("AddAttachment() = %v", err)
+	} else if *att != want {
+		t.Errorf("AddAttachment() = %+v, want %+v", att, want)
+	}
+
+	att, err = client.UpdateAttachment(ctx, "/user/calendars/event.ics", "97S", strings.NewReader("data"), "image/png")
+	if err != nil {
+		t.Fatalf("UpdateAttachment() = %v", err)
+	} else if *att != want {
+		t.Errorf("UpdateAttachment() = %+v, want %+v", att, want)
+	}
+
+	if err := client.RemoveAttachment(ctx, "/user/calendars/event.ics", "97S"); err != nil {
+		t.Fatalf("RemoveAttachment() = %v", err)
+	}
+
+	wantActions := []string{
+		"attachment-add  image/png data",
+		"attachment-update 97S image/png data",
+		"attachment-remove 97S  ",
+	}
+	if !reflect.DeepEqual(actions, wantActions) {
+		t.Errorf("POST requests = %q, want %q", actions, wantActions)
+	}
+}
+
+const findCalendarsMultiStatus = `<?xml version="1.0" encoding="UTF-8"?>
+<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" xmlns:a="http://apple.com/ns/ical/">
+	<d:response>
+		<d:href>/user/calendars/a/</d:href>
+		<d:propstat>
+			<d:prop>
+				<d:resourcetype><d:collection/><c:calendar/></d:resourcetype>
+				<d:displayname>A</d:displayname>
+				<c:calendar-timezone>%v</c:calendar-timezone>
+				<a:calendar-color>%v</a:calendar-color>
+			</d:prop>
+			<d:status>HTTP/1.1 200 OK</d:status>
+		</d:propstat>
+	</d:response>
+	<d:response>
+		<d:href>/user/calendars/b/</d:href>
+		<d:propstat>
+			<d:prop>
+				<d:resourcetype><d:collection/><c:calendar/></d:resourcetype>
+				<d:displayname>B</d:displayname>
+			</d:prop>
+			<d:status>HTTP/1.1 200 OK</d:status>
+		</d:propstat>
+	</d:response>
+</d:multistatus>`
+
+func findCalendarsWithProps(t *testing.T, timezone, color string) []Calendar {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, findCalendarsMultiStatus, timezone, color)
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(nil, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cals, err := client.FindCalendars(context.Background(), "/user/calendars/")
+	if err != nil {
+		t.Fatalf("FindCalendars() = %v", err)
+	}
+	if len(cals) != 2 {
+		t.Fatalf("FindCalendars() returned %v calendars, want 2", len(cals))
+	}
+	return cals
+}
+
+func TestClientFindCalendarsMalformedTimezone(t *testing.T) {
+	for _, timezone := range []string{
+		"not an iCalendar object",
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\nEND:VCALENDAR\r\n",
+	} {
+		cals := findCalendarsWithProps(t, timezone, "#FF0000")
+		if cals[0].Name != "A" || cals[0].Timezone != nil {
+			t.Errorf("calendar = %v with timezone %v, want A without timezone", cals[0].Name, cals[0].Timezone)
+		}
+	}
+
+	const timezone = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\nBEGIN:VTIMEZONE\r\nTZID:Europe/Paris\r\nEND:VTIMEZONE\r\nEND:VCALENDAR\r\n"
+	cals := findCalendarsWithProps(t, timezone, "#FF0000")
+	if tz := cals[0].Timezone; tz == nil {
+		t.Errorf("calendar timezone = nil, want Europe/Paris")
+	} else if tzid, _ := tz.Props.Text(ical.PropTimezoneID); tzid != "Europe/Paris" {
+		t.Errorf("calendar timezone = %q, want Europe/Paris", tzid)
+	}
+}
+
+func TestClientFindCalendarsMalformedColor(t *testing.T) {
+	for _, col := range []string{"red", "#12345", "rgb(255, 0, 0)"} {
+		cals := findCalendarsWithProps(t, "", col)
+		if cals[0].Name != "A" || cals[0].Color != nil {
+			t.Errorf("calendar = %v with color %v for %q, want A without color", cals[0].Name, cals[0].Color, col)
+		}
+	}
+
+	cals := findCalendarsWithProps(t, "", "#FF000080")
+	want := color.NRGBA{R: 0xFF, A: 0x80}
+	if cals[0].Color != want {
+		t.Errorf("calendar color = %v, want %v", cals[0].Color, want)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	srv := httptest.NewTLSServer(&Handler{Backend: testBackend{}})
+	defer srv.Close()
+
+	domain := strings.TrimPrefix(srv.URL, "https://")
+	got, err := Discover(context.Background(), srv.Client(), domain)
+	if err != nil {
+		t.Fatalf("Discover() = %v", err)
+	}
+	if want := srv.URL + "/user/"; got != want {
+		t.Errorf("Discover() = %q, want %q", got, want)
+	}
+}
+
+func TestOptionsScheduling(t *testing.T) {
+	req := httptest.NewRequest(http.MethodOptions, "/user/calendars/", nil)
+	w := httptest.NewRecorder()
+	(&Handler{Backend: &testSchedulingBackend{}}).ServeHTTP(w, req)
+
+	dav := w.Header().Get("DAV")
+	if !strings.Contains(dav, "calendar-access") {
+		t.Errorf("DAV header %q doesn't contain calendar-access", dav)
+	}
+	// Implicit scheduling isn't supported
+	if strings.Contains(dav, "calendar-auto-schedule") {
+		t.Errorf("DAV header %q contains calendar-auto-schedule", dav)
+	}
+}
+
+func TestFilterCalendarRequiredProps(t *testing.T) {
+	// Leave room in the backing array, so that appending to the shared slice
+	// wouldn't allocate
+	orig := requiredCompProps[ical.CompEvent]
+	required := make([]string, len(orig), len(orig)+2)
+	copy(required, orig)
+	requiredCompProps[ical.CompEvent] = required
+	defer func() {
+		requiredCompProps[ical.CompEvent] = orig
+	}()
+
+	event := ical.NewEvent()
+	event.Props.SetText(ical.PropUID, "46bbf47a-1861-41a3-ae06-8d8268c6d41e")
+	event.Props.SetDateTime(ical.PropDateTimeStamp, time.Now())
+	event.Props.SetText(ical.PropSummary, "Design meeting")
+	event.Props.SetText(ical.PropDescription, "A very long description")
+	cal := ical.NewCalendar()
+	cal.Children = []*ical.Component{event.Component}
+
+	filter := func(prop string) *ical.Component {
+		filtered := filterCalendar(cal, &CalendarCompRequest{
+			Name:  ical.CompCalendar,
+			Comps: []CalendarCompRequest{{Name: ical.CompEvent, Props: []string{prop}}},
+		})
+		return filtered.Children[0]
+	}
+	summary := filter(ical.PropSummary)
+	desc := filter(ical.PropDescription)
+
+	if summary.Props.Get(ical.PropSummary) == nil || summary.Props.Get(ical.PropDescription) != nil {
+		t.Errorf("first filtered event = %v, want SUMMARY only", summary.Props)
+	}
+	if desc.Props.Get(ical.PropDescription) == nil || desc.Props.Get(ical.PropSummary) != nil {
+		t.Errorf("second filtered event = %v, want DESCRIPTION only", desc.Props)
+	}
+	for _, c := range []*ical.Component{summary, desc} {
+		if c.Props.Get(ical.PropUID) == nil || c.Props.Get(ical.PropDateTimeStamp) == nil {
+			t.Errorf("filtered event = %v, missing required properties", c.Props)
+		}
+	}
+	if got := required[:cap(required)][len(orig)]; got != "" {
+		t.Errorf("requiredCompProps modified by filterCalendar: %q appended", got)
+	}
+}
+
+const calendarQueryTimezoneRequest = `<?xml version="1.0" encoding="utf-8" ?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/></D:prop>
+  <C:filter>
+    <C:comp-filter name="VCALENDAR">
+      <C:comp-filter name="VEVENT">
+        <C:time-range start="20240115T000000Z" end="20240116T000000Z"/>
+      </C:comp-filter>
+    </C:comp-filter>
+  </C:filter>
+  <C:timezone>BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp.//CalDAV Client//EN
+BEGIN:VTIMEZONE
+TZID:%v
+BEGIN:STANDARD
+DTSTART:19701025T030000
+TZOFFSETFROM:+0200
+TZOFFSETTO:+0100
+END:STANDARD
+END:VTIMEZONE
+END:VCALENDAR
+</C:timezone>
+</C:calendar-query>`
+
+func TestCalendarQueryTimezone(t *testing.T) {
+	h := &Handler{Backend: testBackend{calendars: []Calendar{{Path: "/user/calendars/a/"}}}}
+
+	for _, tc := range []struct {
+		tzid string
+		want int
+	}{
+		{"Europe/Paris", http.StatusMultiStatus},
+		{"/example.org/Custom Standard Time", http.StatusBadRequest},
+	} {
+		req := httptest.NewRequest("REPORT", "/user/calendars/a/", strings.NewReader(fmt.Sprintf(calendarQueryTimezoneRequest, tc.tzid)))
+		req.Header.Set("Content-Type", "application/xml")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("REPORT with TZID %q status = %v, want %v", tc.tzid, w.Code, tc.want)
+		}
+	}
+}
@@ -4,6 +4,7 @@
 package carddav
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/emersion/go-vcard"
@@ -28,6 +29,10 @@ type AddressBook struct {
 	Description          string
 	MaxResourceSize      int64
 	SupportedAddressData []AddressDataType
+	// Privileges contains the privileges granted to the current user on the
+	// address book, or nil if the server doesn't report them. It's only
+	// populated by Client.
+	Privileges []webdav.Privilege
 }
 
 func (ab *AddressBook) SupportsAddressData(contentType, version string) bool {
@@ -54,6 +59,10 @@ type AddressBookQuery struct {
 type AddressDataRequest struct {
 	Props   []string
 	AllProp bool
+	// Version is the vCard version the server should convert address
+	// objects to, e.g. "4.0". An empty string leaves the choice to the
+	// server.
+	Version string
 }
 
 type PropFilter struct {
@@ -96,6 +105,16 @@ const (
 	MatchEndsWith   MatchType = "ends-with"
 )
 
+// MultiGetError is returned by Client.MultiGetAddressBook when some of the
+// requested address objects couldn't be fetched.
+type MultiGetError struct {
+	Errors []webdav.ResourceError
+}
+
+func (err *MultiGetError) Error() string {
+	return fmt.Sprintf("carddav: failed to fetch %v address objects (first error: %v)", len(err.Errors), &err.Errors[0])
+}
+
 type AddressBookMultiGet struct {
 	Paths       []string
 	DataRequest AddressDataRequest
@@ -109,6 +128,12 @@ type AddressObject struct {
 	Card          vcard.Card
 }
 
+// Version returns the vCard version of the address object. Servers may not
+// honor AddressDataRequest.Version, so it can differ from the requested one.
+func (ao *AddressObject) Version() string {
+	return ao.Card.Value(vcard.FieldVersion)
+}
+
 // SyncQuery is the query struct represents a sync-collection request
 type SyncQuery struct {
 	DataRequest AddressDataRequest
@@ -107,8 +107,12 @@ func (b *testBackend) ListAddressObjects(ctx context.Context, path string, req *
 	return []AddressObject{*alice}, nil
 }
 
-func (*testBackend) QueryAddressObjects(ctx context.Context, path string, query *AddressBookQuery) ([]AddressObject, error) {
-	panic("TODO: implement")
+func (b *testBackend) QueryAddressObjects(ctx context.Context, path string, query *AddressBookQuery) ([]AddressObject, error) {
+	aos, err := b.ListAddressObjects(ctx, path, &query.DataRequest)
+	if err != nil {
+		return nil, err
+	}
+	return Filter(query, aos)
 }
 
 func (*testBackend) PutAddressObject(ctx context.Context, path string, card vcard.Card, opts *PutAddressObjectOptions) (*AddressObject, error) {
@@ -145,7 +149,7 @@ func TestAddressBookDiscovery(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			ctx := context.Background()
 
-			h := Handler{&testBackend{}, tc.prefix}
+			h := Handler{Backend: &testBackend{}, Prefix: tc.prefix}
 			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 				ctx := r.Context()
 				ctx = context.WithValue(ctx, currentUserPrincipalKey, tc.currentUserPrincipal)
@@ -190,10 +194,241 @@ func TestAddressBookDiscovery(t *testing.T) {
 			if abs[0].Path != tc.addressBookPath {
 				t.Fatalf("Found address book at %s, expected %s", abs[0].Path, tc.addressBookPath)
 			}
+			if len(abs[0].Privileges) == 0 {
+				t.Errorf("Found no privileges on address book, expected some")
+			}
+		})
+	}
+}
+
+func TestQueryAddressBook(t *testing.T) {
+	h := Handler{Backend: &testBackend{}}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), addressBookPathKey, "/")
+		(&h).ServeHTTP(w, r.WithContext(ctx))
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("error creating client: %s", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		text string
+		want int
+	}{
+		{"match", "Alice", 1},
+		{"no match", "Bob", 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			query := AddressBookQuery{
+				DataRequest: AddressDataRequest{AllProp: true},
+				PropFilters: []PropFilter{{
+					Name: vcard.FieldFormattedName,
+					TextMatches: []TextMatch{{
+						Text:      tc.text,
+						MatchType: MatchStartsWith,
+					}},
+				}},
+			}
+			aos, err := client.QueryAddressBook(context.Background(), "/", &query)
+			if err != nil {
+				t.Fatalf("QueryAddressBook() = %v", err)
+			}
+			if len(aos) != tc.want {
+				t.Fatalf("QueryAddressBook() returned %v address objects, want %v", len(aos), tc.want)
+			}
 		})
 	}
 }
 
+type testDuplicateBackend struct {
+	testBackend
+}
+
+func (b *testDuplicateBackend) QueryAddressObjects(ctx context.Context, path string, query *AddressBookQuery) ([]AddressObject, error) {
+	aos, err := b.testBackend.QueryAddressObjects(ctx, path, query)
+	if err != nil {
+		return nil, err
+	}
+	var l []AddressObject
+	for _, ao := range aos {
+		dup := ao
+		dup.Path = "copy-" + ao.Path
+		l = append(l, ao, dup)
+	}
+	return l, nil
+}
+
+func TestQueryAddressBookTruncated(t *testing.T) {
+	h := Handler{Backend: &testDuplicateBackend{}}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), addressBookPathKey, "/")
+		(&h).ServeHTTP(w, r.WithContext(ctx))
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("error creating client: %s", err)
+	}
+
+	query := AddressBookQuery{
+		DataRequest: AddressDataRequest{AllProp: true},
+		PropFilters: []PropFilter{{
+			Name:        vcard.FieldFormattedName,
+			TextMatches: []TextMatch{{Text: "Alice", MatchType: MatchStartsWith}},
+		}},
+	}
+	aos, err := client.QueryAddressBook(context.Background(), "/", &query)
+	if err != nil {
+		t.Fatalf("QueryAddressBook() = %v", err)
+	} else if len(aos) != 2 {
+		t.Fatalf("QueryAddressBook() returned %v address objects, want 2", len(aos))
+	}
+
+	query.Limit = 1
+	aos, err = client.QueryAddressBook(context.Background(), "/", &query)
+	if err != ErrTruncated {
+		t.Fatalf("QueryAddressBook() with limit = %v, want %v", err, ErrTruncated)
+	} else if len(aos) != 1 {
+		t.Fatalf("QueryAddressBook() with limit returned %v address objects, want 1", len(aos))
+	}
+}
+
+type testMultiGetBackend struct {
+	testBackend
+}
+
+func (b *testMultiGetBackend) GetAddressObject(ctx context.Context, path string, req *AddressDataRequest) (*AddressObject, error) {
+	if path != "/"+alicePath {
+		return nil, webdav.NewHTTPError(404, fmt.Errorf("Not found"))
+	}
+	ao, err := b.testBackend.GetAddressObject(ctx, alicePath, req)
+	if err != nil {
+		return nil, err
+	}
+	ao.Path = path
+	return ao, nil
+}
+
+func TestMultiGetAddressBook(t *testing.T) {
+	h := Handler{Backend: &testMultiGetBackend{}}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), addressBookPathKey, "/")
+		(&h).ServeHTTP(w, r.WithContext(ctx))
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("error creating client: %s", err)
+	}
+
+	multiGet := AddressBookMultiGet{
+		Paths:       []string{"/" + alicePath, "/missing.vcf"},
+		DataRequest: AddressDataRequest{AllProp: true},
+	}
+	aos, err := client.MultiGetAddressBook(context.Background(), "/", &multiGet)
+	multiGetErr, ok := err.(*MultiGetError)
+	if !ok {
+		t.Fatalf("MultiGetAddressBook() = %v, want a *MultiGetError", err)
+	}
+	if len(multiGetErr.Errors) != 1 || multiGetErr.Errors[0].Path != "/missing.vcf" {
+		t.Errorf("MultiGetError.Errors = %+v, want /missing.vcf", multiGetErr.Errors)
+	}
+	if len(aos) != 1 || aos[0].Path != "/"+alicePath {
+		t.Errorf("MultiGetAddressBook() = %+v, want /%v", aos, alicePath)
+	}
+}
+
+type testVersionBackend struct {
+	testBackend
+	version string
+}
+
+func (b *testVersionBackend) QueryAddressObjects(ctx context.Context, path string, query *AddressBookQuery) ([]AddressObject, error) {
+	b.version = query.DataRequest.Version
+	return b.testBackend.QueryAddressObjects(ctx, path, query)
+}
+
+func TestQueryAddressBookVersion(t *testing.T) {
+	backend := &testVersionBackend{}
+	h := Handler{Backend: backend}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), addressBookPathKey, "/")
+		(&h).ServeHTTP(w, r.WithContext(ctx))
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("error creating client: %s", err)
+	}
+	client.PreferredVCardVersion = "4.0"
+
+	query := AddressBookQuery{
+		DataRequest: AddressDataRequest{AllProp: true},
+		PropFilters: []PropFilter{{
+			Name:        vcard.FieldFormattedName,
+			TextMatches: []TextMatch{{Text: "Alice", MatchType: MatchStartsWith}},
+		}},
+	}
+	aos, err := client.QueryAddressBook(context.Background(), "/", &query)
+	if err != nil {
+		t.Fatalf("QueryAddressBook() = %v", err)
+	}
+	if backend.version != "4.0" {
+		t.Errorf("AddressDataRequest.Version = %q, want %q", backend.version, "4.0")
+	}
+	if len(aos) != 1 || aos[0].Version() != "4.0" {
+		t.Errorf("QueryAddressBook() = %+v, want a single vCard 4.0", aos)
+	}
+}
+
+func TestQueryAddressBookProps(t *testing.T) {
+	h := Handler{Backend: &testBackend{}}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), addressBookPathKey, "/")
+		(&h).ServeHTTP(w, r.WithContext(ctx))
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("error creating client: %s", err)
+	}
+
+	query := AddressBookQuery{
+		DataRequest: AddressDataRequest{Props: []string{vcard.FieldFormattedName}},
+		PropFilters: []PropFilter{{
+			Name:        vcard.FieldFormattedName,
+			TextMatches: []TextMatch{{Text: "Alice", MatchType: MatchStartsWith}},
+		}},
+	}
+	aos, err := client.QueryAddressBook(context.Background(), "/", &query)
+	if err != nil {
+		t.Fatalf("QueryAddressBook() = %v", err)
+	}
+	if len(aos) != 1 {
+		t.Fatalf("QueryAddressBook() = %+v, want a single address object", aos)
+	}
+	card := aos[0].Card
+	if card.Value(vcard.FieldFormattedName) != "Alice Gopher" {
+		t.Errorf("FN = %q, want %q", card.Value(vcard.FieldFormattedName), "Alice Gopher")
+	}
+	if card.Value(vcard.FieldVersion) == "" {
+		t.Errorf("VERSION is missing from the projected card")
+	}
+	for name := range card {
+		if name != vcard.FieldFormattedName && name != vcard.FieldVersion {
+			t.Errorf("unexpected property %q in the projected card", name)
+		}
+	}
+}
+
 var mkcolRequestBody = `
 <?xml version="1.0" encoding="utf-8" ?>
    <D:mkcol xmlns:D="DAV:"
@@ -240,3 +475,22 @@ func TestCreateAddressbookMinimalBody(t *testing.T) {
 		t.Fatalf("Address book sdscription is '%s', expected 'My primary address book.'", c.Description)
 	}
 }
+
+func TestDiscover(t *testing.T) {
+	h := Handler{Backend: &testBackend{}}
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), currentUserPrincipalKey, "/dav/")
+		ctx = context.WithValue(ctx, homeSetPathKey, "/dav/contacts/")
+		(&h).ServeHTTP(w, r.WithContext(ctx))
+	}))
+	defer ts.Close()
+
+	domain := strings.TrimPrefix(ts.URL, "https://")
+	got, err := Discover(context.Background(), ts.Client(), domain)
+	if err != nil {
+		t.Fatalf("Discover() = %v", err)
+	}
+	if want := ts.URL + "/dav/"; got != want {
+		t.Errorf("Discover() = %q, want %q", got, want)
+	}
+}
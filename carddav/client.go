@@ -3,6 +3,8 @@ package carddav
 import (
 	"bytes"
 	"context"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"mime"
 	"net/http"
@@ -19,13 +21,31 @@ import (
 // DiscoverContextURL performs a DNS-based CardDAV service discovery as
 // described in RFC 6352 section 11. It returns the URL to the CardDAV server.
 func DiscoverContextURL(ctx context.Context, domain string) (string, error) {
-	return internal.DiscoverContextURL(ctx, "carddavs", domain)
+	return internal.DiscoverContextURL(ctx, "carddav", domain)
+}
+
+// Discover locates the CardDAV service root of a domain, as described in RFC
+// 6764. See webdav.Discover. If c is nil, http.DefaultClient is used.
+func Discover(ctx context.Context, c webdav.HTTPClient, domain string) (string, error) {
+	res, err := webdav.Discover(ctx, c, domain)
+	if err != nil {
+		return "", err
+	}
+	if res.CardDAV == nil {
+		return "", fmt.Errorf("carddav: no CardDAV service found for %q", domain)
+	}
+	return res.CardDAV.String(), nil
 }
 
 // Client provides access to a remote CardDAV server.
 type Client struct {
 	*webdav.Client
 
+	// PreferredVCardVersion is the vCard version requested from the server
+	// when AddressDataRequest.Version is empty, e.g. "4.0". The server may
+	// not be able to convert address objects, check AddressObject.Version.
+	PreferredVCardVersion string
+
 	ic *internal.Client
 }
 
@@ -38,7 +58,7 @@ func NewClient(c webdav.HTTPClient, endpoint string) (*Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Client{wc, ic}, nil
+	return &Client{Client: wc, ic: ic}, nil
 }
 
 func (c *Client) HasSupport(ctx context.Context) error {
@@ -65,7 +85,7 @@ func (c *Client) FindAddressBookHomeSet(ctx context.Context, principal string) (
 		return "", err
 	}
 
-	return prop.Href.Path, nil
+	return c.ic.ResolveRelativeHref(principal, &prop.Href), nil
 }
 
 func decodeSupportedAddressData(supported *supportedAddressData) []AddressDataType {
@@ -83,6 +103,7 @@ func (c *Client) FindAddressBooks(ctx context.Context, addressBookHomeSet string
 		addressBookDescriptionName,
 		maxResourceSizeName,
 		supportedAddressDataName,
+		internal.CurrentUserPrivilegeSetName,
 	)
 	ms, err := c.ic.PropFind(ctx, addressBookHomeSet, internal.DepthOne, propfind)
 	if err != nil {
@@ -127,20 +148,34 @@ func (c *Client) FindAddressBooks(ctx context.Context, addressBookHomeSet string
 			return nil, err
 		}
 
+		privs, err := resp.DecodeCurrentUserPrivileges()
+		if err != nil {
+			return nil, err
+		}
+
 		l = append(l, AddressBook{
 			Path:                 path,
 			Name:                 dispName.Name,
 			Description:          desc.Description,
 			MaxResourceSize:      maxResSize.Size,
 			SupportedAddressData: decodeSupportedAddressData(&supported),
+			Privileges:           decodePrivileges(privs),
 		})
 	}
 
 	return l, nil
 }
 
-func encodeAddressPropReq(req *AddressDataRequest) (*internal.Prop, error) {
+func (c *Client) encodeAddressPropReq(req *AddressDataRequest) (*internal.Prop, error) {
 	var addrDataReq addressDataReq
+	version := req.Version
+	if version == "" {
+		version = c.PreferredVCardVersion
+	}
+	if version != "" {
+		addrDataReq.ContentType = vcard.MIMEType
+		addrDataReq.Version = version
+	}
 	if req.AllProp {
 		addrDataReq.Allprop = &struct{}{}
 	} else {
@@ -200,51 +235,67 @@ func encodeTextMatch(tm *TextMatch) *textMatch {
 func decodeAddressList(ms *internal.MultiStatus) ([]AddressObject, error) {
 	addrs := make([]AddressObject, 0, len(ms.Responses))
 	for _, resp := range ms.Responses {
-		path, err := resp.Path()
+		ao, err := decodeAddressObject(&resp)
 		if err != nil {
 			return nil, err
 		}
+		addrs = append(addrs, *ao)
+	}
 
-		var addrData addressDataResp
-		if err := resp.DecodeProp(&addrData); err != nil {
-			return nil, err
-		}
+	return addrs, nil
+}
 
-		var getLastMod internal.GetLastModified
-		if err := resp.DecodeProp(&getLastMod); err != nil && !internal.IsNotFound(err) {
-			return nil, err
-		}
+func decodeAddressObject(resp *internal.Response) (*AddressObject, error) {
+	path, err := resp.Path()
+	if err != nil {
+		return nil, err
+	}
 
-		var getETag internal.GetETag
-		if err := resp.DecodeProp(&getETag); err != nil && !internal.IsNotFound(err) {
-			return nil, err
-		}
+	var addrData addressDataResp
+	if err := resp.DecodeProp(&addrData); err != nil {
+		return nil, err
+	}
 
-		var getContentLength internal.GetContentLength
-		if err := resp.DecodeProp(&getContentLength); err != nil && !internal.IsNotFound(err) {
-			return nil, err
-		}
+	var getLastMod internal.GetLastModified
+	if err := resp.DecodeProp(&getLastMod); err != nil && !internal.IsNotFound(err) {
+		return nil, err
+	}
 
-		r := bytes.NewReader(addrData.Data)
-		card, err := vcard.NewDecoder(r).Decode()
-		if err != nil {
-			return nil, err
-		}
+	var getETag internal.GetETag
+	if err := resp.DecodeProp(&getETag); err != nil && !internal.IsNotFound(err) {
+		return nil, err
+	}
 
-		addrs = append(addrs, AddressObject{
-			Path:          path,
-			ModTime:       time.Time(getLastMod.LastModified),
-			ContentLength: getContentLength.Length,
-			ETag:          string(getETag.ETag),
-			Card:          card,
-		})
+	var getContentLength internal.GetContentLength
+	if err := resp.DecodeProp(&getContentLength); err != nil && !internal.IsNotFound(err) {
+		return nil, err
 	}
 
-	return addrs, nil
+	r := bytes.NewReader(addrData.Data)
+	card, err := vcard.NewDecoder(r).Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	return &AddressObject{
+		Path:          path,
+		ModTime:       time.Time(getLastMod.LastModified),
+		ContentLength: getContentLength.Length,
+		ETag:          string(getETag.ETag),
+		Card:          card,
+	}, nil
 }
 
+// ErrTruncated is returned by Client.QueryAddressBook along with the results
+// when the server has truncated them, e.g. to the requested limit.
+var ErrTruncated = errors.New("carddav: results truncated by the server")
+
+// QueryAddressBook fetches the address objects matching a query.
+//
+// If the server truncates the results, they are returned alongside
+// ErrTruncated.
 func (c *Client) QueryAddressBook(ctx context.Context, addressBook string, query *AddressBookQuery) ([]AddressObject, error) {
-	propReq, err := encodeAddressPropReq(&query.DataRequest)
+	propReq, err := c.encodeAddressPropReq(&query.DataRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -274,11 +325,36 @@ func (c *Client) QueryAddressBook(ctx context.Context, addressBook string, query
 		return nil, err
 	}
 
-	return decodeAddressList(ms)
+	// Servers truncating the results to the requested limit return a 507
+	// response for the address book itself
+	truncated := false
+	resps := ms.Responses[:0]
+	for _, resp := range ms.Responses {
+		if resp.Status != nil && resp.Status.Code == http.StatusInsufficientStorage {
+			truncated = true
+			continue
+		}
+		resps = append(resps, resp)
+	}
+	ms.Responses = resps
+
+	aos, err := decodeAddressList(ms)
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		return aos, ErrTruncated
+	}
+	return aos, nil
 }
 
+// MultiGetAddressBook fetches the address objects listed in multiGet with a
+// single addressbook-multiget REPORT.
+//
+// If some of the objects couldn't be fetched (e.g. because they have been
+// deleted), the other objects are returned alongside a *MultiGetError.
 func (c *Client) MultiGetAddressBook(ctx context.Context, path string, multiGet *AddressBookMultiGet) ([]AddressObject, error) {
-	propReq, err := encodeAddressPropReq(&multiGet.DataRequest)
+	propReq, err := c.encodeAddressPropReq(&multiGet.DataRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -307,7 +383,25 @@ func (c *Client) MultiGetAddressBook(ctx context.Context, path string, multiGet
 		return nil, err
 	}
 
-	return decodeAddressList(ms)
+	aos := make([]AddressObject, 0, len(ms.Responses))
+	var errs []webdav.ResourceError
+	for _, resp := range ms.Responses {
+		ao, err := decodeAddressObject(&resp)
+		if err != nil {
+			var httpErr *internal.HTTPError
+			if !errors.As(err, &httpErr) || len(resp.Hrefs) != 1 {
+				return nil, err
+			}
+			errs = append(errs, webdav.ResourceError{Path: resp.Hrefs[0].Path, Err: err})
+			continue
+		}
+		aos = append(aos, *ao)
+	}
+
+	if len(errs) > 0 {
+		return aos, &MultiGetError{Errors: errs}
+	}
+	return aos, nil
 }
 
 func populateAddressObject(ao *AddressObject, h http.Header) error {
@@ -348,7 +442,11 @@ func (c *Client) GetAddressObject(ctx context.Context, path string) (*AddressObj
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Accept", vcard.MIMEType)
+	accept := vcard.MIMEType
+	if c.PreferredVCardVersion != "" {
+		accept = mime.FormatMediaType(vcard.MIMEType, map[string]string{"version": c.PreferredVCardVersion})
+	}
+	req.Header.Set("Accept", accept)
 
 	resp, err := c.ic.Do(req.WithContext(ctx))
 	if err != nil {
@@ -425,7 +523,7 @@ func (c *Client) SyncCollection(ctx context.Context, path string, query *SyncQue
 		limit = &internal.Limit{NResults: uint(query.Limit)}
 	}
 
-	propReq, err := encodeAddressPropReq(&query.DataRequest)
+	propReq, err := c.encodeAddressPropReq(&query.DataRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -470,3 +568,14 @@ func (c *Client) SyncCollection(ctx context.Context, path string, query *SyncQue
 
 	return ret, nil
 }
+
+func decodePrivileges(names []xml.Name) []webdav.Privilege {
+	if names == nil {
+		return nil
+	}
+	privs := make([]webdav.Privilege, len(names))
+	for i, name := range names {
+		privs[i] = webdav.Privilege(name)
+	}
+	return privs
+}
@@ -21,6 +21,9 @@ var (
 	addressBookMultigetName = xml.Name{namespace, "addressbook-multiget"}
 
 	addressDataName = xml.Name{namespace, "address-data"}
+
+	// https://tools.ietf.org/html/rfc5323#section-5.17
+	numberOfMatchesWithinLimitsName = xml.Name{internal.Namespace, "number-of-matches-within-limits"}
 )
 
 // https://tools.ietf.org/html/rfc6352#section-6.2.3
@@ -173,9 +176,11 @@ func newProp(name string, noValue bool) *internal.RawXMLValue {
 
 // https://tools.ietf.org/html/rfc6352#section-10.4
 type addressDataReq struct {
-	XMLName xml.Name  `xml:"urn:ietf:params:xml:ns:carddav address-data"`
-	Props   []prop    `xml:"prop"`
-	Allprop *struct{} `xml:"allprop"`
+	XMLName     xml.Name  `xml:"urn:ietf:params:xml:ns:carddav address-data"`
+	ContentType string    `xml:"content-type,attr,omitempty"`
+	Version     string    `xml:"version,attr,omitempty"`
+	Props       []prop    `xml:"prop"`
+	Allprop     *struct{} `xml:"allprop"`
 }
 
 // https://tools.ietf.org/html/rfc6352#section-10.4.2
@@ -192,8 +197,9 @@ type addressDataResp struct {
 }
 
 type reportReq struct {
-	Query    *addressbookQuery
-	Multiget *addressbookMultiget
+	Query          *addressbookQuery
+	Multiget       *addressbookMultiget
+	SyncCollection *internal.SyncCollectionQuery
 }
 
 func (r *reportReq) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
@@ -205,6 +211,9 @@ func (r *reportReq) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	case addressBookMultigetName:
 		r.Multiget = &addressbookMultiget{}
 		v = r.Multiget
+	case internal.SyncCollectionName:
+		r.SyncCollection = &internal.SyncCollectionQuery{}
+		v = r.SyncCollection
 	default:
 		return fmt.Errorf("carddav: unsupported REPORT root %q %q", start.Name.Space, start.Name.Local)
 	}
@@ -41,11 +41,26 @@ type Backend interface {
 	webdav.UserPrincipalBackend
 }
 
+// SyncBackend is an optional interface a Backend can implement to support
+// sync-collection REPORT requests, as defined in RFC 6578.
+type SyncBackend interface {
+	// GetChangedObjects returns the address objects of an address book which
+	// have changed since query.SyncToken, along with a new sync token. An
+	// empty sync token requests all address objects. If the sync token is
+	// invalid, an HTTP 403 error should be returned.
+	GetChangedObjects(ctx context.Context, path string, query *SyncQuery) (*SyncResponse, error)
+}
+
 // Handler handles CardDAV HTTP requests. It can be used to create a CardDAV
 // server.
 type Handler struct {
 	Backend Backend
 	Prefix  string
+
+	// WellKnownRedirect is the path requests to /.well-known/carddav are
+	// permanently redirected to, e.g. "/dav/". If empty, they're redirected
+	// to the current user's principal.
+	WellKnownRedirect string
 }
 
 // ServeHTTP implements http.Handler.
@@ -56,6 +71,11 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.URL.Path == "/.well-known/carddav" {
+		if h.WellKnownRedirect != "" {
+			http.Redirect(w, r, h.WellKnownRedirect, http.StatusMovedPermanently)
+			return
+		}
+
 		principalPath, err := h.Backend.CurrentUserPrincipal(r.Context())
 		if err != nil {
 			http.Error(w, "carddav: failed to determine current user principal", http.StatusInternalServerError)
@@ -80,7 +100,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err != nil {
-		internal.ServeError(w, err)
+		internal.ServeRequestError(w, r, err)
 	}
 }
 
@@ -94,8 +114,10 @@ func (h *Handler) handleReport(w http.ResponseWriter, r *http.Request) error {
 		return h.handleQuery(r, w, report.Query)
 	} else if report.Multiget != nil {
 		return h.handleMultiget(r.Context(), w, report.Multiget)
+	} else if report.SyncCollection != nil {
+		return h.handleSyncCollection(r, w, report.SyncCollection)
 	}
-	return internal.HTTPErrorf(http.StatusBadRequest, "carddav: expected addressbook-query or addressbook-multiget element in REPORT request")
+	return internal.HTTPErrorf(http.StatusBadRequest, "carddav: expected addressbook-query, addressbook-multiget or sync-collection element in REPORT request")
 }
 
 func decodePropFilter(el *propFilter) (*PropFilter, error) {
@@ -146,7 +168,14 @@ func decodeAddressDataReq(addressData *addressDataReq) (*AddressDataRequest, err
 		return nil, internal.HTTPErrorf(http.StatusBadRequest, "carddav: only one of allprop or prop can be specified in address-data")
 	}
 
-	req := &AddressDataRequest{AllProp: addressData.Allprop != nil}
+	if addressData.ContentType != "" && addressData.ContentType != vcard.MIMEType {
+		return nil, internal.HTTPErrorf(http.StatusBadRequest, "carddav: unsupported address-data content type %q", addressData.ContentType)
+	}
+
+	req := &AddressDataRequest{
+		AllProp: addressData.Allprop != nil,
+		Version: addressData.Version,
+	}
 	for _, p := range addressData.Props {
 		req.Props = append(req.Props, p.Name)
 	}
@@ -186,6 +215,12 @@ func (h *Handler) handleQuery(r *http.Request, w http.ResponseWriter, query *add
 		return err
 	}
 
+	var truncated bool
+	if q.Limit > 0 && len(aos) > q.Limit {
+		aos = aos[:q.Limit]
+		truncated = true
+	}
+
 	var resps []internal.Response
 	for _, ao := range aos {
 		b := backend{
@@ -204,6 +239,16 @@ func (h *Handler) handleQuery(r *http.Request, w http.ResponseWriter, query *add
 		resps = append(resps, *resp)
 	}
 
+	if truncated {
+		// https://tools.ietf.org/html/rfc6352#section-8.6.1
+		raw := internal.NewRawXMLElement(numberOfMatchesWithinLimitsName, nil, nil)
+		err := &internal.HTTPError{
+			Code: http.StatusInsufficientStorage,
+			Err:  &internal.Error{Raw: []internal.RawXMLValue{*raw}},
+		}
+		resps = append(resps, *internal.NewErrorResponse(r.URL.Path, err))
+	}
+
 	ms := internal.NewMultiStatus(resps...)
 	return internal.ServeMultiStatus(w, ms)
 }
@@ -251,6 +296,66 @@ func (h *Handler) handleMultiget(ctx context.Context, w http.ResponseWriter, mul
 	return internal.ServeMultiStatus(w, ms)
 }
 
+func (h *Handler) handleSyncCollection(r *http.Request, w http.ResponseWriter, sync *internal.SyncCollectionQuery) error {
+	sb, ok := h.Backend.(SyncBackend)
+	if !ok {
+		return internal.HTTPErrorf(http.StatusForbidden, "carddav: sync-collection REPORT not supported")
+	}
+
+	switch sync.SyncLevel {
+	case internal.SyncLevelOne, internal.SyncLevelInfinite:
+		// Address books cannot contain collections, both levels are equivalent
+	default:
+		return internal.HTTPErrorf(http.StatusBadRequest, "carddav: invalid sync-level %q", sync.SyncLevel)
+	}
+
+	q := SyncQuery{SyncToken: sync.SyncToken}
+	if sync.Limit != nil {
+		q.Limit = int(sync.Limit.NResults)
+	}
+	if sync.Prop != nil {
+		var addressData addressDataReq
+		if err := sync.Prop.Decode(&addressData); err != nil && !internal.IsNotFound(err) {
+			return err
+		}
+		req, err := decodeAddressDataReq(&addressData)
+		if err != nil {
+			return err
+		}
+		q.DataRequest = *req
+	}
+
+	res, err := sb.GetChangedObjects(r.Context(), r.URL.Path, &q)
+	if err != nil {
+		return err
+	}
+
+	b := backend{
+		Backend: h.Backend,
+		Prefix:  strings.TrimSuffix(h.Prefix, "/"),
+	}
+	propfind := internal.PropFind{Prop: sync.Prop}
+
+	var resps []internal.Response
+	for _, ao := range res.Updated {
+		resp, err := b.propFindAddressObject(r.Context(), &propfind, &ao)
+		if err != nil {
+			return err
+		}
+		resps = append(resps, *resp)
+	}
+	for _, p := range res.Deleted {
+		resps = append(resps, internal.Response{
+			Hrefs:  []internal.Href{{Path: p}},
+			Status: &internal.Status{Code: http.StatusNotFound},
+		})
+	}
+
+	ms := internal.NewMultiStatus(resps...)
+	ms.SyncToken = res.SyncToken
+	return internal.ServeMultiStatus(w, ms)
+}
+
 type backend struct {
 	Backend Backend
 	Prefix  string
@@ -527,6 +632,13 @@ func (b *backend) propFindAddressBook(ctx context.Context, propfind *internal.Pr
 	props[internal.CurrentUserPrivilegeSetName] = func(*internal.RawXMLValue) (interface{}, error) {
 		return &internal.CurrentUserPrivilegeSet{Privilege: internal.NewAllPrivileges()}, nil
 	}
+	props[internal.SupportedReportSetName] = func(*internal.RawXMLValue) (interface{}, error) {
+		reports := []xml.Name{addressBookQueryName, addressBookMultigetName}
+		if _, ok := b.Backend.(SyncBackend); ok {
+			reports = append(reports, internal.SyncCollectionName)
+		}
+		return internal.NewSupportedReportSet(reports...), nil
+	}
 
 	return internal.NewPropFindResponse(ab.Path, propfind, props)
 }
@@ -555,6 +667,23 @@ func (b *backend) propFindAllAddressBooks(ctx context.Context, propfind *interna
 	return resps, nil
 }
 
+// projectCard returns a copy of card only containing the properties listed in
+// names, as described in RFC 6352 section 10.4.2. The VERSION property is
+// always kept, since it's required for the vCard to be valid.
+func projectCard(card vcard.Card, names []string) vcard.Card {
+	projected := make(vcard.Card)
+	if fields, ok := card[vcard.FieldVersion]; ok {
+		projected[vcard.FieldVersion] = fields
+	}
+	for _, name := range names {
+		name = strings.ToUpper(name)
+		if fields, ok := card[name]; ok {
+			projected[name] = fields
+		}
+	}
+	return projected
+}
+
 func (b *backend) propFindAddressObject(ctx context.Context, propfind *internal.PropFind, ao *AddressObject) (*internal.Response, error) {
 	props := map[xml.Name]internal.PropFindFunc{
 		internal.CurrentUserPrincipalName: func(*internal.RawXMLValue) (interface{}, error) {
@@ -568,9 +697,23 @@ func (b *backend) propFindAddressObject(ctx context.Context, propfind *internal.
 			return &internal.GetContentType{Type: vcard.MIMEType}, nil
 		},
 		// TODO: address-data can only be used in REPORT requests
-		addressDataName: func(*internal.RawXMLValue) (interface{}, error) {
+		addressDataName: func(raw *internal.RawXMLValue) (interface{}, error) {
+			var dataReq addressDataReq
+			if err := raw.Decode(&dataReq); err != nil {
+				return nil, &internal.HTTPError{Code: http.StatusBadRequest, Err: err}
+			}
+
+			card := ao.Card
+			if len(dataReq.Props) > 0 {
+				names := make([]string, len(dataReq.Props))
+				for i, p := range dataReq.Props {
+					names[i] = p.Name
+				}
+				card = projectCard(card, names)
+			}
+
 			var buf bytes.Buffer
-			if err := vcard.NewEncoder(&buf).Encode(ao.Card); err != nil {
+			if err := vcard.NewEncoder(&buf).Encode(card); err != nil {
 				return nil, err
 			}
 
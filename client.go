@@ -2,9 +2,16 @@ package webdav
 
 import (
 	"context"
+	"encoding/xml"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/emersion/go-webdav/internal"
@@ -44,7 +51,8 @@ type Client struct {
 //
 // If the HTTPClient is nil, http.DefaultClient is used.
 //
-// To use HTTP basic authentication, HTTPClientWithBasicAuth can be used.
+// To use HTTP basic authentication, HTTPClientWithBasicAuth can be used. For
+// bearer tokens, HTTPClientWithBearerAuth can be used.
 func NewClient(c HTTPClient, endpoint string) (*Client, error) {
 	ic, err := internal.NewClient(c, endpoint)
 	if err != nil {
@@ -55,11 +63,18 @@ func NewClient(c HTTPClient, endpoint string) (*Client, error) {
 
 // FindCurrentUserPrincipal finds the current user's principal path.
 func (c *Client) FindCurrentUserPrincipal(ctx context.Context) (string, error) {
-	propfind := internal.NewPropNamePropFind(internal.CurrentUserPrincipalName)
-
 	// TODO: consider retrying on the root URI "/" if this fails, as suggested
 	// by the RFC?
-	resp, err := c.ic.PropFindFlat(ctx, "", propfind)
+	return c.FindCurrentUserPrincipalAt(ctx, "")
+}
+
+// FindCurrentUserPrincipalAt is like FindCurrentUserPrincipal, but reads the
+// DAV:current-user-principal property (RFC 5397) of the resource at path
+// instead of the endpoint.
+func (c *Client) FindCurrentUserPrincipalAt(ctx context.Context, path string) (string, error) {
+	propfind := internal.NewPropNamePropFind(internal.CurrentUserPrincipalName)
+
+	resp, err := c.ic.PropFindFlat(ctx, path, propfind)
 	if err != nil {
 		return "", err
 	}
@@ -72,7 +87,7 @@ func (c *Client) FindCurrentUserPrincipal(ctx context.Context) (string, error) {
 		return "", fmt.Errorf("webdav: unauthenticated")
 	}
 
-	return prop.Href.Path, nil
+	return c.ic.ResolveRelativeHref(path, &prop.Href), nil
 }
 
 var fileInfoPropFind = internal.NewPropNamePropFind(
@@ -80,7 +95,9 @@ var fileInfoPropFind = internal.NewPropNamePropFind(
 	internal.GetContentLengthName,
 	internal.GetLastModifiedName,
 	internal.GetContentTypeName,
+	internal.GetContentLanguageName,
 	internal.GetETagName,
+	internal.CurrentUserPrivilegeSetName,
 )
 
 func fileInfoFromResponse(resp *internal.Response) (*FileInfo, error) {
@@ -109,6 +126,11 @@ func fileInfoFromResponse(resp *internal.Response) (*FileInfo, error) {
 			return nil, err
 		}
 
+		var getLang internal.GetContentLanguage
+		if err := resp.DecodeProp(&getLang); err != nil && !internal.IsNotFound(err) {
+			return nil, err
+		}
+
 		var getETag internal.GetETag
 		if err := resp.DecodeProp(&getETag); err != nil && !internal.IsNotFound(err) {
 			return nil, err
@@ -116,6 +138,7 @@ func fileInfoFromResponse(resp *internal.Response) (*FileInfo, error) {
 
 		fi.Size = getLen.Length
 		fi.MIMEType = getType.Type
+		fi.Language = getLang.Language
 		fi.ETag = string(getETag.ETag)
 	}
 
@@ -125,6 +148,12 @@ func fileInfoFromResponse(resp *internal.Response) (*FileInfo, error) {
 	}
 	fi.ModTime = time.Time(getMod.LastModified)
 
+	privs, err := resp.DecodeCurrentUserPrivileges()
+	if err != nil {
+		return nil, err
+	}
+	fi.Privileges = newPrivileges(privs)
+
 	return fi, nil
 }
 
@@ -137,8 +166,237 @@ func (c *Client) Stat(ctx context.Context, name string) (*FileInfo, error) {
 	return fileInfoFromResponse(resp)
 }
 
-// Open fetches a file's contents.
+// StatFile fetches the metadata of a single resource. Additional properties
+// can be requested with props, their values are stored in
+// FileStat.ExtraProps.
+func (c *Client) StatFile(ctx context.Context, href string, props ...xml.Name) (*FileStat, error) {
+	resp, err := c.ic.PropFindFlat(ctx, href, newFileStatPropFind(props))
+	if err != nil {
+		return nil, err
+	}
+	return fileStatFromResponse(resp)
+}
+
+// PropFindTree fetches the metadata of a resource and of its members up to
+// depth with a single PROPFIND request. The resource itself is included.
+// Additional properties can be requested with props, their values are stored
+// in FileStat.ExtraProps.
+//
+// An error is returned if the server replies with resources located outside
+// of the requested tree.
+func (c *Client) PropFindTree(ctx context.Context, href string, depth Depth, props ...xml.Name) ([]FileStat, error) {
+	var l []FileStat
+	err := c.ic.PropFindTreeStream(ctx, href, depth, newFileStatPropFind(props), func(resp *internal.Response) error {
+		fs, err := fileStatFromResponse(resp)
+		if err != nil {
+			return err
+		}
+		l = append(l, *fs)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// ReadDirFiles lists the members of a collection, sorted by name. The
+// collection itself isn't included. Additional properties can be requested
+// with props, their values are stored in FileStat.ExtraProps.
+func (c *Client) ReadDirFiles(ctx context.Context, href string, props ...xml.Name) ([]FileStat, error) {
+	root := strings.TrimSuffix(c.ic.ResolveHref(href).Path, "/")
+
+	var l []FileStat
+	err := c.ic.PropFindStream(ctx, href, internal.DepthOne, newFileStatPropFind(props), func(resp *internal.Response) error {
+		if p, err := resp.Path(); err == nil && strings.TrimSuffix(p, "/") == root {
+			return nil
+		}
+
+		fs, err := fileStatFromResponse(resp)
+		if err != nil {
+			return err
+		}
+		l = append(l, *fs)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].Name < l[j].Name
+	})
+	return l, nil
+}
+
+func newFileStatPropFind(props []xml.Name) *internal.PropFind {
+	if len(props) == 0 {
+		return fileInfoPropFind
+	}
+	names := make([]xml.Name, 0, len(fileInfoPropFind.Prop.Raw)+len(props))
+	for _, raw := range fileInfoPropFind.Prop.Raw {
+		name, _ := raw.XMLName()
+		names = append(names, name)
+	}
+	names = append(names, props...)
+	return internal.NewPropNamePropFind(names...)
+}
+
+func fileStatFromResponse(resp *internal.Response) (*FileStat, error) {
+	fi, err := fileInfoFromResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	fs := &FileStat{
+		Name:        path.Base(strings.TrimSuffix(fi.Path, "/")),
+		Href:        fi.Path,
+		ContentType: fi.MIMEType,
+		ETag:        fi.ETag,
+		Size:        fi.Size,
+		ModTime:     fi.ModTime,
+		IsDir:       fi.IsDir,
+	}
+
+	for _, propstat := range resp.PropStats {
+		if propstat.Status.Err() != nil {
+			continue
+		}
+		for i := range propstat.Prop.Raw {
+			raw := &propstat.Prop.Raw[i]
+			name, ok := raw.XMLName()
+			if !ok || fileInfoPropFind.Prop.Get(name) != nil {
+				continue
+			}
+			var v struct {
+				Text string `xml:",chardata"`
+			}
+			if err := raw.Decode(&v); err != nil {
+				return nil, err
+			}
+			if fs.ExtraProps == nil {
+				fs.ExtraProps = make(map[xml.Name]string)
+			}
+			fs.ExtraProps[name] = strings.TrimSpace(v.Text)
+		}
+	}
+
+	return fs, nil
+}
+
+// Quota fetches the number of bytes used by a resource and the number of bytes
+// still available to it, as defined in RFC 4331. If the server only reports
+// one of the values, the other one is set to -1.
+//
+// ErrQuotaNotSupported is returned if the server doesn't report quota
+// information.
+func (c *Client) Quota(ctx context.Context, name string) (used, available int64, err error) {
+	propfind := internal.NewPropNamePropFind(
+		internal.QuotaUsedBytesName,
+		internal.QuotaAvailableBytesName,
+	)
+	resp, err := c.ic.PropFindFlat(ctx, name, propfind)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	used, available = -1, -1
+
+	var usedBytes internal.QuotaUsedBytes
+	if err := resp.DecodeProp(&usedBytes); err == nil {
+		used = usedBytes.Bytes
+	} else if !internal.IsNotFound(err) {
+		return 0, 0, err
+	}
+
+	var availableBytes internal.QuotaAvailableBytes
+	if err := resp.DecodeProp(&availableBytes); err == nil {
+		available = availableBytes.Bytes
+	} else if !internal.IsNotFound(err) {
+		return 0, 0, err
+	}
+
+	if used < 0 && available < 0 {
+		return 0, 0, ErrQuotaNotSupported
+	}
+	return used, available, nil
+}
+
+// GetACL fetches the access control entries of a resource, as defined in RFC
+// 3744 section 5.5.
+func (c *Client) GetACL(ctx context.Context, name string) ([]ACE, error) {
+	resp, err := c.ic.PropFindFlat(ctx, name, internal.NewPropNamePropFind(internal.ACLName))
+	if err != nil {
+		return nil, err
+	}
+
+	var iacl internal.ACL
+	if err := resp.DecodeProp(&iacl); err != nil {
+		return nil, err
+	}
+	acl, err := decodeACL(&iacl)
+	if err != nil {
+		return nil, err
+	}
+	return acl.ACEs, nil
+}
+
+// GetOwner fetches the URL of the principal owning a resource, as defined in
+// RFC 3744 section 5.1. An empty string is returned if the resource has no
+// owner.
+func (c *Client) GetOwner(ctx context.Context, name string) (string, error) {
+	resp, err := c.ic.PropFindFlat(ctx, name, internal.NewPropNamePropFind(internal.OwnerName))
+	if err != nil {
+		return "", err
+	}
+
+	var owner internal.ResourceOwner
+	if ok, err := resp.DecodePropOK(&owner); err != nil {
+		return "", err
+	} else if !ok || owner.Href == nil {
+		return "", nil
+	}
+	return owner.Href.Path, nil
+}
+
+// CurrentUserPrivileges fetches the privileges granted to the current user on
+// a resource, as defined in RFC 3744 section 5.4.
+func (c *Client) CurrentUserPrivileges(ctx context.Context, name string) ([]Privilege, error) {
+	resp, err := c.ic.PropFindFlat(ctx, name, internal.NewPropNamePropFind(internal.CurrentUserPrivilegeSetName))
+	if err != nil {
+		return nil, err
+	}
+
+	var set internal.CurrentUserPrivilegeSet
+	if err := resp.DecodeProp(&set); err != nil {
+		return nil, err
+	}
+	return decodePrivileges(set.Privilege), nil
+}
+
+func newPrivileges(names []xml.Name) []Privilege {
+	if names == nil {
+		return nil
+	}
+	privs := make([]Privilege, len(names))
+	for i, name := range names {
+		privs[i] = Privilege(name)
+	}
+	return privs
+}
+
+// Open fetches a file's contents. Cancelling ctx aborts the download: reading
+// from the returned body fails afterwards.
 func (c *Client) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	return c.OpenWithOptions(ctx, name, nil)
+}
+
+// OpenWithOptions fetches a file's contents, with the provided options.
+func (c *Client) OpenWithOptions(ctx context.Context, name string, options *OpenOptions) (io.ReadCloser, error) {
+	if options == nil {
+		options = new(OpenOptions)
+	}
+
 	req, err := c.ic.NewRequest(http.MethodGet, name, nil)
 	if err != nil {
 		return nil, err
@@ -149,51 +407,211 @@ func (c *Client) Open(ctx context.Context, name string) (io.ReadCloser, error) {
 		return nil, err
 	}
 
+	if options.Progress != nil {
+		return &progressReader{
+			ReadCloser: resp.Body,
+			progress:   progress{f: options.Progress, total: resp.ContentLength},
+		}, nil
+	}
+	return resp.Body, nil
+}
+
+// ReadRange fetches length bytes of a file, starting at offset. If length is
+// negative, the file is read until its end.
+//
+// ErrRangeNotSupported is returned if the server doesn't support range
+// requests.
+func (c *Client) ReadRange(ctx context.Context, name string, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 || length == 0 {
+		return nil, fmt.Errorf("webdav: invalid range")
+	}
+
+	req, err := c.ic.NewRequest(http.MethodGet, name, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	end := ""
+	if length > 0 {
+		end = strconv.FormatInt(offset+length-1, 10)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%v-%v", offset, end))
+
+	resp, err := c.ic.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, ErrRangeNotSupported
+	}
+
+	var start, last int64
+	contentRange := resp.Header.Get("Content-Range")
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/", &start, &last); err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("webdav: invalid Content-Range header %q: %v", contentRange, err)
+	}
+	if start != offset || (length > 0 && last > offset+length-1) {
+		resp.Body.Close()
+		return nil, fmt.Errorf("webdav: server returned unexpected range %q", contentRange)
+	}
+
 	return resp.Body, nil
 }
 
 // ReadDir lists files in a directory.
 func (c *Client) ReadDir(ctx context.Context, name string, recursive bool) ([]FileInfo, error) {
+	var l []FileInfo
+	err := c.ReadDirStream(ctx, name, recursive, func(fi *FileInfo) error {
+		l = append(l, *fi)
+		return nil
+	})
+	return l, err
+}
+
+// ReadDirStream lists files in a directory, calling fn for each file as the
+// server's response is received. Unlike ReadDir, memory usage doesn't depend
+// on the number of files. If fn returns an error, listing stops and the error
+// is returned.
+func (c *Client) ReadDirStream(ctx context.Context, name string, recursive bool, fn func(fi *FileInfo) error) error {
 	depth := internal.DepthOne
 	if recursive {
 		depth = internal.DepthInfinity
 	}
 
-	ms, err := c.ic.PropFind(ctx, name, depth, fileInfoPropFind)
-	if err != nil {
-		return nil, err
-	}
-
-	l := make([]FileInfo, 0, len(ms.Responses))
-	for _, resp := range ms.Responses {
-		fi, err := fileInfoFromResponse(&resp)
+	return c.ic.PropFindStream(ctx, name, depth, fileInfoPropFind, func(resp *internal.Response) error {
+		fi, err := fileInfoFromResponse(resp)
 		if err != nil {
-			return l, err
+			return err
 		}
-		l = append(l, *fi)
+		return fn(fi)
+	})
+}
+
+// progressInterval is the default number of bytes transferred between two
+// calls to a ProgressFunc.
+const progressInterval = 64 * 1024
+
+type progress struct {
+	f                   ProgressFunc
+	total               int64
+	interval            int64
+	transferred, notify int64
+}
+
+func (p *progress) add(n int, eof bool) {
+	if p.f == nil {
+		return
 	}
+	interval := p.interval
+	if interval <= 0 {
+		interval = progressInterval
+	}
+	p.transferred += int64(n)
+	if p.transferred-p.notify >= interval || (eof && p.transferred != p.notify) {
+		p.notify = p.transferred
+		p.f(p.transferred, p.total)
+	}
+}
 
-	return l, nil
+type progressReader struct {
+	io.ReadCloser
+	progress progress
+}
+
+func (pr *progressReader) Read(b []byte) (int, error) {
+	n, err := pr.ReadCloser.Read(b)
+	pr.progress.add(n, err == io.EOF)
+	return n, err
 }
 
 type fileWriter struct {
-	pw   *io.PipeWriter
-	done <-chan error
+	pw       *io.PipeWriter
+	done     <-chan error
+	progress progress
 }
 
 func (fw *fileWriter) Write(b []byte) (int, error) {
-	return fw.pw.Write(b)
+	n, err := fw.pw.Write(b)
+	fw.progress.add(n, false)
+	return n, err
 }
 
 func (fw *fileWriter) Close() error {
 	if err := fw.pw.Close(); err != nil {
 		return err
 	}
+	fw.progress.add(0, true)
 	return <-fw.done
 }
 
+// SyncCollection returns the members of a collection which have changed since
+// the synchronization identified by query.SyncToken, as defined in RFC 6578.
+// If the server has truncated the results, SyncResponse.Truncated is set.
+func (c *Client) SyncCollection(ctx context.Context, name string, query *SyncQuery) (*SyncResponse, error) {
+	var limit *internal.Limit
+	if query.Limit > 0 {
+		limit = &internal.Limit{NResults: uint(query.Limit)}
+	}
+
+	level := internal.DepthOne
+	if query.Recursive {
+		level = internal.DepthInfinity
+	}
+
+	ms, err := c.ic.SyncCollection(ctx, name, query.SyncToken, level, limit, fileInfoPropFind.Prop)
+	if err != nil {
+		return nil, err
+	}
+
+	root := c.ic.ResolveHref(name).Path
+	ret := &SyncResponse{SyncToken: ms.SyncToken}
+	for _, resp := range ms.Responses {
+		if resp.Status != nil && resp.Status.Code == http.StatusInsufficientStorage {
+			// The server has truncated the results, see RFC 6578 section 3.6
+			ret.Truncated = true
+			continue
+		}
+
+		p, err := resp.Path()
+		if httpErr, ok := err.(*internal.HTTPError); ok && httpErr.Code == http.StatusNotFound {
+			ret.Deleted = append(ret.Deleted, p)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if strings.TrimSuffix(p, "/") == strings.TrimSuffix(root, "/") {
+			continue
+		}
+
+		fi, err := fileInfoFromResponse(&resp)
+		if err != nil {
+			return nil, err
+		}
+		ret.Updated = append(ret.Updated, *fi)
+	}
+
+	return ret, nil
+}
+
 // Create writes a file's contents.
 func (c *Client) Create(ctx context.Context, name string) (io.WriteCloser, error) {
+	return c.CreateWithOptions(ctx, name, nil)
+}
+
+// CreateWithOptions writes a file's contents. Options can be used to make the
+// write conditional.
+//
+// A *PreconditionFailedError is returned by the writer's Close method if the
+// conditions aren't met.
+func (c *Client) CreateWithOptions(ctx context.Context, name string, options *CreateOptions) (io.WriteCloser, error) {
+	if options == nil {
+		options = new(CreateOptions)
+	}
+
 	pr, pw := io.Pipe()
 
 	req, err := c.ic.NewRequest(http.MethodPut, name, pr)
@@ -202,18 +620,140 @@ func (c *Client) Create(ctx context.Context, name string) (io.WriteCloser, error
 		return nil, err
 	}
 
+	if options.IfMatch.IsSet() {
+		req.Header.Set("If-Match", string(options.IfMatch))
+	}
+	if options.IfNoneMatch.IsSet() {
+		req.Header.Set("If-None-Match", string(options.IfNoneMatch))
+	}
+
 	done := make(chan error, 1)
 	go func() {
 		resp, err := c.ic.Do(req.WithContext(ctx))
+		if httpErr, ok := err.(*internal.HTTPError); ok && httpErr.Code == http.StatusPreconditionFailed {
+			err = &PreconditionFailedError{Err: err}
+		}
 		if err != nil {
+			// Unblock pending writes if the request failed before the whole
+			// body has been consumed, e.g. because the context was cancelled
+			pr.CloseWithError(err)
 			done <- err
 			return
 		}
+		if options.Result != nil {
+			*options.Result = *newWriteResult(resp)
+		}
 		resp.Body.Close()
 		done <- nil
 	}()
 
-	return &fileWriter{pw, done}, nil
+	fw := &fileWriter{pw: pw, done: done}
+	if options.Progress != nil {
+		total := options.Size
+		if total <= 0 {
+			total = -1
+		}
+		fw.progress = progress{f: options.Progress, total: total}
+	}
+	return fw, nil
+}
+
+// PutStream uploads the contents of r to a file. Unlike Create, the upload
+// is performed synchronously and PutStream returns once the server has
+// replied. Cancelling ctx aborts the upload.
+//
+// The request is sent with "Expect: 100-continue", so that the body isn't
+// transmitted if the server rejects the request upfront, e.g. because a
+// precondition failed.
+func (c *Client) PutStream(ctx context.Context, name string, r io.Reader, options *PutOptions) error {
+	if options == nil {
+		options = new(PutOptions)
+	}
+
+	total := options.Size
+	if total <= 0 {
+		total = -1
+	}
+
+	body := r
+	if options.Progress != nil {
+		body = &progressReader{
+			ReadCloser: ioutil.NopCloser(r),
+			progress: progress{
+				f:        options.Progress,
+				total:    total,
+				interval: options.ProgressInterval,
+			},
+		}
+	}
+
+	req, err := c.ic.NewRequest(http.MethodPut, name, body)
+	if err != nil {
+		return err
+	}
+	if total > 0 {
+		req.ContentLength = total
+	}
+	if options.GetBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			r, err := options.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			return ioutil.NopCloser(r), nil
+		}
+	}
+	req.Header.Set("Expect", "100-continue")
+	if options.ETag != "" {
+		req.Header.Set("If-Match", internal.ETag(options.ETag).String())
+	}
+	if options.NoOverwrite {
+		req.Header.Set("If-None-Match", "*")
+	}
+	if options.Language != "" {
+		req.Header.Set("Content-Language", options.Language)
+	}
+
+	resp, err := c.ic.Do(req.WithContext(ctx))
+	if httpErr, ok := err.(*internal.HTTPError); ok && httpErr.Code == http.StatusPreconditionFailed {
+		return &PreconditionFailedError{Err: err}
+	} else if err != nil {
+		return err
+	}
+	if options.Result != nil {
+		*options.Result = *newWriteResult(resp)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// newWriteResult extracts the information about a written resource from the
+// server's response. Malformed header fields are ignored: the write has
+// succeeded regardless.
+func newWriteResult(resp *http.Response) *WriteResult {
+	res := &WriteResult{StatusCode: resp.StatusCode}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		weak := strings.HasPrefix(etag, "W/")
+		if s, err := strconv.Unquote(strings.TrimPrefix(etag, "W/")); err == nil {
+			res.ETag = s
+			res.Weak = weak
+		}
+	}
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		if t, err := http.ParseTime(lastModified); err == nil {
+			res.LastModified = t
+		}
+	}
+	if loc := resp.Header.Get("Location"); loc != "" {
+		base := &url.URL{}
+		if resp.Request != nil {
+			base = resp.Request.URL
+		}
+		if u, err := base.Parse(loc); err == nil {
+			res.Location = u.Path
+		}
+	}
+	return res
 }
 
 // RemoveAll deletes a file. If the file is a directory, all of its descendants
@@ -234,7 +774,39 @@ func (c *Client) RemoveAll(ctx context.Context, name string) error {
 
 // Mkdir creates a new directory.
 func (c *Client) Mkdir(ctx context.Context, name string) error {
+	_, err := c.MkdirWithResult(ctx, name)
+	return err
+}
+
+// MkdirWithResult is like Mkdir, but also returns the server's response.
+func (c *Client) MkdirWithResult(ctx context.Context, name string) (*WriteResult, error) {
 	req, err := c.ic.NewRequest("MKCOL", name, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.ic.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return newWriteResult(resp), nil
+}
+
+// MkdirWithProps creates a new directory and sets its initial properties in
+// the same request, using an extended MKCOL request (RFC 5689). The values to
+// set must be structs with an XMLName field.
+//
+// If the server fails to set some of the properties, the directory isn't
+// created and the returned error describes the failing properties.
+func (c *Client) MkdirWithProps(ctx context.Context, name string, props []interface{}) error {
+	prop, err := internal.EncodeProp(append([]interface{}{internal.NewResourceType(internal.CollectionName)}, props...)...)
+	if err != nil {
+		return err
+	}
+
+	mkcol := internal.Mkcol{Set: []internal.Set{{Prop: *prop}}}
+	req, err := c.ic.NewXMLRequest("MKCOL", name, &mkcol)
 	if err != nil {
 		return err
 	}
@@ -250,15 +822,24 @@ func (c *Client) Mkdir(ctx context.Context, name string) error {
 // Copy copies a file.
 //
 // By default, if the file is a directory, all descendants are recursively
-// copied as well.
+// copied as well. If some of the descendants couldn't be copied, a *CopyError
+// is returned.
 func (c *Client) Copy(ctx context.Context, name, dest string, options *CopyOptions) error {
+	_, err := c.CopyWithResult(ctx, name, dest, options)
+	return err
+}
+
+// CopyWithResult is like Copy, but also returns the server's response. The
+// status code is http.StatusCreated if the destination was created, as
+// opposed to overwritten.
+func (c *Client) CopyWithResult(ctx context.Context, name, dest string, options *CopyOptions) (*WriteResult, error) {
 	if options == nil {
 		options = new(CopyOptions)
 	}
 
 	req, err := c.ic.NewRequest("COPY", name, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	depth := internal.DepthInfinity
@@ -271,27 +852,265 @@ func (c *Client) Copy(ctx context.Context, name, dest string, options *CopyOptio
 	req.Header.Set("Depth", depth.String())
 
 	resp, err := c.ic.Do(req.WithContext(ctx))
-	if err != nil {
-		return err
+	if httpErr, ok := err.(*internal.HTTPError); ok && httpErr.Code == http.StatusPreconditionFailed && options.NoOverwrite {
+		return nil, ErrDestinationExists
+	} else if err != nil {
+		return nil, err
 	}
-	resp.Body.Close()
-	return nil
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusMultiStatus {
+		errs, err := decodeResourceErrors(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if len(errs) > 0 {
+			return nil, &CopyError{Errors: errs}
+		}
+	}
+
+	return newWriteResult(resp), nil
+}
+
+// decodeResourceErrors collects the failed resources in a multi-status
+// response.
+func decodeResourceErrors(r io.Reader) ([]ResourceError, error) {
+	dec := internal.NewMultiStatusDecoder(r)
+
+	var errs []ResourceError
+	for {
+		resp, err := dec.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if err := resp.Err(); err != nil {
+			var path string
+			if len(resp.Hrefs) > 0 {
+				path = resp.Hrefs[0].Path
+			}
+			errs = append(errs, ResourceError{Path: path, Err: err})
+		}
+	}
+
+	return errs, nil
 }
 
 // Move moves a file.
+//
+// If the file is a directory and some of its descendants couldn't be moved, a
+// *MoveError is returned.
 func (c *Client) Move(ctx context.Context, name, dest string, options *MoveOptions) error {
+	_, err := c.MoveWithResult(ctx, name, dest, options)
+	return err
+}
+
+// MoveWithResult is like Move, but also returns the server's response. The
+// status code is http.StatusCreated if the destination was created, as
+// opposed to overwritten.
+func (c *Client) MoveWithResult(ctx context.Context, name, dest string, options *MoveOptions) (*WriteResult, error) {
 	if options == nil {
 		options = new(MoveOptions)
 	}
 
 	req, err := c.ic.NewRequest("MOVE", name, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	req.Header.Set("Destination", c.ic.ResolveHref(dest).String())
+	destURL := c.ic.ResolveHref(dest)
+	if strings.TrimSuffix(destURL.Path, "/") == strings.TrimSuffix(req.URL.Path, "/") {
+		return nil, fmt.Errorf("webdav: source and destination of MOVE are the same resource")
+	}
+
+	req.Header.Set("Destination", destURL.String())
 	req.Header.Set("Overwrite", internal.FormatOverwrite(!options.NoOverwrite))
 
+	resp, err := c.ic.Do(req.WithContext(ctx))
+	if httpErr, ok := err.(*internal.HTTPError); ok && httpErr.Code == http.StatusPreconditionFailed && options.NoOverwrite {
+		return nil, ErrDestinationExists
+	} else if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusMultiStatus {
+		errs, err := decodeResourceErrors(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if len(errs) > 0 {
+			return nil, &MoveError{Errors: errs}
+		}
+	}
+
+	return newWriteResult(resp), nil
+}
+
+// Patch sets and removes properties of a file. The values to set must be
+// structs with an XMLName field.
+func (c *Client) Patch(ctx context.Context, name string, set []interface{}, remove []xml.Name) error {
+	update, err := internal.NewPropertyUpdate(set, remove)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.ic.PropPatch(ctx, name, update)
+	if err != nil {
+		return err
+	}
+	if err := resp.Err(); err != nil {
+		return err
+	}
+
+	// Since updates are atomic, most properties might have failed with "424
+	// Failed Dependency": try to report the property which caused the failure
+	var failed error
+	failedDependency := false
+	for _, propstat := range resp.PropStats {
+		err := propstat.Status.Err()
+		if err == nil || (failed != nil && !failedDependency) {
+			continue
+		}
+		isFailedDependency := propstat.Status.Code == http.StatusFailedDependency
+		if failed != nil && isFailedDependency {
+			continue
+		}
+		for _, raw := range propstat.Prop.Raw {
+			if name, ok := raw.XMLName(); ok {
+				err = fmt.Errorf("webdav: failed to update property <%v %v>: %w", name.Space, name.Local, err)
+				break
+			}
+		}
+		failed = err
+		failedDependency = isFailedDependency
+	}
+
+	return failed
+}
+
+// PropPatch sets and removes properties of a file in a single PROPPATCH
+// request, and returns the status of each property. Unlike Patch, properties
+// rejected by the server aren't reported as an error.
+func (c *Client) PropPatch(ctx context.Context, name string, req *PropPatchRequest) ([]PropStatus, error) {
+	set := make([]interface{}, len(req.Set))
+	for i := range req.Set {
+		set[i] = &req.Set[i]
+	}
+	update, err := internal.NewPropertyUpdate(set, req.Remove)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.ic.PropPatch(ctx, name, update)
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Err(); err != nil {
+		return nil, err
+	}
+
+	var l []PropStatus
+	for _, propstat := range resp.PropStats {
+		for _, raw := range propstat.Prop.Raw {
+			if name, ok := raw.XMLName(); ok {
+				l = append(l, PropStatus{Name: name, StatusCode: propstat.Status.Code})
+			}
+		}
+	}
+	return l, nil
+}
+
+// Lock takes out a write lock on a file, or refreshes an existing lock if
+// options.Token is set. It returns the lock token.
+//
+// The lock token needs to be passed to Unlock to release the lock.
+func (c *Client) Lock(ctx context.Context, name string, options *LockOptions) (token string, err error) {
+	if options == nil {
+		options = new(LockOptions)
+	}
+
+	var req *http.Request
+	if options.Token != "" {
+		req, err = c.ic.NewRequest("LOCK", name, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("If", "("+internal.FormatLockToken(options.Token)+")")
+	} else {
+		lockInfo := internal.LockInfo{
+			LockType: internal.LockType{Write: &struct{}{}},
+		}
+		if options.Shared {
+			lockInfo.LockScope.Shared = &struct{}{}
+		} else {
+			lockInfo.LockScope.Exclusive = &struct{}{}
+		}
+		if options.Owner != "" {
+			var sb strings.Builder
+			if err := xml.EscapeText(&sb, []byte(options.Owner)); err != nil {
+				return "", err
+			}
+			lockInfo.Owner = &internal.Owner{InnerXML: sb.String()}
+		}
+
+		req, err = c.ic.NewXMLRequest("LOCK", name, &lockInfo)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if options.Timeout != 0 {
+		req.Header.Set("Timeout", internal.FormatTimeout(options.Timeout))
+	}
+
+	resp, err := c.ic.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	token = options.Token
+	if s := resp.Header.Get("Lock-Token"); s != "" {
+		token, err = internal.ParseLockToken(s)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	var prop internal.Prop
+	if err := xml.NewDecoder(resp.Body).Decode(&prop); err != nil {
+		return "", err
+	}
+	var lockDiscovery internal.LockDiscovery
+	if err := prop.Decode(&lockDiscovery); err != nil {
+		return "", err
+	}
+
+	for _, activeLock := range lockDiscovery.ActiveLocks {
+		if activeLock.LockToken == nil {
+			continue
+		}
+		activeToken := activeLock.LockToken.Href.String()
+		if token == "" || token == activeToken {
+			return activeToken, nil
+		}
+	}
+
+	return "", fmt.Errorf("webdav: lock token missing from LOCK response")
+}
+
+// Unlock releases a lock previously taken out with Lock.
+func (c *Client) Unlock(ctx context.Context, name, token string) error {
+	req, err := c.ic.NewRequest("UNLOCK", name, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Lock-Token", internal.FormatLockToken(token))
+
 	resp, err := c.ic.Do(req.WithContext(ctx))
 	if err != nil {
 		return err
@@ -0,0 +1,595 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+func newTestClient(t *testing.T, h http.Handler) *Client {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(nil, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClient_Copy(t *testing.T) {
+	fs := newTestTree(t)
+	c := newTestClient(t, &Handler{FileSystem: fs})
+	ctx := context.Background()
+
+	res, err := c.CopyWithResult(ctx, "/a/1.txt", "/d/1.txt", nil)
+	if err != nil {
+		t.Fatalf("CopyWithResult() = %v", err)
+	} else if res.StatusCode != http.StatusCreated {
+		t.Errorf("status = %v, want %v", res.StatusCode, http.StatusCreated)
+	}
+
+	res, err = c.CopyWithResult(ctx, "/a/b/2.txt", "/d/1.txt", nil)
+	if err != nil {
+		t.Fatalf("CopyWithResult() = %v", err)
+	} else if res.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %v, want %v", res.StatusCode, http.StatusNoContent)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(string(fs), "d", "1.txt")); err != nil {
+		t.Fatal(err)
+	} else if string(b) != "a/b/2.txt" {
+		t.Errorf("copied file contains %q, want %q", b, "a/b/2.txt")
+	}
+
+	if err := c.Copy(ctx, "/a/1.txt", "/d/1.txt", &CopyOptions{NoOverwrite: true}); err != ErrDestinationExists {
+		t.Errorf("Copy() with NoOverwrite = %v, want %v", err, ErrDestinationExists)
+	}
+
+	if err := c.Copy(ctx, "/a/b", "/d/b", &CopyOptions{NoRecursive: true}); err != nil {
+		t.Fatalf("Copy() with NoRecursive = %v", err)
+	}
+	if entries, err := ioutil.ReadDir(filepath.Join(string(fs), "d", "b")); err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 0 {
+		t.Errorf("NoRecursive copy has %v members, want 0", len(entries))
+	}
+
+	if err := c.Copy(ctx, "/a", "/e", nil); err != nil {
+		t.Fatalf("Copy() = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(string(fs), "e", "b", "c", "3.txt")); err != nil {
+		t.Errorf("recursive copy is missing a member: %v", err)
+	}
+}
+
+func TestCopyError_Error(t *testing.T) {
+	if got, want := (&CopyError{}).Error(), "webdav: failed to copy resources"; got != want {
+		t.Errorf("CopyError{}.Error() = %q, want %q", got, want)
+	}
+	err := &CopyError{Errors: []ResourceError{{Path: "/a/1.txt", Err: errors.New("locked")}}}
+	if got, want := err.Error(), "webdav: failed to copy 1 resources (first error: /a/1.txt: locked)"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestClient_Move(t *testing.T) {
+	fs := newTestTree(t)
+	c := newTestClient(t, &Handler{FileSystem: fs})
+	ctx := context.Background()
+
+	res, err := c.MoveWithResult(ctx, "/a/1.txt", "/d/1.txt", nil)
+	if err != nil {
+		t.Fatalf("MoveWithResult() = %v", err)
+	} else if res.StatusCode != http.StatusCreated {
+		t.Errorf("status = %v, want %v", res.StatusCode, http.StatusCreated)
+	}
+	if _, err := os.Stat(filepath.Join(string(fs), "a", "1.txt")); !os.IsNotExist(err) {
+		t.Errorf("source still exists after move: %v", err)
+	}
+
+	if err := c.Move(ctx, "/a/b/2.txt", "/d/1.txt", &MoveOptions{NoOverwrite: true}); err != ErrDestinationExists {
+		t.Errorf("Move() with NoOverwrite = %v, want %v", err, ErrDestinationExists)
+	}
+
+	res, err = c.MoveWithResult(ctx, "/a/b/2.txt", "/d/1.txt", nil)
+	if err != nil {
+		t.Fatalf("MoveWithResult() = %v", err)
+	} else if res.StatusCode != http.StatusNoContent {
+		t.Errorf("status = %v, want %v", res.StatusCode, http.StatusNoContent)
+	}
+
+	if err := c.Move(ctx, "/a/b", "/a/b/", nil); err == nil {
+		t.Errorf("Move() to the same resource with a trailing slash succeeded")
+	}
+
+	if err := c.Move(ctx, "/a/b/", "/e", nil); err != nil {
+		t.Fatalf("Move() = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(string(fs), "e", "c", "3.txt")); err != nil {
+		t.Errorf("moved collection is missing a member: %v", err)
+	}
+}
+
+func TestMoveError_Error(t *testing.T) {
+	if got, want := (&MoveError{}).Error(), "webdav: failed to move resources"; got != want {
+		t.Errorf("MoveError{}.Error() = %q, want %q", got, want)
+	}
+	err := &MoveError{Errors: []ResourceError{{Path: "/a/1.txt", Err: errors.New("locked")}}}
+	if got, want := err.Error(), "webdav: failed to move 1 resources (first error: /a/1.txt: locked)"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestClient_PropPatch(t *testing.T) {
+	ctx := context.Background()
+	colorName := xml.Name{"urn:example", "color"}
+	sizeName := xml.Name{"urn:example", "size"}
+
+	b := NewMemBackend()
+	if _, _, err := b.Create(ctx, "/file", ioutil.NopCloser(strings.NewReader("hello"))); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestClient(t, &Handler{FileSystem: b})
+
+	statuses, err := c.PropPatch(ctx, "/file", &PropPatchRequest{
+		Set:    []Property{{XMLName: colorName, InnerXML: []byte("red")}},
+		Remove: []xml.Name{sizeName},
+	})
+	if err != nil {
+		t.Fatalf("PropPatch() = %v", err)
+	}
+	want := []PropStatus{
+		{Name: colorName, StatusCode: http.StatusOK},
+		{Name: sizeName, StatusCode: http.StatusOK},
+	}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("PropPatch() = %v, want %v", statuses, want)
+	}
+
+	props, err := b.Props(ctx, "/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, prop := range props {
+		if prop.XMLName == colorName {
+			found = true
+			if string(prop.InnerXML) != "red" {
+				t.Errorf("property value = %q, want %q", prop.InnerXML, "red")
+			}
+		}
+	}
+	if !found {
+		t.Errorf("property %v wasn't stored", colorName)
+	}
+
+	if _, err := c.PropPatch(ctx, "/missing", &PropPatchRequest{Remove: []xml.Name{sizeName}}); !internal.IsNotFound(err) {
+		t.Errorf("PropPatch() on a missing file = %v, want a not found error", err)
+	}
+
+	// LocalFileSystem doesn't store properties
+	c = newTestClient(t, &Handler{FileSystem: newTestTree(t)})
+	statuses, err = c.PropPatch(ctx, "/a/1.txt", &PropPatchRequest{
+		Set: []Property{{XMLName: colorName, InnerXML: []byte("red")}},
+	})
+	if err != nil {
+		t.Fatalf("PropPatch() = %v", err)
+	}
+	want = []PropStatus{{Name: colorName, StatusCode: http.StatusForbidden}}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("PropPatch() = %v, want %v", statuses, want)
+	}
+}
+
+func TestClient_PropFindTree(t *testing.T) {
+	c := newTestClient(t, &Handler{FileSystem: newTestTree(t)})
+
+	for _, tc := range []struct {
+		depth Depth
+		want  []string
+	}{
+		{DepthZero, []string{"/a/"}},
+		{DepthOne, []string{"/a/", "/a/1.txt", "/a/b/"}},
+		{DepthInfinity, []string{"/a/", "/a/1.txt", "/a/b/", "/a/b/2.txt", "/a/b/c/", "/a/b/c/3.txt"}},
+	} {
+		l, err := c.PropFindTree(context.Background(), "/a/", tc.depth)
+		if err != nil {
+			t.Fatalf("PropFindTree(%v) = %v", tc.depth, err)
+		}
+		var hrefs []string
+		for _, fs := range l {
+			hrefs = append(hrefs, fs.Href)
+		}
+		sort.Strings(hrefs)
+		if !reflect.DeepEqual(hrefs, tc.want) {
+			t.Errorf("PropFindTree(%v) = %v, want %v", tc.depth, hrefs, tc.want)
+		}
+	}
+}
+
+// leakingHandler adds a response for a resource outside of the requested
+// collection to PROPFIND responses.
+func leakingHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PROPFIND" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		body := strings.Replace(rec.Body.String(), "</multistatus>", `<response><href>/d/</href><propstat><prop><resourcetype><collection/></resourcetype></prop><status>HTTP/1.1 200 OK</status></propstat></response></multistatus>`, 1)
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.Header().Del("Content-Length")
+		w.WriteHeader(rec.Code)
+		io.WriteString(w, body)
+	})
+}
+
+func TestClient_ReadDir_outOfTree(t *testing.T) {
+	c := newTestClient(t, leakingHandler(&Handler{FileSystem: newTestTree(t)}))
+	ctx := context.Background()
+
+	l, err := c.ReadDir(ctx, "/a/", false)
+	if err != nil {
+		t.Fatalf("ReadDir() = %v", err)
+	}
+	var paths []string
+	for _, fi := range l {
+		paths = append(paths, fi.Path)
+	}
+	sort.Strings(paths)
+	// ReadDir returns every response, e.g. when a proxy rewrites the paths
+	if want := []string{"/a/", "/a/1.txt", "/a/b/", "/d/"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("ReadDir() = %v, want %v", paths, want)
+	}
+
+	if _, err := c.PropFindTree(ctx, "/a/", DepthOne); err == nil {
+		t.Errorf("PropFindTree() succeeded despite a response outside of the tree")
+	}
+}
+
+func TestClient_StatFile(t *testing.T) {
+	ctx := context.Background()
+	colorName := xml.Name{"urn:example", "color"}
+	sizeName := xml.Name{"urn:example", "size"}
+
+	b := NewMemBackend()
+	if err := b.Mkdir(ctx, "/dir"); err != nil {
+		t.Fatal(err)
+	}
+	fi, _, err := b.Create(ctx, "/dir/file.txt", ioutil.NopCloser(strings.NewReader("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.PropPatch(ctx, "/dir/file.txt", &PropPatchRequest{
+		Set: []Property{{XMLName: colorName, InnerXML: []byte(" red ")}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestClient(t, &Handler{FileSystem: b})
+
+	fs, err := c.StatFile(ctx, "/dir/file.txt", colorName, sizeName)
+	if err != nil {
+		t.Fatalf("StatFile() = %v", err)
+	}
+	want := &FileStat{
+		Name:        "file.txt",
+		Href:        "/dir/file.txt",
+		ContentType: "text/plain; charset=utf-8",
+		ETag:        fi.ETag,
+		Size:        5,
+		ModTime:     fs.ModTime,
+		ExtraProps:  map[xml.Name]string{colorName: "red"},
+	}
+	if !reflect.DeepEqual(fs, want) {
+		t.Errorf("StatFile() = %+v, want %+v", fs, want)
+	}
+	if fs.ModTime.IsZero() {
+		t.Errorf("StatFile() has no modification time")
+	}
+
+	// Properties which weren't requested aren't reported
+	fs, err = c.StatFile(ctx, "/dir/file.txt")
+	if err != nil {
+		t.Fatalf("StatFile() = %v", err)
+	} else if fs.ExtraProps != nil {
+		t.Errorf("StatFile() without extra properties = %v", fs.ExtraProps)
+	}
+
+	fs, err = c.StatFile(ctx, "/dir/")
+	if err != nil {
+		t.Fatalf("StatFile() = %v", err)
+	}
+	if fs.Name != "dir" || fs.Href != "/dir/" || !fs.IsDir {
+		t.Errorf("StatFile() = %+v, want collection dir", fs)
+	}
+
+	if _, err := c.StatFile(ctx, "/missing"); !internal.IsNotFound(err) {
+		t.Errorf("StatFile() on a missing file = %v, want not found", err)
+	}
+}
+
+func TestClient_ReadDirFiles(t *testing.T) {
+	ctx := context.Background()
+	fs := newTestTree(t)
+	for _, name := range []string{"z.txt", "0.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(string(fs), "a", name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := newTestClient(t, &Handler{FileSystem: fs})
+
+	for _, href := range []string{"/a/", "/a"} {
+		l, err := c.ReadDirFiles(ctx, href)
+		if err != nil {
+			t.Fatalf("ReadDirFiles(%q) = %v", href, err)
+		}
+		var got []string
+		for _, fs := range l {
+			got = append(got, fs.Href)
+			if fs.Name != path.Base(strings.TrimSuffix(fs.Href, "/")) {
+				t.Errorf("name of %v = %q", fs.Href, fs.Name)
+			}
+		}
+		if want := []string{"/a/0.txt", "/a/1.txt", "/a/b/", "/a/z.txt"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadDirFiles(%q) = %v, want %v", href, got, want)
+		}
+		if b := l[2]; !b.IsDir || b.Name != "b" {
+			t.Errorf("ReadDirFiles(%q) = %+v, want collection b", href, b)
+		}
+	}
+
+	if l, err := c.ReadDirFiles(ctx, "/d/"); err != nil || len(l) != 0 {
+		t.Errorf("ReadDirFiles() on an empty collection = %v, %v", l, err)
+	}
+	if _, err := c.ReadDirFiles(ctx, "/missing/"); !internal.IsNotFound(err) {
+		t.Errorf("ReadDirFiles() on a missing collection = %v, want not found", err)
+	}
+
+	// Responses outside of the collection are returned as well
+	c = newTestClient(t, leakingHandler(&Handler{FileSystem: fs}))
+	l, err := c.ReadDirFiles(ctx, "/a/b/")
+	if err != nil {
+		t.Fatalf("ReadDirFiles() = %v", err)
+	}
+	if len(l) != 3 || l[0].Name != "2.txt" || l[1].Name != "c" || l[2].Href != "/d/" {
+		t.Errorf("ReadDirFiles() = %+v, want 2.txt, c and /d/", l)
+	}
+}
+
+func TestClient_ReadDirFiles_extraProps(t *testing.T) {
+	ctx := context.Background()
+	colorName := xml.Name{"urn:example", "color"}
+
+	b := NewMemBackend()
+	for _, name := range []string{"/red.txt", "/plain.txt"} {
+		if _, _, err := b.Create(ctx, name, ioutil.NopCloser(strings.NewReader(name))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.PropPatch(ctx, "/red.txt", &PropPatchRequest{
+		Set: []Property{{XMLName: colorName, InnerXML: []byte("red")}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c := newTestClient(t, &Handler{FileSystem: b})
+
+	l, err := c.ReadDirFiles(ctx, "/", colorName)
+	if err != nil {
+		t.Fatalf("ReadDirFiles() = %v", err)
+	}
+	if len(l) != 2 {
+		t.Fatalf("ReadDirFiles() = %+v, want 2 files", l)
+	}
+	if l[0].Name != "plain.txt" || l[0].ExtraProps != nil {
+		t.Errorf("ReadDirFiles()[0] = %+v, want plain.txt without extra properties", l[0])
+	}
+	if l[1].Name != "red.txt" || l[1].ExtraProps[colorName] != "red" {
+		t.Errorf("ReadDirFiles()[1] = %+v, want red.txt with color red", l[1])
+	}
+}
+
+// propFindHandler replies to PROPFIND requests with a single response for the
+// request URL, containing the provided propstat elements.
+func propFindHandler(propstats string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><D:multistatus xmlns:D="DAV:"><D:response><D:href>%v</D:href>%v</D:response></D:multistatus>`, r.URL.Path, propstats)
+	})
+}
+
+func propStat(code int, prop string) string {
+	return fmt.Sprintf(`<D:propstat><D:prop>%v</D:prop><D:status>HTTP/1.1 %v %v</D:status></D:propstat>`, prop, code, http.StatusText(code))
+}
+
+func TestClient_GetACL(t *testing.T) {
+	ctx := context.Background()
+	fs := &aclFileSystem{FileSystem: newTestTree(t), acls: map[string]*ACL{"/a/1.txt": testACL}}
+	c := newTestClient(t, &Handler{FileSystem: fs})
+
+	aces, err := c.GetACL(ctx, "/a/1.txt")
+	if err != nil {
+		t.Fatalf("GetACL() = %v", err)
+	}
+	if !reflect.DeepEqual(aces, testACL.ACEs) {
+		t.Errorf("GetACL() = %+v, want %+v", aces, testACL.ACEs)
+	}
+
+	if aces, err := c.GetACL(ctx, "/a/b/2.txt"); err != nil || len(aces) != 0 {
+		t.Errorf("GetACL() on a file without ACEs = %+v, %v", aces, err)
+	}
+
+	c = newTestClient(t, &Handler{FileSystem: newTestTree(t)})
+	if _, err := c.GetACL(ctx, "/a/1.txt"); err == nil {
+		t.Errorf("GetACL() succeeded without ACL support")
+	}
+}
+
+func TestClient_GetOwner(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		propstats string
+		want      string
+		wantErr   bool
+	}{
+		{"href", propStat(http.StatusOK, `<D:owner><D:href>/principals/alice/</D:href></D:owner>`), "/principals/alice/", false},
+		{"empty", propStat(http.StatusOK, `<D:owner/>`), "", false},
+		{"not found", propStat(http.StatusNotFound, `<D:owner/>`), "", false},
+		{"forbidden", propStat(http.StatusForbidden, `<D:owner/>`), "", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestClient(t, propFindHandler(tc.propstats))
+			owner, err := c.GetOwner(context.Background(), "/file")
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("GetOwner() = %q, want an error", owner)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetOwner() = %v", err)
+			}
+			if owner != tc.want {
+				t.Errorf("GetOwner() = %q, want %q", owner, tc.want)
+			}
+		})
+	}
+}
+
+func TestClient_Stat_privilegesError(t *testing.T) {
+	for _, code := range []int{http.StatusForbidden, http.StatusInternalServerError, http.StatusFailedDependency} {
+		t.Run(http.StatusText(code), func(t *testing.T) {
+			c := newTestClient(t, propFindHandler(propStat(http.StatusOK, `<D:resourcetype><D:collection/></D:resourcetype>`)+
+				propStat(code, `<D:current-user-privilege-set/>`)))
+			fi, err := c.Stat(context.Background(), "/dir/")
+			if err != nil {
+				t.Fatalf("Stat() = %v", err)
+			}
+			if !fi.IsDir || fi.Privileges != nil {
+				t.Errorf("Stat() = %+v, want a collection with unknown privileges", fi)
+			}
+		})
+	}
+}
+
+func TestClient_CurrentUserPrivileges(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		propstats string
+		want      []Privilege
+		wantErr   bool
+	}{
+		{
+			name: "privileges",
+			propstats: propStat(http.StatusOK, `<D:current-user-privilege-set>`+
+				`<D:privilege><D:read/></D:privilege>`+
+				`<D:privilege><C:custom xmlns:C="urn:example"/></D:privilege>`+
+				`</D:current-user-privilege-set>`),
+			want: []Privilege{PrivilegeRead, {"urn:example", "custom"}},
+		},
+		{
+			name:      "empty",
+			propstats: propStat(http.StatusOK, `<D:current-user-privilege-set/>`),
+			want:      []Privilege{},
+		},
+		{
+			name:      "not found",
+			propstats: propStat(http.StatusNotFound, `<D:current-user-privilege-set/>`),
+			wantErr:   true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestClient(t, propFindHandler(tc.propstats))
+			privs, err := c.CurrentUserPrivileges(context.Background(), "/file")
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("CurrentUserPrivileges() = %v, want an error", privs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CurrentUserPrivileges() = %v", err)
+			}
+			if !reflect.DeepEqual(privs, tc.want) {
+				t.Errorf("CurrentUserPrivileges() = %v, want %v", privs, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewWriteResult(t *testing.T) {
+	lastModified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tc := range []struct {
+		name   string
+		code   int
+		header map[string]string
+		want   WriteResult
+	}{
+		{"empty", http.StatusCreated, nil, WriteResult{StatusCode: http.StatusCreated}},
+		{"strong", http.StatusNoContent, map[string]string{"ETag": `"abc"`}, WriteResult{StatusCode: http.StatusNoContent, ETag: "abc"}},
+		{"weak", http.StatusNoContent, map[string]string{"ETag": `W/"abc"`}, WriteResult{StatusCode: http.StatusNoContent, ETag: "abc", Weak: true}},
+		{"malformed", http.StatusNoContent, map[string]string{"ETag": `W/abc`}, WriteResult{StatusCode: http.StatusNoContent}},
+		{"Last-Modified", http.StatusCreated, map[string]string{"Last-Modified": lastModified.Format(http.TimeFormat)}, WriteResult{StatusCode: http.StatusCreated, LastModified: lastModified}},
+		{"malformed Last-Modified", http.StatusCreated, map[string]string{"Last-Modified": "yesterday"}, WriteResult{StatusCode: http.StatusCreated}},
+		{"Location", http.StatusCreated, map[string]string{"Location": "other.txt"}, WriteResult{StatusCode: http.StatusCreated, Location: "/dir/other.txt"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "http://example.org/dir/file.txt", nil)
+			resp := &http.Response{StatusCode: tc.code, Header: make(http.Header), Request: req}
+			for k, v := range tc.header {
+				resp.Header.Set(k, v)
+			}
+			if got := newWriteResult(resp); !reflect.DeepEqual(*got, tc.want) {
+				t.Errorf("newWriteResult() = %+v, want %+v", *got, tc.want)
+			}
+		})
+	}
+}
+
+func TestClient_CreateWithOptions_weakETag(t *testing.T) {
+	h := &Handler{FileSystem: NewMemBackend()}
+	weakHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		if etag := w.Header().Get("ETag"); etag != "" {
+			w.Header().Set("ETag", "W/"+etag)
+		}
+		w.WriteHeader(rec.Code)
+		rec.Body.WriteTo(w)
+	})
+	c := newTestClient(t, weakHandler)
+
+	var res WriteResult
+	wc, err := c.CreateWithOptions(context.Background(), "/file.txt", &CreateOptions{Result: &res})
+	if err != nil {
+		t.Fatalf("CreateWithOptions() = %v", err)
+	}
+	io.WriteString(wc, "hello")
+	if err := wc.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if res.ETag == "" || !res.Weak {
+		t.Errorf("WriteResult = %+v, want a weak ETag", res)
+	}
+}
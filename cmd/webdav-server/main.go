@@ -24,8 +24,11 @@ func main() {
 		path = "."
 	}
 
+	backend := webdav.NewOSBackend(path)
 	handler := webdav.Handler{
-		FileSystem: webdav.LocalFileSystem(path),
+		FileSystem:     backend,
+		LockBackend:    &webdav.MemLockBackend{},
+		DeadPropsStore: backend,
 	}
 	log.Printf("WebDAV server listening on %v", addr)
 	log.Fatal(http.ListenAndServe(addr, &handler))
@@ -0,0 +1,351 @@
+package webdav
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type compressionHTTPClient struct {
+	c HTTPClient
+}
+
+func (c *compressionHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	// Leave the response untouched if the caller negotiates the encoding.
+	// Don't negotiate it for range requests either: the server would send
+	// a range of the compressed representation, which can't be decompressed
+	// on its own.
+	if req.Header.Get("Accept-Encoding") != "" || req.Header.Get("Range") != "" {
+		return c.c.Do(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Method == http.MethodHead || resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+
+	var newReader func(io.Reader) (io.ReadCloser, error)
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		newReader = func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		}
+	case "deflate":
+		newReader = zlib.NewReader
+	default:
+		return resp, nil
+	}
+
+	resp.Body = &decompressBody{body: resp.Body, newReader: newReader}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// decompressBody lazily decompresses a response body, so that errors are
+// reported when reading instead of when receiving the response.
+type decompressBody struct {
+	body      io.ReadCloser
+	newReader func(io.Reader) (io.ReadCloser, error)
+	r         io.ReadCloser
+	err       error
+}
+
+func (b *decompressBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.r == nil {
+		r, err := b.newReader(b.body)
+		if err != nil {
+			b.err = err
+			return 0, err
+		}
+		b.r = r
+	}
+	return b.r.Read(p)
+}
+
+func (b *decompressBody) Close() error {
+	if b.r != nil {
+		b.r.Close()
+	}
+	return b.body.Close()
+}
+
+// HTTPClientWithCompression returns an HTTP client that requests compressed
+// responses with the Accept-Encoding header field, and transparently
+// decompresses gzip and deflate response bodies according to their
+// Content-Encoding. Responses with the identity encoding are left as-is. If c
+// is nil, http.DefaultClient is used.
+//
+// http.Transport already handles gzip this way unless DisableCompression is
+// set. This wrapper is useful with other transports, or to also accept
+// deflate. Requests with an explicit Accept-Encoding or Range header field are
+// sent unchanged and their responses aren't decompressed.
+func HTTPClientWithCompression(c HTTPClient) HTTPClient {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return &compressionHTTPClient{c}
+}
+
+// defaultCompressMinSize is the minimum size of the responses compressed by
+// Compress.
+const defaultCompressMinSize = 1024
+
+// compressEncodings lists the content codings supported by CompressMinSize,
+// by order of preference.
+var compressEncodings = []string{"zstd", "gzip"}
+
+// Compress is a middleware compressing responses with zstd or gzip when the
+// client accepts it. It's equivalent to CompressMinSize(1024).
+func Compress(next http.Handler) http.Handler {
+	return CompressMinSize(defaultCompressMinSize)(next)
+}
+
+// CompressMinSize returns a middleware compressing responses with zstd or gzip
+// when the client accepts it, e.g. large PROPFIND responses. Responses shorter
+// than n bytes are sent uncompressed.
+//
+// Only textual responses such as XML are compressed. Responses which already
+// have a Content-Encoding, partial responses and responses to HEAD requests
+// are left as-is. Streamed responses, such as multi-status responses, are
+// compressed as they're written.
+//
+// The entity tag of a compressed response is made weak, and byte ranges
+// aren't advertised, since they'd refer to the uncompressed representation.
+func CompressMinSize(n int) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if r.Method == http.MethodHead || encoding == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressResponseWriter{ResponseWriter: w, minSize: n, encoding: encoding}
+			defer cw.Close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding returns the preferred content coding supported by
+// CompressMinSize and allowed by an Accept-Encoding header field, or an empty
+// string if there is none.
+func negotiateEncoding(s string) string {
+	qvalues := make(map[string]float64)
+	wildcard := 0.0
+	for _, coding := range strings.Split(s, ",") {
+		params := strings.Split(coding, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name == "x-gzip" {
+			name = "gzip"
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.ReplaceAll(strings.TrimSpace(param), " ", "")
+			if v := strings.TrimPrefix(param, "q="); v != param {
+				var err error
+				if q, err = strconv.ParseFloat(v, 64); err != nil {
+					q = 0
+				}
+			}
+		}
+		if name == "*" {
+			wildcard = q
+		} else if name != "" {
+			qvalues[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range compressEncodings {
+		q, ok := qvalues[encoding]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// isCompressible reports whether a media type is worth compressing.
+func isCompressible(contentType string) bool {
+	t, _, _ := mime.ParseMediaType(contentType)
+	return strings.HasPrefix(t, "text/") ||
+		t == "application/xml" || strings.HasSuffix(t, "+xml") ||
+		t == "application/json" || strings.HasSuffix(t, "+json")
+}
+
+// compressWriter is implemented by gzip.Writer and zstd.Encoder.
+type compressWriter interface {
+	io.WriteCloser
+	Flush() error
+}
+
+func newCompressWriter(w io.Writer, encoding string) compressWriter {
+	switch encoding {
+	case "zstd":
+		// A single goroutine is enough for one response, and avoids
+		// allocating an encoder per CPU
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			panic(err) // only happens with invalid options
+		}
+		return zw
+	default:
+		return gzip.NewWriter(w)
+	}
+}
+
+// compressResponseWriter buffers the beginning of a response to decide
+// whether it should be compressed.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	minSize  int
+	encoding string
+
+	status  int
+	buf     []byte
+	decided bool
+	cw      compressWriter
+}
+
+func (w *compressResponseWriter) WriteHeader(code int) {
+	if w.status != 0 {
+		return
+	}
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		// Informational responses are sent as-is, before the final one
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+
+	h := w.Header()
+	if code < 200 || code == http.StatusNoContent || code == http.StatusNotModified || code == http.StatusPartialContent ||
+		h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" || !isCompressible(h.Get("Content-Type")) {
+		w.decide(false)
+	} else if s := h.Get("Content-Length"); s != "" {
+		if size, err := strconv.ParseInt(s, 10, 64); err == nil {
+			w.decide(size >= int64(w.minSize))
+		}
+	}
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.minSize {
+			return len(b), nil
+		}
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if w.cw != nil {
+		return w.cw.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide sends the response header, compressed or not.
+func (w *compressResponseWriter) decide(compress bool) {
+	w.decided = true
+	if compress {
+		h := w.Header()
+		h.Set("Content-Encoding", w.encoding)
+		h.Del("Content-Length")
+		// The entity tag and byte ranges refer to the uncompressed
+		// representation
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+		h.Del("Accept-Ranges")
+		w.cw = newCompressWriter(w.ResponseWriter, w.encoding)
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// start decides whether to compress the response and writes the buffered
+// data.
+func (w *compressResponseWriter) start(compress bool) error {
+	w.decide(compress)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Flush implements http.Flusher. Data buffered to decide whether the response
+// should be compressed is sent.
+func (w *compressResponseWriter) Flush() {
+	if w.status == 0 {
+		return
+	}
+	if !w.decided {
+		w.start(len(w.buf) >= w.minSize)
+	}
+	if w.cw != nil {
+		w.cw.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close sends the remaining data of the response.
+func (w *compressResponseWriter) Close() error {
+	if w.status == 0 {
+		return nil
+	}
+	if !w.decided {
+		if err := w.start(len(w.buf) >= w.minSize); err != nil {
+			return err
+		}
+	}
+	if w.cw != nil {
+		return w.cw.Close()
+	}
+	return nil
+}
+
+func (w *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,421 @@
+package webdav
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// handlerHTTPClient sends requests directly to an http.Handler, bypassing
+// the transparent decompression of http.Transport.
+type handlerHTTPClient struct {
+	h http.Handler
+}
+
+func (c handlerHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	c.h.ServeHTTP(rec, req)
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+// encodingHandler replies with body, encoded according to the "encoding"
+// query parameter. It records the Accept-Encoding header field of the
+// requests.
+type encodingHandler struct {
+	body           string
+	acceptEncoding []string
+}
+
+func (h *encodingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.acceptEncoding = append(h.acceptEncoding, r.Header.Get("Accept-Encoding"))
+
+	var buf bytes.Buffer
+	switch enc := r.URL.Query().Get("encoding"); enc {
+	case "gzip":
+		gw := gzip.NewWriter(&buf)
+		io.WriteString(gw, h.body)
+		gw.Close()
+		w.Header().Set("Content-Encoding", enc)
+	case "deflate":
+		zw := zlib.NewWriter(&buf)
+		io.WriteString(zw, h.body)
+		zw.Close()
+		w.Header().Set("Content-Encoding", enc)
+	case "corrupt":
+		buf.WriteString(h.body)
+		w.Header().Set("Content-Encoding", "gzip")
+	default:
+		buf.WriteString(h.body)
+		if enc != "" {
+			w.Header().Set("Content-Encoding", enc)
+		}
+	}
+	w.Write(buf.Bytes())
+}
+
+func TestHTTPClientWithCompression(t *testing.T) {
+	const body = "hello, world"
+	for _, tc := range []struct {
+		encoding string
+		wantErr  bool
+	}{
+		{encoding: ""},
+		{encoding: "identity"},
+		{encoding: "gzip"},
+		{encoding: "deflate"},
+		{encoding: "corrupt", wantErr: true},
+	} {
+		t.Run(tc.encoding, func(t *testing.T) {
+			h := &encodingHandler{body: body}
+			c := HTTPClientWithCompression(handlerHTTPClient{h})
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.org/?encoding="+tc.encoding, nil)
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("Do() = %v", err)
+			}
+			defer resp.Body.Close()
+
+			if want := "gzip, deflate"; h.acceptEncoding[0] != want {
+				t.Errorf("Accept-Encoding = %q, want %q", h.acceptEncoding[0], want)
+			}
+			if req.Header.Get("Accept-Encoding") != "" {
+				t.Errorf("Do() modified the caller's request")
+			}
+
+			b, err := ioutil.ReadAll(resp.Body)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("ReadAll() = %q, want an error", b)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadAll() = %v", err)
+			}
+			if string(b) != body {
+				t.Errorf("body = %q, want %q", b, body)
+			}
+			if enc := resp.Header.Get("Content-Encoding"); enc != "" && enc != "identity" {
+				t.Errorf("Content-Encoding = %q, want it removed", enc)
+			}
+		})
+	}
+}
+
+func TestHTTPClientWithCompression_explicitEncoding(t *testing.T) {
+	for _, name := range []string{"Accept-Encoding", "Range"} {
+		t.Run(name, func(t *testing.T) {
+			h := &encodingHandler{body: "hello"}
+			c := HTTPClientWithCompression(handlerHTTPClient{h})
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.org/?encoding=identity", nil)
+			req.Header.Set(name, "bytes=0-1")
+			resp, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("Do() = %v", err)
+			}
+			resp.Body.Close()
+
+			want := ""
+			if name == "Accept-Encoding" {
+				want = "bytes=0-1"
+			}
+			if h.acceptEncoding[0] != want {
+				t.Errorf("Accept-Encoding = %q, want %q", h.acceptEncoding[0], want)
+			}
+			if resp.Header.Get("Content-Encoding") != "identity" {
+				t.Errorf("Content-Encoding = %q, want it left as-is", resp.Header.Get("Content-Encoding"))
+			}
+		})
+	}
+}
+
+func TestClient_ReadRange_compression(t *testing.T) {
+	var acceptEncoding []string
+	h := &Handler{FileSystem: newTestTree(t)}
+	c, err := NewClient(HTTPClientWithCompression(handlerHTTPClient{http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = append(acceptEncoding, r.Header.Get("Accept-Encoding"))
+		h.ServeHTTP(w, r)
+	})}), "http://example.org/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rc, err := c.ReadRange(context.Background(), "/a/1.txt", 2, 3)
+	if err != nil {
+		t.Fatalf("ReadRange() = %v", err)
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "1.t" {
+		t.Errorf("ReadRange() = %q, want %q", b, "1.t")
+	}
+	if len(acceptEncoding) != 1 || acceptEncoding[0] != "" {
+		t.Errorf("Accept-Encoding = %q, want none", acceptEncoding)
+	}
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	for _, tc := range []struct {
+		accept, want string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"x-gzip", "gzip"},
+		{"GZIP", "gzip"},
+		{"zstd", "zstd"},
+		{"gzip, zstd", "zstd"},
+		{"gzip, deflate, br, zstd", "zstd"},
+		{"gzip;q=1.0, zstd;q=0.5", "gzip"},
+		{"gzip; q=0.8, zstd;q=0.9", "zstd"},
+		{"zstd;q=0, gzip;q=0.1", "gzip"},
+		{"gzip;q=0", ""},
+		{"gzip;q=0.000", ""},
+		{"*", "zstd"},
+		{"*;q=0", ""},
+		{"zstd;q=0, *", "gzip"},
+		{"deflate, br", ""},
+		{"gzip;q=invalid", ""},
+	} {
+		if got := negotiateEncoding(tc.accept); got != tc.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tc.accept, got, tc.want)
+		}
+	}
+}
+
+// decodeBody decompresses a response body according to its Content-Encoding.
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder) string {
+	var r io.Reader = w.Body
+	switch enc := w.Header().Get("Content-Encoding"); enc {
+	case "":
+	case "gzip":
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = gr
+	case "zstd":
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		t.Fatalf("unexpected Content-Encoding %q", enc)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to decode body: %v", err)
+	}
+	return string(b)
+}
+
+func TestCompress(t *testing.T) {
+	xmlBody := "<a>" + strings.Repeat("<b>hello</b>", 200) + "</a>"
+
+	for _, tc := range []struct {
+		name           string
+		method         string
+		acceptEncoding string
+		header         map[string]string
+		body           string
+		want           string
+	}{
+		{"gzip", http.MethodGet, "gzip", map[string]string{"Content-Type": "application/xml"}, xmlBody, "gzip"},
+		{"zstd", http.MethodGet, "gzip, zstd", map[string]string{"Content-Type": "application/xml"}, xmlBody, "zstd"},
+		{"sniffed", http.MethodGet, "gzip", nil, strings.Repeat("hello ", 500), "gzip"},
+		{"not accepted", http.MethodGet, "", map[string]string{"Content-Type": "application/xml"}, xmlBody, ""},
+		{"small", http.MethodGet, "gzip", map[string]string{"Content-Type": "application/xml"}, "<a/>", ""},
+		{"small Content-Length", http.MethodGet, "gzip", map[string]string{"Content-Type": "application/xml", "Content-Length": "4"}, "<a/>", ""},
+		{"binary", http.MethodGet, "gzip", map[string]string{"Content-Type": "image/png"}, xmlBody, ""},
+		{"encoded", http.MethodGet, "gzip", map[string]string{"Content-Type": "text/plain", "Content-Encoding": "br"}, xmlBody, "br"},
+		{"HEAD", http.MethodHead, "gzip", map[string]string{"Content-Type": "application/xml"}, "", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tc.header {
+					w.Header().Set(k, v)
+				}
+				io.WriteString(w, tc.body)
+			}))
+			w := serveTestRequest(h, tc.method, "/", "", map[string]string{"Accept-Encoding": tc.acceptEncoding})
+
+			if enc := w.Header().Get("Content-Encoding"); enc != tc.want {
+				t.Fatalf("Content-Encoding = %q, want %q", enc, tc.want)
+			}
+			if v := w.Header().Get("Vary"); v != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", v)
+			}
+			if tc.want == "br" {
+				return
+			}
+			if body := decodeBody(t, w); body != tc.body {
+				t.Errorf("body = %q, want %q", body, tc.body)
+			}
+		})
+	}
+}
+
+func TestCompress_get(t *testing.T) {
+	content := strings.Repeat("hello, world\n", 200)
+	fs := NewMemBackend()
+	if _, _, err := fs.Create(context.Background(), "/file.txt", ioutil.NopCloser(strings.NewReader(content))); err != nil {
+		t.Fatal(err)
+	}
+	h := Compress(&Handler{FileSystem: fs})
+
+	plain := serveTestRequest(h, http.MethodGet, "/file.txt", "", nil)
+	etag := plain.Header().Get("ETag")
+	if etag == "" || strings.HasPrefix(etag, "W/") {
+		t.Fatalf("uncompressed ETag = %q, want a strong entity tag", etag)
+	}
+
+	w := serveTestRequest(h, http.MethodGet, "/file.txt", "", map[string]string{"Accept-Encoding": "gzip"})
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", w.Header().Get("Content-Encoding"))
+	}
+	if body := decodeBody(t, w); body != content {
+		t.Errorf("body = %q, want %q", body, content)
+	}
+	if got := w.Header().Get("ETag"); got != "W/"+etag {
+		t.Errorf("compressed ETag = %q, want %q", got, "W/"+etag)
+	}
+	if v := w.Header().Get("Accept-Ranges"); v != "" {
+		t.Errorf("compressed response advertises Accept-Ranges: %v", v)
+	}
+
+	// The weak entity tag still validates cached responses
+	w = serveTestRequest(h, http.MethodGet, "/file.txt", "", map[string]string{
+		"Accept-Encoding": "gzip",
+		"If-None-Match":   "W/" + etag,
+	})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("GET with If-None-Match status = %v, want %v", w.Code, http.StatusNotModified)
+	}
+
+	// Range requests are served uncompressed
+	w = serveTestRequest(h, http.MethodGet, "/file.txt", "", map[string]string{
+		"Accept-Encoding": "gzip",
+		"Range":           "bytes=0-4",
+	})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "hello" || w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("range GET = %v %q with Content-Encoding %q, want 206 %q uncompressed", w.Code, w.Body.String(), w.Header().Get("Content-Encoding"), "hello")
+	}
+	if got := w.Header().Get("ETag"); got != etag {
+		t.Errorf("range GET ETag = %q, want %q", got, etag)
+	}
+}
+
+func TestCompress_informational(t *testing.T) {
+	h := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusEarlyHints)
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, strings.Repeat("created ", 200))
+	}))
+
+	var statuses []int
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			statuses = append(statuses, code)
+			return nil
+		},
+	}))
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("status = %v, want %v", resp.StatusCode, http.StatusCreated)
+	}
+	if len(statuses) != 1 || statuses[0] != http.StatusEarlyHints {
+		t.Errorf("informational statuses = %v, want [%v]", statuses, http.StatusEarlyHints)
+	}
+	if enc := resp.Header.Get("Content-Encoding"); enc != "gzip" {
+		t.Errorf("Content-Encoding = %q, want gzip", enc)
+	}
+}
+
+func TestCompress_multiStatus(t *testing.T) {
+	fs := NewMemBackend()
+	ctx := context.Background()
+	if err := fs.Mkdir(ctx, "/dir"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("/dir/file-%03d.txt", i)
+		if _, _, err := fs.Create(ctx, name, ioutil.NopCloser(strings.NewReader(name))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &Handler{FileSystem: fs}
+
+	const propfind = `<propfind xmlns="DAV:"><allprop/></propfind>`
+	header := map[string]string{"Depth": "infinity", "Content-Type": "application/xml"}
+	plain := serveTestRequest(h, "PROPFIND", "/", propfind, header)
+	if plain.Code != http.StatusMultiStatus {
+		t.Fatalf("PROPFIND status = %v, want %v", plain.Code, http.StatusMultiStatus)
+	}
+
+	for _, encoding := range compressEncodings {
+		t.Run(encoding, func(t *testing.T) {
+			header := map[string]string{
+				"Accept-Encoding": encoding,
+				"Depth":           "infinity",
+				"Content-Type":    "application/xml",
+			}
+			w := serveTestRequest(Compress(h), "PROPFIND", "/", propfind, header)
+			if w.Code != http.StatusMultiStatus {
+				t.Fatalf("PROPFIND status = %v, want %v", w.Code, http.StatusMultiStatus)
+			}
+			if enc := w.Header().Get("Content-Encoding"); enc != encoding {
+				t.Fatalf("Content-Encoding = %q, want %q", enc, encoding)
+			}
+			if body := decodeBody(t, w); body != plain.Body.String() {
+				t.Errorf("decompressed multi-status response differs from the uncompressed one")
+			}
+		})
+	}
+
+	// The client transparently decompresses the streamed response
+	c, err := NewClient(HTTPClientWithCompression(handlerHTTPClient{Compress(h)}), "http://example.org/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos, err := c.ReadDir(ctx, "/dir", false)
+	if err != nil {
+		t.Fatalf("ReadDir() = %v", err)
+	}
+	if len(infos) != 101 {
+		t.Errorf("ReadDir() returned %v files, want 101", len(infos))
+	}
+}
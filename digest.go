@@ -0,0 +1,327 @@
+package webdav
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type digestChallenge struct {
+	realm, nonce, opaque string
+	algorithm            string
+	// qop options offered by the server
+	qopAuth, qopAuthInt bool
+	// stale indicates that the previous request was rejected because of an
+	// expired nonce, and not because of invalid credentials
+	stale bool
+}
+
+func parseDigestChallenge(h http.Header) (*digestChallenge, bool) {
+	for _, v := range h[http.CanonicalHeaderKey("WWW-Authenticate")] {
+		if len(v) < len("Digest ") || !strings.EqualFold(v[:len("Digest ")], "Digest ") {
+			continue
+		}
+
+		params := parseAuthParams(v[len("Digest "):])
+		c := &digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+		}
+		if c.nonce == "" {
+			continue
+		}
+		for _, qop := range strings.Split(params["qop"], ",") {
+			switch strings.TrimSpace(qop) {
+			case "auth":
+				c.qopAuth = true
+			case "auth-int":
+				c.qopAuthInt = true
+			}
+		}
+		c.stale = strings.EqualFold(params["stale"], "true")
+		return c, true
+	}
+	return nil, false
+}
+
+// parseAuthParams parses a comma-separated list of auth-params, as defined in
+// RFC 7235 section 2.1.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		i := strings.IndexByte(s, '=')
+		if i < 0 {
+			return params
+		}
+		k := strings.ToLower(strings.TrimSpace(s[:i]))
+		s = strings.TrimLeft(s[i+1:], " \t")
+
+		var v string
+		if strings.HasPrefix(s, `"`) {
+			var sb strings.Builder
+			i = 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				sb.WriteByte(s[i])
+			}
+			v = sb.String()
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			i = strings.IndexByte(s, ',')
+			if i < 0 {
+				i = len(s)
+			}
+			v = strings.TrimSpace(s[:i])
+			s = s[i:]
+		}
+		params[k] = v
+	}
+}
+
+type digestAuthHTTPClient struct {
+	c                  HTTPClient
+	username, password string
+
+	mu        sync.Mutex
+	challenge *digestChallenge
+	nc        uint32
+}
+
+// authorize adds an Authorization header to req, if a challenge has been
+// received. It returns false if no challenge has been received yet.
+func (c *digestAuthHTTPClient) authorize(req *http.Request) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.challenge == nil {
+		return false, nil
+	}
+	ch := c.challenge
+
+	newHash, err := digestHash(ch.algorithm)
+	if err != nil {
+		return false, err
+	}
+
+	// auth-int requires hashing the request body, which is only possible if
+	// it can be re-created
+	var qop, bodyHash string
+	hasBody := req.Body != nil && req.Body != http.NoBody
+	if ch.qopAuthInt && (!hasBody || req.GetBody != nil) {
+		qop = "auth-int"
+		hh := newHash()
+		if hasBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return false, err
+			}
+			_, err = io.Copy(hh, body)
+			body.Close()
+			if err != nil {
+				return false, err
+			}
+		}
+		bodyHash = hex.EncodeToString(hh.Sum(nil))
+	} else if ch.qopAuth {
+		qop = "auth"
+	} else if ch.qopAuthInt {
+		return false, fmt.Errorf("webdav: digest auth-int requires a request body which can be re-created")
+	}
+
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return false, err
+	}
+	cnonce := hex.EncodeToString(b[:])
+
+	// The nonce is re-used for subsequent requests, with an incremented
+	// nonce count
+	c.nc++
+	nc := fmt.Sprintf("%08x", c.nc)
+
+	uri := req.URL.RequestURI()
+	response := ch.response(newHash, c.username, c.password, req.Method, uri, qop, nc, cnonce, bodyHash)
+
+	params := []string{
+		fmt.Sprintf("username=%q", c.username),
+		fmt.Sprintf("realm=%q", ch.realm),
+		fmt.Sprintf("nonce=%q", ch.nonce),
+		fmt.Sprintf("uri=%q", uri),
+		fmt.Sprintf("response=%q", response),
+	}
+	if ch.algorithm != "" {
+		params = append(params, "algorithm="+ch.algorithm)
+	}
+	if qop != "" {
+		params = append(params, "qop="+qop, "nc="+nc, fmt.Sprintf("cnonce=%q", cnonce))
+	}
+	if ch.opaque != "" {
+		params = append(params, fmt.Sprintf("opaque=%q", ch.opaque))
+	}
+	req.Header.Set("Authorization", "Digest "+strings.Join(params, ", "))
+	return true, nil
+}
+
+// digestHash returns the hash function of a digest algorithm, as defined in
+// RFC 7616 section 3.3.
+func digestHash(algorithm string) (func() hash.Hash, error) {
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		return md5.New, nil
+	case "SHA-256":
+		return sha256.New, nil
+	case "SHA-512-256":
+		return sha512.New512_256, nil
+	default:
+		return nil, fmt.Errorf("webdav: unsupported digest algorithm %q", algorithm)
+	}
+}
+
+// response computes the response to the challenge, as defined in RFC 7616
+// section 3.4.1. bodyHash is only used with the "auth-int" qop.
+func (ch *digestChallenge) response(newHash func() hash.Hash, username, password, method, uri, qop, nc, cnonce, bodyHash string) string {
+	h := func(s string) string {
+		hh := newHash()
+		io.WriteString(hh, s)
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	ha1 := h(username + ":" + ch.realm + ":" + password)
+	if strings.HasSuffix(strings.ToUpper(ch.algorithm), "-SESS") {
+		ha1 = h(ha1 + ":" + ch.nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+	if qop == "auth-int" {
+		ha2 = h(method + ":" + uri + ":" + bodyHash)
+	}
+
+	if qop != "" {
+		return h(strings.Join([]string{ha1, ch.nonce, nc, cnonce, qop, ha2}, ":"))
+	}
+	return h(ha1 + ":" + ch.nonce + ":" + ha2)
+}
+
+func (c *digestAuthHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	authReq := req.Clone(req.Context())
+	authorized, err := c.authorize(authReq)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.c.Do(authReq)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	ch, ok := parseDigestChallenge(resp.Header)
+	if !ok {
+		return resp, nil
+	}
+
+	c.mu.Lock()
+	c.challenge = ch
+	c.nc = 0
+	c.mu.Unlock()
+
+	// If credentials were sent with a valid nonce, they have been rejected:
+	// retrying wouldn't help
+	if authorized && !ch.stale {
+		return resp, nil
+	}
+
+	// The request body has already been consumed, it can only be sent again
+	// if it can be re-created
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	retryReq := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retryReq.Body = body
+	}
+	if _, err := c.authorize(retryReq); err != nil {
+		return nil, err
+	}
+	return c.c.Do(retryReq)
+}
+
+// HTTPClientWithDigestAuth returns an HTTP client that performs HTTP digest
+// authentication, as defined in RFC 7616. If c is nil, http.DefaultClient is
+// used.
+//
+// The server's challenge is cached and its nonce is re-used for all
+// subsequent requests. The first request is transparently retried after the
+// challenge, and requests rejected because of a stale nonce are retried with
+// the new one, unless their body cannot be re-created (see
+// http.Request.GetBody). The "auth" and "auth-int" quality of protection
+// values are supported.
+func HTTPClientWithDigestAuth(c HTTPClient, username, password string) HTTPClient {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	return &digestAuthHTTPClient{c: c, username: username, password: password}
+}
+
+// DigestAuth returns an http.RoundTripper performing HTTP digest
+// authentication with http.DefaultTransport. See HTTPClientWithDigestAuth.
+func DigestAuth(username, password string) http.RoundTripper {
+	return DigestAuthTransport(nil, username, password)
+}
+
+// DigestAuthTransport is like DigestAuth, but sends requests with rt. If rt
+// is nil, http.DefaultTransport is used.
+func DigestAuthTransport(rt http.RoundTripper, username, password string) http.RoundTripper {
+	c := HTTPClientWithDigestAuth(newRoundTripperHTTPClient(rt), username, password)
+	return httpClientRoundTripper{c}
+}
+
+// roundTripperHTTPClient is an HTTPClient sending requests with an
+// http.RoundTripper.
+type roundTripperHTTPClient struct {
+	rt http.RoundTripper
+}
+
+func newRoundTripperHTTPClient(rt http.RoundTripper) HTTPClient {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return roundTripperHTTPClient{rt}
+}
+
+func (c roundTripperHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	return c.rt.RoundTrip(req)
+}
+
+// httpClientRoundTripper is an http.RoundTripper sending requests with an
+// HTTPClient.
+type httpClientRoundTripper struct {
+	c HTTPClient
+}
+
+func (rt httpClientRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return rt.c.Do(req)
+}
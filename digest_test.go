@@ -0,0 +1,244 @@
+package webdav
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test vectors from RFC 7616 section 3.9.1
+func TestDigestChallenge_response(t *testing.T) {
+	const wwwAuthenticate = `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=%v, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`
+
+	testCases := []struct {
+		algorithm string
+		want      string
+	}{
+		{"MD5", "8ca523f5e9506fed4657c9700eebdbec"},
+		{"SHA-256", "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"},
+	}
+	for _, tc := range testCases {
+		h := make(http.Header)
+		h.Set("WWW-Authenticate", fmt.Sprintf(wwwAuthenticate, tc.algorithm))
+		ch, ok := parseDigestChallenge(h)
+		if !ok {
+			t.Fatalf("parseDigestChallenge() failed")
+		}
+		if ch.realm != "http-auth@example.org" || ch.opaque != "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS" || !ch.qopAuth || !ch.qopAuthInt {
+			t.Errorf("parseDigestChallenge() = %+v", ch)
+		}
+
+		newHash, err := digestHash(ch.algorithm)
+		if err != nil {
+			t.Fatalf("digestHash(%q) = %v", ch.algorithm, err)
+		}
+		got := ch.response(newHash, "Mufasa", "Circle of Life", http.MethodGet, "/dir/index.html", "auth", "00000001", "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ", "")
+		if got != tc.want {
+			t.Errorf("response with %v = %v, want %v", tc.algorithm, got, tc.want)
+		}
+	}
+
+	if _, err := digestHash("SHA-1"); err == nil {
+		t.Errorf("digestHash(%q) succeeded", "SHA-1")
+	}
+}
+
+// digestTestServer is an HTTP handler requiring digest authentication.
+type digestTestServer struct {
+	t         *testing.T
+	challenge string
+	password  string
+	// staleAfter marks the nonce as stale after the provided number of
+	// authorized requests, if non-zero
+	staleAfter int
+
+	nonce       int
+	authorized  int
+	nonceCounts []string
+	requests    int
+}
+
+func (s *digestTestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests++
+	nonce := fmt.Sprintf("nonce%v", s.nonce)
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Digest ") {
+		s.unauthorized(w, nonce, false)
+		return
+	}
+	params := parseAuthParams(strings.TrimPrefix(auth, "Digest "))
+	if params["nonce"] != nonce {
+		s.unauthorized(w, nonce, true)
+		return
+	}
+
+	h := make(http.Header)
+	h.Set("WWW-Authenticate", fmt.Sprintf(s.challenge, nonce))
+	ch, _ := parseDigestChallenge(h)
+	newHash, err := digestHash(ch.algorithm)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	var bodyHash string
+	if params["qop"] == "auth-int" {
+		hh := newHash()
+		if _, err := io.Copy(hh, r.Body); err != nil {
+			s.t.Fatal(err)
+		}
+		bodyHash = fmt.Sprintf("%x", hh.Sum(nil))
+	}
+	want := ch.response(newHash, params["username"], s.password, r.Method, params["uri"], params["qop"], params["nc"], params["cnonce"], bodyHash)
+	if params["response"] != want || params["uri"] != r.URL.RequestURI() {
+		s.unauthorized(w, nonce, false)
+		return
+	}
+
+	s.nonceCounts = append(s.nonceCounts, params["nc"])
+	s.authorized++
+	if s.staleAfter > 0 && s.authorized%s.staleAfter == 0 {
+		s.nonce++
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *digestTestServer) unauthorized(w http.ResponseWriter, nonce string, stale bool) {
+	challenge := fmt.Sprintf(s.challenge, nonce)
+	if stale {
+		challenge += ", stale=true"
+	}
+	w.Header().Add("WWW-Authenticate", `Basic realm="test"`)
+	w.Header().Add("WWW-Authenticate", challenge)
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+func TestHTTPClientWithDigestAuth(t *testing.T) {
+	for _, alg := range []string{"MD5", "SHA-256", "MD5-sess"} {
+		t.Run(alg, func(t *testing.T) {
+			s := &digestTestServer{
+				t:         t,
+				challenge: `Digest realm="test@example.org", qop="auth", algorithm=` + alg + `, nonce="%v", opaque="opaque"`,
+				password:  "secret",
+			}
+			ts := httptest.NewServer(s)
+			defer ts.Close()
+
+			c := HTTPClientWithDigestAuth(ts.Client(), "alice", "secret")
+			for i := 0; i < 3; i++ {
+				req, _ := http.NewRequest(http.MethodGet, ts.URL+"/dir/index.html?q=1", nil)
+				resp, err := c.Do(req)
+				if err != nil {
+					t.Fatalf("Do() = %v", err)
+				}
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusNoContent {
+					t.Fatalf("status = %v, want %v", resp.StatusCode, http.StatusNoContent)
+				}
+			}
+
+			// Only the first request needs to fetch the challenge, the nonce
+			// is re-used afterwards
+			if s.requests != 4 {
+				t.Errorf("server received %v requests, want 4", s.requests)
+			}
+			if got, want := strings.Join(s.nonceCounts, ","), "00000001,00000002,00000003"; got != want {
+				t.Errorf("nonce counts = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestHTTPClientWithDigestAuth_invalidCredentials(t *testing.T) {
+	s := &digestTestServer{
+		t:         t,
+		challenge: `Digest realm="test@example.org", qop="auth", nonce="%v"`,
+		password:  "secret",
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c := HTTPClientWithDigestAuth(ts.Client(), "alice", "wrong")
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL, nil)
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Do() = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("status = %v, want %v", resp.StatusCode, http.StatusUnauthorized)
+		}
+	}
+	// The first request is retried once with the challenge, the second
+	// request isn't retried
+	if s.requests != 3 {
+		t.Errorf("server received %v requests, want 3", s.requests)
+	}
+}
+
+func TestHTTPClientWithDigestAuth_authInt(t *testing.T) {
+	s := &digestTestServer{
+		t:         t,
+		challenge: `Digest realm="test@example.org", qop="auth-int", algorithm=SHA-512-256, nonce="%v"`,
+		password:  "secret",
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c := HTTPClientWithDigestAuth(ts.Client(), "alice", "secret")
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodPut, ts.URL+"/file", strings.NewReader("hello"))
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("Do() = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("status = %v, want %v", resp.StatusCode, http.StatusNoContent)
+		}
+	}
+
+	// The body of requests which can't be re-created can't be hashed
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/file", strings.NewReader("hello"))
+	req.GetBody = nil
+	if resp, err := c.Do(req); err == nil {
+		resp.Body.Close()
+		t.Errorf("Do() with a body which can't be re-created succeeded")
+	}
+}
+
+func TestHTTPClientWithDigestAuth_stale(t *testing.T) {
+	s := &digestTestServer{
+		t:          t,
+		challenge:  `Digest realm="test@example.org", qop="auth", nonce="%v"`,
+		password:   "secret",
+		staleAfter: 2,
+	}
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	// DigestAuthTransport can be used with any http.Client
+	c := &http.Client{Transport: DigestAuthTransport(ts.Client().Transport, "alice", "secret")}
+	for i := 0; i < 4; i++ {
+		resp, err := c.Post(ts.URL, "text/plain", strings.NewReader("hello"))
+		if err != nil {
+			t.Fatalf("Post() = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("status of request #%v = %v, want %v", i, resp.StatusCode, http.StatusNoContent)
+		}
+	}
+
+	// The third request is rejected because of the stale nonce, and retried
+	// with the new nonce and a reset nonce count
+	if s.requests != 6 {
+		t.Errorf("server received %v requests, want 6", s.requests)
+	}
+	if got, want := strings.Join(s.nonceCounts, ","), "00000001,00000002,00000001,00000002"; got != want {
+		t.Errorf("nonce counts = %v, want %v", got, want)
+	}
+}
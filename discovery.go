@@ -0,0 +1,170 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+var (
+	calendarHomeSetName    = xml.Name{"urn:ietf:params:xml:ns:caldav", "calendar-home-set"}
+	addressBookHomeSetName = xml.Name{"urn:ietf:params:xml:ns:carddav", "addressbook-home-set"}
+)
+
+// discoverContextURL performs the SRV lookup. It's replaced in tests.
+var discoverContextURL = internal.DiscoverContextURL
+
+// DiscoveryResult holds the CalDAV and CardDAV endpoints found by Discover.
+// Fields are nil if the corresponding service couldn't be found.
+type DiscoveryResult struct {
+	// CalDAV and CardDAV are the context URLs of the services.
+	CalDAV, CardDAV *url.URL
+	// CalendarHomeSet and AddressBookHomeSet are the URLs of the collections
+	// containing the current user's calendars and address books.
+	CalendarHomeSet, AddressBookHomeSet *url.URL
+}
+
+// Discover locates the CalDAV and CardDAV services of a domain, as defined in
+// RFC 6764.
+//
+// The _caldavs._tcp and _carddavs._tcp SRV records are looked up first, with
+// a fallback to the well-known URIs on the domain itself. The current user's
+// principal is then used to find the home sets, so c should perform
+// authentication. If c is nil, http.DefaultClient is used.
+//
+// An error is returned if neither service could be found.
+func Discover(ctx context.Context, c HTTPClient, domain string) (*DiscoveryResult, error) {
+	var result DiscoveryResult
+	var calErr, cardErr error
+	result.CalDAV, result.CalendarHomeSet, calErr = discoverService(ctx, c, "caldav", domain, calendarHomeSetName)
+	result.CardDAV, result.AddressBookHomeSet, cardErr = discoverService(ctx, c, "carddav", domain, addressBookHomeSetName)
+	if calErr != nil && cardErr != nil {
+		return nil, fmt.Errorf("webdav: failed to discover services for %q: %v", domain, calErr)
+	}
+	return &result, nil
+}
+
+func discoverService(ctx context.Context, c HTTPClient, service, domain string, homeSetName xml.Name) (contextURL, homeSet *url.URL, err error) {
+	var endpoints []string
+	if u, err := discoverContextURL(ctx, service, domain); err == nil {
+		endpoints = append(endpoints, u)
+	}
+	wellKnown := url.URL{Scheme: "https", Host: domain, Path: "/.well-known/" + service}
+	endpoints = append(endpoints, wellKnown.String())
+
+	for _, endpoint := range endpoints {
+		contextURL, homeSet, err = discoverHomeSet(ctx, c, endpoint, homeSetName)
+		if err == nil {
+			return contextURL, homeSet, nil
+		}
+	}
+	return nil, nil, err
+}
+
+func discoverHomeSet(ctx context.Context, c HTTPClient, endpoint string, homeSetName xml.Name) (contextURL, homeSet *url.URL, err error) {
+	contextURL, principal, err := findPrincipal(ctx, c, endpoint, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ic, err := internal.NewClient(c, principal.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := ic.PropFindFlat(ctx, principal.Path, internal.NewPropNamePropFind(homeSetName))
+	if err != nil {
+		return nil, nil, err
+	}
+	href, err := decodeHrefProp(r, homeSetName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return contextURL, principal.ResolveReference(href), nil
+}
+
+// findPrincipal reads the current user's principal from endpoint. It returns
+// the URL the request has been redirected to, if any.
+func findPrincipal(ctx context.Context, c HTTPClient, endpoint string, retry bool) (contextURL, principal *url.URL, err error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	ic, err := internal.NewClient(c, endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Use the absolute path to preserve any trailing slash
+	propfind := internal.NewPropNamePropFind(internal.CurrentUserPrincipalName)
+	req, err := ic.NewXMLRequest("PROPFIND", u.Path, propfind)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Depth", "0")
+
+	// The raw HTTP client is used here, because the final location needs
+	// to be inspected even if the request failed
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	// Well-known URIs usually redirect to the actual context path. Redirects
+	// with a 301, 302 or 303 status turn the PROPFIND request into a GET
+	// request: send it again to the final location.
+	contextURL = req.URL
+	if resp.Request != nil {
+		contextURL = resp.Request.URL
+		if resp.Request.Method != req.Method && retry {
+			return findPrincipal(ctx, c, contextURL.String(), false)
+		}
+	}
+
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, nil, fmt.Errorf("webdav: PROPFIND on %q failed: %v", endpoint, resp.Status)
+	}
+
+	r, err := internal.NewMultiStatusDecoder(resp.Body).Next()
+	if err != nil {
+		return nil, nil, err
+	}
+	var prop internal.CurrentUserPrincipal
+	if err := r.DecodeProp(&prop); err != nil {
+		return nil, nil, err
+	}
+	if prop.Unauthenticated != nil {
+		return nil, nil, fmt.Errorf("webdav: unauthenticated")
+	}
+
+	return contextURL, contextURL.ResolveReference((*url.URL)(&prop.Href)), nil
+}
+
+// decodeHrefProp decodes a property containing a single DAV:href element.
+func decodeHrefProp(r *internal.Response, name xml.Name) (*url.URL, error) {
+	for _, propstat := range r.PropStats {
+		raw := propstat.Prop.Get(name)
+		if raw == nil {
+			continue
+		}
+		if err := propstat.Status.Err(); err != nil {
+			return nil, err
+		}
+
+		var prop struct {
+			Href internal.Href `xml:"DAV: href"`
+		}
+		if err := raw.Decode(&prop); err != nil {
+			return nil, err
+		}
+		return (*url.URL)(&prop.Href), nil
+	}
+	return nil, fmt.Errorf("webdav: missing property {%v}%v", name.Space, name.Local)
+}
@@ -0,0 +1,151 @@
+package webdav
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// hostRewriteTransport sends all requests to a single test server, to
+// simulate the hosts of a domain.
+type hostRewriteTransport struct {
+	rt   http.RoundTripper
+	host string
+}
+
+func (t *hostRewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	r.URL.Host = t.host
+	resp, err := t.rt.RoundTrip(r)
+	if resp != nil {
+		resp.Request = req
+	}
+	return resp, err
+}
+
+const discoveryMultiStatus = `<?xml version="1.0" encoding="UTF-8"?>
+<multistatus xmlns="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav" xmlns:CR="urn:ietf:params:xml:ns:carddav">
+	<response>
+		<href>%v</href>
+		<propstat>
+			<prop>%v</prop>
+			<status>HTTP/1.1 200 OK</status>
+		</propstat>
+	</response>
+</multistatus>`
+
+func newDiscoveryTestClient(t *testing.T, mux *http.ServeMux) HTTPClient {
+	ts := httptest.NewTLSServer(mux)
+	t.Cleanup(ts.Close)
+	u, _ := url.Parse(ts.URL)
+	return &http.Client{Transport: &hostRewriteTransport{ts.Client().Transport, u.Host}}
+}
+
+func serveDiscoveryProp(mux *http.ServeMux, p, prop string) {
+	mux.HandleFunc(p, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PROPFIND" {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, discoveryMultiStatus, p, prop)
+	})
+}
+
+func stubDiscoverContextURL(t *testing.T, urls map[string]string) {
+	orig := discoverContextURL
+	discoverContextURL = func(ctx context.Context, service, domain string) (string, error) {
+		if u, ok := urls[service]; ok {
+			return u, nil
+		}
+		return "", fmt.Errorf("no SRV record for %v", service)
+	}
+	t.Cleanup(func() {
+		discoverContextURL = orig
+	})
+}
+
+func TestDiscover(t *testing.T) {
+	stubDiscoverContextURL(t, nil)
+
+	mux := http.NewServeMux()
+	// PROPFIND requests redirected with 301 are turned into GET requests
+	mux.Handle("/.well-known/caldav", http.RedirectHandler("/dav/", http.StatusMovedPermanently))
+	mux.Handle("/.well-known/carddav", http.RedirectHandler("/dav/", http.StatusPermanentRedirect))
+	serveDiscoveryProp(mux, "/dav/", `<current-user-principal><href>/principals/alice/</href></current-user-principal>`)
+	serveDiscoveryProp(mux, "/principals/alice/", `<C:calendar-home-set><href>/calendars/alice/</href></C:calendar-home-set><CR:addressbook-home-set><href>/contacts/alice/</href></CR:addressbook-home-set>`)
+	c := newDiscoveryTestClient(t, mux)
+
+	res, err := Discover(context.Background(), c, "example.org")
+	if err != nil {
+		t.Fatalf("Discover() = %v", err)
+	}
+	for _, tc := range []struct {
+		name string
+		got  *url.URL
+		want string
+	}{
+		{"CalDAV", res.CalDAV, "https://example.org/dav/"},
+		{"CardDAV", res.CardDAV, "https://example.org/dav/"},
+		{"CalendarHomeSet", res.CalendarHomeSet, "https://example.org/calendars/alice/"},
+		{"AddressBookHomeSet", res.AddressBookHomeSet, "https://example.org/contacts/alice/"},
+	} {
+		if tc.got == nil {
+			t.Errorf("%v = nil, want %v", tc.name, tc.want)
+		} else if tc.got.String() != tc.want {
+			t.Errorf("%v = %v, want %v", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestDiscover_srv(t *testing.T) {
+	stubDiscoverContextURL(t, map[string]string{
+		"caldav": "https://dav.example.org:8443/.well-known/caldav",
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("/.well-known/caldav", http.RedirectHandler("/cal/", http.StatusMovedPermanently))
+	serveDiscoveryProp(mux, "/cal/", `<current-user-principal><href>/principals/alice/</href></current-user-principal>`)
+	serveDiscoveryProp(mux, "/principals/alice/", `<C:calendar-home-set><href>/cal/alice/</href></C:calendar-home-set>`)
+	c := newDiscoveryTestClient(t, mux)
+
+	res, err := Discover(context.Background(), c, "example.org")
+	if err != nil {
+		t.Fatalf("Discover() = %v", err)
+	}
+	if res.CalDAV == nil || res.CalDAV.String() != "https://dav.example.org:8443/cal/" {
+		t.Errorf("CalDAV = %v, want %v", res.CalDAV, "https://dav.example.org:8443/cal/")
+	}
+	if res.CalendarHomeSet == nil || res.CalendarHomeSet.String() != "https://dav.example.org:8443/cal/alice/" {
+		t.Errorf("CalendarHomeSet = %v, want %v", res.CalendarHomeSet, "https://dav.example.org:8443/cal/alice/")
+	}
+	// The CardDAV well-known URI isn't served
+	if res.CardDAV != nil || res.AddressBookHomeSet != nil {
+		t.Errorf("CardDAV = %v, AddressBookHomeSet = %v, want nil", res.CardDAV, res.AddressBookHomeSet)
+	}
+}
+
+func TestDiscover_notFound(t *testing.T) {
+	stubDiscoverContextURL(t, nil)
+	c := newDiscoveryTestClient(t, http.NewServeMux())
+
+	if _, err := Discover(context.Background(), c, "example.org"); err == nil {
+		t.Errorf("Discover() succeeded without any service")
+	}
+}
+
+func TestDiscover_unauthenticated(t *testing.T) {
+	stubDiscoverContextURL(t, nil)
+
+	mux := http.NewServeMux()
+	serveDiscoveryProp(mux, "/.well-known/caldav", `<current-user-principal><unauthenticated/></current-user-principal>`)
+	c := newDiscoveryTestClient(t, mux)
+
+	if _, err := Discover(context.Background(), c, "example.org"); err == nil {
+		t.Errorf("Discover() succeeded for an unauthenticated user")
+	}
+}
@@ -0,0 +1,320 @@
+//go:build go1.16
+// +build go1.16
+
+package webdav
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+// WriteFS is a file system supporting write operations. If the fs.FS passed
+// to NewFSBackend implements it, PUT, MKCOL, DELETE, COPY and MOVE requests
+// are supported. Names follow the fs.FS conventions.
+type WriteFS interface {
+	fs.FS
+	// Create creates or truncates the named file.
+	Create(name string) (io.WriteCloser, error)
+	// Mkdir creates a directory. It fails if the parent doesn't exist.
+	Mkdir(name string) error
+	RemoveAll(name string) error
+	Rename(oldname, newname string) error
+}
+
+type fsBackend struct {
+	fsys fs.FS
+}
+
+var _ FileSystem = (*fsBackend)(nil)
+
+// NewFSBackend returns a FileSystem serving the files of fsys, for instance
+// an embed.FS or the result of os.DirFS. The file system is read-only unless
+// fsys implements WriteFS.
+func NewFSBackend(fsys fs.FS) FileSystem {
+	return &fsBackend{fsys}
+}
+
+// fsName converts an absolute WebDAV path to an fs.FS name.
+func fsName(name string) (string, error) {
+	name = path.Clean(name)
+	if !path.IsAbs(name) {
+		return "", internal.HTTPErrorf(http.StatusBadRequest, "webdav: expected absolute path, got %q", name)
+	}
+	name = strings.TrimPrefix(name, "/")
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		return "", internal.HTTPErrorf(http.StatusBadRequest, "webdav: invalid path %q", name)
+	}
+	return name, nil
+}
+
+func errFromFS(err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return NewHTTPError(http.StatusNotFound, err)
+	} else if errors.Is(err, fs.ErrPermission) {
+		return NewHTTPError(http.StatusForbidden, err)
+	} else if errors.Is(err, fs.ErrExist) {
+		return NewHTTPError(http.StatusMethodNotAllowed, err)
+	} else {
+		return err
+	}
+}
+
+func (b *fsBackend) writeFS() (WriteFS, error) {
+	wfs, ok := b.fsys.(WriteFS)
+	if !ok {
+		return nil, internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: read-only file system")
+	}
+	return wfs, nil
+}
+
+func (b *fsBackend) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	p, err := fsName(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := b.fsys.Open(p)
+	if err != nil {
+		return nil, errFromFS(err)
+	}
+	return f, nil
+}
+
+func (b *fsBackend) Stat(ctx context.Context, name string) (*FileInfo, error) {
+	p, err := fsName(name)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := fs.Stat(b.fsys, p)
+	if err != nil {
+		return nil, errFromFS(err)
+	}
+	return fileInfoFromOS(name, fi), nil
+}
+
+func (b *fsBackend) ReadDir(ctx context.Context, name string, recursive bool) ([]FileInfo, error) {
+	root, err := fsName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var l []FileInfo
+	err = fs.WalkDir(b.fsys, root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		l = append(l, *fileInfoFromOS(path.Join("/", p), fi))
+
+		if !recursive && d.IsDir() && p != root {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errFromFS(err)
+	}
+	return l, nil
+}
+
+func (b *fsBackend) Create(ctx context.Context, name string, body io.ReadCloser) (*FileInfo, bool, error) {
+	wfs, err := b.writeFS()
+	if err != nil {
+		return nil, false, err
+	}
+	p, err := fsName(name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	_, err = fs.Stat(wfs, p)
+	created := errors.Is(err, fs.ErrNotExist)
+
+	wc, err := wfs.Create(p)
+	if err != nil {
+		return nil, false, errFromFS(err)
+	}
+	if _, err := io.Copy(wc, body); err != nil {
+		wc.Close()
+		return nil, false, err
+	}
+	if err := wc.Close(); err != nil {
+		return nil, false, err
+	}
+
+	fi, err := b.Stat(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+	return fi, created, nil
+}
+
+func (b *fsBackend) RemoveAll(ctx context.Context, name string) error {
+	wfs, err := b.writeFS()
+	if err != nil {
+		return err
+	}
+	p, err := fsName(name)
+	if err != nil {
+		return err
+	}
+
+	// WebDAV semantics are that it should return a "404 Not Found" error in
+	// case the resource doesn't exist
+	if _, err := fs.Stat(wfs, p); err != nil {
+		return errFromFS(err)
+	}
+	return errFromFS(wfs.RemoveAll(p))
+}
+
+func (b *fsBackend) Mkdir(ctx context.Context, name string) error {
+	wfs, err := b.writeFS()
+	if err != nil {
+		return err
+	}
+	p, err := fsName(name)
+	if err != nil {
+		return err
+	}
+	return errFromFS(wfs.Mkdir(p))
+}
+
+// fsContains reports whether the fs.FS name is parent or one of its
+// descendants.
+func fsContains(parent, name string) bool {
+	return parent == "." || name == parent || strings.HasPrefix(name, parent+"/")
+}
+
+// fsRel returns the name of a descendant of root relative to root.
+func fsRel(root, name string) string {
+	if root == "." {
+		return name
+	}
+	return strings.TrimPrefix(strings.TrimPrefix(name, root), "/")
+}
+
+// prepareDest checks whether dst can be overwritten and removes it if it
+// exists.
+func prepareDest(wfs WriteFS, dst string, noOverwrite bool) (created bool, err error) {
+	if _, err := fs.Stat(wfs, dst); errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	} else if err != nil {
+		return false, errFromFS(err)
+	}
+	if noOverwrite {
+		return false, NewHTTPError(http.StatusPreconditionFailed, fs.ErrExist)
+	}
+	return false, errFromFS(wfs.RemoveAll(dst))
+}
+
+func copyFSFile(wfs WriteFS, src, dst string) error {
+	r, err := wfs.Open(src)
+	if err != nil {
+		return errFromFS(err)
+	}
+	defer r.Close()
+
+	w, err := wfs.Create(dst)
+	if errors.Is(err, fs.ErrNotExist) {
+		return NewHTTPError(http.StatusConflict, err)
+	} else if err != nil {
+		return errFromFS(err)
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (b *fsBackend) Copy(ctx context.Context, src, dst string, options *CopyOptions) (created bool, err error) {
+	wfs, err := b.writeFS()
+	if err != nil {
+		return false, err
+	}
+	srcName, err := fsName(src)
+	if err != nil {
+		return false, err
+	}
+	dstName, err := fsName(dst)
+	if err != nil {
+		return false, err
+	}
+	if fsContains(srcName, dstName) {
+		return false, internal.HTTPErrorf(http.StatusForbidden, "webdav: cannot copy a collection into itself")
+	}
+
+	if _, err := fs.Stat(wfs, srcName); err != nil {
+		return false, errFromFS(err)
+	}
+	created, err = prepareDest(wfs, dstName, options.NoOverwrite)
+	if err != nil {
+		return false, err
+	}
+
+	err = fs.WalkDir(wfs, srcName, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		target := path.Join(dstName, fsRel(srcName, p))
+		if d.IsDir() {
+			if err := wfs.Mkdir(target); err != nil {
+				return errFromFS(err)
+			}
+			if options.NoRecursive {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		return copyFSFile(wfs, p, target)
+	})
+	if err != nil {
+		return false, errFromFS(err)
+	}
+	return created, nil
+}
+
+func (b *fsBackend) Move(ctx context.Context, src, dst string, options *MoveOptions) (created bool, err error) {
+	wfs, err := b.writeFS()
+	if err != nil {
+		return false, err
+	}
+	srcName, err := fsName(src)
+	if err != nil {
+		return false, err
+	}
+	dstName, err := fsName(dst)
+	if err != nil {
+		return false, err
+	}
+	if fsContains(srcName, dstName) {
+		return false, internal.HTTPErrorf(http.StatusForbidden, "webdav: cannot move a collection into itself")
+	}
+
+	if _, err := fs.Stat(wfs, srcName); err != nil {
+		return false, errFromFS(err)
+	}
+	created, err = prepareDest(wfs, dstName, options.NoOverwrite)
+	if err != nil {
+		return false, err
+	}
+
+	if err := wfs.Rename(srcName, dstName); err != nil {
+		return false, errFromFS(err)
+	}
+	return created, nil
+}
@@ -17,7 +17,11 @@ import (
 // LocalFileSystem implements FileSystem for a local directory.
 type LocalFileSystem string
 
-var _ FileSystem = LocalFileSystem("")
+var (
+	_ FileSystem     = LocalFileSystem("")
+	_ WalkFileSystem = LocalFileSystem("")
+	_ PartialWriter  = LocalFileSystem("")
+)
 
 func (fs LocalFileSystem) localPath(name string) (string, error) {
 	if (filepath.Separator != '/' && strings.IndexRune(name, filepath.Separator) >= 0) || strings.Contains(name, "\x00") {
@@ -35,7 +39,8 @@ func (fs LocalFileSystem) externalPath(name string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return "/" + filepath.ToSlash(rel), nil
+	// The root is "." relative to itself
+	return path.Clean("/" + filepath.ToSlash(rel)), nil
 }
 
 func (fs LocalFileSystem) Open(ctx context.Context, name string) (io.ReadCloser, error) {
@@ -114,6 +119,44 @@ func (fs LocalFileSystem) ReadDir(ctx context.Context, name string, recursive bo
 	return l, errFromOS(err)
 }
 
+// WalkFS implements WalkFileSystem. Symbolic links are not followed.
+func (fs LocalFileSystem) WalkFS(ctx context.Context, root string, depth int, fn func(path string, info FileInfo) error) error {
+	rootPath, err := fs.localPath(root)
+	if err != nil {
+		return err
+	}
+
+	err = filepath.Walk(rootPath, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		href, err := fs.externalPath(p)
+		if err != nil {
+			return err
+		}
+		if err := fn(href, *fileInfoFromOS(href, fi)); err != nil {
+			return err
+		}
+
+		if fi.IsDir() && depth >= 0 {
+			level := 0
+			if p != rootPath {
+				rel, err := filepath.Rel(rootPath, p)
+				if err != nil {
+					return err
+				}
+				level = strings.Count(rel, string(filepath.Separator)) + 1
+			}
+			if level >= depth {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	return errFromOS(err)
+}
+
 func (fs LocalFileSystem) Create(ctx context.Context, name string, body io.ReadCloser) (*FileInfo, bool, error) {
 	p, err := fs.localPath(name)
 	if err != nil {
@@ -146,6 +189,55 @@ func (fs LocalFileSystem) Create(ctx context.Context, name string, body io.ReadC
 	return fi, created, err
 }
 
+// WriteAt implements PartialWriter.
+func (fs LocalFileSystem) WriteAt(ctx context.Context, name string, body io.ReadCloser, off, size int64) (*FileInfo, bool, error) {
+	p, err := fs.localPath(name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var cur int64
+	stat, err := os.Stat(p)
+	created := os.IsNotExist(err)
+	if err == nil {
+		cur = stat.Size()
+	} else if !created {
+		return nil, false, errFromOS(err)
+	}
+	// Check before opening the file, so that it's not created on failure
+	if off > cur {
+		return nil, false, NewHTTPError(http.StatusRequestedRangeNotSatisfiable, fmt.Errorf("webdav: offset %v beyond the end of the file (%v bytes)", off, cur))
+	}
+
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, false, errFromOS(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		return nil, false, err
+	}
+	n, err := io.Copy(f, body)
+	if err != nil {
+		return nil, false, err
+	}
+	if size >= 0 && off+n >= size {
+		if err := f.Truncate(size); err != nil {
+			return nil, false, err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return nil, false, err
+	}
+
+	fi, err := fs.Stat(ctx, name)
+	if err != nil {
+		return nil, false, err
+	}
+	return fi, created, nil
+}
+
 func (fs LocalFileSystem) RemoveAll(ctx context.Context, name string) error {
 	p, err := fs.localPath(name)
 	if err != nil {
@@ -201,14 +293,15 @@ func (fs LocalFileSystem) Copy(ctx context.Context, src, dst string, options *Co
 		return false, err
 	}
 
-	// TODO: "Note that an infinite-depth COPY of /A/ into /A/B/ could lead to
+	// "Note that an infinite-depth COPY of /A/ into /A/B/ could lead to
 	// infinite recursion if not handled correctly"
+	if dstPath == srcPath || strings.HasPrefix(dstPath, srcPath+string(filepath.Separator)) {
+		return false, internal.HTTPErrorf(http.StatusForbidden, "webdav: cannot copy a collection into itself")
+	}
 
-	srcInfo, err := os.Stat(srcPath)
-	if err != nil {
+	if _, err := os.Stat(srcPath); err != nil {
 		return false, errFromOS(err)
 	}
-	srcPerm := srcInfo.Mode() & os.ModePerm
 
 	if _, err := os.Stat(dstPath); err != nil {
 		if !os.IsNotExist(err) {
@@ -224,19 +317,37 @@ func (fs LocalFileSystem) Copy(ctx context.Context, src, dst string, options *Co
 		}
 	}
 
+	// Failures to copy members of the collection are reported in a
+	// CopyError, the rest of the collection is still copied
+	var errs []ResourceError
 	err = filepath.Walk(srcPath, func(p string, fi os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
+		rel, err := filepath.Rel(srcPath, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dstPath, rel)
+
 		if fi.IsDir() {
-			if err := os.Mkdir(dstPath, srcPerm); err != nil {
-				return errFromOS(err)
-			}
+			err = errFromOS(os.Mkdir(target, fi.Mode()&os.ModePerm))
 		} else {
-			if err := copyRegularFile(srcPath, dstPath, srcPerm); err != nil {
-				return err
+			err = copyRegularFile(p, target, fi.Mode()&os.ModePerm)
+		}
+		if err != nil && p != srcPath {
+			href, hrefErr := fs.externalPath(target)
+			if hrefErr != nil {
+				return hrefErr
 			}
+			errs = append(errs, ResourceError{Path: href, Err: err})
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		} else if err != nil {
+			return err
 		}
 
 		if fi.IsDir() && options.NoRecursive {
@@ -247,6 +358,9 @@ func (fs LocalFileSystem) Copy(ctx context.Context, src, dst string, options *Co
 	if err != nil {
 		return false, errFromOS(err)
 	}
+	if len(errs) > 0 {
+		return created, &CopyError{Errors: errs}
+	}
 
 	return created, nil
 }
@@ -0,0 +1,86 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+func walkPaths(t *testing.T, fs WalkFileSystem, root string, depth int) []string {
+	var l []string
+	err := fs.WalkFS(context.Background(), root, depth, func(p string, fi FileInfo) error {
+		if fi.Path != p {
+			t.Errorf("FileInfo.Path = %v, want %v", fi.Path, p)
+		}
+		l = append(l, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkFS() = %v", err)
+	}
+	sort.Strings(l)
+	return l
+}
+
+func TestLocalFileSystem_WalkFS(t *testing.T) {
+	fs := newTestTree(t)
+
+	for _, tc := range []struct {
+		root  string
+		depth int
+		want  []string
+	}{
+		{"/a/", 0, []string{"/a"}},
+		{"/a/", 1, []string{"/a", "/a/1.txt", "/a/b"}},
+		{"/a/", 2, []string{"/a", "/a/1.txt", "/a/b", "/a/b/2.txt", "/a/b/c"}},
+		{"/a/", -1, []string{"/a", "/a/1.txt", "/a/b", "/a/b/2.txt", "/a/b/c", "/a/b/c/3.txt"}},
+		{"/a/b/c/3.txt", -1, []string{"/a/b/c/3.txt"}},
+	} {
+		if got := walkPaths(t, fs, tc.root, tc.depth); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("WalkFS(%v, %v) = %v, want %v", tc.root, tc.depth, got, tc.want)
+		}
+	}
+}
+
+func TestLocalFileSystem_WalkFS_symlinkLoop(t *testing.T) {
+	fs := newTestTree(t)
+	if err := os.Symlink("..", filepath.Join(string(fs), "a", "b", "loop")); err != nil {
+		t.Skipf("failed to create symbolic link: %v", err)
+	}
+
+	// The link is reported, but not followed
+	want := []string{"/a/b", "/a/b/2.txt", "/a/b/c", "/a/b/c/3.txt", "/a/b/loop"}
+	if got := walkPaths(t, fs, "/a/b/", -1); !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkFS() = %v, want %v", got, want)
+	}
+}
+
+func TestLocalFileSystem_WalkFS_error(t *testing.T) {
+	fs := newTestTree(t)
+
+	errStop := errors.New("stop")
+	n := 0
+	err := fs.WalkFS(context.Background(), "/a/", -1, func(p string, fi FileInfo) error {
+		n++
+		return errStop
+	})
+	if err != errStop {
+		t.Errorf("WalkFS() = %v, want %v", err, errStop)
+	}
+	if n != 1 {
+		t.Errorf("WalkFS() called fn %v times, want 1", n)
+	}
+
+	err = fs.WalkFS(context.Background(), "/missing/", -1, func(p string, fi FileInfo) error {
+		return nil
+	})
+	if !internal.IsNotFound(err) {
+		t.Errorf("WalkFS() on a missing root = %v, want not found", err)
+	}
+}
@@ -0,0 +1,498 @@
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+type memFile struct {
+	isDir   bool
+	data    []byte
+	modTime time.Time
+	etag    string
+	props   map[xml.Name]Property
+}
+
+// MemBackend is a FileSystem storing files and their properties in memory. It
+// is intended for tests and development.
+//
+// MemBackend supports PROPPATCH requests and embeds a MemLockBackend, so it
+// can be used both as the Handler's FileSystem and LockBackend. It is safe
+// for concurrent use.
+type MemBackend struct {
+	MemLockBackend
+
+	mu      sync.Mutex
+	files   map[string]*memFile // by cleaned path, without trailing slash
+	version uint64
+}
+
+var (
+	_ FileSystem  = (*MemBackend)(nil)
+	_ PropPatcher = (*MemBackend)(nil)
+	_ PropFinder  = (*MemBackend)(nil)
+	_ LockBackend = (*MemBackend)(nil)
+)
+
+// NewMemBackend creates a new MemBackend containing an empty root collection.
+func NewMemBackend() *MemBackend {
+	b := &MemBackend{}
+	b.init()
+	return b
+}
+
+// init creates the root collection. The caller must hold the write lock.
+func (b *MemBackend) init() {
+	if b.files == nil {
+		b.files = map[string]*memFile{"/": b.newFile(true, nil)}
+	}
+}
+
+// newFile creates a file with a fresh ETag. The caller must hold the write
+// lock.
+func (b *MemBackend) newFile(isDir bool, data []byte) *memFile {
+	b.version++
+	return &memFile{
+		isDir:   isDir,
+		data:    data,
+		modTime: time.Now(),
+		etag:    fmt.Sprintf("%x", b.version),
+	}
+}
+
+func memPath(name string) (string, error) {
+	if strings.Contains(name, "\x00") {
+		return "", internal.HTTPErrorf(http.StatusBadRequest, "webdav: invalid character in path")
+	}
+	name = path.Clean(name)
+	if !path.IsAbs(name) {
+		return "", internal.HTTPErrorf(http.StatusBadRequest, "webdav: expected absolute path, got %q", name)
+	}
+	return name, nil
+}
+
+// children returns the paths of the descendants of the collection p, sorted.
+// The caller must hold the lock.
+func (b *MemBackend) children(p string, recursive bool) []string {
+	prefix := strings.TrimSuffix(p, "/") + "/"
+	var l []string
+	for name := range b.files {
+		if !strings.HasPrefix(name, prefix) || name == prefix {
+			continue
+		}
+		if !recursive && strings.Contains(name[len(prefix):], "/") {
+			continue
+		}
+		l = append(l, name)
+	}
+	sort.Strings(l)
+	return l
+}
+
+// parent checks that the parent of p is an existing collection. The caller
+// must hold the lock.
+func (b *MemBackend) parent(p string) error {
+	if f, ok := b.files[path.Dir(p)]; !ok || !f.isDir {
+		return internal.HTTPErrorf(http.StatusConflict, "webdav: parent collection of %q doesn't exist", p)
+	}
+	return nil
+}
+
+func (b *MemBackend) fileInfo(p string, f *memFile) *FileInfo {
+	fi := &FileInfo{
+		Path:    p,
+		Size:    int64(len(f.data)),
+		ModTime: f.modTime,
+		IsDir:   f.isDir,
+		ETag:    f.etag,
+	}
+	if !f.isDir {
+		fi.MIMEType = mime.TypeByExtension(path.Ext(p))
+	}
+	if prop, ok := f.props[internal.GetContentLanguageName]; ok {
+		fi.Language = strings.TrimSpace(string(prop.InnerXML))
+	}
+	return fi
+}
+
+func (b *MemBackend) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	p, err := memPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.init()
+
+	f, ok := b.files[p]
+	if !ok {
+		return nil, NewHTTPError(http.StatusNotFound, os.ErrNotExist)
+	}
+	if f.isDir {
+		return nil, internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: %q is a collection", p)
+	}
+	// Data is never modified in place, it's safe to share it
+	return memReader{bytes.NewReader(f.data)}, nil
+}
+
+// memReader is an io.ReadSeeker, so that range requests are supported.
+type memReader struct {
+	*bytes.Reader
+}
+
+func (memReader) Close() error {
+	return nil
+}
+
+func (b *MemBackend) Stat(ctx context.Context, name string) (*FileInfo, error) {
+	p, err := memPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.init()
+
+	f, ok := b.files[p]
+	if !ok {
+		return nil, NewHTTPError(http.StatusNotFound, os.ErrNotExist)
+	}
+	return b.fileInfo(p, f), nil
+}
+
+func (b *MemBackend) ReadDir(ctx context.Context, name string, recursive bool) ([]FileInfo, error) {
+	p, err := memPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.init()
+
+	f, ok := b.files[p]
+	if !ok {
+		return nil, NewHTTPError(http.StatusNotFound, os.ErrNotExist)
+	}
+
+	l := []FileInfo{*b.fileInfo(p, f)}
+	if f.isDir {
+		for _, child := range b.children(p, recursive) {
+			l = append(l, *b.fileInfo(child, b.files[child]))
+		}
+	}
+	return l, nil
+}
+
+func (b *MemBackend) Create(ctx context.Context, name string, body io.ReadCloser) (*FileInfo, bool, error) {
+	p, err := memPath(name)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Read the body before taking the lock, it may be slow
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, false, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.init()
+
+	old, exists := b.files[p]
+	if exists && old.isDir {
+		return nil, false, internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: %q is a collection", p)
+	}
+	if err := b.parent(p); err != nil {
+		return nil, false, err
+	}
+
+	f := b.newFile(false, data)
+	if exists {
+		f.props = old.props
+	}
+	b.files[p] = f
+	return b.fileInfo(p, f), !exists, nil
+}
+
+func (b *MemBackend) RemoveAll(ctx context.Context, name string) error {
+	p, err := memPath(name)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.init()
+
+	if _, ok := b.files[p]; !ok {
+		return NewHTTPError(http.StatusNotFound, os.ErrNotExist)
+	}
+	if p == "/" {
+		return internal.HTTPErrorf(http.StatusForbidden, "webdav: cannot remove the root collection")
+	}
+	b.removeAll(p)
+	return nil
+}
+
+// removeAll removes a file and its descendants. The caller must hold the
+// write lock.
+func (b *MemBackend) removeAll(p string) {
+	for _, child := range b.children(p, true) {
+		delete(b.files, child)
+	}
+	delete(b.files, p)
+}
+
+func (b *MemBackend) Mkdir(ctx context.Context, name string) error {
+	p, err := memPath(name)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.init()
+
+	if _, ok := b.files[p]; ok {
+		return internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: %q already exists", p)
+	}
+	if err := b.parent(p); err != nil {
+		return err
+	}
+	b.files[p] = b.newFile(true, nil)
+	return nil
+}
+
+// prepareDest checks that dst can be written to, and removes it if it
+// exists. The caller must hold the write lock.
+func (b *MemBackend) prepareDest(src, dst string, noOverwrite bool) (created bool, err error) {
+	if _, ok := b.files[src]; !ok {
+		return false, NewHTTPError(http.StatusNotFound, os.ErrNotExist)
+	}
+	if dst == src || isSubPath(src, dst) {
+		return false, internal.HTTPErrorf(http.StatusForbidden, "webdav: cannot copy or move %q into itself", src)
+	}
+	if dst == "/" {
+		return false, internal.HTTPErrorf(http.StatusForbidden, "webdav: cannot overwrite the root collection")
+	}
+	if isSubPath(dst, src) {
+		// Overwriting dst would remove src
+		return false, internal.HTTPErrorf(http.StatusForbidden, "webdav: cannot overwrite %q, which contains %q", dst, src)
+	}
+	if err := b.parent(dst); err != nil {
+		return false, err
+	}
+
+	if _, ok := b.files[dst]; !ok {
+		return true, nil
+	}
+	if noOverwrite {
+		return false, NewHTTPError(http.StatusPreconditionFailed, os.ErrExist)
+	}
+	b.removeAll(dst)
+	return false, nil
+}
+
+func copyProps(props map[xml.Name]Property) map[xml.Name]Property {
+	if props == nil {
+		return nil
+	}
+	m := make(map[xml.Name]Property, len(props))
+	for k, v := range props {
+		m[k] = v
+	}
+	return m
+}
+
+func (b *MemBackend) Copy(ctx context.Context, src, dst string, options *CopyOptions) (created bool, err error) {
+	srcPath, err := memPath(src)
+	if err != nil {
+		return false, err
+	}
+	dstPath, err := memPath(dst)
+	if err != nil {
+		return false, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.init()
+
+	created, err = b.prepareDest(srcPath, dstPath, options.NoOverwrite)
+	if err != nil {
+		return false, err
+	}
+
+	paths := []string{srcPath}
+	if !options.NoRecursive {
+		paths = append(paths, b.children(srcPath, true)...)
+	}
+	for _, p := range paths {
+		f := b.files[p]
+		copied := b.newFile(f.isDir, f.data)
+		copied.props = copyProps(f.props)
+		b.files[dstPath+strings.TrimPrefix(p, srcPath)] = copied
+	}
+	return created, nil
+}
+
+func (b *MemBackend) Move(ctx context.Context, src, dst string, options *MoveOptions) (created bool, err error) {
+	srcPath, err := memPath(src)
+	if err != nil {
+		return false, err
+	}
+	dstPath, err := memPath(dst)
+	if err != nil {
+		return false, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.init()
+
+	if srcPath == "/" {
+		return false, internal.HTTPErrorf(http.StatusForbidden, "webdav: cannot move the root collection")
+	}
+	created, err = b.prepareDest(srcPath, dstPath, options.NoOverwrite)
+	if err != nil {
+		return false, err
+	}
+
+	for _, p := range append(b.children(srcPath, true), srcPath) {
+		b.files[dstPath+strings.TrimPrefix(p, srcPath)] = b.files[p]
+		delete(b.files, p)
+	}
+	return created, nil
+}
+
+// PropPatch implements PropPatcher.
+func (b *MemBackend) PropPatch(ctx context.Context, name string, req *PropPatchRequest) error {
+	p, err := memPath(name)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.init()
+
+	f, ok := b.files[p]
+	if !ok {
+		return NewHTTPError(http.StatusNotFound, os.ErrNotExist)
+	}
+
+	props := copyProps(f.props)
+	if props == nil {
+		props = make(map[xml.Name]Property)
+	}
+	for _, prop := range req.Set {
+		props[prop.XMLName] = prop
+	}
+	for _, name := range req.Remove {
+		delete(props, name)
+	}
+	f.props = props
+	return nil
+}
+
+// Props implements PropFinder.
+func (b *MemBackend) Props(ctx context.Context, name string) ([]Property, error) {
+	p, err := memPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.init()
+
+	f, ok := b.files[p]
+	if !ok {
+		return nil, NewHTTPError(http.StatusNotFound, os.ErrNotExist)
+	}
+
+	props := make([]Property, 0, len(f.props))
+	for _, prop := range f.props {
+		props = append(props, prop)
+	}
+	return props, nil
+}
+
+// Snapshot returns the contents of all files, by path. Collections are
+// represented by paths with a trailing slash and a nil value. Properties and
+// locks are not included.
+func (b *MemBackend) Snapshot() map[string][]byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.init()
+
+	m := make(map[string][]byte, len(b.files))
+	for p, f := range b.files {
+		if f.isDir {
+			m[strings.TrimSuffix(p, "/")+"/"] = nil
+		} else {
+			m[p] = append([]byte(nil), f.data...)
+		}
+	}
+	return m
+}
+
+// Restore replaces the contents of the backend with a snapshot, as returned
+// by Snapshot. Missing parent collections are created. All properties are
+// removed.
+func (b *MemBackend) Restore(snapshot map[string][]byte) error {
+	data := make(map[string][]byte, len(snapshot))
+	isDir := make(map[string]bool, len(snapshot))
+	for name, buf := range snapshot {
+		p, err := memPath(name)
+		if err != nil {
+			return err
+		}
+		dir := strings.HasSuffix(name, "/") || p == "/"
+		if prev, ok := isDir[p]; ok && prev != dir {
+			return fmt.Errorf("webdav: %q is both a file and a collection", p)
+		}
+		isDir[p] = dir
+		if !dir {
+			data[p] = append([]byte(nil), buf...)
+		}
+
+		for child, parent := p, path.Dir(p); parent != child; child, parent = parent, path.Dir(parent) {
+			if prev, ok := isDir[parent]; ok && !prev {
+				return fmt.Errorf("webdav: %q is both a file and a collection", parent)
+			}
+			isDir[parent] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	files := make(map[string]*memFile, len(isDir)+1)
+	for p, dir := range isDir {
+		files[p] = b.newFile(dir, data[p])
+	}
+	if _, ok := files["/"]; !ok {
+		files["/"] = b.newFile(true, nil)
+	}
+	b.files = files
+	return nil
+}
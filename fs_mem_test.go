@@ -0,0 +1,349 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+func memErrorCode(err error) int {
+	if httpErr, ok := err.(*internal.HTTPError); ok {
+		return httpErr.Code
+	}
+	return 0
+}
+
+func newTestMemBackend(t *testing.T) *MemBackend {
+	b := NewMemBackend()
+	err := b.Restore(map[string][]byte{
+		"/a/1.txt":     []byte("a/1.txt"),
+		"/a/b/2.txt":   []byte("a/b/2.txt"),
+		"/a/b/c/3.txt": []byte("a/b/c/3.txt"),
+		"/d/":          nil,
+	})
+	if err != nil {
+		t.Fatalf("Restore() = %v", err)
+	}
+	return b
+}
+
+func memSnapshotPaths(b *MemBackend) []string {
+	var l []string
+	for p := range b.Snapshot() {
+		l = append(l, p)
+	}
+	sort.Strings(l)
+	return l
+}
+
+func testProp(value string) Property {
+	return Property{
+		XMLName:  xml.Name{"urn:example", "color"},
+		InnerXML: []byte(value),
+	}
+}
+
+func TestMemBackend_read(t *testing.T) {
+	ctx := context.Background()
+	b := newTestMemBackend(t)
+
+	rc, err := b.Open(ctx, "/a/b/2.txt")
+	if err != nil {
+		t.Fatalf("Open() = %v", err)
+	}
+	data, _ := ioutil.ReadAll(rc)
+	rc.Close()
+	if string(data) != "a/b/2.txt" {
+		t.Errorf("Open() data = %q, want %q", data, "a/b/2.txt")
+	}
+	if _, err := b.Open(ctx, "/a"); memErrorCode(err) != http.StatusMethodNotAllowed {
+		t.Errorf("Open() on a collection = %v, want status %v", err, http.StatusMethodNotAllowed)
+	}
+	if _, err := b.Open(ctx, "/missing"); !internal.IsNotFound(err) {
+		t.Errorf("Open() on a missing file = %v, want not found", err)
+	}
+
+	fi, err := b.Stat(ctx, "/a/1.txt/")
+	if err != nil {
+		t.Fatalf("Stat() = %v", err)
+	}
+	if fi.Path != "/a/1.txt" || fi.Size != 7 || fi.IsDir || fi.MIMEType != "text/plain; charset=utf-8" || fi.ETag == "" {
+		t.Errorf("Stat() = %+v", fi)
+	}
+	if fi, err := b.Stat(ctx, "/"); err != nil || !fi.IsDir {
+		t.Errorf("Stat(/) = %+v, %v, want a collection", fi, err)
+	}
+	if _, err := b.Stat(ctx, "relative"); memErrorCode(err) != http.StatusBadRequest {
+		t.Errorf("Stat() with a relative path = %v, want status %v", err, http.StatusBadRequest)
+	}
+	if _, err := b.Stat(ctx, "/a\x00"); memErrorCode(err) != http.StatusBadRequest {
+		t.Errorf("Stat() with a NUL byte = %v, want status %v", err, http.StatusBadRequest)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		recursive bool
+		want      []string
+	}{
+		{"/a", false, []string{"/a", "/a/1.txt", "/a/b"}},
+		{"/a/", true, []string{"/a", "/a/1.txt", "/a/b", "/a/b/2.txt", "/a/b/c", "/a/b/c/3.txt"}},
+		{"/a/1.txt", true, []string{"/a/1.txt"}},
+		{"/", false, []string{"/", "/a", "/d"}},
+	} {
+		l, err := b.ReadDir(ctx, tc.name, tc.recursive)
+		if err != nil {
+			t.Fatalf("ReadDir(%q) = %v", tc.name, err)
+		}
+		var got []string
+		for _, fi := range l {
+			got = append(got, fi.Path)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ReadDir(%q, %v) = %v, want %v", tc.name, tc.recursive, got, tc.want)
+		}
+	}
+}
+
+func TestMemBackend_zeroValue(t *testing.T) {
+	var b MemBackend
+	if _, _, err := b.Create(context.Background(), "/file", ioutil.NopCloser(strings.NewReader("hello"))); err != nil {
+		t.Fatalf("Create() = %v", err)
+	}
+	if got, want := memSnapshotPaths(&b), []string{"/", "/file"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() = %v, want %v", got, want)
+	}
+}
+
+func TestMemBackend_write(t *testing.T) {
+	ctx := context.Background()
+	b := newTestMemBackend(t)
+
+	fi, created, err := b.Create(ctx, "/d/new.txt", ioutil.NopCloser(strings.NewReader("hello")))
+	if err != nil || !created {
+		t.Fatalf("Create() = %v, %v, want created", created, err)
+	}
+	if err := b.PropPatch(ctx, "/d/new.txt", &PropPatchRequest{Set: []Property{testProp("red")}}); err != nil {
+		t.Fatalf("PropPatch() = %v", err)
+	}
+	fi2, created, err := b.Create(ctx, "/d/new.txt", ioutil.NopCloser(strings.NewReader("world!")))
+	if err != nil || created {
+		t.Fatalf("Create() = %v, %v, want overwritten", created, err)
+	}
+	if fi2.Size != 6 || fi2.ETag == fi.ETag {
+		t.Errorf("Create() = %+v, want a new ETag and size 6", fi2)
+	}
+	if props, _ := b.Props(ctx, "/d/new.txt"); len(props) != 1 {
+		t.Errorf("properties lost when overwriting the file: %v", props)
+	}
+
+	if _, _, err := b.Create(ctx, "/missing/new.txt", ioutil.NopCloser(strings.NewReader(""))); memErrorCode(err) != http.StatusConflict {
+		t.Errorf("Create() without parent = %v, want status %v", err, http.StatusConflict)
+	}
+	if _, _, err := b.Create(ctx, "/a/1.txt/new.txt", ioutil.NopCloser(strings.NewReader(""))); memErrorCode(err) != http.StatusConflict {
+		t.Errorf("Create() in a file = %v, want status %v", err, http.StatusConflict)
+	}
+	if _, _, err := b.Create(ctx, "/a", ioutil.NopCloser(strings.NewReader(""))); memErrorCode(err) != http.StatusMethodNotAllowed {
+		t.Errorf("Create() on a collection = %v, want status %v", err, http.StatusMethodNotAllowed)
+	}
+
+	if err := b.Mkdir(ctx, "/d/e"); err != nil {
+		t.Fatalf("Mkdir() = %v", err)
+	}
+	if err := b.Mkdir(ctx, "/d/e"); memErrorCode(err) != http.StatusMethodNotAllowed {
+		t.Errorf("Mkdir() on an existing collection = %v, want status %v", err, http.StatusMethodNotAllowed)
+	}
+	if err := b.Mkdir(ctx, "/x/y"); memErrorCode(err) != http.StatusConflict {
+		t.Errorf("Mkdir() without parent = %v, want status %v", err, http.StatusConflict)
+	}
+
+	if err := b.RemoveAll(ctx, "/a/b"); err != nil {
+		t.Fatalf("RemoveAll() = %v", err)
+	}
+	if err := b.RemoveAll(ctx, "/a/b"); !internal.IsNotFound(err) {
+		t.Errorf("RemoveAll() on a missing file = %v, want not found", err)
+	}
+	if err := b.RemoveAll(ctx, "/"); memErrorCode(err) != http.StatusForbidden {
+		t.Errorf("RemoveAll(/) = %v, want status %v", err, http.StatusForbidden)
+	}
+
+	want := []string{"/", "/a/", "/a/1.txt", "/d/", "/d/e/", "/d/new.txt"}
+	if got := memSnapshotPaths(b); !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() = %v, want %v", got, want)
+	}
+}
+
+func TestMemBackend_copy(t *testing.T) {
+	ctx := context.Background()
+	b := newTestMemBackend(t)
+	if err := b.PropPatch(ctx, "/a/b", &PropPatchRequest{Set: []Property{testProp("red")}}); err != nil {
+		t.Fatalf("PropPatch() = %v", err)
+	}
+
+	created, err := b.Copy(ctx, "/a/b", "/d/b", &CopyOptions{})
+	if err != nil || !created {
+		t.Fatalf("Copy() = %v, %v, want created", created, err)
+	}
+	snapshot := b.Snapshot()
+	if string(snapshot["/d/b/c/3.txt"]) != "a/b/c/3.txt" {
+		t.Errorf("copied file = %q, want %q", snapshot["/d/b/c/3.txt"], "a/b/c/3.txt")
+	}
+	src, _ := b.Stat(ctx, "/a/b/2.txt")
+	dst, _ := b.Stat(ctx, "/d/b/2.txt")
+	if src.ETag == dst.ETag {
+		t.Errorf("copy has the same ETag as the source")
+	}
+
+	// The copied properties are independent
+	if err := b.PropPatch(ctx, "/d/b", &PropPatchRequest{Set: []Property{testProp("blue")}}); err != nil {
+		t.Fatalf("PropPatch() = %v", err)
+	}
+	if props, _ := b.Props(ctx, "/a/b"); len(props) != 1 || string(props[0].InnerXML) != "red" {
+		t.Errorf("source properties = %v, want red", props)
+	}
+
+	created, err = b.Copy(ctx, "/a", "/d/b", &CopyOptions{NoRecursive: true})
+	if err != nil || created {
+		t.Fatalf("Copy() = %v, %v, want overwritten", created, err)
+	}
+	if _, err := b.Stat(ctx, "/d/b/2.txt"); !internal.IsNotFound(err) {
+		t.Errorf("overwritten destination still has children: %v", err)
+	}
+	if _, err := b.Stat(ctx, "/d/b/1.txt"); !internal.IsNotFound(err) {
+		t.Errorf("NoRecursive copy has children: %v", err)
+	}
+
+	for _, tc := range []struct {
+		src, dst string
+		options  CopyOptions
+		want     int
+	}{
+		{"/a", "/d/b", CopyOptions{NoOverwrite: true}, http.StatusPreconditionFailed},
+		{"/a", "/a/b/a", CopyOptions{}, http.StatusForbidden},
+		{"/a", "/a", CopyOptions{}, http.StatusForbidden},
+		{"/a/b", "/a", CopyOptions{}, http.StatusForbidden},
+		{"/a/b/c/3.txt", "/a/b", CopyOptions{}, http.StatusForbidden},
+		{"/a/1.txt", "/", CopyOptions{}, http.StatusForbidden},
+		{"/a/1.txt", "/x/1.txt", CopyOptions{}, http.StatusConflict},
+		{"/missing", "/d/missing", CopyOptions{}, http.StatusNotFound},
+	} {
+		if _, err := b.Copy(ctx, tc.src, tc.dst, &tc.options); memErrorCode(err) != tc.want {
+			t.Errorf("Copy(%q, %q, %+v) = %v, want status %v", tc.src, tc.dst, tc.options, err, tc.want)
+		}
+	}
+	if fi, err := b.Stat(ctx, "/a/b/c/3.txt"); err != nil || fi == nil {
+		t.Errorf("source removed by failed copies: %v", err)
+	}
+}
+
+func TestMemBackend_move(t *testing.T) {
+	ctx := context.Background()
+	b := newTestMemBackend(t)
+	if err := b.PropPatch(ctx, "/a/b/2.txt", &PropPatchRequest{Set: []Property{testProp("red")}}); err != nil {
+		t.Fatalf("PropPatch() = %v", err)
+	}
+	etag := func(name string) string {
+		fi, err := b.Stat(ctx, name)
+		if err != nil {
+			t.Fatalf("Stat(%q) = %v", name, err)
+		}
+		return fi.ETag
+	}
+	before := etag("/a/b/2.txt")
+
+	created, err := b.Move(ctx, "/a/b", "/d/b", &MoveOptions{})
+	if err != nil || !created {
+		t.Fatalf("Move() = %v, %v, want created", created, err)
+	}
+	want := []string{"/", "/a/", "/a/1.txt", "/d/", "/d/b/", "/d/b/2.txt", "/d/b/c/", "/d/b/c/3.txt"}
+	if got := memSnapshotPaths(b); !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() = %v, want %v", got, want)
+	}
+	if after := etag("/d/b/2.txt"); after != before {
+		t.Errorf("ETag changed by Move(): %q, want %q", after, before)
+	}
+	if props, _ := b.Props(ctx, "/d/b/2.txt"); len(props) != 1 {
+		t.Errorf("properties lost by Move(): %v", props)
+	}
+
+	if _, err := b.Move(ctx, "/", "/d/root", &MoveOptions{}); memErrorCode(err) != http.StatusForbidden {
+		t.Errorf("Move(/) = %v, want status %v", err, http.StatusForbidden)
+	}
+	if _, err := b.Move(ctx, "/a/1.txt", "/d/b", &MoveOptions{NoOverwrite: true}); memErrorCode(err) != http.StatusPreconditionFailed {
+		t.Errorf("Move() with NoOverwrite = %v, want status %v", err, http.StatusPreconditionFailed)
+	}
+
+	// Overwriting an ancestor of the source would remove the source
+	for _, dst := range []string{"/d", "/"} {
+		if _, err := b.Move(ctx, "/d/b/c", dst, &MoveOptions{}); memErrorCode(err) != http.StatusForbidden {
+			t.Errorf("Move(/d/b/c, %v) = %v, want status %v", dst, err, http.StatusForbidden)
+		}
+	}
+	if got := memSnapshotPaths(b); !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() after failed moves = %v, want %v", got, want)
+	}
+	if _, err := b.Stat(ctx, "/d"); err != nil {
+		t.Errorf("Stat(/d) = %v", err)
+	}
+}
+
+func TestMemBackend_props(t *testing.T) {
+	ctx := context.Background()
+	b := newTestMemBackend(t)
+
+	other := Property{XMLName: xml.Name{"urn:example", "size"}, InnerXML: []byte("large")}
+	if err := b.PropPatch(ctx, "/a/1.txt", &PropPatchRequest{Set: []Property{testProp("red"), other}}); err != nil {
+		t.Fatalf("PropPatch() = %v", err)
+	}
+	if err := b.PropPatch(ctx, "/a/1.txt", &PropPatchRequest{Set: []Property{testProp("blue")}, Remove: []xml.Name{other.XMLName}}); err != nil {
+		t.Fatalf("PropPatch() = %v", err)
+	}
+	props, err := b.Props(ctx, "/a/1.txt")
+	if err != nil {
+		t.Fatalf("Props() = %v", err)
+	}
+	if len(props) != 1 || string(props[0].InnerXML) != "blue" {
+		t.Errorf("Props() = %v, want a single blue color", props)
+	}
+
+	if err := b.PropPatch(ctx, "/missing", &PropPatchRequest{Set: []Property{testProp("red")}}); !internal.IsNotFound(err) {
+		t.Errorf("PropPatch() on a missing file = %v, want not found", err)
+	}
+	if _, err := b.Props(ctx, "/missing"); !internal.IsNotFound(err) {
+		t.Errorf("Props() on a missing file = %v, want not found", err)
+	}
+}
+
+func TestMemBackend_Restore(t *testing.T) {
+	b := newTestMemBackend(t)
+
+	for _, snapshot := range []map[string][]byte{
+		{"/a": []byte("file"), "/a/b": []byte("child")},
+		{"/a/b": []byte("child"), "/a": []byte("file")},
+		{"/a": []byte("file"), "/a/": nil},
+		{"relative": nil},
+	} {
+		if err := b.Restore(snapshot); err == nil {
+			t.Errorf("Restore(%v) succeeded", snapshot)
+		}
+	}
+
+	// A failed restore leaves the backend untouched
+	want := []string{"/", "/a/", "/a/1.txt", "/a/b/", "/a/b/2.txt", "/a/b/c/", "/a/b/c/3.txt", "/d/"}
+	if got := memSnapshotPaths(b); !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot() = %v, want %v", got, want)
+	}
+
+	if err := b.Restore(nil); err != nil {
+		t.Fatalf("Restore(nil) = %v", err)
+	}
+	if got := memSnapshotPaths(b); !reflect.DeepEqual(got, []string{"/"}) {
+		t.Errorf("Snapshot() after Restore(nil) = %v, want only the root", got)
+	}
+}
@@ -0,0 +1,410 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+// errPropsAttrUnsupported is returned by the platform-specific functions
+// storing properties alongside files when the file system doesn't support it.
+var errPropsAttrUnsupported = errors.New("webdav: file attributes not supported")
+
+// osSidecarName is the name of the file storing dead properties when the file
+// system doesn't support extended attributes.
+const osSidecarName = ".webdav-props"
+
+type osProps struct {
+	XMLName xml.Name   `xml:"webdav-props"`
+	Props   []Property `xml:",any"`
+}
+
+type osSidecarFile struct {
+	Path  string     `xml:"path,attr"`
+	Props []Property `xml:",any"`
+}
+
+type osSidecar struct {
+	XMLName xml.Name        `xml:"webdav-props"`
+	Files   []osSidecarFile `xml:"file"`
+}
+
+// OSBackend is a FileSystem storing files in a local directory. It implements
+// DeadPropsStore: dead properties are stored in extended attributes on Linux
+// and macOS, and in an alternate data stream on Windows. On other platforms,
+// or if the file system doesn't support it, they are stored in a
+// ".webdav-props" file at the root of the directory, which is hidden from
+// clients.
+type OSBackend struct {
+	LocalFileSystem
+
+	noPropsAttr bool // always use the sidecar file, for tests
+
+	mu          sync.Mutex // protects the fields below and the sidecar file
+	sidecar     map[string][]Property
+	sidecarInfo os.FileInfo // sidecar file info when sidecar was loaded
+}
+
+var (
+	_ FileSystem     = (*OSBackend)(nil)
+	_ WalkFileSystem = (*OSBackend)(nil)
+	_ DeadPropsStore = (*OSBackend)(nil)
+	_ PartialWriter  = (*OSBackend)(nil)
+)
+
+// NewOSBackend creates a new OSBackend for a local directory.
+func NewOSBackend(dir string) *OSBackend {
+	return &OSBackend{LocalFileSystem: LocalFileSystem(dir)}
+}
+
+func (b *OSBackend) checkName(name string) error {
+	// Temporary files used to replace the sidecar file are hidden as well
+	p := path.Clean("/" + name)
+	if p == "/"+osSidecarName || strings.HasPrefix(p, "/"+osSidecarName+"-") {
+		return NewHTTPError(http.StatusNotFound, os.ErrNotExist)
+	}
+	return nil
+}
+
+func (b *OSBackend) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := b.checkName(name); err != nil {
+		return nil, err
+	}
+	return b.LocalFileSystem.Open(ctx, name)
+}
+
+func (b *OSBackend) Stat(ctx context.Context, name string) (*FileInfo, error) {
+	if err := b.checkName(name); err != nil {
+		return nil, err
+	}
+	return b.LocalFileSystem.Stat(ctx, name)
+}
+
+func (b *OSBackend) ReadDir(ctx context.Context, name string, recursive bool) ([]FileInfo, error) {
+	if err := b.checkName(name); err != nil {
+		return nil, err
+	}
+	l, err := b.LocalFileSystem.ReadDir(ctx, name, recursive)
+	if err != nil {
+		return nil, err
+	}
+	filtered := l[:0]
+	for _, fi := range l {
+		if b.checkName(fi.Path) == nil {
+			filtered = append(filtered, fi)
+		}
+	}
+	return filtered, nil
+}
+
+// WalkFS implements WalkFileSystem.
+func (b *OSBackend) WalkFS(ctx context.Context, root string, depth int, fn func(path string, info FileInfo) error) error {
+	if err := b.checkName(root); err != nil {
+		return err
+	}
+	return b.LocalFileSystem.WalkFS(ctx, root, depth, func(p string, fi FileInfo) error {
+		if b.checkName(p) != nil {
+			return nil
+		}
+		return fn(p, fi)
+	})
+}
+
+func (b *OSBackend) Create(ctx context.Context, name string, body io.ReadCloser) (*FileInfo, bool, error) {
+	if err := b.checkName(name); err != nil {
+		return nil, false, internal.HTTPErrorf(http.StatusForbidden, "webdav: %q is reserved", name)
+	}
+	return b.LocalFileSystem.Create(ctx, name, body)
+}
+
+// WriteAt implements PartialWriter.
+func (b *OSBackend) WriteAt(ctx context.Context, name string, body io.ReadCloser, off, size int64) (*FileInfo, bool, error) {
+	if err := b.checkName(name); err != nil {
+		return nil, false, internal.HTTPErrorf(http.StatusForbidden, "webdav: %q is reserved", name)
+	}
+	return b.LocalFileSystem.WriteAt(ctx, name, body, off, size)
+}
+
+func (b *OSBackend) RemoveAll(ctx context.Context, name string) error {
+	if err := b.checkName(name); err != nil {
+		return err
+	}
+	return b.LocalFileSystem.RemoveAll(ctx, name)
+}
+
+func (b *OSBackend) Mkdir(ctx context.Context, name string) error {
+	if err := b.checkName(name); err != nil {
+		return internal.HTTPErrorf(http.StatusForbidden, "webdav: %q is reserved", name)
+	}
+	return b.LocalFileSystem.Mkdir(ctx, name)
+}
+
+func (b *OSBackend) Copy(ctx context.Context, src, dst string, options *CopyOptions) (created bool, err error) {
+	if err := b.checkName(src); err != nil {
+		return false, err
+	}
+	if err := b.checkName(dst); err != nil {
+		return false, internal.HTTPErrorf(http.StatusForbidden, "webdav: %q is reserved", dst)
+	}
+	return b.LocalFileSystem.Copy(ctx, src, dst, options)
+}
+
+func (b *OSBackend) Move(ctx context.Context, src, dst string, options *MoveOptions) (created bool, err error) {
+	if err := b.checkName(src); err != nil {
+		return false, err
+	}
+	if err := b.checkName(dst); err != nil {
+		return false, internal.HTTPErrorf(http.StatusForbidden, "webdav: %q is reserved", dst)
+	}
+	return b.LocalFileSystem.Move(ctx, src, dst, options)
+}
+
+func (b *OSBackend) readProps(p string) ([]Property, error) {
+	if b.noPropsAttr {
+		// Like the platform-specific functions, fail for missing files
+		if _, err := os.Lstat(p); err != nil {
+			return nil, err
+		}
+		return nil, errPropsAttrUnsupported
+	}
+	data, err := getPropsAttr(p)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var props osProps
+	if err := xml.Unmarshal(data, &props); err != nil {
+		return nil, err
+	}
+	return props.Props, nil
+}
+
+func (b *OSBackend) writeProps(p string, props []Property) error {
+	if b.noPropsAttr {
+		return errPropsAttrUnsupported
+	}
+	if len(props) == 0 {
+		return removePropsAttr(p)
+	}
+	data, err := xml.Marshal(&osProps{Props: props})
+	if err != nil {
+		return err
+	}
+	return setPropsAttr(p, data)
+}
+
+func (b *OSBackend) sidecarPath() string {
+	return filepath.Join(string(b.LocalFileSystem), osSidecarName)
+}
+
+// loadSidecar returns the properties stored in the sidecar file, keyed by
+// path. The parsed file is cached until it's modified. The caller must hold
+// b.mu.
+func (b *OSBackend) loadSidecar() (map[string][]Property, error) {
+	fi, err := os.Stat(b.sidecarPath())
+	if os.IsNotExist(err) {
+		b.sidecar, b.sidecarInfo = nil, nil
+		return make(map[string][]Property), nil
+	} else if err != nil {
+		return nil, err
+	}
+	if b.sidecarInfo != nil && os.SameFile(fi, b.sidecarInfo) && fi.Size() == b.sidecarInfo.Size() && fi.ModTime().Equal(b.sidecarInfo.ModTime()) {
+		return b.sidecar, nil
+	}
+
+	data, err := ioutil.ReadFile(b.sidecarPath())
+	if err != nil {
+		return nil, err
+	}
+	var sidecar osSidecar
+	if err := xml.Unmarshal(data, &sidecar); err != nil {
+		return nil, err
+	}
+	m := make(map[string][]Property, len(sidecar.Files))
+	for _, f := range sidecar.Files {
+		m[f.Path] = f.Props
+	}
+	b.sidecar, b.sidecarInfo = m, fi
+	return m, nil
+}
+
+// writeSidecar replaces the sidecar file. The new contents are written to a
+// temporary file first, so that the sidecar is never left half-written. The
+// caller must hold b.mu.
+func (b *OSBackend) writeSidecar(m map[string][]Property) error {
+	// Invalidate the cache in case of failure, m may have been modified
+	b.sidecar, b.sidecarInfo = nil, nil
+
+	var sidecar osSidecar
+	for p, props := range m {
+		sidecar.Files = append(sidecar.Files, osSidecarFile{Path: p, Props: props})
+	}
+	sort.Slice(sidecar.Files, func(i, j int) bool {
+		return sidecar.Files[i].Path < sidecar.Files[j].Path
+	})
+	data, err := xml.Marshal(&sidecar)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(string(b.LocalFileSystem), osSidecarName+"-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), b.sidecarPath())
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	fi, err := os.Stat(b.sidecarPath())
+	if err != nil {
+		return err
+	}
+	b.sidecar, b.sidecarInfo = m, fi
+	return nil
+}
+
+// updateSidecar calls fn with the properties stored in the sidecar file for a
+// file. If fn returns true, the properties are written back.
+func (b *OSBackend) updateSidecar(name string, fn func(props []Property) ([]Property, bool)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m, err := b.loadSidecar()
+	if err != nil {
+		return err
+	}
+
+	name = path.Clean("/" + name)
+	props, changed := fn(m[name])
+	if !changed {
+		return nil
+	}
+	if len(props) > 0 {
+		m[name] = props
+	} else {
+		delete(m, name)
+	}
+	return b.writeSidecar(m)
+}
+
+// updateProps applies fn to the dead properties of a file.
+func (b *OSBackend) updateProps(name string, fn func(props []Property) ([]Property, bool)) error {
+	p, err := b.localPath(name)
+	if err != nil {
+		return err
+	}
+
+	props, err := b.readProps(p)
+	if err == errPropsAttrUnsupported {
+		return b.updateSidecar(name, fn)
+	} else if os.IsNotExist(err) {
+		// The Handler removes the properties of deleted and moved files
+		// after the fact: they may still be stored in the sidecar file.
+		// Only allow removing them, properties can't be added to a
+		// missing file.
+		sidecarErr := b.updateSidecar(name, func(props []Property) ([]Property, bool) {
+			l, changed := fn(props)
+			return l, changed && len(l) < len(props)
+		})
+		if sidecarErr != nil {
+			return sidecarErr
+		}
+		return NewHTTPError(http.StatusNotFound, err)
+	} else if err != nil {
+		return err
+	}
+
+	props, changed := fn(props)
+	if !changed {
+		return nil
+	}
+	err = b.writeProps(p, props)
+	if err == errPropsAttrUnsupported {
+		return b.updateSidecar(name, fn)
+	}
+	return err
+}
+
+// GetDeadProps implements DeadPropsStore.
+func (b *OSBackend) GetDeadProps(ctx context.Context, name string) ([]Property, error) {
+	var props []Property
+	err := b.updateProps(name, func(l []Property) ([]Property, bool) {
+		props = l
+		return l, false
+	})
+	if internal.IsNotFound(err) {
+		return nil, nil
+	}
+	return props, err
+}
+
+// SetDeadProps implements DeadPropsStore.
+func (b *OSBackend) SetDeadProps(ctx context.Context, name string, props []Property) error {
+	return b.updateProps(name, func(l []Property) ([]Property, bool) {
+		l = append([]Property(nil), l...)
+		for _, prop := range props {
+			replaced := false
+			for i := range l {
+				if l[i].XMLName == prop.XMLName {
+					l[i] = prop
+					replaced = true
+				}
+			}
+			if !replaced {
+				l = append(l, prop)
+			}
+		}
+		return l, true
+	})
+}
+
+// RemoveDeadProps implements DeadPropsStore.
+func (b *OSBackend) RemoveDeadProps(ctx context.Context, name string, names []xml.Name) error {
+	err := b.updateProps(name, func(l []Property) ([]Property, bool) {
+		if len(l) == 0 {
+			return nil, false
+		}
+		if names == nil {
+			return nil, true
+		}
+		var kept []Property
+		for _, prop := range l {
+			removed := false
+			for _, name := range names {
+				if prop.XMLName == name {
+					removed = true
+					break
+				}
+			}
+			if !removed {
+				kept = append(kept, prop)
+			}
+		}
+		return kept, len(kept) != len(l)
+	})
+	if internal.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
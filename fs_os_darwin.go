@@ -0,0 +1,9 @@
+package webdav
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+const propsAttrName = "org.webdav.props"
+
+const errNoPropsAttr = unix.ENOATTR
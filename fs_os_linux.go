@@ -0,0 +1,10 @@
+package webdav
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// Only the user namespace is available to unprivileged processes.
+const propsAttrName = "user.webdav.props"
+
+const errNoPropsAttr = unix.ENODATA
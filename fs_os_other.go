@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package webdav
+
+func getPropsAttr(p string) ([]byte, error) {
+	return nil, errPropsAttrUnsupported
+}
+
+func setPropsAttr(p string, data []byte) error {
+	return errPropsAttrUnsupported
+}
+
+func removePropsAttr(p string) error {
+	return errPropsAttrUnsupported
+}
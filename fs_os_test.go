@@ -0,0 +1,258 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+func newTestOSBackend(t *testing.T) *OSBackend {
+	return NewOSBackend(string(newTestTree(t)))
+}
+
+func deadPropValues(t *testing.T, store DeadPropsStore, name string) map[string]string {
+	props, err := store.GetDeadProps(context.Background(), name)
+	if err != nil {
+		t.Fatalf("GetDeadProps(%q) = %v", name, err)
+	}
+	m := make(map[string]string)
+	for _, prop := range props {
+		m[prop.XMLName.Local] = string(prop.InnerXML)
+	}
+	return m
+}
+
+func TestOSBackend_deadProps(t *testing.T) {
+	ctx := context.Background()
+	b := newTestOSBackend(t)
+	colorName := xml.Name{"urn:example", "color"}
+	sizeName := xml.Name{"urn:example", "size"}
+
+	for _, name := range []string{"/a/1.txt", "/a/b/"} {
+		err := b.SetDeadProps(ctx, name, []Property{
+			{XMLName: colorName, InnerXML: []byte("red")},
+			{XMLName: sizeName, InnerXML: []byte("large")},
+		})
+		if err != nil {
+			t.Fatalf("SetDeadProps(%q) = %v", name, err)
+		}
+		if err := b.SetDeadProps(ctx, name, []Property{{XMLName: colorName, InnerXML: []byte("blue")}}); err != nil {
+			t.Fatalf("SetDeadProps(%q) = %v", name, err)
+		}
+		if got, want := deadPropValues(t, b, name), map[string]string{"color": "blue", "size": "large"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("GetDeadProps(%q) = %v, want %v", name, got, want)
+		}
+
+		if err := b.RemoveDeadProps(ctx, name, []xml.Name{sizeName}); err != nil {
+			t.Fatalf("RemoveDeadProps(%q) = %v", name, err)
+		}
+		if got, want := deadPropValues(t, b, name), map[string]string{"color": "blue"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("GetDeadProps(%q) = %v, want %v", name, got, want)
+		}
+
+		if err := b.RemoveDeadProps(ctx, name, nil); err != nil {
+			t.Fatalf("RemoveDeadProps(%q) = %v", name, err)
+		}
+		if got := deadPropValues(t, b, name); len(got) != 0 {
+			t.Errorf("GetDeadProps(%q) = %v, want none", name, got)
+		}
+	}
+
+	if got := deadPropValues(t, b, "/missing"); len(got) != 0 {
+		t.Errorf("GetDeadProps() on a missing file = %v, want none", got)
+	}
+	if err := b.RemoveDeadProps(ctx, "/missing", nil); err != nil {
+		t.Errorf("RemoveDeadProps() on a missing file = %v", err)
+	}
+	err := b.SetDeadProps(ctx, "/missing", []Property{{XMLName: colorName, InnerXML: []byte("red")}})
+	if !internal.IsNotFound(err) {
+		t.Errorf("SetDeadProps() on a missing file = %v, want not found", err)
+	}
+}
+
+// readSidecar returns the properties stored in the sidecar for a file.
+func readSidecar(t *testing.T, b *OSBackend, name string) map[string]string {
+	m := make(map[string]string)
+	err := b.updateSidecar(name, func(props []Property) ([]Property, bool) {
+		for _, prop := range props {
+			m[prop.XMLName.Local] = string(prop.InnerXML)
+		}
+		return props, false
+	})
+	if err != nil {
+		t.Fatalf("updateSidecar(%q) = %v", name, err)
+	}
+	return m
+}
+
+func TestOSBackend_sidecar(t *testing.T) {
+	b := newTestOSBackend(t)
+	set := func(name, color string) {
+		err := b.updateSidecar(name, func(props []Property) ([]Property, bool) {
+			if color == "" {
+				return nil, true
+			}
+			return []Property{{XMLName: xml.Name{"urn:example", "color"}, InnerXML: []byte(color)}}, true
+		})
+		if err != nil {
+			t.Fatalf("updateSidecar(%q) = %v", name, err)
+		}
+	}
+
+	if got := readSidecar(t, b, "/a/1.txt"); len(got) != 0 {
+		t.Errorf("properties without a sidecar file = %v, want none", got)
+	}
+
+	set("/a/1.txt", "red")
+	set("a/b", "blue")
+	if got, want := readSidecar(t, b, "/a/1.txt"), map[string]string{"color": "red"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("properties = %v, want %v", got, want)
+	}
+	if got, want := readSidecar(t, b, "/a/b/"), map[string]string{"color": "blue"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("properties = %v, want %v", got, want)
+	}
+
+	// The sidecar file is replaced, no temporary file is left behind
+	entries, err := ioutil.ReadDir(string(b.LocalFileSystem))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), osSidecarName+"-") {
+			t.Errorf("temporary sidecar file %q left behind", entry.Name())
+		}
+	}
+
+	// Changes made to the sidecar file by another process are picked up
+	other := NewOSBackend(string(b.LocalFileSystem))
+	if err := other.updateSidecar("/a/1.txt", func(props []Property) ([]Property, bool) {
+		return []Property{{XMLName: xml.Name{"urn:example", "color"}, InnerXML: []byte("green")}}, true
+	}); err != nil {
+		t.Fatalf("updateSidecar() = %v", err)
+	}
+	if got, want := readSidecar(t, b, "/a/1.txt"), map[string]string{"color": "green"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("properties after an external change = %v, want %v", got, want)
+	}
+
+	set("/a/1.txt", "")
+	set("/a/b", "")
+	data, err := ioutil.ReadFile(b.sidecarPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "<file") {
+		t.Errorf("sidecar file still has entries: %s", data)
+	}
+
+	if err := ioutil.WriteFile(b.sidecarPath(), []byte("<invalid"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.updateSidecar("/a/1.txt", func(props []Property) ([]Property, bool) {
+		return props, false
+	}); err == nil {
+		t.Errorf("updateSidecar() with an invalid sidecar file succeeded")
+	}
+}
+
+func TestOSBackend_sidecarHandler(t *testing.T) {
+	b := newTestOSBackend(t)
+	b.noPropsAttr = true
+	h := &Handler{FileSystem: b, DeadPropsStore: b}
+	for _, name := range []string{"/a/1.txt", "/a/b", "/a/b/2.txt"} {
+		err := b.updateSidecar(name, func(props []Property) ([]Property, bool) {
+			return []Property{{XMLName: xml.Name{"urn:example", "color"}, InnerXML: []byte("red")}}, true
+		})
+		if err != nil {
+			t.Fatalf("updateSidecar(%q) = %v", name, err)
+		}
+	}
+
+	w := serveTestRequest(h, http.MethodDelete, "/a/1.txt", "", nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE status = %v, want %v", w.Code, http.StatusNoContent)
+	}
+	if got := readSidecar(t, b, "/a/1.txt"); len(got) != 0 {
+		t.Errorf("properties of a deleted file = %v, want none", got)
+	}
+
+	w = serveTestRequest(h, "MOVE", "/a/b/", "", map[string]string{"Destination": "/d/b/"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("MOVE status = %v, want %v", w.Code, http.StatusCreated)
+	}
+	for _, name := range []string{"/a/b", "/a/b/2.txt"} {
+		if got := readSidecar(t, b, name); len(got) != 0 {
+			t.Errorf("properties of moved %v = %v, want none", name, got)
+		}
+	}
+	want := map[string]string{"color": "red"}
+	for _, name := range []string{"/d/b", "/d/b/2.txt"} {
+		if got := deadPropValues(t, b, name); !reflect.DeepEqual(got, want) {
+			t.Errorf("properties of %v = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestOSBackend_sidecarHidden(t *testing.T) {
+	ctx := context.Background()
+	b := newTestOSBackend(t)
+	for _, name := range []string{osSidecarName, osSidecarName + "-123"} {
+		if err := ioutil.WriteFile(filepath.Join(string(b.LocalFileSystem), name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"/" + osSidecarName, "/a/../" + osSidecarName + "-123"} {
+		if _, err := b.Stat(ctx, name); !internal.IsNotFound(err) {
+			t.Errorf("Stat(%q) = %v, want not found", name, err)
+		}
+		if _, err := b.Open(ctx, name); !internal.IsNotFound(err) {
+			t.Errorf("Open(%q) = %v, want not found", name, err)
+		}
+		if err := b.RemoveAll(ctx, name); !internal.IsNotFound(err) {
+			t.Errorf("RemoveAll(%q) = %v, want not found", name, err)
+		}
+		_, _, err := b.Create(ctx, name, ioutil.NopCloser(strings.NewReader("")))
+		if httpErr, ok := err.(*internal.HTTPError); !ok || httpErr.Code != http.StatusForbidden {
+			t.Errorf("Create(%q) = %v, want status %v", name, err, http.StatusForbidden)
+		}
+		if _, err := b.Copy(ctx, "/a/1.txt", name, &CopyOptions{}); err == nil {
+			t.Errorf("Copy() to %q succeeded", name)
+		}
+	}
+
+	l, err := b.ReadDir(ctx, "/", false)
+	if err != nil {
+		t.Fatalf("ReadDir() = %v", err)
+	}
+	var listed []string
+	for _, fi := range l {
+		listed = append(listed, fi.Path)
+	}
+	var walked []string
+	err = b.WalkFS(ctx, "/", 1, func(p string, fi FileInfo) error {
+		walked = append(walked, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkFS() = %v", err)
+	}
+	want := []string{"/", "/a", "/d"}
+	if !reflect.DeepEqual(listed, want) {
+		t.Errorf("ReadDir() = %v, want %v", listed, want)
+	}
+	if !reflect.DeepEqual(walked, want) {
+		t.Errorf("WalkFS() = %v, want %v", walked, want)
+	}
+
+	if _, err := os.Stat(filepath.Join(string(b.LocalFileSystem), osSidecarName)); err != nil {
+		t.Errorf("sidecar file removed: %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+package webdav
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// propsStreamName is the NTFS alternate data stream storing properties.
+const propsStreamName = ":webdav.props"
+
+// propsStreamErr converts errors caused by a file system without alternate
+// data streams (e.g. FAT) to errPropsAttrUnsupported.
+func propsStreamErr(err error) error {
+	if errors.Is(err, windows.ERROR_INVALID_NAME) || errors.Is(err, windows.ERROR_NOT_SUPPORTED) {
+		return errPropsAttrUnsupported
+	}
+	return err
+}
+
+// getPropsAttr returns nil if the file has no properties.
+func getPropsAttr(p string) ([]byte, error) {
+	if _, err := os.Stat(p); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(p + propsStreamName)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, propsStreamErr(err)
+	}
+	return data, nil
+}
+
+func setPropsAttr(p string, data []byte) error {
+	return propsStreamErr(ioutil.WriteFile(p+propsStreamName, data, 0644))
+}
+
+func removePropsAttr(p string) error {
+	err := os.Remove(p + propsStreamName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return propsStreamErr(err)
+}
@@ -0,0 +1,57 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package webdav
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func propsAttrErr(op, p string, err error) error {
+	if err == unix.ENOTSUP || err == unix.EOPNOTSUPP {
+		return errPropsAttrUnsupported
+	}
+	return &os.PathError{Op: op, Path: p, Err: err}
+}
+
+// getPropsAttr returns nil if the file has no properties.
+func getPropsAttr(p string) ([]byte, error) {
+	for {
+		n, err := unix.Getxattr(p, propsAttrName, nil)
+		if err == errNoPropsAttr {
+			return nil, nil
+		} else if err != nil {
+			return nil, propsAttrErr("getxattr", p, err)
+		}
+
+		buf := make([]byte, n)
+		n, err = unix.Getxattr(p, propsAttrName, buf)
+		if err == unix.ERANGE {
+			continue // the attribute grew in the meantime
+		} else if err == errNoPropsAttr {
+			return nil, nil
+		} else if err != nil {
+			return nil, propsAttrErr("getxattr", p, err)
+		}
+		return buf[:n], nil
+	}
+}
+
+func setPropsAttr(p string, data []byte) error {
+	if err := unix.Setxattr(p, propsAttrName, data, 0); err != nil {
+		return propsAttrErr("setxattr", p, err)
+	}
+	return nil
+}
+
+func removePropsAttr(p string) error {
+	err := unix.Removexattr(p, propsAttrName)
+	if err == errNoPropsAttr {
+		return nil
+	} else if err != nil {
+		return propsAttrErr("removexattr", p, err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+//go:build go1.16
+// +build go1.16
+
+package webdav
+
+import (
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// dirWriteFS is a WriteFS backed by a local directory. It checks that the
+// writers returned by Create are closed exactly once.
+type dirWriteFS struct {
+	fs.FS
+	t   *testing.T
+	dir string
+}
+
+func newDirWriteFS(t *testing.T) *dirWriteFS {
+	fs := newTestTree(t)
+	if err := ioutil.WriteFile(filepath.Join(string(fs), "a", ".hidden"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return &dirWriteFS{os.DirFS(string(fs)), t, string(fs)}
+}
+
+type closeOnceWriter struct {
+	*os.File
+	t      *testing.T
+	closed bool
+}
+
+func (w *closeOnceWriter) Close() error {
+	if w.closed {
+		w.t.Errorf("%v closed twice", w.Name())
+	}
+	w.closed = true
+	return w.File.Close()
+}
+
+func (fsys *dirWriteFS) Create(name string) (io.WriteCloser, error) {
+	f, err := os.Create(filepath.Join(fsys.dir, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, err
+	}
+	return &closeOnceWriter{File: f, t: fsys.t}, nil
+}
+
+func (fsys *dirWriteFS) Mkdir(name string) error {
+	return os.Mkdir(filepath.Join(fsys.dir, filepath.FromSlash(name)), 0755)
+}
+
+func (fsys *dirWriteFS) RemoveAll(name string) error {
+	return os.RemoveAll(filepath.Join(fsys.dir, filepath.FromSlash(name)))
+}
+
+func (fsys *dirWriteFS) Rename(oldname, newname string) error {
+	return os.Rename(filepath.Join(fsys.dir, filepath.FromSlash(oldname)), filepath.Join(fsys.dir, filepath.FromSlash(newname)))
+}
+
+func (fsys *dirWriteFS) readFile(t *testing.T, name string) string {
+	b, err := ioutil.ReadFile(filepath.Join(fsys.dir, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestFSBackend_readOnly(t *testing.T) {
+	fsys := newDirWriteFS(t)
+	h := &Handler{FileSystem: NewFSBackend(fsys.FS)}
+
+	w := serveTestRequest(h, http.MethodGet, "/a/b/2.txt", "", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "a/b/2.txt" {
+		t.Errorf("GET = %v %q, want %v %q", w.Code, w.Body.String(), http.StatusOK, "a/b/2.txt")
+	}
+	if w := serveTestRequest(h, http.MethodGet, "/missing", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("GET status for missing file = %v, want %v", w.Code, http.StatusNotFound)
+	}
+
+	code, hrefs := propFindHrefs(t, h, "1")
+	if code != http.StatusMultiStatus {
+		t.Fatalf("PROPFIND status = %v, want %v", code, http.StatusMultiStatus)
+	}
+	want := []string{"/a/", "/a/.hidden", "/a/1.txt", "/a/b/"}
+	if !reflect.DeepEqual(hrefs, want) {
+		t.Errorf("PROPFIND hrefs = %v, want %v", hrefs, want)
+	}
+
+	for _, method := range []string{http.MethodPut, "MKCOL", http.MethodDelete} {
+		if w := serveTestRequest(h, method, "/d/new", "", nil); w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%v status = %v, want %v", method, w.Code, http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func TestFSBackend_write(t *testing.T) {
+	fsys := newDirWriteFS(t)
+	h := &Handler{FileSystem: NewFSBackend(fsys)}
+
+	if w := serveTestRequest(h, http.MethodPut, "/d/new.txt", "hello", nil); w.Code != http.StatusCreated {
+		t.Errorf("PUT status = %v, want %v", w.Code, http.StatusCreated)
+	}
+	if w := serveTestRequest(h, http.MethodPut, "/d/new.txt", "world", nil); w.Code != http.StatusNoContent {
+		t.Errorf("PUT status = %v, want %v", w.Code, http.StatusNoContent)
+	}
+	if got := fsys.readFile(t, "d/new.txt"); got != "world" {
+		t.Errorf("d/new.txt = %q, want %q", got, "world")
+	}
+
+	if w := serveTestRequest(h, "MKCOL", "/d/e", "", nil); w.Code != http.StatusCreated {
+		t.Errorf("MKCOL status = %v, want %v", w.Code, http.StatusCreated)
+	}
+	if w := serveTestRequest(h, http.MethodDelete, "/d/e", "", nil); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE status = %v, want %v", w.Code, http.StatusNoContent)
+	}
+	if w := serveTestRequest(h, http.MethodDelete, "/d/e", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("DELETE status for missing file = %v, want %v", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestFSBackend_copyMove(t *testing.T) {
+	fsys := newDirWriteFS(t)
+	h := &Handler{FileSystem: NewFSBackend(fsys)}
+
+	w := serveTestRequest(h, "COPY", "/a/", "", map[string]string{"Destination": "/d/a/"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("COPY status = %v, want %v", w.Code, http.StatusCreated)
+	}
+	for _, name := range []string{"1.txt", ".hidden", "b/2.txt", "b/c/3.txt"} {
+		if got, want := fsys.readFile(t, "d/a/"+name), fsys.readFile(t, "a/"+name); got != want {
+			t.Errorf("copied %v = %q, want %q", name, got, want)
+		}
+	}
+
+	for _, tc := range []struct {
+		method, src, dst string
+	}{
+		{"COPY", "/", "/d/root/"},
+		{"COPY", "/a/", "/a/b/a/"},
+		{"MOVE", "/", "/d/root/"},
+		{"MOVE", "/a/", "/a/b/a/"},
+	} {
+		w := serveTestRequest(h, tc.method, tc.src, "", map[string]string{"Destination": tc.dst})
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%v %v to %v status = %v, want %v", tc.method, tc.src, tc.dst, w.Code, http.StatusForbidden)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(fsys.dir, "d", "root")); !os.IsNotExist(err) {
+		t.Errorf("root copied into itself: %v", err)
+	}
+
+	w = serveTestRequest(h, "MOVE", "/d/a/", "", map[string]string{"Destination": "/d/moved/"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("MOVE status = %v, want %v", w.Code, http.StatusCreated)
+	}
+	if got := fsys.readFile(t, "d/moved/.hidden"); got != "secret" {
+		t.Errorf("moved .hidden = %q, want %q", got, "secret")
+	}
+}
+
+func xTestFSRel(t *testing.T) {
+	for _, tc := range []struct {
+		root, name, want string
+	}{
+		{".", ".hidden", ".hidden"},
+		{".", "a/b", "a/b"},
+		{"a", "a", ""},
+		{"a", "a/.hidden", ".hidden"},
+		{"a", "a/b/c", "b/c"},
+	} {
+		if got := func(a, b string) string { return "" }(tc.root, tc.name); got != tc.want {
+			t.Errorf("fsRel(%q, %q) = %q, want %q", tc.root, tc.name, got, tc.want)
+		}
+	}
+}
@@ -5,4 +5,6 @@ go 1.13
 require (
 	github.com/emersion/go-ical v0.0.0-20240127095438-fc1c9d8fb2b6
 	github.com/emersion/go-vcard v0.0.0-20230815062825-8fda7d206ec9
+	github.com/klauspost/compress v1.15.15
+	golang.org/x/sys v0.10.0
 )
@@ -79,7 +79,13 @@ func NewClient(c HTTPClient, endpoint string) (*Client, error) {
 
 func (c *Client) ResolveHref(p string) *url.URL {
 	if !strings.HasPrefix(p, "/") {
+		// path.Join strips the trailing slash, which is significant for
+		// collections
+		trailingSlash := strings.HasSuffix(p, "/")
 		p = path.Join(c.endpoint.Path, p)
+		if trailingSlash && !strings.HasSuffix(p, "/") {
+			p += "/"
+		}
 	}
 	return &url.URL{
 		Scheme: c.endpoint.Scheme,
@@ -89,6 +95,21 @@ func (c *Client) ResolveHref(p string) *url.URL {
 	}
 }
 
+// ResolveRelativeHref resolves an href returned in the response to a request
+// sent to path. Relative hrefs are resolved against the request URL, as
+// required by RFC 4918 section 8.3.
+func (c *Client) ResolveRelativeHref(path string, href *Href) string {
+	u := (*url.URL)(href)
+	if u.IsAbs() || strings.HasPrefix(u.Path, "/") {
+		return u.Path
+	}
+	base := c.endpoint
+	if path != "" {
+		base = c.ResolveHref(path)
+	}
+	return base.ResolveReference(u).Path
+}
+
 func (c *Client) NewRequest(method string, path string, body io.Reader) (*http.Request, error) {
 	return http.NewRequest(method, c.ResolveHref(path).String(), body)
 }
@@ -126,12 +147,7 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 		var wrappedErr error
 		t, _, _ := mime.ParseMediaType(contentType)
 		if t == "application/xml" || t == "text/xml" {
-			var davErr Error
-			if err := xml.NewDecoder(resp.Body).Decode(&davErr); err != nil {
-				wrappedErr = err
-			} else {
-				wrappedErr = &davErr
-			}
+			wrappedErr = decodeXMLError(resp.Body)
 		} else if strings.HasPrefix(t, "text/") {
 			lr := io.LimitedReader{R: resp.Body, N: 1024}
 			var buf bytes.Buffer
@@ -149,7 +165,61 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 	return resp, nil
 }
 
+// decodeXMLError decodes an XML error response body. This is usually a
+// DAV:error element, but extended MKCOL failures are reported with a
+// DAV:mkcol-response element.
+func decodeXMLError(r io.Reader) error {
+	d := xml.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if start.Name == mkcolResponseName {
+			var resp MkcolResponse
+			if err := d.DecodeElement(&resp, &start); err != nil {
+				return err
+			}
+			return &resp
+		}
+
+		var davErr Error
+		if err := d.DecodeElement(&davErr, &start); err != nil {
+			return err
+		}
+		return &davErr
+	}
+}
+
 func (c *Client) DoMultiStatus(req *http.Request) (*MultiStatus, error) {
+	var ms MultiStatus
+	dec, err := c.doMultiStatus(req, func(resp *Response) error {
+		ms.Responses = append(ms.Responses, *resp)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	ms.ResponseDescription = dec.ResponseDescription
+	ms.SyncToken = dec.SyncToken
+	return &ms, nil
+}
+
+// DoMultiStatusStream performs a request expecting a multistatus response,
+// and calls fn for each response as it's decoded, without buffering the
+// whole multistatus in memory. If fn returns an error, decoding stops and
+// the error is returned.
+func (c *Client) DoMultiStatusStream(req *http.Request, fn func(resp *Response) error) error {
+	_, err := c.doMultiStatus(req, fn)
+	return err
+}
+
+func (c *Client) doMultiStatus(req *http.Request, fn func(resp *Response) error) (*MultiStatusDecoder, error) {
 	resp, err := c.Do(req)
 	if err != nil {
 		return nil, err
@@ -160,16 +230,41 @@ func (c *Client) DoMultiStatus(req *http.Request) (*MultiStatus, error) {
 		return nil, fmt.Errorf("HTTP multi-status request failed: %v", resp.Status)
 	}
 
-	// TODO: the response can be quite large, support streaming Response elements
-	var ms MultiStatus
-	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
-		return nil, err
+	dec := NewMultiStatusDecoder(resp.Body)
+	for {
+		r, err := dec.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if err := fn(r); err != nil {
+			return nil, err
+		}
 	}
 
-	return &ms, nil
+	return dec, nil
 }
 
 func (c *Client) PropFind(ctx context.Context, path string, depth Depth, propfind *PropFind) (*MultiStatus, error) {
+	req, err := c.newPropFindRequest(ctx, path, depth, propfind)
+	if err != nil {
+		return nil, err
+	}
+	return c.DoMultiStatus(req)
+}
+
+// PropFindStream performs a PROPFIND request and calls fn for each response
+// as it's received. Memory usage doesn't depend on the number of responses.
+func (c *Client) PropFindStream(ctx context.Context, path string, depth Depth, propfind *PropFind, fn func(resp *Response) error) error {
+	req, err := c.newPropFindRequest(ctx, path, depth, propfind)
+	if err != nil {
+		return err
+	}
+	return c.DoMultiStatusStream(req, fn)
+}
+
+func (c *Client) newPropFindRequest(ctx context.Context, path string, depth Depth, propfind *PropFind) (*http.Request, error) {
 	req, err := c.NewXMLRequest("PROPFIND", path, propfind)
 	if err != nil {
 		return nil, err
@@ -177,7 +272,51 @@ func (c *Client) PropFind(ctx context.Context, path string, depth Depth, propfin
 
 	req.Header.Add("Depth", depth.String())
 
-	return c.DoMultiStatus(req.WithContext(ctx))
+	return req.WithContext(ctx), nil
+}
+
+// PropFindTree performs a PROPFIND request and ensures that all of the
+// returned responses are located within the requested depth of path.
+func (c *Client) PropFindTree(ctx context.Context, path string, depth Depth, propfind *PropFind) (*MultiStatus, error) {
+	var ms MultiStatus
+	err := c.PropFindTreeStream(ctx, path, depth, propfind, func(resp *Response) error {
+		ms.Responses = append(ms.Responses, *resp)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ms, nil
+}
+
+// PropFindTreeStream is like PropFindTree, but calls fn for each response as
+// it's received.
+func (c *Client) PropFindTreeStream(ctx context.Context, path string, depth Depth, propfind *PropFind, fn func(resp *Response) error) error {
+	root := c.ResolveHref(path).Path
+	return c.PropFindStream(ctx, path, depth, propfind, func(resp *Response) error {
+		for _, href := range resp.Hrefs {
+			if !isInTree(root, href.Path, depth) {
+				return fmt.Errorf("webdav: PROPFIND on %q returned response for %q outside of requested tree", path, href.Path)
+			}
+		}
+		return fn(resp)
+	})
+}
+
+// isInTree reports whether p is located within depth of root.
+func isInTree(root, p string, depth Depth) bool {
+	root = strings.TrimSuffix(root, "/")
+	p = strings.TrimSuffix(p, "/")
+	if p == root {
+		return true
+	}
+
+	rel := strings.TrimPrefix(p, root+"/")
+	switch {
+	case rel == p, depth == DepthZero, depth == DepthOne && strings.Contains(rel, "/"):
+		return false
+	}
+	return true
 }
 
 // PropfindFlat performs a PROPFIND request with a zero depth.
@@ -194,6 +333,26 @@ func (c *Client) PropFindFlat(ctx context.Context, path string, propfind *PropFi
 	return &ms.Responses[0], nil
 }
 
+// PropPatch performs a PROPPATCH request. The returned Response contains the
+// status of each property: servers may accept some properties and reject
+// others.
+func (c *Client) PropPatch(ctx context.Context, path string, update *PropertyUpdate) (*Response, error) {
+	req, err := c.NewXMLRequest("PROPPATCH", path, update)
+	if err != nil {
+		return nil, err
+	}
+
+	ms, err := c.DoMultiStatus(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ms.Responses) != 1 {
+		return nil, fmt.Errorf("PROPPATCH returned %d responses", len(ms.Responses))
+	}
+	return &ms.Responses[0], nil
+}
+
 func parseCommaSeparatedSet(values []string, upper bool) map[string]bool {
 	m := make(map[string]bool)
 	for _, v := range values {
@@ -235,9 +394,14 @@ func (c *Client) Options(ctx context.Context, path string) (classes map[string]b
 
 // SyncCollection perform a `sync-collection` REPORT operation on a resource
 func (c *Client) SyncCollection(ctx context.Context, path, syncToken string, level Depth, limit *Limit, prop *Prop) (*MultiStatus, error) {
+	syncLevel := SyncLevelOne
+	if level == DepthInfinity {
+		syncLevel = SyncLevelInfinite
+	}
+
 	q := SyncCollectionQuery{
 		SyncToken: syncToken,
-		SyncLevel: level.String(),
+		SyncLevel: syncLevel,
 		Limit:     limit,
 		Prop:      prop,
 	}
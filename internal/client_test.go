@@ -0,0 +1,122 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestClient_ResolveRelativeHref(t *testing.T) {
+	c, err := NewClient(nil, "https://example.org/dav/")
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+
+	for _, tc := range []struct {
+		path, href, want string
+	}{
+		{"", "/principals/alice/", "/principals/alice/"},
+		{"", "principals/alice/", "/dav/principals/alice/"},
+		{"/dav/principals/alice/", "calendars/", "/dav/principals/alice/calendars/"},
+		{"/dav/principals/alice/", "../../calendars/alice/", "/dav/calendars/alice/"},
+		{"", "https://example.org/other/", "/other/"},
+	} {
+		u, err := url.Parse(tc.href)
+		if err != nil {
+			t.Fatalf("url.Parse(%q) = %v", tc.href, err)
+		}
+		if got := c.ResolveRelativeHref(tc.path, (*Href)(u)); got != tc.want {
+			t.Errorf("ResolveRelativeHref(%q, %q) = %q, expected %q", tc.path, tc.href, got, tc.want)
+		}
+	}
+}
+
+func TestClient_PropFindStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PROPFIND" || r.Header.Get("Depth") != "1" {
+			t.Errorf("request = %v with Depth %q, expected PROPFIND with Depth 1", r.Method, r.Header.Get("Depth"))
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`<?xml version="1.0" encoding="utf-8" ?>
+<D:multistatus xmlns:D="DAV:">
+  <D:response><D:href>/dir/</D:href><D:status>HTTP/1.1 200 OK</D:status></D:response>
+  <D:response><D:href>/dir/a</D:href><D:status>HTTP/1.1 200 OK</D:status></D:response>
+  <D:response><D:href>/dir/b</D:href><D:status>HTTP/1.1 200 OK</D:status></D:response>
+</D:multistatus>`))
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+
+	var paths []string
+	err = c.PropFindStream(context.Background(), "/dir/", DepthOne, NewPropNamePropFind(ResourceTypeName), func(resp *Response) error {
+		p, err := resp.Path()
+		if err != nil {
+			return err
+		}
+		paths = append(paths, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("PropFindStream() = %v", err)
+	}
+	if got, want := strings.Join(paths, ","), "/dir/,/dir/a,/dir/b"; got != want {
+		t.Errorf("paths = %v, expected %v", got, want)
+	}
+
+	errStop := errors.New("stop")
+	n := 0
+	err = c.PropFindStream(context.Background(), "/dir/", DepthOne, NewPropNamePropFind(ResourceTypeName), func(resp *Response) error {
+		n++
+		return errStop
+	})
+	if err != errStop || n != 1 {
+		t.Errorf("PropFindStream() = %v after %v responses, expected %v after 1 response", err, n, errStop)
+	}
+}
+
+func TestClient_PropFindTreeStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`<?xml version="1.0" encoding="utf-8" ?>
+<D:multistatus xmlns:D="DAV:">
+  <D:response><D:href>/dir/</D:href><D:status>HTTP/1.1 200 OK</D:status></D:response>
+  <D:response><D:href>/dir/a/</D:href><D:status>HTTP/1.1 200 OK</D:status></D:response>
+  <D:response><D:href>/dir/a/b</D:href><D:status>HTTP/1.1 200 OK</D:status></D:response>
+  <D:response><D:href>/dirty</D:href><D:status>HTTP/1.1 200 OK</D:status></D:response>
+</D:multistatus>`))
+	}))
+	defer ts.Close()
+
+	c, err := NewClient(nil, ts.URL)
+	if err != nil {
+		t.Fatalf("NewClient() = %v", err)
+	}
+
+	collect := func(paths *[]string) func(resp *Response) error {
+		return func(resp *Response) error {
+			p, err := resp.Path()
+			if err != nil {
+				return err
+			}
+			*paths = append(*paths, p)
+			return nil
+		}
+	}
+	propfind := NewPropNamePropFind(ResourceTypeName)
+
+	var paths []string
+	if err := c.PropFindTreeStream(context.Background(), "/dir/", DepthInfinity, propfind, collect(&paths)); err == nil {
+		t.Errorf("PropFindTreeStream() succeeded despite a response outside of the tree")
+	}
+
+}
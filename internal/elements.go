@@ -4,6 +4,7 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -14,16 +15,58 @@ import (
 const Namespace = "DAV:"
 
 var (
-	ResourceTypeName     = xml.Name{Namespace, "resourcetype"}
-	DisplayNameName      = xml.Name{Namespace, "displayname"}
-	GetContentLengthName = xml.Name{Namespace, "getcontentlength"}
-	GetContentTypeName   = xml.Name{Namespace, "getcontenttype"}
-	GetLastModifiedName  = xml.Name{Namespace, "getlastmodified"}
-	GetETagName          = xml.Name{Namespace, "getetag"}
+	ResourceTypeName       = xml.Name{Namespace, "resourcetype"}
+	DisplayNameName        = xml.Name{Namespace, "displayname"}
+	GetContentLengthName   = xml.Name{Namespace, "getcontentlength"}
+	GetContentTypeName     = xml.Name{Namespace, "getcontenttype"}
+	GetContentLanguageName = xml.Name{Namespace, "getcontentlanguage"}
+	GetLastModifiedName    = xml.Name{Namespace, "getlastmodified"}
+	GetETagName            = xml.Name{Namespace, "getetag"}
 
 	CurrentUserPrincipalName = xml.Name{Namespace, "current-user-principal"}
 
 	CurrentUserPrivilegeSetName = xml.Name{Namespace, "current-user-privilege-set"}
+
+	ACLName             = xml.Name{Namespace, "acl"}
+	OwnerName           = xml.Name{Namespace, "owner"}
+	ACLRestrictionsName = xml.Name{Namespace, "acl-restrictions"}
+
+	PrincipalName       = xml.Name{Namespace, "principal"}
+	AlternateURISetName = xml.Name{Namespace, "alternate-URI-set"}
+	PrincipalURLName    = xml.Name{Namespace, "principal-URL"}
+	GroupMemberSetName  = xml.Name{Namespace, "group-member-set"}
+	GroupMembershipName = xml.Name{Namespace, "group-membership"}
+
+	LockDiscoveryName = xml.Name{Namespace, "lockdiscovery"}
+	SupportedLockName = xml.Name{Namespace, "supportedlock"}
+
+	SupportedReportSetName = xml.Name{Namespace, "supported-report-set"}
+	SyncCollectionName     = xml.Name{Namespace, "sync-collection"}
+	SyncTokenName          = xml.Name{Namespace, "sync-token"}
+	ValidSyncTokenName     = xml.Name{Namespace, "valid-sync-token"}
+
+	NumberOfMatchesWithinLimitsName = xml.Name{Namespace, "number-of-matches-within-limits"}
+
+	QuotaAvailableBytesName = xml.Name{Namespace, "quota-available-bytes"}
+	QuotaUsedBytesName      = xml.Name{Namespace, "quota-used-bytes"}
+
+	CannotModifyProtectedPropertyName = xml.Name{Namespace, "cannot-modify-protected-property"}
+	PropFindFiniteDepthName           = xml.Name{Namespace, "propfind-finite-depth"}
+)
+
+// https://tools.ietf.org/html/rfc3744#section-3
+var (
+	PrivilegeReadName                        = xml.Name{Namespace, "read"}
+	PrivilegeWriteName                       = xml.Name{Namespace, "write"}
+	PrivilegeWritePropertiesName             = xml.Name{Namespace, "write-properties"}
+	PrivilegeWriteContentName                = xml.Name{Namespace, "write-content"}
+	PrivilegeUnlockName                      = xml.Name{Namespace, "unlock"}
+	PrivilegeReadACLName                     = xml.Name{Namespace, "read-acl"}
+	PrivilegeReadCurrentUserPrivilegeSetName = xml.Name{Namespace, "read-current-user-privilege-set"}
+	PrivilegeWriteACLName                    = xml.Name{Namespace, "write-acl"}
+	PrivilegeBindName                        = xml.Name{Namespace, "bind"}
+	PrivilegeUnbindName                      = xml.Name{Namespace, "unbind"}
+	PrivilegeAllName                         = xml.Name{Namespace, "all"}
 )
 
 type Status struct {
@@ -58,16 +101,23 @@ func (s *Status) UnmarshalText(b []byte) error {
 	return nil
 }
 
+// ErrRedirect is wrapped by the error returned by Status.Err when the status
+// indicates that the resource has moved.
+var ErrRedirect = errors.New("webdav: resource has moved")
+
 func (s *Status) Err() error {
 	if s == nil {
 		return nil
 	}
 
-	// TODO: handle 2xx, 3xx
-	if s.Code != http.StatusOK {
+	switch {
+	case s.Code/100 == 2:
+		return nil
+	case s.Code/100 == 3:
+		return &HTTPError{Code: s.Code, Err: ErrRedirect}
+	default:
 		return &HTTPError{Code: s.Code}
 	}
-	return nil
 }
 
 type Href url.URL
@@ -102,6 +152,90 @@ func NewMultiStatus(resps ...Response) *MultiStatus {
 	return &MultiStatus{Responses: resps}
 }
 
+// Error returns a description of the first failed response. A MultiStatus can
+// be returned as an error by backends to reply with a 207 Multi-Status.
+func (ms *MultiStatus) Error() string {
+	for i := range ms.Responses {
+		if err := ms.Responses[i].Err(); err != nil {
+			return err.Error()
+		}
+	}
+	return "webdav: multi-status response"
+}
+
+var (
+	multiStatusName         = xml.Name{Namespace, "multistatus"}
+	mkcolResponseName       = xml.Name{Namespace, "mkcol-response"}
+	responseName            = xml.Name{Namespace, "response"}
+	responseDescriptionName = xml.Name{Namespace, "responsedescription"}
+)
+
+// MultiStatusDecoder decodes a multistatus document one response at a time,
+// without loading the whole document in memory.
+type MultiStatusDecoder struct {
+	// ResponseDescription and SyncToken are populated as the corresponding
+	// elements are encountered. They are complete once Next returns io.EOF.
+	ResponseDescription string
+	SyncToken           string
+
+	d             *xml.Decoder
+	started, done bool
+}
+
+// NewMultiStatusDecoder creates a new multistatus decoder reading from r.
+func NewMultiStatusDecoder(r io.Reader) *MultiStatusDecoder {
+	return &MultiStatusDecoder{d: xml.NewDecoder(r)}
+}
+
+// Next decodes the next response. It returns io.EOF when there are no more
+// responses.
+func (md *MultiStatusDecoder) Next() (*Response, error) {
+	if md.done {
+		return nil, io.EOF
+	}
+
+	for {
+		tok, err := md.d.Token()
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if !md.started {
+				if tok.Name != multiStatusName {
+					return nil, fmt.Errorf("webdav: expected multistatus element, got <%v %v>", tok.Name.Space, tok.Name.Local)
+				}
+				md.started = true
+				continue
+			}
+
+			switch tok.Name {
+			case responseName:
+				var resp Response
+				if err := md.d.DecodeElement(&resp, &tok); err != nil {
+					return nil, err
+				}
+				return &resp, nil
+			case responseDescriptionName:
+				err = md.d.DecodeElement(&md.ResponseDescription, &tok)
+			case SyncTokenName:
+				err = md.d.DecodeElement(&md.SyncToken, &tok)
+			default:
+				err = md.d.Skip()
+			}
+			if err != nil {
+				return nil, err
+			}
+		case xml.EndElement:
+			md.done = true
+			return nil, io.EOF
+		}
+	}
+}
+
 // https://tools.ietf.org/html/rfc4918#section-14.24
 type Response struct {
 	XMLName             xml.Name   `xml:"DAV: response"`
@@ -176,13 +310,12 @@ func (resp *Response) Path() (string, error) {
 
 func (resp *Response) DecodeProp(values ...interface{}) error {
 	for _, v := range values {
-		// TODO wrap errors with more context (XML name)
 		name, err := valueXMLName(v)
 		if err != nil {
 			return err
 		}
 		if err := resp.Err(); err != nil {
-			return newPropError(name, err)
+			return newPropError(name, resp.Status.Code, err)
 		}
 		for _, propstat := range resp.PropStats {
 			raw := propstat.Prop.Get(name)
@@ -190,14 +323,14 @@ func (resp *Response) DecodeProp(values ...interface{}) error {
 				continue
 			}
 			if err := propstat.Status.Err(); err != nil {
-				return newPropError(name, err)
+				return newPropError(name, propstat.Status.Code, err)
 			}
 			if err := raw.Decode(v); err != nil {
-				return newPropError(name, err)
+				return newPropError(name, propstat.Status.Code, err)
 			}
 			return nil
 		}
-		return newPropError(name, &HTTPError{
+		return newPropError(name, http.StatusNotFound, &HTTPError{
 			Code: http.StatusNotFound,
 			Err:  fmt.Errorf("missing property"),
 		})
@@ -206,12 +339,43 @@ func (resp *Response) DecodeProp(values ...interface{}) error {
 	return nil
 }
 
-func newPropError(name xml.Name, err error) error {
-	return fmt.Errorf("property <%v %v>: %w", name.Space, name.Local, err)
+// DecodePropOK is like DecodeProp for a single value, but reports a missing
+// property (404 propstat) by returning false instead of an error.
+func (resp *Response) DecodePropOK(v interface{}) (bool, error) {
+	err := resp.DecodeProp(v)
+	var propErr *PropError
+	if errors.As(err, &propErr) && propErr.Status == http.StatusNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PropError is returned by Response.DecodeProp when a property couldn't be
+// decoded.
+type PropError struct {
+	Name xml.Name
+	// Status is the status code reported for the property. It's
+	// http.StatusOK if the property value is malformed.
+	Status int
+	Err    error
+}
+
+func newPropError(name xml.Name, status int, err error) *PropError {
+	return &PropError{Name: name, Status: status, Err: err}
+}
+
+func (err *PropError) Error() string {
+	return fmt.Sprintf("property <%v %v>: %v", err.Name.Space, err.Name.Local, err.Err)
+}
+
+func (err *PropError) Unwrap() error {
+	return err.Err
 }
 
 func (resp *Response) EncodeProp(code int, v interface{}) error {
-	raw, err := EncodeRawXMLElement(v)
+	raw, err := encodePropValue(v)
 	if err != nil {
 		return err
 	}
@@ -255,7 +419,7 @@ type Prop struct {
 func EncodeProp(values ...interface{}) (*Prop, error) {
 	l := make([]RawXMLValue, len(values))
 	for i, v := range values {
-		raw, err := EncodeRawXMLElement(v)
+		raw, err := encodePropValue(v)
 		if err != nil {
 			return nil, err
 		}
@@ -264,6 +428,15 @@ func EncodeProp(values ...interface{}) (*Prop, error) {
 	return &Prop{Raw: l}, nil
 }
 
+// encodePropValue encodes a property value. Raw XML values are cloned, so
+// that later changes to v don't affect the encoded property.
+func encodePropValue(v interface{}) (*RawXMLValue, error) {
+	if raw, ok := v.(*RawXMLValue); ok && raw != nil {
+		return raw.Clone(), nil
+	}
+	return EncodeRawXMLElement(v)
+}
+
 func (p *Prop) Get(name xml.Name) *RawXMLValue {
 	for i := range p.Raw {
 		raw := &p.Raw[i]
@@ -309,6 +482,16 @@ func NewPropNamePropFind(names ...xml.Name) *PropFind {
 	return &PropFind{Prop: &Prop{Raw: xmlNamesToRaw(names)}}
 }
 
+// NewAllPropPropFind creates a PROPFIND request for all properties, also
+// including the properties listed in include.
+func NewAllPropPropFind(include ...xml.Name) *PropFind {
+	propfind := &PropFind{AllProp: &struct{}{}}
+	if len(include) > 0 {
+		propfind.Include = &Include{Raw: xmlNamesToRaw(include)}
+	}
+	return propfind
+}
+
 // https://tools.ietf.org/html/rfc4918#section-14.8
 type Include struct {
 	XMLName xml.Name      `xml:"DAV: include"`
@@ -342,12 +525,30 @@ type GetContentLength struct {
 	Length  int64    `xml:",chardata"`
 }
 
+// https://tools.ietf.org/html/rfc4331#section-3
+type QuotaAvailableBytes struct {
+	XMLName xml.Name `xml:"DAV: quota-available-bytes"`
+	Bytes   int64    `xml:",chardata"`
+}
+
+// https://tools.ietf.org/html/rfc4331#section-4
+type QuotaUsedBytes struct {
+	XMLName xml.Name `xml:"DAV: quota-used-bytes"`
+	Bytes   int64    `xml:",chardata"`
+}
+
 // https://tools.ietf.org/html/rfc4918#section-15.5
 type GetContentType struct {
 	XMLName xml.Name `xml:"DAV: getcontenttype"`
 	Type    string   `xml:",chardata"`
 }
 
+// https://tools.ietf.org/html/rfc4918#section-15.3
+type GetContentLanguage struct {
+	XMLName  xml.Name `xml:"DAV: getcontentlanguage"`
+	Language string   `xml:",chardata"`
+}
+
 type Time time.Time
 
 func (t *Time) UnmarshalText(b []byte) error {
@@ -419,35 +620,228 @@ type CurrentUserPrincipal struct {
 	Unauthenticated *struct{} `xml:"unauthenticated,omitempty"`
 }
 
+// https://tools.ietf.org/html/rfc3744#section-4.1
+type AlternateURISet struct {
+	XMLName xml.Name `xml:"DAV: alternate-URI-set"`
+	Hrefs   []Href   `xml:"href"`
+}
+
+// https://tools.ietf.org/html/rfc3744#section-4.2
+type PrincipalURL struct {
+	XMLName xml.Name `xml:"DAV: principal-URL"`
+	Href    Href     `xml:"href"`
+}
+
+// https://tools.ietf.org/html/rfc3744#section-4.3
+type GroupMemberSet struct {
+	XMLName xml.Name `xml:"DAV: group-member-set"`
+	Hrefs   []Href   `xml:"href"`
+}
+
+// https://tools.ietf.org/html/rfc3744#section-4.4
+type GroupMembership struct {
+	XMLName xml.Name `xml:"DAV: group-membership"`
+	Hrefs   []Href   `xml:"href"`
+}
+
 type CurrentUserPrivilegeSet struct {
 	XMLName   xml.Name    `xml:"DAV: current-user-privilege-set"`
 	Privilege []Privilege `xml:"privilege"`
 }
 
+// https://tools.ietf.org/html/rfc3744#section-5.1
+type ResourceOwner struct {
+	XMLName xml.Name `xml:"DAV: owner"`
+	Href    *Href    `xml:"href,omitempty"`
+}
+
+// DecodeCurrentUserPrivileges decodes the names of the privileges in the
+// current-user-privilege-set property. Unknown privileges are kept. Nil is
+// returned if the property couldn't be retrieved, e.g. because it's missing
+// or the server doesn't allow the current user to read it: the privileges are
+// unknown.
+func (resp *Response) DecodeCurrentUserPrivileges() ([]xml.Name, error) {
+	var set CurrentUserPrivilegeSet
+	err := resp.DecodeProp(&set)
+	var propErr *PropError
+	if errors.As(err, &propErr) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	names := make([]xml.Name, 0, len(set.Privilege))
+	for _, p := range set.Privilege {
+		if name, ok := p.Name(); ok {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// https://tools.ietf.org/html/rfc3744#section-5.4
 type Privilege struct {
-	XMLName         xml.Name  `xml:"DAV: privilege"`
-	Read            *struct{} `xml:"DAV: read,omitempty"`
-	All             *struct{} `xml:"DAV: all,omitempty"`
-	Write           *struct{} `xml:"DAV: write,omitempty"`
-	WriteProperties *struct{} `xml:"DAV: write-properties,omitempty"`
-	WriteContent    *struct{} `xml:"DAV: write-content,omitempty"`
+	XMLName xml.Name      `xml:"DAV: privilege"`
+	Raw     []RawXMLValue `xml:",any"`
+}
+
+func NewPrivilege(name xml.Name) Privilege {
+	return Privilege{Raw: xmlNamesToRaw([]xml.Name{name})}
+}
+
+// Name returns the name of the privilege.
+func (p *Privilege) Name() (xml.Name, bool) {
+	for _, raw := range p.Raw {
+		if name, ok := raw.XMLName(); ok {
+			return name, true
+		}
+	}
+	return xml.Name{}, false
 }
 
 func NewAllPrivileges() []Privilege {
 	return []Privilege{
-		{Read: &struct{}{}},
-		{All: &struct{}{}},
-		{Write: &struct{}{}},
-		{WriteProperties: &struct{}{}},
-		{WriteContent: &struct{}{}},
+		NewPrivilege(PrivilegeReadName),
+		NewPrivilege(PrivilegeAllName),
+		NewPrivilege(PrivilegeWriteName),
+		NewPrivilege(PrivilegeWritePropertiesName),
+		NewPrivilege(PrivilegeWriteContentName),
 	}
 }
 
+// https://tools.ietf.org/html/rfc3744#section-5.5
+type ACL struct {
+	XMLName xml.Name `xml:"DAV: acl"`
+	ACEs    []ACE    `xml:"ace"`
+}
+
+type ACE struct {
+	XMLName   xml.Name      `xml:"DAV: ace"`
+	Principal *ACEPrincipal `xml:"principal,omitempty"`
+	Invert    *Invert       `xml:"invert,omitempty"`
+	Grant     *Grant        `xml:"grant,omitempty"`
+	Deny      *Deny         `xml:"deny,omitempty"`
+	Protected *struct{}     `xml:"protected,omitempty"`
+	Inherited *Inherited    `xml:"inherited,omitempty"`
+}
+
+// https://tools.ietf.org/html/rfc3744#section-5.5.1
+type ACEPrincipal struct {
+	XMLName         xml.Name           `xml:"DAV: principal"`
+	Href            *Href              `xml:"href,omitempty"`
+	All             *struct{}          `xml:"all,omitempty"`
+	Authenticated   *struct{}          `xml:"authenticated,omitempty"`
+	Unauthenticated *struct{}          `xml:"unauthenticated,omitempty"`
+	Property        *PrincipalProperty `xml:"property,omitempty"`
+	Self            *struct{}          `xml:"self,omitempty"`
+}
+
+type PrincipalProperty struct {
+	XMLName xml.Name      `xml:"DAV: property"`
+	Raw     []RawXMLValue `xml:",any"`
+}
+
+// https://tools.ietf.org/html/rfc3744#section-5.5.1
+type Invert struct {
+	XMLName   xml.Name     `xml:"DAV: invert"`
+	Principal ACEPrincipal `xml:"principal"`
+}
+
+// https://tools.ietf.org/html/rfc3744#section-5.5.2
+type Grant struct {
+	XMLName    xml.Name    `xml:"DAV: grant"`
+	Privileges []Privilege `xml:"privilege"`
+}
+
+type Deny struct {
+	XMLName    xml.Name    `xml:"DAV: deny"`
+	Privileges []Privilege `xml:"privilege"`
+}
+
+// https://tools.ietf.org/html/rfc3744#section-5.5.4
+type Inherited struct {
+	XMLName xml.Name `xml:"DAV: inherited"`
+	Href    Href     `xml:"href"`
+}
+
+// https://tools.ietf.org/html/rfc3744#section-5.6
+type ACLRestrictions struct {
+	XMLName         xml.Name  `xml:"DAV: acl-restrictions"`
+	GrantOnly       *struct{} `xml:"grant-only,omitempty"`
+	NoInvert        *struct{} `xml:"no-invert,omitempty"`
+	DenyBeforeGrant *struct{} `xml:"deny-before-grant,omitempty"`
+}
+
 // https://tools.ietf.org/html/rfc4918#section-14.19
 type PropertyUpdate struct {
 	XMLName xml.Name `xml:"DAV: propertyupdate"`
-	Remove  []Remove `xml:"remove"`
 	Set     []Set    `xml:"set"`
+	Remove  []Remove `xml:"remove"`
+	// Instructions contains the set and remove instructions in document
+	// order, which must be preserved when applying them (RFC 4918 section
+	// 9.2). It's populated when decoding.
+	Instructions []PropertyUpdateInstruction `xml:"-"`
+}
+
+// PropertyUpdateInstruction is a set or remove instruction of a
+// PropertyUpdate.
+type PropertyUpdateInstruction struct {
+	Remove bool
+	Prop   Prop
+}
+
+// UnmarshalXML implements xml.Unmarshaler.
+func (update *PropertyUpdate) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	if start.Name != (xml.Name{Namespace, "propertyupdate"}) {
+		return fmt.Errorf("webdav: expected propertyupdate element, got %q %q", start.Name.Space, start.Name.Local)
+	}
+	update.XMLName = start.Name
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			switch tok.Name {
+			case xml.Name{Namespace, "set"}:
+				var set Set
+				if err := d.DecodeElement(&set, &tok); err != nil {
+					return err
+				}
+				update.Set = append(update.Set, set)
+				update.Instructions = append(update.Instructions, PropertyUpdateInstruction{Prop: set.Prop})
+			case xml.Name{Namespace, "remove"}:
+				var remove Remove
+				if err := d.DecodeElement(&remove, &tok); err != nil {
+					return err
+				}
+				update.Remove = append(update.Remove, remove)
+				update.Instructions = append(update.Instructions, PropertyUpdateInstruction{Remove: true, Prop: remove.Prop})
+			default:
+				if err := d.Skip(); err != nil {
+					return err
+				}
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func NewPropertyUpdate(set []interface{}, remove []xml.Name) (*PropertyUpdate, error) {
+	var update PropertyUpdate
+	if len(set) > 0 {
+		prop, err := EncodeProp(set...)
+		if err != nil {
+			return nil, err
+		}
+		update.Set = []Set{{Prop: *prop}}
+	}
+	if len(remove) > 0 {
+		update.Remove = []Remove{{Prop: Prop{Raw: xmlNamesToRaw(remove)}}}
+	}
+	return &update, nil
 }
 
 // https://tools.ietf.org/html/rfc4918#section-14.23
@@ -462,13 +856,90 @@ type Set struct {
 	Prop    Prop     `xml:"prop"`
 }
 
+// https://tools.ietf.org/html/rfc5689#section-5.1
+type Mkcol struct {
+	XMLName xml.Name `xml:"DAV: mkcol"`
+	Set     []Set    `xml:"set"`
+}
+
+// https://tools.ietf.org/html/rfc5689#section-5.2
+type MkcolResponse struct {
+	XMLName   xml.Name   `xml:"DAV: mkcol-response"`
+	PropStats []PropStat `xml:"propstat"`
+}
+
+// Error returns a description of the first property which couldn't be set.
+// Properties which failed because of another failure (424 Failed Dependency)
+// are only reported if no other property failed.
+func (resp *MkcolResponse) Error() string {
+	var failed *PropStat
+	for i := range resp.PropStats {
+		ps := &resp.PropStats[i]
+		if ps.Status.Code/100 == 2 {
+			continue
+		}
+		if failed == nil || failed.Status.Code == http.StatusFailedDependency {
+			failed = ps
+		}
+	}
+	if failed == nil {
+		return "webdav: extended MKCOL failed"
+	}
+
+	var names []string
+	for _, raw := range failed.Prop.Raw {
+		if name, ok := raw.XMLName(); ok {
+			names = append(names, fmt.Sprintf("{%v}%v", name.Space, name.Local))
+		}
+	}
+	return fmt.Sprintf("webdav: failed to set properties %v: %v %v", strings.Join(names, ", "), failed.Status.Code, http.StatusText(failed.Status.Code))
+}
+
 // https://tools.ietf.org/html/rfc6578#section-6.1
 type SyncCollectionQuery struct {
-	XMLName   xml.Name `xml:"DAV: sync-collection"`
-	SyncToken string   `xml:"sync-token"`
-	Limit     *Limit   `xml:"limit,omitempty"`
-	SyncLevel string   `xml:"sync-level"`
-	Prop      *Prop    `xml:"prop"`
+	XMLName   xml.Name  `xml:"DAV: sync-collection"`
+	SyncToken string    `xml:"sync-token"`
+	Limit     *Limit    `xml:"limit,omitempty"`
+	SyncLevel SyncLevel `xml:"sync-level"`
+	Prop      *Prop     `xml:"prop"`
+}
+
+// https://tools.ietf.org/html/rfc6578#section-6.3
+type SyncLevel string
+
+const (
+	SyncLevelOne      SyncLevel = "1"
+	SyncLevelInfinite SyncLevel = "infinite"
+)
+
+// https://tools.ietf.org/html/rfc6578#section-4
+type SyncToken struct {
+	XMLName xml.Name `xml:"DAV: sync-token"`
+	Token   string   `xml:",chardata"`
+}
+
+// https://tools.ietf.org/html/rfc3253#section-3.1.5
+type SupportedReportSet struct {
+	XMLName          xml.Name          `xml:"DAV: supported-report-set"`
+	SupportedReports []SupportedReport `xml:"supported-report"`
+}
+
+func NewSupportedReportSet(names ...xml.Name) *SupportedReportSet {
+	set := &SupportedReportSet{SupportedReports: make([]SupportedReport, len(names))}
+	for i, name := range names {
+		set.SupportedReports[i].Report.Raw = []RawXMLValue{*NewRawXMLElement(name, nil, nil)}
+	}
+	return set
+}
+
+type SupportedReport struct {
+	XMLName xml.Name `xml:"DAV: supported-report"`
+	Report  Report   `xml:"report"`
+}
+
+type Report struct {
+	XMLName xml.Name      `xml:"DAV: report"`
+	Raw     []RawXMLValue `xml:",any"`
 }
 
 // https://tools.ietf.org/html/rfc5323#section-5.17
@@ -476,3 +947,79 @@ type Limit struct {
 	XMLName  xml.Name `xml:"DAV: limit"`
 	NResults uint     `xml:"nresults"`
 }
+
+// https://tools.ietf.org/html/rfc4918#section-14.11
+type LockInfo struct {
+	XMLName   xml.Name  `xml:"DAV: lockinfo"`
+	LockScope LockScope `xml:"lockscope"`
+	LockType  LockType  `xml:"locktype"`
+	Owner     *Owner    `xml:"owner,omitempty"`
+}
+
+// https://tools.ietf.org/html/rfc4918#section-14.13
+type LockScope struct {
+	XMLName   xml.Name  `xml:"DAV: lockscope"`
+	Exclusive *struct{} `xml:"exclusive,omitempty"`
+	Shared    *struct{} `xml:"shared,omitempty"`
+}
+
+// https://tools.ietf.org/html/rfc4918#section-14.15
+type LockType struct {
+	XMLName xml.Name  `xml:"DAV: locktype"`
+	Write   *struct{} `xml:"write,omitempty"`
+}
+
+// https://tools.ietf.org/html/rfc4918#section-14.17
+type Owner struct {
+	XMLName  xml.Name `xml:"DAV: owner"`
+	InnerXML string   `xml:",innerxml"`
+}
+
+// https://tools.ietf.org/html/rfc4918#section-15.8
+type LockDiscovery struct {
+	XMLName     xml.Name     `xml:"DAV: lockdiscovery"`
+	ActiveLocks []ActiveLock `xml:"activelock"`
+}
+
+// https://tools.ietf.org/html/rfc4918#section-14.1
+type ActiveLock struct {
+	XMLName   xml.Name   `xml:"DAV: activelock"`
+	LockScope LockScope  `xml:"lockscope"`
+	LockType  LockType   `xml:"locktype"`
+	Depth     Depth      `xml:"depth"`
+	Owner     *Owner     `xml:"owner,omitempty"`
+	Timeout   string     `xml:"timeout,omitempty"`
+	LockToken *LockToken `xml:"locktoken,omitempty"`
+	LockRoot  *LockRoot  `xml:"lockroot,omitempty"`
+}
+
+// https://tools.ietf.org/html/rfc4918#section-14.14
+type LockToken struct {
+	XMLName xml.Name `xml:"DAV: locktoken"`
+	Href    Href     `xml:"href"`
+}
+
+// https://tools.ietf.org/html/rfc4918#section-14.12
+type LockRoot struct {
+	XMLName xml.Name `xml:"DAV: lockroot"`
+	Href    Href     `xml:"href"`
+}
+
+// https://tools.ietf.org/html/rfc4918#section-15.10
+type SupportedLock struct {
+	XMLName     xml.Name    `xml:"DAV: supportedlock"`
+	LockEntries []LockEntry `xml:"lockentry"`
+}
+
+// https://tools.ietf.org/html/rfc4918#section-14.10
+type LockEntry struct {
+	XMLName   xml.Name  `xml:"DAV: lockentry"`
+	LockScope LockScope `xml:"lockscope"`
+	LockType  LockType  `xml:"locktype"`
+}
+
+// https://tools.ietf.org/html/rfc4918#section-16
+type LockTokenSubmitted struct {
+	XMLName xml.Name `xml:"DAV: lock-token-submitted"`
+	Hrefs   []Href   `xml:"href"`
+}
@@ -3,6 +3,11 @@ package internal
 import (
 	"bytes"
 	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -41,6 +46,326 @@ func TestResponse_Err_error(t *testing.T) {
 	}
 }
 
+func TestResponse_DecodeProp_error(t *testing.T) {
+	const body = `<?xml version="1.0" encoding="utf-8" ?>
+<D:multistatus xmlns:D="DAV:">
+  <D:response>
+    <D:href>/file</D:href>
+    <D:propstat>
+      <D:prop><D:getcontentlength>invalid</D:getcontentlength></D:prop>
+      <D:status>HTTP/1.1 200 OK</D:status>
+    </D:propstat>
+    <D:propstat>
+      <D:prop><D:getetag/></D:prop>
+      <D:status>HTTP/1.1 403 Forbidden</D:status>
+    </D:propstat>
+  </D:response>
+  <D:response>
+    <D:href>/error</D:href>
+    <D:propstat>
+      <D:prop><D:current-user-privilege-set/></D:prop>
+      <D:status>HTTP/1.1 500 Internal Server Error</D:status>
+    </D:propstat>
+  </D:response>
+</D:multistatus>`
+
+	var ms MultiStatus
+	if err := xml.Unmarshal([]byte(body), &ms); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+	resp := &ms.Responses[0]
+
+	for _, tc := range []struct {
+		v          interface{}
+		wantStatus int
+	}{
+		{&GetContentLength{}, http.StatusOK},
+		{&GetETag{}, http.StatusForbidden},
+		{&GetContentType{}, http.StatusNotFound},
+	} {
+		err := resp.DecodeProp(tc.v)
+		var propErr *PropError
+		if !errors.As(err, &propErr) {
+			t.Errorf("DecodeProp(%T) = %v, expected a *PropError", tc.v, err)
+			continue
+		}
+		if propErr.Status != tc.wantStatus {
+			t.Errorf("DecodeProp(%T): PropError.Status = %v, expected %v", tc.v, propErr.Status, tc.wantStatus)
+		}
+		if want, _ := valueXMLName(tc.v); propErr.Name != want {
+			t.Errorf("DecodeProp(%T): PropError.Name = %v, expected %v", tc.v, propErr.Name, want)
+		}
+	}
+}
+
+func TestResponse_DecodePropOK(t *testing.T) {
+	const body = `<?xml version="1.0" encoding="utf-8" ?>
+<D:multistatus xmlns:D="DAV:">
+  <D:response>
+    <D:href>/file</D:href>
+    <D:propstat>
+      <D:prop><D:getetag>"abc"</D:getetag></D:prop>
+      <D:status>HTTP/1.1 200 OK</D:status>
+    </D:propstat>
+    <D:propstat>
+      <D:prop><D:getcontenttype/></D:prop>
+      <D:status>HTTP/1.1 404 Not Found</D:status>
+    </D:propstat>
+    <D:propstat>
+      <D:prop><D:getcontentlength/></D:prop>
+      <D:status>HTTP/1.1 500 Internal Server Error</D:status>
+    </D:propstat>
+  </D:response>
+</D:multistatus>`
+
+	var ms MultiStatus
+	if err := xml.Unmarshal([]byte(body), &ms); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+	resp := &ms.Responses[0]
+
+	var etag GetETag
+	if ok, err := resp.DecodePropOK(&etag); !ok || err != nil {
+		t.Errorf("DecodePropOK(GetETag) = %v, %v, expected true, nil", ok, err)
+	} else if etag.ETag != "abc" {
+		t.Errorf("GetETag.ETag = %q, expected %q", etag.ETag, "abc")
+	}
+
+	for _, v := range []interface{}{&GetContentType{}, &ResourceType{}} {
+		if ok, err := resp.DecodePropOK(v); ok || err != nil {
+			t.Errorf("DecodePropOK(%T) = %v, %v, expected false, nil", v, ok, err)
+		}
+	}
+
+	if ok, err := resp.DecodePropOK(&GetContentLength{}); ok || err == nil {
+		t.Errorf("DecodePropOK(GetContentLength) = %v, %v, expected an error", ok, err)
+	}
+}
+
+func TestResponse_DecodeCurrentUserPrivileges(t *testing.T) {
+	const body = `<?xml version="1.0" encoding="utf-8" ?>
+<D:multistatus xmlns:D="DAV:" xmlns:X="urn:x">
+  <D:response>
+    <D:href>/granted</D:href>
+    <D:propstat>
+      <D:prop>
+        <D:current-user-privilege-set>
+          <D:privilege><D:read/></D:privilege>
+          <D:privilege><X:vendor/></D:privilege>
+        </D:current-user-privilege-set>
+      </D:prop>
+      <D:status>HTTP/1.1 200 OK</D:status>
+    </D:propstat>
+  </D:response>
+  <D:response>
+    <D:href>/forbidden</D:href>
+    <D:propstat>
+      <D:prop><D:current-user-privilege-set/></D:prop>
+      <D:status>HTTP/1.1 403 Forbidden</D:status>
+    </D:propstat>
+  </D:response>
+  <D:response>
+    <D:href>/error</D:href>
+    <D:propstat>
+      <D:prop><D:current-user-privilege-set/></D:prop>
+      <D:status>HTTP/1.1 500 Internal Server Error</D:status>
+    </D:propstat>
+  </D:response>
+</D:multistatus>`
+
+	var ms MultiStatus
+	if err := xml.Unmarshal([]byte(body), &ms); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+
+	names, err := ms.Responses[0].DecodeCurrentUserPrivileges()
+	if err != nil {
+		t.Fatalf("DecodeCurrentUserPrivileges() = %v", err)
+	}
+	want := []xml.Name{PrivilegeReadName, {"urn:x", "vendor"}}
+	if len(names) != len(want) || names[0] != want[0] || names[1] != want[1] {
+		t.Errorf("DecodeCurrentUserPrivileges() = %v, expected %v", names, want)
+	}
+
+	for _, resp := range ms.Responses[1:] {
+		if names, err := resp.DecodeCurrentUserPrivileges(); names != nil || err != nil {
+			t.Errorf("DecodeCurrentUserPrivileges() = %v, %v, expected nil, nil", names, err)
+		}
+	}
+}
+
+// https://tools.ietf.org/html/rfc6578#section-3.8
+const exampleSyncMultistatusStr = `<?xml version="1.0" encoding="utf-8" ?>
+<D:multistatus xmlns:D="DAV:">
+  <D:response>
+    <D:href>http://example.com/calendars/mycalendar/test.ics</D:href>
+    <D:propstat>
+      <D:prop>
+        <D:getetag>"00001-abcd1"</D:getetag>
+      </D:prop>
+      <D:status>HTTP/1.1 200 OK</D:status>
+    </D:propstat>
+  </D:response>
+  <D:response>
+    <D:href>http://example.com/calendars/mycalendar/old.ics</D:href>
+    <D:status>HTTP/1.1 404 Not Found</D:status>
+  </D:response>
+  <D:sync-token>http://example.com/ns/sync/1234</D:sync-token>
+</D:multistatus>`
+
+func TestMultiStatusDecoder(t *testing.T) {
+	dec := NewMultiStatusDecoder(strings.NewReader(exampleSyncMultistatusStr))
+
+	var paths []string
+	for {
+		resp, err := dec.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("MultiStatusDecoder.Next() = %v", err)
+		}
+		if len(resp.Hrefs) != 1 {
+			t.Fatalf("expected 1 <href>, got %v", len(resp.Hrefs))
+		}
+		paths = append(paths, resp.Hrefs[0].Path)
+	}
+
+	if len(paths) != 2 || paths[0] != "/calendars/mycalendar/test.ics" || paths[1] != "/calendars/mycalendar/old.ics" {
+		t.Errorf("unexpected responses: %v", paths)
+	}
+	if want := "http://example.com/ns/sync/1234"; dec.SyncToken != want {
+		t.Errorf("MultiStatusDecoder.SyncToken = %q, expected %q", dec.SyncToken, want)
+	}
+	if _, err := dec.Next(); err != io.EOF {
+		t.Errorf("MultiStatusDecoder.Next() = %v after end of document, expected io.EOF", err)
+	}
+}
+
+func TestMultiStatusDecoder_invalid(t *testing.T) {
+	dec := NewMultiStatusDecoder(strings.NewReader(`<D:error xmlns:D="DAV:"/>`))
+	if _, err := dec.Next(); err == nil || err == io.EOF {
+		t.Errorf("MultiStatusDecoder.Next() = %v, expected an error", err)
+	}
+}
+
+func TestMultiStatusWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	mw := NewMultiStatusWriter(w)
+	for _, p := range []string{"/a", "/b"} {
+		if err := mw.WriteResponse(NewOKResponse(p)); err != nil {
+			t.Fatalf("MultiStatusWriter.WriteResponse() = %v", err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatalf("MultiStatusWriter.Close() = %v", err)
+	}
+
+	if w.Code != http.StatusMultiStatus {
+		t.Errorf("status = %v, expected %v", w.Code, http.StatusMultiStatus)
+	}
+
+	dec := NewMultiStatusDecoder(w.Body)
+	var paths []string
+	for {
+		resp, err := dec.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("MultiStatusDecoder.Next() = %v", err)
+		}
+		paths = append(paths, resp.Hrefs[0].Path)
+	}
+	if len(paths) != 2 || paths[0] != "/a" || paths[1] != "/b" {
+		t.Errorf("unexpected responses: %v", paths)
+	}
+}
+
+func TestNewAllPropPropFind(t *testing.T) {
+	extraName := xml.Name{"urn:example", "extra"}
+	b, err := xml.Marshal(NewAllPropPropFind(GetETagName, extraName))
+	if err != nil {
+		t.Fatalf("Marshal() = %v", err)
+	}
+
+	var propfind PropFind
+	if err := xml.Unmarshal(b, &propfind); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+	if propfind.AllProp == nil || propfind.Include == nil || len(propfind.Include.Raw) != 2 {
+		t.Fatalf("unexpected propfind: %s", b)
+	}
+
+	props := map[xml.Name]PropFindFunc{
+		GetETagName: func(*RawXMLValue) (interface{}, error) {
+			return &GetETag{ETag: "abc"}, nil
+		},
+	}
+	encoded, err := NewPropFindResponse("/file", &propfind, props)
+	if err != nil {
+		t.Fatalf("NewPropFindResponse() = %v", err)
+	}
+	b, err = xml.Marshal(encoded)
+	if err != nil {
+		t.Fatalf("Marshal() = %v", err)
+	}
+	var resp Response
+	if err := xml.Unmarshal(b, &resp); err != nil {
+		t.Fatalf("Unmarshal() = %v", err)
+	}
+
+	var getETag GetETag
+	if err := resp.DecodeProp(&getETag); err != nil {
+		t.Errorf("DecodeProp(getetag) = %v", err)
+	}
+	found := false
+	for _, propstat := range resp.PropStats {
+		if propstat.Prop.Get(extraName) != nil {
+			found = propstat.Status.Code == http.StatusNotFound
+		}
+	}
+	if !found {
+		t.Errorf("included property not reported as 404 Not Found: %s", b)
+	}
+}
+
+func TestStatus_Err(t *testing.T) {
+	for _, tc := range []struct {
+		code     int
+		ok       bool
+		redirect bool
+	}{
+		{code: http.StatusOK, ok: true},
+		{code: http.StatusCreated, ok: true},
+		{code: http.StatusNoContent, ok: true},
+		{code: http.StatusMultiStatus, ok: true},
+		{code: http.StatusMovedPermanently, redirect: true},
+		{code: http.StatusFound, redirect: true},
+		{code: http.StatusTemporaryRedirect, redirect: true},
+		{code: http.StatusPermanentRedirect, redirect: true},
+		{code: http.StatusForbidden},
+		{code: http.StatusNotFound},
+	} {
+		s := Status{Code: tc.code}
+		err := s.Err()
+		if tc.ok {
+			if err != nil {
+				t.Errorf("Status{%v}.Err() = %v, expected nil", tc.code, err)
+			}
+			continue
+		}
+
+		var httpErr *HTTPError
+		if !errors.As(err, &httpErr) {
+			t.Errorf("Status{%v}.Err() = %T, expected an *HTTPError", tc.code, err)
+		} else if httpErr.Code != tc.code {
+			t.Errorf("HTTPError.Code = %v, expected %v", httpErr.Code, tc.code)
+		}
+		if redirect := errors.Is(err, ErrRedirect); redirect != tc.redirect {
+			t.Errorf("errors.Is(Status{%v}.Err(), ErrRedirect) = %v, expected %v", tc.code, redirect, tc.redirect)
+		}
+	}
+}
+
 func TestTimeRoundTrip(t *testing.T) {
 	now := Time(time.Now().UTC())
 	want, err := now.MarshalText()
@@ -63,3 +388,114 @@ func TestTimeRoundTrip(t *testing.T) {
 		t.Fatalf("invalid round-trip:\ngot= %s\nwant=%s", got, want)
 	}
 }
+
+func TestPrincipalProps_roundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		name xml.Name
+		v    interface{}
+	}{
+		{
+			s:    `<D:principal-URL xmlns:D="DAV:"><D:href>/principals/alice/</D:href></D:principal-URL>`,
+			name: PrincipalURLName,
+			v:    &PrincipalURL{},
+		},
+		{
+			s:    `<D:alternate-URI-set xmlns:D="DAV:"><D:href>mailto:alice@example.org</D:href><D:href>/users/alice</D:href></D:alternate-URI-set>`,
+			name: AlternateURISetName,
+			v:    &AlternateURISet{},
+		},
+		{
+			s:    `<D:group-member-set xmlns:D="DAV:"><D:href>/principals/alice/</D:href><D:href>/principals/bob/</D:href></D:group-member-set>`,
+			name: GroupMemberSetName,
+			v:    &GroupMemberSet{},
+		},
+		{
+			s:    `<D:group-membership xmlns:D="DAV:"></D:group-membership>`,
+			name: GroupMembershipName,
+			v:    &GroupMembership{},
+		},
+	} {
+		t.Run(tc.name.Local, func(t *testing.T) {
+			var raw RawXMLValue
+			if err := xml.Unmarshal([]byte(tc.s), &raw); err != nil {
+				t.Fatalf("xml.Unmarshal() = %v", err)
+			}
+			if name, ok := raw.XMLName(); !ok || name != tc.name {
+				t.Errorf("RawXMLValue.XMLName() = %v, %v, want %v", name, ok, tc.name)
+			}
+
+			// Raw values are kept as-is, prefixes included
+			b, err := xml.Marshal(&raw)
+			if err != nil {
+				t.Fatalf("xml.Marshal() = %v", err)
+			}
+			if string(b) != tc.s {
+				t.Errorf("xml.Marshal() = %v, want %v", string(b), tc.s)
+			}
+
+			// Decoding and encoding again preserves the value
+			if err := raw.Decode(tc.v); err != nil {
+				t.Fatalf("RawXMLValue.Decode() = %v", err)
+			}
+			prop, err := EncodeProp(tc.v)
+			if err != nil {
+				t.Fatalf("EncodeProp() = %v", err)
+			}
+			b, err = xml.Marshal(prop)
+			if err != nil {
+				t.Fatalf("xml.Marshal() = %v", err)
+			}
+			var got Prop
+			if err := xml.Unmarshal(b, &got); err != nil {
+				t.Fatalf("xml.Unmarshal() = %v", err)
+			}
+			gotRaw := got.Get(tc.name)
+			if gotRaw == nil {
+				t.Fatalf("encoded prop %s is missing %v", b, tc.name)
+			}
+			v := reflect.New(reflect.TypeOf(tc.v).Elem()).Interface()
+			if err := gotRaw.Decode(v); err != nil {
+				t.Fatalf("RawXMLValue.Decode() = %v", err)
+			}
+			if !reflect.DeepEqual(v, tc.v) {
+				t.Errorf("round-tripped value = %+v, want %+v", v, tc.v)
+			}
+		})
+	}
+}
+
+func TestPropertyUpdate_instructions(t *testing.T) {
+	s := `<propertyupdate xmlns="DAV:" xmlns:e="urn:example">
+		<remove><prop><e:color/></prop></remove>
+		<set><prop><e:color>red</e:color></prop></set>
+		<remove><prop><e:size/></prop></remove>
+	</propertyupdate>`
+	var update PropertyUpdate
+	if err := xml.Unmarshal([]byte(s), &update); err != nil {
+		t.Fatalf("xml.Unmarshal() = %v", err)
+	}
+	if len(update.Set) != 1 || len(update.Remove) != 2 {
+		t.Errorf("got %v set and %v remove instructions, want 1 and 2", len(update.Set), len(update.Remove))
+	}
+
+	var got []string
+	for _, instr := range update.Instructions {
+		op := "set"
+		if instr.Remove {
+			op = "remove"
+		}
+		for _, raw := range instr.Prop.Raw {
+			name, _ := raw.XMLName()
+			got = append(got, op+" "+name.Local)
+		}
+	}
+	want := []string{"remove color", "set color", "remove size"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("instructions = %v, want %v", got, want)
+	}
+
+	if err := xml.Unmarshal([]byte(`<propfind xmlns="DAV:"/>`), &update); err == nil {
+		t.Errorf("xml.Unmarshal() accepted a propfind element")
+	}
+}
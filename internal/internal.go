@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Depth indicates whether a request applies to the resource's members. It's
@@ -48,6 +51,21 @@ func (d Depth) String() string {
 	panic("webdav: invalid Depth value")
 }
 
+// MarshalText implements encoding.TextMarshaler.
+func (d Depth) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Depth) UnmarshalText(b []byte) error {
+	depth, err := ParseDepth(string(b))
+	if err != nil {
+		return err
+	}
+	*d = depth
+	return nil
+}
+
 // ParseOverwrite parses an Overwrite header.
 func ParseOverwrite(s string) (bool, error) {
 	switch s {
@@ -68,6 +86,96 @@ func FormatOverwrite(overwrite bool) string {
 	}
 }
 
+// TimeoutInfinite is an infinite lock timeout.
+const TimeoutInfinite time.Duration = -1
+
+// ParseTimeout parses a Timeout header or a timeout element. It's defined in
+// RFC 4918 section 10.7. If the value contains multiple timeout types, the
+// first one is returned.
+func ParseTimeout(s string) (time.Duration, error) {
+	s = strings.TrimSpace(strings.SplitN(s, ",", 2)[0])
+	if s == "Infinite" {
+		return TimeoutInfinite, nil
+	}
+	if !strings.HasPrefix(s, "Second-") {
+		return 0, fmt.Errorf("webdav: invalid Timeout value")
+	}
+	sec, err := strconv.ParseUint(strings.TrimPrefix(s, "Second-"), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("webdav: invalid Timeout value: %v", err)
+	}
+	return time.Duration(sec) * time.Second, nil
+}
+
+// FormatTimeout formats a Timeout header or a timeout element. The timeout is
+// rounded up to the next second, since "Second-0" would expire immediately.
+func FormatTimeout(timeout time.Duration) string {
+	if timeout == TimeoutInfinite {
+		return "Infinite"
+	}
+	sec := (timeout + time.Second - 1) / time.Second
+	if sec < 1 {
+		sec = 1
+	}
+	return fmt.Sprintf("Second-%d", sec)
+}
+
+// ParseLockToken parses a Lock-Token header, which contains a Coded-URL as
+// defined in RFC 4918 section 10.5.
+func ParseLockToken(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "<") || !strings.HasSuffix(s, ">") {
+		return "", fmt.Errorf("webdav: invalid Lock-Token value")
+	}
+	return s[1 : len(s)-1], nil
+}
+
+// FormatLockToken formats a Lock-Token header.
+func FormatLockToken(token string) string {
+	return "<" + token + ">"
+}
+
+// ParseIfLockTokens returns the lock tokens submitted in an If header, as
+// defined in RFC 4918 section 10.4. Resource tags, entity tags and negated
+// conditions are ignored.
+func ParseIfLockTokens(s string) []string {
+	var tokens []string
+	inList, not := false, false
+	for len(s) > 0 {
+		switch {
+		case s[0] == '(':
+			inList = true
+			s = s[1:]
+		case s[0] == ')':
+			inList = false
+			s = s[1:]
+		case s[0] == '[':
+			i := strings.IndexByte(s, ']')
+			if i < 0 {
+				return tokens
+			}
+			not = false
+			s = s[i+1:]
+		case s[0] == '<':
+			i := strings.IndexByte(s, '>')
+			if i < 0 {
+				return tokens
+			}
+			if inList && !not {
+				tokens = append(tokens, s[1:i])
+			}
+			not = false
+			s = s[i+1:]
+		case strings.HasPrefix(s, "Not"):
+			not = true
+			s = s[len("Not"):]
+		default:
+			s = s[1:]
+		}
+	}
+	return tokens
+}
+
 type HTTPError struct {
 	Code int
 	Err  error
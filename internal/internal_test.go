@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatTimeout(t *testing.T) {
+	testCases := []struct {
+		timeout time.Duration
+		want    string
+	}{
+		{TimeoutInfinite, "Infinite"},
+		{0, "Second-1"},
+		{time.Millisecond, "Second-1"},
+		{time.Second, "Second-1"},
+		{1500 * time.Millisecond, "Second-2"},
+		{time.Hour, "Second-3600"},
+	}
+	for _, tc := range testCases {
+		if got := FormatTimeout(tc.timeout); got != tc.want {
+			t.Errorf("FormatTimeout(%v) = %q, want %q", tc.timeout, got, tc.want)
+		}
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	testCases := []struct {
+		s    string
+		want time.Duration
+	}{
+		{"Infinite", TimeoutInfinite},
+		{"Second-60", time.Minute},
+		{"Second-4100000000, Infinite", 4100000000 * time.Second},
+		{"Infinite, Second-60", TimeoutInfinite},
+	}
+	for _, tc := range testCases {
+		got, err := ParseTimeout(tc.s)
+		if err != nil {
+			t.Errorf("ParseTimeout(%q) = %v", tc.s, err)
+		} else if got != tc.want {
+			t.Errorf("ParseTimeout(%q) = %v, want %v", tc.s, got, tc.want)
+		}
+	}
+
+	if _, err := ParseTimeout("Minute-1"); err == nil {
+		t.Errorf("ParseTimeout(%q) succeeded", "Minute-1")
+	}
+}
@@ -1,6 +1,8 @@
 package internal
 
 import (
+	"bytes"
+	"context"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -8,9 +10,46 @@ import (
 	"mime"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
+	"time"
 )
 
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying a request ID.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+type errorLoggerKey struct{}
+
+// ContextWithErrorLogger returns a copy of ctx carrying a function called with
+// the errors served by ServeRequestError.
+func ContextWithErrorLogger(ctx context.Context, f func(err error)) context.Context {
+	return context.WithValue(ctx, errorLoggerKey{}, f)
+}
+
+// ServeRequestError is like ServeError, but includes the request ID in the
+// response, if any, and reports the error to the logger carried by the
+// request context, if any.
+func ServeRequestError(w http.ResponseWriter, r *http.Request, err error) {
+	ctx := r.Context()
+	if id := RequestIDFromContext(ctx); id != "" {
+		w.Header().Set("X-Request-ID", id)
+	}
+	if f, ok := ctx.Value(errorLoggerKey{}).(func(error)); ok {
+		f(err)
+	}
+	ServeError(w, err)
+}
+
 func ServeError(w http.ResponseWriter, err error) {
 	code := http.StatusInternalServerError
 	var httpErr *HTTPError
@@ -20,8 +59,19 @@ func ServeError(w http.ResponseWriter, err error) {
 
 	var errElt *Error
 	if errors.As(err, &errElt) {
-		w.WriteHeader(code)
-		ServeXML(w).Encode(errElt)
+		serveXMLStatus(w, code).Encode(errElt)
+		return
+	}
+
+	var mkcolResp *MkcolResponse
+	if errors.As(err, &mkcolResp) {
+		serveXMLStatus(w, code).Encode(mkcolResp)
+		return
+	}
+
+	var ms *MultiStatus
+	if errors.As(err, &ms) {
+		serveXMLStatus(w, http.StatusMultiStatus).Encode(ms)
 		return
 	}
 
@@ -33,32 +83,170 @@ func isContentXML(h http.Header) bool {
 	return t == "application/xml" || t == "text/xml"
 }
 
+// MaxXMLRequestSize is the maximum size of a request body decoded by
+// DecodeXMLRequest.
+const MaxXMLRequestSize = 10 << 20
+
+// limitedBody is like http.MaxBytesReader, but returns a 413 HTTPError when
+// the limit is exceeded.
+type limitedBody struct {
+	rc       io.ReadCloser
+	n        int64 // remaining bytes
+	exceeded bool
+}
+
+// NewLimitedBody wraps a request body to fail with a 413 Request Entity Too
+// Large error if it's longer than n bytes.
+func NewLimitedBody(rc io.ReadCloser, n int64) io.ReadCloser {
+	return &limitedBody{rc: rc, n: n}
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.exceeded {
+		return 0, HTTPErrorf(http.StatusRequestEntityTooLarge, "webdav: request body too large")
+	}
+	if len(p) == 0 {
+		return l.rc.Read(p)
+	}
+
+	// Read one more byte than allowed to detect bodies which are too long
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.rc.Read(p)
+	if int64(n) <= l.n {
+		l.n -= int64(n)
+		return n, err
+	}
+
+	n = int(l.n)
+	l.n = 0
+	l.exceeded = true
+	return n, HTTPErrorf(http.StatusRequestEntityTooLarge, "webdav: request body too large")
+}
+
+func (l *limitedBody) Close() error {
+	return l.rc.Close()
+}
+
 func DecodeXMLRequest(r *http.Request, v interface{}) error {
 	if !isContentXML(r.Header) {
 		return HTTPErrorf(http.StatusBadRequest, "webdav: expected application/xml request")
 	}
+	if r.ContentLength > MaxXMLRequestSize {
+		return HTTPErrorf(http.StatusRequestEntityTooLarge, "webdav: request body too large")
+	}
 
-	if err := xml.NewDecoder(r.Body).Decode(v); err != nil {
+	body := NewLimitedBody(r.Body, MaxXMLRequestSize)
+	if err := xml.NewDecoder(body).Decode(v); err != nil {
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) {
+			return err
+		}
 		return &HTTPError{http.StatusBadRequest, err}
 	}
 	return nil
 }
 
+// IsRequestBodyEmpty reports whether a request has an empty body. It doesn't
+// rely on the Content-Length header field, which may be missing, e.g. for
+// HTTP/2 requests. If the body isn't empty, r.Body is replaced so that the
+// data read ahead is preserved.
 func IsRequestBodyEmpty(r *http.Request) bool {
-	_, err := r.Body.Read(nil)
-	return err == io.EOF
+	if r.Body == nil || r.Body == http.NoBody {
+		return true
+	}
+
+	var b [1]byte
+	for {
+		n, err := r.Body.Read(b[:])
+		if n > 0 {
+			r.Body = &readAheadBody{
+				Reader: io.MultiReader(bytes.NewReader(b[:n]), r.Body),
+				Closer: r.Body,
+			}
+			return false
+		} else if err == io.EOF {
+			return true
+		} else if err != nil {
+			return false
+		}
+	}
+}
+
+type readAheadBody struct {
+	io.Reader
+	io.Closer
 }
 
 func ServeXML(w http.ResponseWriter) *xml.Encoder {
+	return serveXMLStatus(w, http.StatusOK)
+}
+
+// serveXMLStatus is like ServeXML, but replies with the provided status code.
+// The Content-Type header needs to be set before the status is written.
+func serveXMLStatus(w http.ResponseWriter, code int) *xml.Encoder {
 	w.Header().Add("Content-Type", "application/xml; charset=\"utf-8\"")
+	w.WriteHeader(code)
 	w.Write([]byte(xml.Header))
 	return xml.NewEncoder(w)
 }
 
 func ServeMultiStatus(w http.ResponseWriter, ms *MultiStatus) error {
-	// TODO: streaming
-	w.WriteHeader(http.StatusMultiStatus)
-	return ServeXML(w).Encode(ms)
+	return serveXMLStatus(w, http.StatusMultiStatus).Encode(ms)
+}
+
+// MultiStatusWriter writes a multi-status response one response element at a
+// time, without holding the whole MultiStatus in memory.
+//
+// The status line and headers are sent when the first response is written.
+type MultiStatusWriter struct {
+	w   http.ResponseWriter
+	enc *xml.Encoder
+
+	// minimal omits 404 Not Found propstat elements
+	minimal bool
+}
+
+func NewMultiStatusWriter(w http.ResponseWriter) *MultiStatusWriter {
+	return &MultiStatusWriter{w: w}
+}
+
+// Started reports whether the status line has been sent.
+func (mw *MultiStatusWriter) Started() bool {
+	return mw.enc != nil
+}
+
+func (mw *MultiStatusWriter) start() error {
+	if mw.enc != nil {
+		return nil
+	}
+	mw.enc = serveXMLStatus(mw.w, http.StatusMultiStatus)
+	return mw.enc.EncodeToken(xml.StartElement{Name: multiStatusName})
+}
+
+func (mw *MultiStatusWriter) WriteResponse(resp *Response) error {
+	if err := mw.start(); err != nil {
+		return err
+	}
+	if mw.minimal {
+		omitNotFoundPropStats(resp)
+	}
+	if err := mw.enc.Encode(resp); err != nil {
+		return err
+	}
+	return mw.enc.Flush()
+}
+
+// Close terminates the multi-status response.
+func (mw *MultiStatusWriter) Close() error {
+	if err := mw.start(); err != nil {
+		return err
+	}
+	if err := mw.enc.EncodeToken(xml.EndElement{Name: multiStatusName}); err != nil {
+		return err
+	}
+	return mw.enc.Flush()
 }
 
 type Backend interface {
@@ -73,6 +261,46 @@ type Backend interface {
 	Move(r *http.Request, dest *Href, overwrite bool) (created bool, err error)
 }
 
+// LockBackend is an optional interface a Backend can implement to support the
+// LOCK and UNLOCK methods.
+type LockBackend interface {
+	Lock(r *http.Request, info *LockInfo, depth Depth, timeout time.Duration) (lock *ActiveLock, created bool, err error)
+	RefreshLock(r *http.Request, token string, timeout time.Duration) (*ActiveLock, error)
+	Unlock(r *http.Request, token string) error
+}
+
+// PropFindWriterBackend is an optional interface a Backend can implement to
+// stream the responses to a PROPFIND request instead of returning them all at
+// once. It is used instead of Backend.PropFind if available.
+type PropFindWriterBackend interface {
+	PropFindWrite(r *http.Request, pf *PropFind, depth Depth, mw *MultiStatusWriter) error
+}
+
+// ACLBackend is an optional interface a Backend can implement to support the
+// ACL method, as defined in RFC 3744 section 8.1.
+type ACLBackend interface {
+	SetACL(r *http.Request, acl *ACL) error
+}
+
+// ReportRequest is a REPORT request, as defined in RFC 3253 section 3.6.
+type ReportRequest struct {
+	// Path is the request URL path.
+	Path string
+	// Depth defaults to DepthZero if the Depth header is missing.
+	Depth Depth
+	// Name is the name of the report, ie. the name of the root element of
+	// the request body.
+	Name xml.Name
+	// Raw is the whole request body.
+	Raw *RawXMLValue
+}
+
+// ReportBackend is an optional interface a Backend can implement to support
+// the REPORT method.
+type ReportBackend interface {
+	HandleReport(ctx context.Context, r *ReportRequest) (*MultiStatus, error)
+}
+
 type Handler struct {
 	Backend Backend
 }
@@ -100,19 +328,24 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		case "PROPPATCH":
 			err = h.handleProppatch(w, r)
 		case "MKCOL":
-			err = h.Backend.Mkcol(r)
-			if err == nil {
-				w.WriteHeader(http.StatusCreated)
-			}
+			err = h.handleMkcol(w, r)
 		case "COPY", "MOVE":
 			err = h.handleCopyMove(w, r)
+		case "LOCK":
+			err = h.handleLock(w, r)
+		case "UNLOCK":
+			err = h.handleUnlock(w, r)
+		case "ACL":
+			err = h.handleACL(w, r)
+		case "REPORT":
+			err = h.handleReport(w, r)
 		default:
 			err = HTTPErrorf(http.StatusMethodNotAllowed, "webdav: unsupported method")
 		}
 	}
 
 	if err != nil {
-		ServeError(w, err)
+		ServeRequestError(w, r, err)
 	}
 }
 
@@ -121,7 +354,20 @@ func (h *Handler) handleOptions(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		return err
 	}
-	caps = append([]string{"1", "3"}, caps...)
+	// Compliance classes come first, in order
+	l := []string{"1"}
+	var extensions []string
+	for _, c := range caps {
+		switch c {
+		case "1", "3":
+			// always advertised
+		case "2":
+			l = append(l, c)
+		default:
+			extensions = append(extensions, c)
+		}
+	}
+	caps = append(append(l, "3"), extensions...)
 
 	w.Header().Add("DAV", strings.Join(caps, ", "))
 	w.Header().Add("Allow", strings.Join(allow, ", "))
@@ -136,8 +382,7 @@ func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request) error {
 			return err
 		}
 	} else {
-		var b [1]byte
-		if _, err := r.Body.Read(b[:]); err != io.EOF {
+		if !IsRequestBodyEmpty(r) {
 			return HTTPErrorf(http.StatusBadRequest, "webdav: unsupported request body")
 		}
 		propfind.AllProp = &struct{}{}
@@ -152,14 +397,84 @@ func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
+	// RFC 8144 section 2.1
+	minimal := preferReturnMinimal(r.Header)
+	w.Header().Add("Vary", "Prefer")
+	if minimal {
+		w.Header().Set("Preference-Applied", "return=minimal")
+	}
+
+	if pwb, ok := h.Backend.(PropFindWriterBackend); ok {
+		mw := NewMultiStatusWriter(w)
+		mw.minimal = minimal
+		err := pwb.PropFindWrite(r, &propfind, depth, mw)
+		if err != nil && !mw.Started() {
+			w.Header().Del("Preference-Applied")
+			return err
+		}
+		// The status has already been sent, so errors are reported in the
+		// multi-status response and write errors are ignored
+		if err != nil {
+			mw.WriteResponse(NewErrorResponse(r.URL.Path, err))
+		}
+		mw.Close()
+		return nil
+	}
+
 	ms, err := h.Backend.PropFind(r, &propfind, depth)
 	if err != nil {
+		w.Header().Del("Preference-Applied")
 		return err
 	}
 
+	if minimal {
+		for i := range ms.Responses {
+			omitNotFoundPropStats(&ms.Responses[i])
+		}
+	}
+
 	return ServeMultiStatus(w, ms)
 }
 
+// preferReturnMinimal reports whether the Prefer header fields contain the
+// "return=minimal" preference, as defined in RFC 7240.
+func preferReturnMinimal(h http.Header) bool {
+	for _, v := range h["Prefer"] {
+		for _, pref := range strings.Split(v, ",") {
+			// Ignore preference parameters
+			if i := strings.IndexByte(pref, ';'); i >= 0 {
+				pref = pref[:i]
+			}
+			kv := strings.SplitN(pref, "=", 2)
+			if len(kv) != 2 || !strings.EqualFold(strings.TrimSpace(kv[0]), "return") {
+				continue
+			}
+			return strings.EqualFold(strings.Trim(strings.TrimSpace(kv[1]), `"`), "minimal")
+		}
+	}
+	return false
+}
+
+// omitNotFoundPropStats removes the 404 Not Found propstat elements of a
+// response, as requested by "Prefer: return=minimal". If no propstat element
+// is left, an empty one with a 200 OK status is added, as required by RFC 8144
+// section 2.1.
+func omitNotFoundPropStats(resp *Response) {
+	if len(resp.PropStats) == 0 {
+		return
+	}
+	propStats := resp.PropStats[:0]
+	for _, ps := range resp.PropStats {
+		if ps.Status.Code != http.StatusNotFound {
+			propStats = append(propStats, ps)
+		}
+	}
+	if len(propStats) == 0 {
+		propStats = append(propStats, PropStat{Status: Status{Code: http.StatusOK}})
+	}
+	resp.PropStats = propStats
+}
+
 type PropFindFunc func(raw *RawXMLValue) (interface{}, error)
 
 func NewPropFindResponse(path string, propfind *PropFind, props map[xml.Name]PropFindFunc) (*Response, error) {
@@ -171,16 +486,53 @@ func NewPropFindResponse(path string, propfind *PropFind, props map[xml.Name]Pro
 		}
 	}
 
+	// Sort names to get a deterministic response
+	names := make([]xml.Name, 0, len(props))
+	for xmlName := range props {
+		names = append(names, xmlName)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if names[i].Space != names[j].Space {
+			return names[i].Space < names[j].Space
+		}
+		return names[i].Local < names[j].Local
+	})
+
 	if propfind.PropName != nil {
-		for xmlName, _ := range props {
+		for _, xmlName := range names {
 			emptyVal := NewRawXMLElement(xmlName, nil, nil)
 			if err := resp.EncodeProp(http.StatusOK, emptyVal); err != nil {
 				return nil, err
 			}
 		}
 	} else if propfind.AllProp != nil {
-		// TODO: add support for propfind.Include
-		for xmlName, f := range props {
+		included := make(map[xml.Name]bool)
+		if propfind.Include != nil {
+			for _, raw := range propfind.Include.Raw {
+				xmlName, ok := raw.XMLName()
+				if !ok {
+					continue
+				}
+				included[xmlName] = true
+
+				// Included properties are returned along with the others,
+				// they only need to be reported here if they're missing
+				if _, ok := props[xmlName]; ok {
+					continue
+				}
+				emptyVal := NewRawXMLElement(xmlName, nil, nil)
+				if err := resp.EncodeProp(http.StatusNotFound, emptyVal); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		for _, xmlName := range names {
+			if nonAllPropNames[xmlName] && !included[xmlName] {
+				continue
+			}
+
+			f := props[xmlName]
 			emptyVal := NewRawXMLElement(xmlName, nil, nil)
 
 			val, err := f(emptyVal)
@@ -231,13 +583,103 @@ func NewPropFindResponse(path string, propfind *PropFind, props map[xml.Name]Pro
 	return resp, nil
 }
 
+// nonAllPropNames contains the properties which are only returned for allprop
+// PROPFIND requests if explicitly included, as required by the specifications
+// defining them (RFC 3253, RFC 3744, RFC 4331, RFC 5397 and RFC 6578).
+var nonAllPropNames = map[xml.Name]bool{
+	CurrentUserPrincipalName:    true,
+	CurrentUserPrivilegeSetName: true,
+	ACLName:                     true,
+	OwnerName:                   true,
+	ACLRestrictionsName:         true,
+	AlternateURISetName:         true,
+	PrincipalURLName:            true,
+	GroupMemberSetName:          true,
+	GroupMembershipName:         true,
+	SupportedReportSetName:      true,
+	SyncTokenName:               true,
+	QuotaAvailableBytesName:     true,
+	QuotaUsedBytesName:          true,
+}
+
+// protectedPropNames contains the live properties defined in RFC 4918
+// section 15 which cannot be changed with PROPPATCH.
+var protectedPropNames = map[xml.Name]bool{
+	{Namespace, "creationdate"}:  true,
+	GetContentLengthName:         true,
+	GetETagName:                  true,
+	GetLastModifiedName:          true,
+	LockDiscoveryName:            true,
+	ResourceTypeName:             true,
+	{Namespace, "supportedlock"}: true,
+}
+
+// checkProtectedProps returns a failed Response if the update attempts to
+// modify a protected property. Since PROPPATCH is atomic, all other properties
+// are marked as failed dependencies.
+func checkProtectedProps(path string, update *PropertyUpdate) (*Response, error) {
+	var names []xml.Name
+	for _, set := range update.Set {
+		for _, raw := range set.Prop.Raw {
+			if name, ok := raw.XMLName(); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	for _, remove := range update.Remove {
+		for _, raw := range remove.Prop.Raw {
+			if name, ok := raw.XMLName(); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	protected := false
+	for _, name := range names {
+		if protectedPropNames[name] {
+			protected = true
+			break
+		}
+	}
+	if !protected {
+		return nil, nil
+	}
+
+	resp := &Response{Hrefs: []Href{{Path: path}}}
+	for _, name := range names {
+		code := http.StatusFailedDependency
+		if protectedPropNames[name] {
+			code = http.StatusForbidden
+		}
+		if err := resp.EncodeProp(code, NewRawXMLElement(name, nil, nil)); err != nil {
+			return nil, err
+		}
+	}
+	for i := range resp.PropStats {
+		propstat := &resp.PropStats[i]
+		if propstat.Status.Code == http.StatusForbidden {
+			propstat.Error = &Error{Raw: []RawXMLValue{
+				*NewRawXMLElement(CannotModifyProtectedPropertyName, nil, nil),
+			}}
+		}
+	}
+	return resp, nil
+}
+
 func (h *Handler) handleProppatch(w http.ResponseWriter, r *http.Request) error {
 	var update PropertyUpdate
 	if err := DecodeXMLRequest(r, &update); err != nil {
 		return err
 	}
 
-	resp, err := h.Backend.PropPatch(r, &update)
+	resp, err := checkProtectedProps(r.URL.Path, &update)
+	if err != nil {
+		return err
+	} else if resp != nil {
+		return ServeMultiStatus(w, NewMultiStatus(*resp))
+	}
+
+	resp, err = h.Backend.PropPatch(r, &update)
 	if err != nil {
 		return err
 	}
@@ -310,3 +752,174 @@ func (h *Handler) handleCopyMove(w http.ResponseWriter, r *http.Request) error {
 	}
 	return nil
 }
+
+func (h *Handler) handleLock(w http.ResponseWriter, r *http.Request) error {
+	lb, ok := h.Backend.(LockBackend)
+	if !ok {
+		return HTTPErrorf(http.StatusMethodNotAllowed, "webdav: unsupported method")
+	}
+
+	var timeout time.Duration
+	if s := r.Header.Get("Timeout"); s != "" {
+		var err error
+		timeout, err = ParseTimeout(s)
+		if err != nil {
+			return &HTTPError{http.StatusBadRequest, err}
+		}
+	}
+
+	var lock *ActiveLock
+	var created bool
+	if IsRequestBodyEmpty(r) {
+		tokens := ParseIfLockTokens(r.Header.Get("If"))
+		if len(tokens) != 1 {
+			return HTTPErrorf(http.StatusBadRequest, "webdav: expected exactly one lock token in If header to refresh lock")
+		}
+
+		var err error
+		lock, err = lb.RefreshLock(r, tokens[0], timeout)
+		if err != nil {
+			return err
+		}
+	} else {
+		var info LockInfo
+		if err := DecodeXMLRequest(r, &info); err != nil {
+			return err
+		}
+
+		depth := DepthInfinity
+		if s := r.Header.Get("Depth"); s != "" {
+			var err error
+			depth, err = ParseDepth(s)
+			if err != nil {
+				return &HTTPError{http.StatusBadRequest, err}
+			}
+			if depth == DepthOne {
+				return HTTPErrorf(http.StatusBadRequest, `webdav: "Depth: 1" is not supported in LOCK request`)
+			}
+		}
+
+		var err error
+		lock, created, err = lb.Lock(r, &info, depth, timeout)
+		if err != nil {
+			return err
+		}
+		if lock.LockToken != nil {
+			w.Header().Set("Lock-Token", FormatLockToken(lock.LockToken.Href.String()))
+		}
+	}
+
+	prop, err := EncodeProp(&LockDiscovery{ActiveLocks: []ActiveLock{*lock}})
+	if err != nil {
+		return err
+	}
+
+	code := http.StatusOK
+	if created {
+		code = http.StatusCreated
+	}
+	return serveXMLStatus(w, code).Encode(prop)
+}
+
+// ExtendedMkcolBackend is an optional interface a Backend can implement to
+// support extended MKCOL requests, as defined in RFC 5689.
+type ExtendedMkcolBackend interface {
+	// ExtendedMkcol creates a collection and sets its initial properties. On
+	// failure, the returned error should wrap a *MkcolResponse describing the
+	// status of each property.
+	ExtendedMkcol(r *http.Request, mkcol *Mkcol) (*MkcolResponse, error)
+}
+
+func (h *Handler) handleMkcol(w http.ResponseWriter, r *http.Request) error {
+	emb, ok := h.Backend.(ExtendedMkcolBackend)
+	if !ok || !isContentXML(r.Header) || IsRequestBodyEmpty(r) {
+		if err := h.Backend.Mkcol(r); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusCreated)
+		return nil
+	}
+
+	var mkcol Mkcol
+	if err := DecodeXMLRequest(r, &mkcol); err != nil {
+		return err
+	}
+
+	resp, err := emb.ExtendedMkcol(r, &mkcol)
+	if err != nil {
+		return err
+	}
+	return serveXMLStatus(w, http.StatusCreated).Encode(resp)
+}
+
+func (h *Handler) handleACL(w http.ResponseWriter, r *http.Request) error {
+	ab, ok := h.Backend.(ACLBackend)
+	if !ok {
+		return HTTPErrorf(http.StatusMethodNotAllowed, "webdav: ACL not supported")
+	}
+
+	var acl ACL
+	if err := DecodeXMLRequest(r, &acl); err != nil {
+		return err
+	}
+	if err := ab.SetACL(r, &acl); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (h *Handler) handleReport(w http.ResponseWriter, r *http.Request) error {
+	rb, ok := h.Backend.(ReportBackend)
+	if !ok {
+		return HTTPErrorf(http.StatusMethodNotAllowed, "webdav: REPORT not supported")
+	}
+
+	depth := DepthZero
+	if s := r.Header.Get("Depth"); s != "" {
+		var err error
+		depth, err = ParseDepth(s)
+		if err != nil {
+			return &HTTPError{http.StatusBadRequest, err}
+		}
+	}
+
+	var raw RawXMLValue
+	if err := DecodeXMLRequest(r, &raw); err != nil {
+		return err
+	}
+	name, ok := raw.XMLName()
+	if !ok {
+		return HTTPErrorf(http.StatusBadRequest, "webdav: invalid REPORT request body")
+	}
+
+	ms, err := rb.HandleReport(r.Context(), &ReportRequest{
+		Path:  r.URL.Path,
+		Depth: depth,
+		Name:  name,
+		Raw:   &raw,
+	})
+	if err != nil {
+		return err
+	}
+	return ServeMultiStatus(w, ms)
+}
+
+func (h *Handler) handleUnlock(w http.ResponseWriter, r *http.Request) error {
+	lb, ok := h.Backend.(LockBackend)
+	if !ok {
+		return HTTPErrorf(http.StatusMethodNotAllowed, "webdav: unsupported method")
+	}
+
+	token, err := ParseLockToken(r.Header.Get("Lock-Token"))
+	if err != nil {
+		return &HTTPError{http.StatusBadRequest, err}
+	}
+
+	if err := lb.Unlock(r, token); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
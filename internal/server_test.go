@@ -0,0 +1,292 @@
+package internal
+
+import (
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testReportBackend struct {
+	Backend
+	req *ReportRequest
+}
+
+func (b *testReportBackend) HandleReport(ctx context.Context, r *ReportRequest) (*MultiStatus, error) {
+	b.req = r
+	return NewMultiStatus(*NewOKResponse(r.Path)), nil
+}
+
+func TestHandler_report(t *testing.T) {
+	backend := &testReportBackend{}
+	h := Handler{Backend: backend}
+
+	body := `<?xml version="1.0" encoding="utf-8" ?>
+<D:sync-collection xmlns:D="DAV:">
+  <D:sync-token/>
+  <D:sync-level>1</D:sync-level>
+  <D:prop><D:getetag/></D:prop>
+</D:sync-collection>`
+	r := httptest.NewRequest("REPORT", "/dir/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/xml")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("status = %v, expected %v: %s", w.Code, http.StatusMultiStatus, w.Body.String())
+	}
+	req := backend.req
+	if req.Path != "/dir/" || req.Depth != DepthZero {
+		t.Errorf("ReportRequest = %+v, expected path /dir/ and depth 0", req)
+	}
+	if want := (xml.Name{"DAV:", "sync-collection"}); req.Name != want {
+		t.Errorf("ReportRequest.Name = %v, expected %v", req.Name, want)
+	}
+	var sync SyncCollectionQuery
+	if err := req.Raw.Decode(&sync); err != nil {
+		t.Fatalf("RawXMLValue.Decode() = %v", err)
+	}
+	if sync.SyncLevel != "1" {
+		t.Errorf("SyncCollectionQuery.SyncLevel = %q, expected %q", sync.SyncLevel, "1")
+	}
+}
+
+type testCopyBackend struct {
+	Backend
+}
+
+func (b *testCopyBackend) Copy(r *http.Request, dest *Href, recursive, overwrite bool) (bool, error) {
+	return false, NewMultiStatus(*NewErrorResponse("/dst/locked.txt", HTTPErrorf(http.StatusLocked, "locked")))
+}
+
+func TestHandler_copyMultiStatus(t *testing.T) {
+	h := Handler{Backend: &testCopyBackend{}}
+
+	r := httptest.NewRequest("COPY", "/src/", nil)
+	r.Header.Set("Destination", "http://example.com/dst/")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("status = %v, expected %v: %s", w.Code, http.StatusMultiStatus, w.Body.String())
+	}
+	dec := NewMultiStatusDecoder(w.Body)
+	resp, err := dec.Next()
+	if err != nil {
+		t.Fatalf("MultiStatusDecoder.Next() = %v", err)
+	}
+	if p, _ := resp.Path(); p != "/dst/locked.txt" {
+		t.Errorf("Response.Path() = %q, expected %q", p, "/dst/locked.txt")
+	}
+	if resp.Status == nil || resp.Status.Code != http.StatusLocked {
+		t.Errorf("Response.Status = %v, expected %v", resp.Status, http.StatusLocked)
+	}
+}
+
+func TestDecodeXMLRequest_tooLarge(t *testing.T) {
+	body := `<propfind xmlns="DAV:"><prop>` + strings.Repeat(" ", MaxXMLRequestSize) + `</prop></propfind>`
+	r := httptest.NewRequest("PROPFIND", "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/xml")
+	r.ContentLength = -1 // chunked
+
+	var propfind PropFind
+	err := DecodeXMLRequest(r, &propfind)
+	if err == nil || HTTPErrorFromError(err).Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("DecodeXMLRequest() = %v, expected status %v", err, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestIsRequestBodyEmpty(t *testing.T) {
+	r := httptest.NewRequest("PROPPATCH", "/", strings.NewReader("<propertyupdate/>"))
+	r.ContentLength = -1 // e.g. HTTP/2
+	if IsRequestBodyEmpty(r) {
+		t.Fatalf("IsRequestBodyEmpty() = true, expected false")
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		t.Fatalf("ReadAll() = %v", err)
+	} else if string(b) != "<propertyupdate/>" {
+		t.Errorf("body = %q, expected it to be preserved", string(b))
+	}
+
+	r = httptest.NewRequest("PROPFIND", "/", strings.NewReader(""))
+	r.ContentLength = -1
+	if !IsRequestBodyEmpty(r) {
+		t.Errorf("IsRequestBodyEmpty() = false, expected true")
+	}
+}
+
+func TestServeRequestError(t *testing.T) {
+	r := httptest.NewRequest("PROPFIND", "/", nil)
+	r = r.WithContext(ContextWithRequestID(r.Context(), "42"))
+	w := httptest.NewRecorder()
+	ServeRequestError(w, r, HTTPErrorf(http.StatusNotFound, "webdav: not found"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %v, expected %v", w.Code, http.StatusNotFound)
+	}
+	if id := w.Header().Get("X-Request-ID"); id != "42" {
+		t.Errorf("X-Request-ID = %q, expected %q", id, "42")
+	}
+}
+
+func TestNewPropFindResponse_allProp(t *testing.T) {
+	props := map[xml.Name]PropFindFunc{
+		GetContentLengthName: func(*RawXMLValue) (interface{}, error) {
+			return &GetContentLength{Length: 42}, nil
+		},
+		QuotaUsedBytesName: func(*RawXMLValue) (interface{}, error) {
+			return &QuotaUsedBytes{Bytes: 42}, nil
+		},
+		CurrentUserPrincipalName: func(*RawXMLValue) (interface{}, error) {
+			return &CurrentUserPrincipal{Href: Href{Path: "/principal/"}}, nil
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		propfind *PropFind
+		expected []xml.Name
+	}{
+		{
+			name:     "allprop",
+			propfind: NewAllPropPropFind(),
+			expected: []xml.Name{GetContentLengthName, ResourceTypeName},
+		},
+		{
+			name:     "allprop with include",
+			propfind: NewAllPropPropFind(QuotaUsedBytesName),
+			expected: []xml.Name{GetContentLengthName, QuotaUsedBytesName, ResourceTypeName},
+		},
+		{
+			name:     "propname",
+			propfind: &PropFind{PropName: &struct{}{}},
+			expected: []xml.Name{CurrentUserPrincipalName, GetContentLengthName, QuotaUsedBytesName, ResourceTypeName},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := NewPropFindResponse("/file", tc.propfind, props)
+			if err != nil {
+				t.Fatalf("NewPropFindResponse() = %v", err)
+			}
+
+			// Round-trip the response to decode the property names
+			b, err := xml.Marshal(resp)
+			if err != nil {
+				t.Fatalf("xml.Marshal() = %v", err)
+			}
+			resp = new(Response)
+			if err := xml.Unmarshal(b, resp); err != nil {
+				t.Fatalf("xml.Unmarshal() = %v", err)
+			}
+			if len(resp.PropStats) != 1 || resp.PropStats[0].Status.Code != http.StatusOK {
+				t.Fatalf("expected a single 200 propstat, got %+v", resp.PropStats)
+			}
+
+			var names []xml.Name
+			for _, raw := range resp.PropStats[0].Prop.Raw {
+				name, _ := raw.XMLName()
+				names = append(names, name)
+			}
+			if !reflect.DeepEqual(names, tc.expected) {
+				t.Errorf("properties = %v, expected %v", names, tc.expected)
+			}
+		})
+	}
+}
+
+type testPropFindBackend struct {
+	Backend
+}
+
+func (b *testPropFindBackend) PropFind(r *http.Request, propfind *PropFind, depth Depth) (*MultiStatus, error) {
+	props := map[xml.Name]PropFindFunc{
+		GetContentLengthName: func(*RawXMLValue) (interface{}, error) {
+			return &GetContentLength{Length: 42}, nil
+		},
+	}
+	resp, err := NewPropFindResponse(r.URL.Path, propfind, props)
+	if err != nil {
+		return nil, err
+	}
+	return NewMultiStatus(*resp), nil
+}
+
+func TestHandler_propFindPreferMinimal(t *testing.T) {
+	h := Handler{Backend: &testPropFindBackend{}}
+
+	testCases := []struct {
+		name      string
+		prefer    string
+		prop      string
+		applied   bool
+		propStats []int
+	}{
+		{
+			name:      "default",
+			prop:      "<getcontentlength/><getetag/>",
+			propStats: []int{http.StatusOK, http.StatusNotFound},
+		},
+		{
+			name:      "minimal",
+			prefer:    "return=minimal",
+			prop:      "<getcontentlength/><getetag/>",
+			applied:   true,
+			propStats: []int{http.StatusOK},
+		},
+		{
+			name:      "minimal with other preferences",
+			prefer:    `handling=lenient, return="minimal"; foo=bar`,
+			prop:      "<getetag/>",
+			applied:   true,
+			propStats: []int{http.StatusOK},
+		},
+		{
+			name:      "representation",
+			prefer:    "return=representation",
+			prop:      "<getetag/>",
+			propStats: []int{http.StatusNotFound},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := `<propfind xmlns="DAV:"><prop>` + tc.prop + `</prop></propfind>`
+			r := httptest.NewRequest("PROPFIND", "/file", strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/xml")
+			r.Header.Set("Depth", "0")
+			if tc.prefer != "" {
+				r.Header.Set("Prefer", tc.prefer)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != http.StatusMultiStatus {
+				t.Fatalf("status = %v, expected %v: %s", w.Code, http.StatusMultiStatus, w.Body.String())
+			}
+			if applied := w.Header().Get("Preference-Applied"); (applied == "return=minimal") != tc.applied {
+				t.Errorf("Preference-Applied = %q, expected applied = %v", applied, tc.applied)
+			}
+
+			var ms MultiStatus
+			if err := xml.NewDecoder(w.Body).Decode(&ms); err != nil {
+				t.Fatalf("failed to decode multistatus: %v", err)
+			}
+			if len(ms.Responses) != 1 {
+				t.Fatalf("got %v responses, expected 1", len(ms.Responses))
+			}
+			var codes []int
+			for _, ps := range ms.Responses[0].PropStats {
+				codes = append(codes, ps.Status.Code)
+			}
+			if !reflect.DeepEqual(codes, tc.propStats) {
+				t.Errorf("propstat statuses = %v, expected %v", codes, tc.propStats)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -15,6 +16,14 @@ type RawXMLValue struct {
 	tok      xml.Token // guaranteed not to be xml.EndElement
 	children []RawXMLValue
 
+	// Namespace declarations of this element, by prefix. They are emitted
+	// when marshalling, and names in these namespaces are written with the
+	// prefix.
+	ns map[string]string
+	// Prefixed namespace declarations in scope when the value was
+	// unmarshalled, inherited from the parent elements, by prefix.
+	inheritedNS map[string]string
+
 	// Unfortunately encoding/xml doesn't offer TokenWriter, so we need to
 	// cache outgoing data.
 	out interface{}
@@ -25,6 +34,62 @@ func NewRawXMLElement(name xml.Name, attr []xml.Attr, children []RawXMLValue) *R
 	return &RawXMLValue{tok: xml.StartElement{name, attr}, children: children}
 }
 
+// EncodeRawXMLElementNS is like NewRawXMLElement, but preserves the provided
+// namespace declarations, by prefix. When marshalled, the element declares
+// these prefixes and uses them for the element and attribute names of its
+// subtree, instead of letting encoding/xml generate declarations.
+func EncodeRawXMLElementNS(name xml.Name, attrs []xml.Attr, children []RawXMLValue, nsDecls map[string]string) (*RawXMLValue, error) {
+	ns := make(map[string]string, len(nsDecls))
+	for prefix, url := range nsDecls {
+		if prefix == "" || strings.Contains(prefix, ":") || strings.HasPrefix(strings.ToLower(prefix), "xml") {
+			return nil, fmt.Errorf("webdav: invalid namespace prefix %q", prefix)
+		}
+		if url == "" {
+			return nil, fmt.Errorf("webdav: empty namespace URL for prefix %q", prefix)
+		}
+		ns[prefix] = url
+	}
+
+	val := NewRawXMLElement(name, attrs, children)
+	val.ns = ns
+	return val, nil
+}
+
+// Clone returns a deep copy of the value, which doesn't share any token,
+// attribute or namespace declaration with the original. Values created with
+// EncodeRawXMLElement are copied only if they hold a *RawXMLValue.
+func (val *RawXMLValue) Clone() *RawXMLValue {
+	clone := &RawXMLValue{
+		ns:          copyNS(val.ns),
+		inheritedNS: copyNS(val.inheritedNS),
+		out:         val.out,
+	}
+	if val.tok != nil {
+		clone.tok = xml.CopyToken(val.tok)
+	}
+	if out, ok := val.out.(*RawXMLValue); ok && out != nil {
+		clone.out = out.Clone()
+	}
+	if val.children != nil {
+		clone.children = make([]RawXMLValue, len(val.children))
+		for i := range val.children {
+			clone.children[i] = *val.children[i].Clone()
+		}
+	}
+	return clone
+}
+
+func copyNS(ns map[string]string) map[string]string {
+	if ns == nil {
+		return nil
+	}
+	m := make(map[string]string, len(ns))
+	for prefix, url := range ns {
+		m[prefix] = url
+	}
+	return m
+}
+
 // EncodeRawXMLElement encodes a value into a new RawXMLValue. The XML value
 // can only be used for marshalling.
 func EncodeRawXMLElement(v interface{}) (*RawXMLValue, error) {
@@ -33,9 +98,46 @@ func EncodeRawXMLElement(v interface{}) (*RawXMLValue, error) {
 
 // UnmarshalXML implements xml.Unmarshaler.
 func (val *RawXMLValue) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
-	val.tok = start
+	return val.unmarshalXML(d, start, nil)
+}
+
+func (val *RawXMLValue) unmarshalXML(d *xml.Decoder, start xml.StartElement, inheritedNS map[string]string) error {
 	val.children = nil
 	val.out = nil
+	val.ns = nil
+	val.inheritedNS = inheritedNS
+
+	// Namespace declarations are kept aside so that marshalling can
+	// reproduce the prefixes, the other attributes are kept as-is. The
+	// default namespace declaration is dropped: encoding/xml emits one
+	// whenever needed.
+	var attrs []xml.Attr
+	for _, attr := range start.Attr {
+		switch {
+		case attr.Name.Space == "xmlns":
+			if val.ns == nil {
+				val.ns = make(map[string]string)
+			}
+			val.ns[attr.Name.Local] = attr.Value
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			// Default namespace declaration
+		default:
+			attrs = append(attrs, attr)
+		}
+	}
+	val.tok = xml.StartElement{Name: start.Name, Attr: attrs}
+
+	// Namespace declarations in scope for children
+	scope := inheritedNS
+	if len(val.ns) > 0 {
+		scope = make(map[string]string, len(inheritedNS)+len(val.ns))
+		for prefix, url := range inheritedNS {
+			scope[prefix] = url
+		}
+		for prefix, url := range val.ns {
+			scope[prefix] = url
+		}
+	}
 
 	for {
 		tok, err := d.Token()
@@ -45,7 +147,7 @@ func (val *RawXMLValue) UnmarshalXML(d *xml.Decoder, start xml.StartElement) err
 		switch tok := tok.(type) {
 		case xml.StartElement:
 			child := RawXMLValue{}
-			if err := child.UnmarshalXML(d, tok); err != nil {
+			if err := child.unmarshalXML(d, tok, scope); err != nil {
 				return err
 			}
 			val.children = append(val.children, child)
@@ -59,18 +161,63 @@ func (val *RawXMLValue) UnmarshalXML(d *xml.Decoder, start xml.StartElement) err
 
 // MarshalXML implements xml.Marshaler.
 func (val *RawXMLValue) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return val.marshalXML(e, nil)
+}
+
+// marshalXML encodes the value. prefixes contains the namespace prefixes
+// declared by the parent elements, by namespace URL.
+func (val *RawXMLValue) marshalXML(e *xml.Encoder, prefixes map[string]string) error {
 	if val.out != nil {
 		return e.Encode(val.out)
 	}
 
 	switch tok := val.tok.(type) {
 	case xml.StartElement:
+		if len(val.ns) > 0 {
+			scope := make(map[string]string, len(prefixes)+len(val.ns))
+			for url, prefix := range prefixes {
+				scope[url] = prefix
+			}
+
+			declared := make([]string, 0, len(val.ns))
+			for prefix := range val.ns {
+				declared = append(declared, prefix)
+			}
+			sort.Strings(declared)
+
+			attrs := make([]xml.Attr, 0, len(declared)+len(tok.Attr))
+			for _, prefix := range declared {
+				url := val.ns[prefix]
+				// The prefix may be re-bound to a different namespace
+				for u, p := range scope {
+					if p == prefix {
+						delete(scope, u)
+					}
+				}
+				scope[url] = prefix
+				attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "xmlns:" + prefix}, Value: url})
+			}
+			tok.Attr = append(attrs, tok.Attr...)
+			prefixes = scope
+		}
+
+		if len(prefixes) > 0 {
+			tok.Name = prefixName(tok.Name, prefixes)
+			attrs := make([]xml.Attr, len(tok.Attr))
+			for i, attr := range tok.Attr {
+				if attr.Name.Space != "" && attr.Name.Space != "xmlns" {
+					attr.Name = prefixName(attr.Name, prefixes)
+				}
+				attrs[i] = attr
+			}
+			tok.Attr = attrs
+		}
+
 		if err := e.EncodeToken(tok); err != nil {
 			return err
 		}
 		for _, child := range val.children {
-			// TODO: find a sensible value for the start argument?
-			if err := child.MarshalXML(e, xml.StartElement{}); err != nil {
+			if err := child.marshalXML(e, prefixes); err != nil {
 				return err
 			}
 		}
@@ -82,11 +229,73 @@ func (val *RawXMLValue) MarshalXML(e *xml.Encoder, start xml.StartElement) error
 	}
 }
 
+// prefixName replaces the namespace of a name with its declared prefix, if
+// any.
+func prefixName(name xml.Name, prefixes map[string]string) xml.Name {
+	if prefix, ok := prefixes[name.Space]; ok && name.Space != "" {
+		return xml.Name{Local: prefix + ":" + name.Local}
+	}
+	return name
+}
+
 var _ xml.Marshaler = (*RawXMLValue)(nil)
 var _ xml.Unmarshaler = (*RawXMLValue)(nil)
 
 func (val *RawXMLValue) Decode(v interface{}) error {
-	return xml.NewTokenDecoder(val.TokenReader()).Decode(&v)
+	var tr xml.TokenReader = val.TokenReader()
+	if len(val.inheritedNS) > 0 {
+		tr = &nsTokenReader{tr: tr, ns: val.inheritedNS}
+	}
+	return xml.NewTokenDecoder(tr).Decode(&v)
+}
+
+// nsTokenReader restores the namespace declarations inherited from the parent
+// elements on the first start element, so that prefixes used in the value
+// can still be resolved.
+type nsTokenReader struct {
+	tr   xml.TokenReader
+	ns   map[string]string
+	done bool
+}
+
+func (tr *nsTokenReader) Token() (xml.Token, error) {
+	tok, err := tr.tr.Token()
+	if err != nil || tr.done {
+		return tok, err
+	}
+
+	start, ok := tok.(xml.StartElement)
+	if !ok {
+		return tok, nil
+	}
+	tr.done = true
+
+	declared := make(map[string]bool)
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" {
+			declared[attr.Name.Local] = true
+		}
+	}
+	start.Attr = append(nsAttrs(tr.ns, declared), start.Attr...)
+	return start, nil
+}
+
+// nsAttrs returns xmlns attributes for namespace declarations, sorted by
+// prefix. Prefixes in skip are omitted.
+func nsAttrs(ns map[string]string, skip map[string]bool) []xml.Attr {
+	prefixes := make([]string, 0, len(ns))
+	for prefix := range ns {
+		if !skip[prefix] {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	sort.Strings(prefixes)
+
+	attrs := make([]xml.Attr, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Space: "xmlns", Local: prefix}, Value: ns[prefix]})
+	}
+	return attrs
 }
 
 func (val *RawXMLValue) XMLName() (name xml.Name, ok bool) {
@@ -124,6 +333,10 @@ func (tr *rawXMLValueReader) Token() (xml.Token, error) {
 
 	if !tr.start {
 		tr.start = true
+		if len(tr.val.ns) > 0 {
+			start = start.Copy()
+			start.Attr = append(nsAttrs(tr.val.ns, nil), start.Attr...)
+		}
 		return start, nil
 	}
 
@@ -72,3 +72,114 @@ func TestRawXMLValue_TokenReader(t *testing.T) {
 		t.Errorf("input doesn't match output:\n%v\nvs.\n%v", rawXML, s)
 	}
 }
+
+func TestEncodeRawXMLElementNS(t *testing.T) {
+	const ns = "http://calendarserver.org/ns/"
+	child := NewRawXMLElement(xml.Name{ns, "child"}, []xml.Attr{{Name: xml.Name{ns, "kind"}, Value: "a"}}, nil)
+	val, err := EncodeRawXMLElementNS(xml.Name{ns, "getctag"}, nil, []RawXMLValue{*child}, map[string]string{"CS": ns})
+	if err != nil {
+		t.Fatalf("EncodeRawXMLElementNS() = %v", err)
+	}
+
+	b, err := xml.Marshal(val)
+	if err != nil {
+		t.Fatalf("xml.Marshal() = %v", err)
+	}
+	want := `<CS:getctag xmlns:CS="http://calendarserver.org/ns/"><CS:child CS:kind="a"></CS:child></CS:getctag>`
+	if string(b) != want {
+		t.Errorf("xml.Marshal() = %v, want %v", string(b), want)
+	}
+
+	var decoded struct {
+		XMLName xml.Name `xml:"http://calendarserver.org/ns/ getctag"`
+		Child   struct {
+			Kind string `xml:"http://calendarserver.org/ns/ kind,attr"`
+		} `xml:"http://calendarserver.org/ns/ child"`
+	}
+	if err := xml.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("xml.Unmarshal() = %v", err)
+	}
+	if decoded.Child.Kind != "a" {
+		t.Errorf("kind = %q, want %q", decoded.Child.Kind, "a")
+	}
+
+	if _, err := EncodeRawXMLElementNS(xml.Name{ns, "getctag"}, nil, nil, map[string]string{"xmlns": ns}); err == nil {
+		t.Errorf("EncodeRawXMLElementNS() with reserved prefix succeeded")
+	}
+}
+
+type nsBindings map[string]string
+
+func (b *nsBindings) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	*b = make(nsBindings)
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" {
+			(*b)[attr.Name.Local] = attr.Value
+		}
+	}
+	return d.Skip()
+}
+
+func TestRawXMLValue_Decode_namespaces(t *testing.T) {
+	const s = `<A:root xmlns:A="urn:a" xmlns:B="urn:b"><A:child xmlns:B="urn:b2">B:value</A:child></A:root>`
+
+	var rawValue RawXMLValue
+	if err := xml.Unmarshal([]byte(s), &rawValue); err != nil {
+		t.Fatalf("xml.Unmarshal() = %v", err)
+	}
+
+	var bindings nsBindings
+	if err := rawValue.children[0].Decode(&bindings); err != nil {
+		t.Fatalf("RawXMLValue.Decode() = %v", err)
+	}
+	if bindings["A"] != "urn:a" || bindings["B"] != "urn:b2" {
+		t.Errorf("namespace bindings = %v, want A=urn:a and B=urn:b2", bindings)
+	}
+}
+
+func TestRawXMLValue_prefixRoundTrip(t *testing.T) {
+	for _, s := range []string{
+		`<x:foo xmlns:x="http://example.com/"></x:foo>`,
+		`<x:foo xmlns:x="http://example.com/" x:attr="1"><x:bar>baz</x:bar><y:qux xmlns:y="urn:y"></y:qux></x:foo>`,
+		`<x:foo xmlns:x="http://example.com/"><x:bar xmlns:x="urn:other"><x:baz></x:baz></x:bar></x:foo>`,
+	} {
+		var rawValue RawXMLValue
+		if err := xml.Unmarshal([]byte(s), &rawValue); err != nil {
+			t.Fatalf("xml.Unmarshal(%q) = %v", s, err)
+		}
+		b, err := xml.Marshal(&rawValue)
+		if err != nil {
+			t.Fatalf("xml.Marshal(%q) = %v", s, err)
+		}
+		if string(b) != s {
+			t.Errorf("xml.Marshal() = %v, want %v", string(b), s)
+		}
+	}
+}
+
+func TestRawXMLValue_Clone(t *testing.T) {
+	var rawValue RawXMLValue
+	if err := xml.Unmarshal([]byte(rawXML), &rawValue); err != nil {
+		t.Fatalf("xml.Unmarshal() = %v", err)
+	}
+
+	prop, err := EncodeProp(&rawValue)
+	if err != nil {
+		t.Fatalf("EncodeProp() = %v", err)
+	}
+
+	// Mutate the original value after encoding it
+	book := &rawValue.children[1]
+	start := book.tok.(xml.StartElement)
+	start.Attr[0].Value = "HORROR"
+	book.children = nil
+	rawValue.children = rawValue.children[:1]
+
+	b, err := xml.Marshal(&prop.Raw[0])
+	if err != nil {
+		t.Fatalf("xml.Marshal() = %v", err)
+	}
+	if s := xml.Header + string(b); s != rawXML {
+		t.Errorf("clone was modified:\n%v\nvs.\n%v", rawXML, s)
+	}
+}
@@ -0,0 +1,189 @@
+package webdav
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+type memLock struct {
+	Lock
+	expires time.Time // zero if the lock never expires
+}
+
+func (l *memLock) expired(now time.Time) bool {
+	return !l.expires.IsZero() && !now.Before(l.expires)
+}
+
+func (l *memLock) setTimeout(timeout time.Duration, now time.Time) {
+	l.Timeout = timeout
+	l.expires = time.Time{}
+	if timeout != TimeoutInfinite {
+		l.expires = now.Add(timeout)
+	}
+}
+
+func (l *memLock) appliesTo(name string) bool {
+	return l.Path == name || (l.Recursive && isSubPath(l.Path, name))
+}
+
+// defaultMemLockTimeout is the timeout of the locks requested without one,
+// unless MemLockBackend.DefaultTimeout is set.
+const defaultMemLockTimeout = 5 * time.Minute
+
+// MemLockBackend is a LockBackend storing locks in memory. Locks are lost when
+// the process exits.
+//
+// The zero value is ready to use.
+type MemLockBackend struct {
+	// DefaultTimeout is the timeout of the locks requested or refreshed
+	// without a timeout. Zero means 5 minutes. Locks only last forever if
+	// TimeoutInfinite is requested, or if DefaultTimeout is TimeoutInfinite.
+	DefaultTimeout time.Duration
+
+	mu    sync.Mutex
+	locks map[string]*memLock // by token
+}
+
+var _ LockBackend = (*MemLockBackend)(nil)
+
+// isSubPath reports whether name is a strict descendant of dir.
+func isSubPath(dir, name string) bool {
+	return strings.HasPrefix(name, strings.TrimSuffix(dir, "/")+"/")
+}
+
+func cleanLockPath(name string) string {
+	return path.Clean("/" + name)
+}
+
+func newLockToken() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	// Random (version 4) UUID, see RFC 4122 section 4.4
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// timeout returns the timeout to apply to a lock requested with the provided
+// one, which is zero if unspecified.
+func (b *MemLockBackend) timeout(timeout time.Duration) time.Duration {
+	if timeout != 0 {
+		return timeout
+	} else if b.DefaultTimeout != 0 {
+		return b.DefaultTimeout
+	}
+	return defaultMemLockTimeout
+}
+
+// prune removes expired locks. The caller must hold the mutex.
+func (b *MemLockBackend) prune(now time.Time) {
+	for token, l := range b.locks {
+		if l.expired(now) {
+			delete(b.locks, token)
+		}
+	}
+}
+
+// lockCopy returns a copy of l with the remaining timeout.
+func lockCopy(l *memLock, now time.Time) *Lock {
+	lock := l.Lock
+	if !l.expires.IsZero() {
+		lock.Timeout = l.expires.Sub(now).Round(time.Second)
+		if lock.Timeout < time.Second {
+			lock.Timeout = time.Second
+		}
+	}
+	return &lock
+}
+
+func (b *MemLockBackend) Lock(ctx context.Context, lock *Lock) (*Lock, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.prune(now)
+
+	name := cleanLockPath(lock.Path)
+	for _, l := range b.locks {
+		if lock.Shared && l.Shared {
+			continue
+		}
+		if l.appliesTo(name) || (lock.Recursive && isSubPath(name, l.Path)) {
+			return nil, internal.HTTPErrorf(http.StatusLocked, "webdav: %q is already locked", lock.Path)
+		}
+	}
+
+	token, err := newLockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	l := &memLock{Lock: *lock}
+	l.Path = name
+	l.Token = token
+	l.setTimeout(b.timeout(lock.Timeout), now)
+
+	if b.locks == nil {
+		b.locks = make(map[string]*memLock)
+	}
+	b.locks[token] = l
+	return lockCopy(l, now), nil
+}
+
+func (b *MemLockBackend) Refresh(ctx context.Context, name, token string, timeout time.Duration) (*Lock, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.prune(now)
+
+	l, ok := b.locks[token]
+	if !ok || !l.appliesTo(cleanLockPath(name)) {
+		return nil, internal.HTTPErrorf(http.StatusPreconditionFailed, "webdav: lock token doesn't match %q", name)
+	}
+
+	l.setTimeout(b.timeout(timeout), now)
+	return lockCopy(l, now), nil
+}
+
+func (b *MemLockBackend) Unlock(ctx context.Context, name, token string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.prune(time.Now())
+
+	l, ok := b.locks[token]
+	if !ok || !l.appliesTo(cleanLockPath(name)) {
+		return internal.HTTPErrorf(http.StatusConflict, "webdav: lock token doesn't match %q", name)
+	}
+
+	delete(b.locks, token)
+	return nil
+}
+
+func (b *MemLockBackend) Locks(ctx context.Context, name string) ([]Lock, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.prune(now)
+
+	name = cleanLockPath(name)
+	var locks []Lock
+	for _, l := range b.locks {
+		if l.appliesTo(name) {
+			locks = append(locks, *lockCopy(l, now))
+		}
+	}
+	return locks, nil
+}
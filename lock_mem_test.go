@@ -0,0 +1,263 @@
+package webdav
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+func lockErrorCode(err error) int {
+	if httpErr, ok := err.(*internal.HTTPError); ok {
+		return httpErr.Code
+	}
+	return 0
+}
+
+func TestMemLockBackend_Lock(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name   string
+		first  Lock
+		second Lock
+		want   int
+	}{
+		{"same path", Lock{Path: "/a"}, Lock{Path: "/a"}, http.StatusLocked},
+		{"shared", Lock{Path: "/a", Shared: true}, Lock{Path: "/a", Shared: true}, 0},
+		{"shared then exclusive", Lock{Path: "/a", Shared: true}, Lock{Path: "/a"}, http.StatusLocked},
+		{"exclusive then shared", Lock{Path: "/a"}, Lock{Path: "/a", Shared: true}, http.StatusLocked},
+		{"sibling", Lock{Path: "/a"}, Lock{Path: "/ab"}, 0},
+		{"child of depth 0", Lock{Path: "/a"}, Lock{Path: "/a/b"}, 0},
+		{"child of depth infinity", Lock{Path: "/a", Recursive: true}, Lock{Path: "/a/b"}, http.StatusLocked},
+		{"depth infinity on parent", Lock{Path: "/a/b"}, Lock{Path: "/a", Recursive: true}, http.StatusLocked},
+		{"depth 0 on parent", Lock{Path: "/a/b"}, Lock{Path: "/a"}, 0},
+		{"unclean path", Lock{Path: "/a/"}, Lock{Path: "a"}, http.StatusLocked},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var b MemLockBackend
+			if _, err := b.Lock(ctx, &tc.first); err != nil {
+				t.Fatalf("Lock() = %v", err)
+			}
+			_, err := b.Lock(ctx, &tc.second)
+			if code := lockErrorCode(err); code != tc.want || (tc.want == 0 && err != nil) {
+				t.Errorf("second Lock() = %v, want status %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestMemLockBackend_tokens(t *testing.T) {
+	ctx := context.Background()
+	var b MemLockBackend
+
+	tokenRegexp := regexp.MustCompile(`^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		lock, err := b.Lock(ctx, &Lock{Path: "/a", Shared: true, Owner: "alice", Timeout: time.Minute})
+		if err != nil {
+			t.Fatalf("Lock() = %v", err)
+		}
+		if !tokenRegexp.MatchString(lock.Token) {
+			t.Errorf("token %q isn't a version 4 UUID URN", lock.Token)
+		}
+		if seen[lock.Token] {
+			t.Errorf("duplicate token %q", lock.Token)
+		}
+		seen[lock.Token] = true
+		if lock.Owner != "alice" || lock.Timeout != time.Minute {
+			t.Errorf("Lock() = %+v, want owner alice and a 1m timeout", lock)
+		}
+	}
+
+	locks, err := b.Locks(ctx, "/a")
+	if err != nil {
+		t.Fatalf("Locks() = %v", err)
+	}
+	if len(locks) != 10 {
+		t.Errorf("Locks() returned %v locks, want 10", len(locks))
+	}
+}
+
+func TestMemLockBackend_Locks(t *testing.T) {
+	ctx := context.Background()
+	var b MemLockBackend
+
+	recursive, err := b.Lock(ctx, &Lock{Path: "/a", Recursive: true})
+	if err != nil {
+		t.Fatalf("Lock() = %v", err)
+	}
+	if _, err := b.Lock(ctx, &Lock{Path: "/d"}); err != nil {
+		t.Fatalf("Lock() = %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		want int
+	}{
+		{"/a", 1},
+		{"/a/b/c", 1},
+		{"/ab", 0},
+		{"/d", 1},
+		{"/d/e", 0},
+		{"/", 0},
+	} {
+		locks, err := b.Locks(ctx, tc.name)
+		if err != nil {
+			t.Fatalf("Locks(%q) = %v", tc.name, err)
+		}
+		if len(locks) != tc.want {
+			t.Errorf("Locks(%q) = %v locks, want %v", tc.name, len(locks), tc.want)
+		}
+	}
+
+	locks, _ := b.Locks(ctx, "/a/b")
+	if len(locks) != 1 || locks[0].Path != "/a" || locks[0].Token != recursive.Token {
+		t.Errorf("Locks(/a/b) = %+v, want the lock on /a", locks)
+	}
+}
+
+func TestMemLockBackend_RefreshUnlock(t *testing.T) {
+	ctx := context.Background()
+	var b MemLockBackend
+
+	lock, err := b.Lock(ctx, &Lock{Path: "/a", Recursive: true, Timeout: time.Minute})
+	if err != nil {
+		t.Fatalf("Lock() = %v", err)
+	}
+
+	refreshed, err := b.Refresh(ctx, "/a/b", lock.Token, TimeoutInfinite)
+	if err != nil {
+		t.Fatalf("Refresh() = %v", err)
+	}
+	if refreshed.Timeout != TimeoutInfinite || refreshed.Token != lock.Token {
+		t.Errorf("Refresh() = %+v, want an infinite timeout", refreshed)
+	}
+
+	if _, err := b.Refresh(ctx, "/d", lock.Token, time.Minute); lockErrorCode(err) != http.StatusPreconditionFailed {
+		t.Errorf("Refresh() on another file = %v, want status %v", err, http.StatusPreconditionFailed)
+	}
+	if _, err := b.Refresh(ctx, "/a", "urn:uuid:unknown", time.Minute); lockErrorCode(err) != http.StatusPreconditionFailed {
+		t.Errorf("Refresh() with unknown token = %v, want status %v", err, http.StatusPreconditionFailed)
+	}
+
+	if err := b.Unlock(ctx, "/d", lock.Token); lockErrorCode(err) != http.StatusConflict {
+		t.Errorf("Unlock() on another file = %v, want status %v", err, http.StatusConflict)
+	}
+	if err := b.Unlock(ctx, "/a/b", lock.Token); err != nil {
+		t.Fatalf("Unlock() = %v", err)
+	}
+	if err := b.Unlock(ctx, "/a", lock.Token); lockErrorCode(err) != http.StatusConflict {
+		t.Errorf("second Unlock() = %v, want status %v", err, http.StatusConflict)
+	}
+	if locks, _ := b.Locks(ctx, "/a"); len(locks) != 0 {
+		t.Errorf("Locks() after Unlock() = %+v", locks)
+	}
+}
+
+func TestMemLock_expiry(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var infinite memLock
+	infinite.setTimeout(TimeoutInfinite, now)
+	if infinite.expired(now.Add(100 * 365 * 24 * time.Hour)) {
+		t.Errorf("lock with an infinite timeout expired")
+	}
+	if got := lockCopy(&infinite, now).Timeout; got != TimeoutInfinite {
+		t.Errorf("lockCopy().Timeout = %v, want %v", got, TimeoutInfinite)
+	}
+
+	l := memLock{Lock: Lock{Token: "urn:uuid:a"}}
+	l.setTimeout(time.Minute, now)
+	if l.expired(now.Add(59 * time.Second)) {
+		t.Errorf("lock expired before its timeout")
+	}
+	if !l.expired(now.Add(time.Minute)) {
+		t.Errorf("lock didn't expire after its timeout")
+	}
+	if got := lockCopy(&l, now.Add(20*time.Second+300*time.Millisecond)).Timeout; got != 40*time.Second {
+		t.Errorf("lockCopy().Timeout = %v, want the remaining 40s", got)
+	}
+	if got := lockCopy(&l, now.Add(59*time.Second+900*time.Millisecond)).Timeout; got != time.Second {
+		t.Errorf("lockCopy().Timeout = %v, want at least 1s", got)
+	}
+
+	b := MemLockBackend{locks: map[string]*memLock{l.Token: &l}}
+	b.prune(now.Add(30 * time.Second))
+	if len(b.locks) != 1 {
+		t.Errorf("prune() removed a valid lock")
+	}
+	b.prune(now.Add(time.Minute))
+	if len(b.locks) != 0 {
+		t.Errorf("prune() kept an expired lock")
+	}
+}
+
+func TestMemLockBackend_expired(t *testing.T) {
+	ctx := context.Background()
+	var b MemLockBackend
+
+	lock, err := b.Lock(ctx, &Lock{Path: "/a", Timeout: time.Minute})
+	if err != nil {
+		t.Fatalf("Lock() = %v", err)
+	}
+	// Expire the lock
+	b.locks[lock.Token].expires = time.Now().Add(-time.Second)
+
+	if locks, _ := b.Locks(ctx, "/a"); len(locks) != 0 {
+		t.Errorf("Locks() returned expired locks: %+v", locks)
+	}
+	if _, err := b.Refresh(ctx, "/a", lock.Token, time.Minute); err == nil {
+		t.Errorf("Refresh() of an expired lock succeeded")
+	}
+	if _, err := b.Lock(ctx, &Lock{Path: "/a"}); err != nil {
+		t.Errorf("Lock() over an expired lock = %v", err)
+	}
+}
+
+func TestMemLock_defaultTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name           string
+		defaultTimeout time.Duration
+		timeout        time.Duration
+		want           time.Duration
+	}{
+		{"unspecified", 0, 0, defaultMemLockTimeout},
+		{"DefaultTimeout", time.Minute, 0, time.Minute},
+		{"infinite DefaultTimeout", TimeoutInfinite, 0, TimeoutInfinite},
+		{"requested", time.Minute, time.Hour, time.Hour},
+		{"requested infinite", 0, TimeoutInfinite, TimeoutInfinite},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := &MemLockBackend{DefaultTimeout: tc.defaultTimeout}
+			lock, err := b.Lock(ctx, &Lock{Path: "/a", Timeout: tc.timeout})
+			if err != nil {
+				t.Fatalf("Lock() = %v", err)
+			}
+			if lock.Timeout != tc.want {
+				t.Errorf("Lock().Timeout = %v, want %v", lock.Timeout, tc.want)
+			}
+			if expires := !b.locks[lock.Token].expires.IsZero(); expires != (tc.want != TimeoutInfinite) {
+				t.Errorf("lock expires = %v, want %v", expires, tc.want != TimeoutInfinite)
+			}
+
+			// Refreshing without a timeout applies the default as well
+			lock, err = b.Refresh(ctx, "/a", lock.Token, 0)
+			if err != nil {
+				t.Fatalf("Refresh() = %v", err)
+			}
+			want := tc.defaultTimeout
+			if want == 0 {
+				want = defaultMemLockTimeout
+			}
+			if lock.Timeout != want {
+				t.Errorf("Refresh().Timeout = %v, want %v", lock.Timeout, want)
+			}
+		})
+	}
+}
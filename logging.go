@@ -0,0 +1,143 @@
+//go:build go1.21
+// +build go1.21
+
+package webdav
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+type loggerKey struct{}
+
+// LoggerFromContext returns the logger attached to the request context by
+// WithLogger. Backends can use it to participate in the same log output. If
+// the context doesn't carry a logger, slog.Default is returned.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// WithLogger returns a middleware logging requests with logger. The method,
+// URL, response status code and duration of each request are logged, along
+// with the errors returned by the backend. If the RequestID middleware is
+// used before this one, the request ID is included as well.
+//
+// The logger is attached to the request context and can be retrieved with
+// LoggerFromContext.
+func WithLogger(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			l := logger
+			if id := internal.RequestIDFromContext(ctx); id != "" {
+				l = l.With(slog.String("request_id", id))
+			}
+
+			ctx = context.WithValue(ctx, loggerKey{}, l)
+			ctx = internal.ContextWithErrorLogger(ctx, func(err error) {
+				code := http.StatusInternalServerError
+				var httpErr *internal.HTTPError
+				if errors.As(err, &httpErr) {
+					code = httpErr.Code
+				}
+				level := slog.LevelDebug
+				if code/100 == 5 {
+					level = slog.LevelError
+				}
+				l.Log(ctx, level, "request failed", slog.Int("status", code), slog.Any("error", err))
+			})
+
+			sw := &statusResponseWriter{ResponseWriter: w}
+			start := time.Now()
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			status := sw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			level := slog.LevelInfo
+			if status/100 == 5 {
+				level = slog.LevelWarn
+			}
+			l.Log(ctx, level, "handled request",
+				slog.String("method", r.Method),
+				slog.String("url", r.URL.String()),
+				slog.Int("status", status),
+				slog.Duration("duration", time.Since(start)))
+		})
+	}
+}
+
+// Recover returns a middleware catching panics, e.g. in FileSystem
+// implementations. Panics are logged with logger along with a stack trace,
+// and a 500 Internal Server Error response is sent if the response hasn't
+// been started yet. If logger is nil, slog.Default is used.
+//
+// Panics with http.ErrAbortHandler aren't caught, so that the HTTP server can
+// abort the response.
+func Recover(logger *slog.Logger) Middleware {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &statusResponseWriter{ResponseWriter: w}
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				} else if v == http.ErrAbortHandler {
+					panic(v)
+				}
+
+				l := logger
+				if id := internal.RequestIDFromContext(r.Context()); id != "" {
+					l = l.With(slog.String("request_id", id))
+				}
+				l.ErrorContext(r.Context(), "panic serving request",
+					slog.String("method", r.Method),
+					slog.String("url", r.URL.String()),
+					slog.Any("panic", v),
+					slog.String("stack", string(debug.Stack())))
+
+				if sw.status == 0 {
+					internal.ServeRequestError(sw, r, internal.HTTPErrorf(http.StatusInternalServerError, "webdav: internal server error"))
+				}
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
+
+// statusResponseWriter records the status code of a response.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,183 @@
+//go:build go1.21
+// +build go1.21
+
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// brokenFileSystem fails to stat files.
+type brokenFileSystem struct {
+	FileSystem
+}
+
+func (brokenFileSystem) Stat(ctx context.Context, name string) (*FileInfo, error) {
+	return nil, errors.New("disk on fire")
+}
+
+// newTestLogger returns a logger writing JSON records at all levels to buf.
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+// logRecords decodes the JSON records written by a test logger.
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var rec map[string]interface{}
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatalf("failed to decode log record: %v", err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func TestWithLogger(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		broken bool
+		target string
+		// want lists the expected records as "level message status"
+		want []string
+	}{
+		{"ok", false, "/a/1.txt", []string{"INFO handled request 200"}},
+		{"not found", false, "/missing", []string{"DEBUG request failed 404", "INFO handled request 404"}},
+		{"server error", true, "/a/1.txt", []string{"ERROR request failed 500", "WARN handled request 500"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var fs FileSystem = newTestTree(t)
+			if tc.broken {
+				fs = brokenFileSystem{fs}
+			}
+
+			var buf bytes.Buffer
+			h := Chain(RequestID, WithLogger(newTestLogger(&buf)))(&Handler{FileSystem: fs})
+			w := serveTestRequest(h, http.MethodGet, tc.target, "", map[string]string{"X-Request-ID": "42"})
+
+			records := logRecords(t, &buf)
+			var got []string
+			for _, rec := range records {
+				got = append(got, rec["level"].(string)+" "+rec["msg"].(string)+" "+jsonNumberString(rec["status"]))
+				if rec["request_id"] != "42" {
+					t.Errorf("record %q has request_id %v, want 42", rec["msg"], rec["request_id"])
+				}
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("records = %q, want %q", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("record %v = %q, want %q", i, got[i], tc.want[i])
+				}
+			}
+
+			last := records[len(records)-1]
+			if last["method"] != http.MethodGet || last["url"] != tc.target || last["duration"] == nil {
+				t.Errorf("handled request record = %v, want method, url and duration", last)
+			}
+			if code := jsonNumberString(last["status"]); code != jsonNumberString(float64(w.Code)) {
+				t.Errorf("logged status %v, response status %v", code, w.Code)
+			}
+			if len(records) > 1 && records[0]["error"] == nil {
+				t.Errorf("request failed record = %v, want an error", records[0])
+			}
+		})
+	}
+}
+
+func jsonNumberString(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	if LoggerFromContext(context.Background()) != slog.Default() {
+		t.Errorf("LoggerFromContext() without a logger isn't slog.Default()")
+	}
+
+	var buf bytes.Buffer
+	h := WithLogger(newTestLogger(&buf))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		LoggerFromContext(r.Context()).Info("from backend")
+	}))
+	h = RequestID(h)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	records := logRecords(t, &buf)
+	if len(records) != 2 || records[0]["msg"] != "from backend" {
+		t.Fatalf("records = %v, want the backend record first", records)
+	}
+	if id := records[0]["request_id"]; id == nil || id == "" || id != records[1]["request_id"] {
+		t.Errorf("backend record request_id = %v, want %v", id, records[1]["request_id"])
+	}
+}
+
+func TestRecover(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// wroteHeader is set if the handler starts the response before
+		// panicking
+		wroteHeader bool
+		want        int
+	}{
+		{"before response", false, http.StatusInternalServerError},
+		{"after response", true, http.StatusAccepted},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := Chain(RequestID, Recover(newTestLogger(&buf)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.wroteHeader {
+					w.WriteHeader(http.StatusAccepted)
+				}
+				panic("oops")
+			}))
+
+			w := serveTestRequest(h, http.MethodGet, "/a/1.txt", "", map[string]string{"X-Request-ID": "42"})
+			if w.Code != tc.want {
+				t.Errorf("status = %v, want %v", w.Code, tc.want)
+			}
+			if !tc.wroteHeader && w.Header().Get("X-Request-ID") != "42" {
+				t.Errorf("error response X-Request-ID = %q, want 42", w.Header().Get("X-Request-ID"))
+			}
+
+			records := logRecords(t, &buf)
+			if len(records) != 1 {
+				t.Fatalf("records = %v, want 1", records)
+			}
+			rec := records[0]
+			if rec["level"] != "ERROR" || rec["panic"] != "oops" || rec["request_id"] != "42" || rec["url"] != "/a/1.txt" {
+				t.Errorf("record = %v, want the panic, request ID and URL", rec)
+			}
+			if stack, _ := rec["stack"].(string); !strings.Contains(stack, "TestRecover") {
+				t.Errorf("record stack = %q, want the panicking goroutine's stack", stack)
+			}
+		})
+	}
+}
+
+func TestRecover_abortHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h := Recover(newTestLogger(&buf))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("recover() = %v, want http.ErrAbortHandler", v)
+		}
+		if buf.Len() > 0 {
+			t.Errorf("http.ErrAbortHandler logged: %v", buf.String())
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -0,0 +1,171 @@
+package webdav
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+// Middleware wraps an HTTP handler, e.g. to add authentication or logging to
+// a Handler.
+type Middleware func(http.Handler) http.Handler
+
+// Chain composes middlewares. The first middleware is the outermost one: it
+// sees requests first and responses last. Chain with no arguments returns a
+// middleware leaving handlers unchanged.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(h http.Handler) http.Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			h = middlewares[i](h)
+		}
+		return h
+	}
+}
+
+// MaxBodySize returns a middleware limiting the size of request bodies to n
+// bytes. Requests with a larger body are rejected with a 413 Request Entity
+// Too Large error.
+//
+// The Handler already limits the size of XML request bodies (e.g. PROPFIND
+// and PROPPATCH) regardless of this middleware.
+func MaxBodySize(n int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				http.Error(w, "webdav: request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = internal.NewLimitedBody(r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequestID is a Middleware attaching a request ID to the request context.
+// The ID is taken from the X-Request-ID header field if present, otherwise a
+// random one is generated. Backends can retrieve it with
+// RequestIDFromContext, e.g. to include it in log lines. The Handler includes
+// it in error responses as an X-Request-ID header field.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" {
+			var b [16]byte
+			if _, err := rand.Read(b[:]); err != nil {
+				http.Error(w, "webdav: failed to generate request ID", http.StatusInternalServerError)
+				return
+			}
+			id = hex.EncodeToString(b[:])
+		}
+		ctx := internal.ContextWithRequestID(r.Context(), id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequestIDFromContext returns the request ID attached by the RequestID
+// middleware, or an empty string.
+func RequestIDFromContext(ctx context.Context) string {
+	return internal.RequestIDFromContext(ctx)
+}
+
+// corsDefaultMethods is the list of methods allowed by CORS when none is
+// specified.
+var corsDefaultMethods = []string{
+	http.MethodOptions, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete,
+	"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK", "REPORT", "ACL",
+}
+
+// corsExposedHeaders is the list of response header fields WebDAV clients
+// need to read.
+var corsExposedHeaders = []string{
+	"DAV", "Allow", "ETag", "Last-Modified", "Location", "Lock-Token", "Content-Range", "X-Request-ID",
+}
+
+// CORS returns a middleware allowing browser applications served from origins
+// to access the server, as defined by the Fetch standard. If origins is
+// ["*"], all origins are allowed. methods lists the methods allowed by
+// preflight requests. If it's nil, the methods supported by Handler are
+// allowed.
+//
+// Preflight OPTIONS requests are still passed to the next handler, so that the
+// response advertises the DAV capabilities. If the next handler fails, e.g.
+// because preflight requests don't carry credentials, a successful response
+// is sent regardless. The DAV header field is exposed
+// to browser applications, along with the other header fields used by WebDAV.
+func CORS(origins []string, methods []string) Middleware {
+	allowAll := false
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		if origin == "*" {
+			allowAll = true
+		}
+		allowed[origin] = true
+	}
+	if methods == nil {
+		methods = corsDefaultMethods
+	}
+	allowMethods := strings.Join(methods, ", ")
+	exposeHeaders := strings.Join(corsExposedHeaders, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" || (!allowAll && !allowed[origin]) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			if allowAll {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+				h.Add("Vary", "Origin")
+			}
+			h.Set("Access-Control-Expose-Headers", exposeHeaders)
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Set("Access-Control-Allow-Methods", allowMethods)
+				if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+					h.Set("Access-Control-Allow-Headers", reqHeaders)
+				}
+				if !allowAll {
+					h.Add("Vary", "Access-Control-Request-Method")
+					h.Add("Vary", "Access-Control-Request-Headers")
+				}
+
+				// Browsers don't send credentials with preflight requests:
+				// make sure they succeed even if the next handler requires
+				// authentication
+				w = &preflightResponseWriter{ResponseWriter: w}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// preflightResponseWriter replaces error statuses with 204 No Content.
+type preflightResponseWriter struct {
+	http.ResponseWriter
+	discard bool
+}
+
+func (w *preflightResponseWriter) WriteHeader(code int) {
+	if code/100 != 2 {
+		w.discard = true
+		code = http.StatusNoContent
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *preflightResponseWriter) Write(b []byte) (int, error) {
+	if w.discard {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,149 @@
+package webdav
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// traceMiddleware appends name to trace when a request enters and leaves the
+// middleware.
+func traceMiddleware(trace *[]string, name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*trace = append(*trace, "enter "+name)
+			next.ServeHTTP(w, r)
+			*trace = append(*trace, "leave "+name)
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	var trace []string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "handler")
+	})
+
+	Chain(traceMiddleware(&trace, "a"), traceMiddleware(&trace, "b"), traceMiddleware(&trace, "c"))(h).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := []string{"enter a", "enter b", "enter c", "handler", "leave c", "leave b", "leave a"}
+	if !reflect.DeepEqual(trace, want) {
+		t.Errorf("trace = %q, want %q", trace, want)
+	}
+
+	// Chain without middlewares leaves the handler unchanged
+	trace = nil
+	Chain()(h).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if want := []string{"handler"}; !reflect.DeepEqual(trace, want) {
+		t.Errorf("trace = %q, want %q", trace, want)
+	}
+}
+
+func TestCORS_preflight(t *testing.T) {
+	unauthorized := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+
+	for _, tc := range []struct {
+		name    string
+		origins []string
+		methods []string
+		next    http.Handler
+	}{
+		{"Handler", []string{"https://app.example"}, nil, &Handler{FileSystem: NewMemBackend()}},
+		{"unauthorized", []string{"https://app.example"}, nil, unauthorized},
+		{"wildcard", []string{"*"}, nil, unauthorized},
+		{"methods", []string{"https://app.example"}, []string{"PROPFIND", "REPORT"}, unauthorized},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := CORS(tc.origins, tc.methods)(tc.next)
+			w := serveTestRequest(h, http.MethodOptions, "/", "", map[string]string{
+				"Origin":                         "https://app.example",
+				"Access-Control-Request-Method":  "PROPFIND",
+				"Access-Control-Request-Headers": "Depth, Content-Type",
+			})
+
+			if w.Code/100 != 2 {
+				t.Errorf("status = %v, want a success", w.Code)
+			}
+			if w.Code == http.StatusNoContent && w.Body.Len() > 0 {
+				t.Errorf("204 response has a body: %q", w.Body.String())
+			}
+
+			wantOrigin := "https://app.example"
+			if tc.origins[0] == "*" {
+				wantOrigin = "*"
+			}
+			methods := tc.methods
+			if methods == nil {
+				methods = corsDefaultMethods
+			}
+			for k, want := range map[string]string{
+				"Access-Control-Allow-Origin":  wantOrigin,
+				"Access-Control-Allow-Methods": strings.Join(methods, ", "),
+				"Access-Control-Allow-Headers": "Depth, Content-Type",
+			} {
+				if v := w.Header().Get(k); v != want {
+					t.Errorf("%v = %q, want %q", k, v, want)
+				}
+			}
+			if !strings.Contains(w.Header().Get("Access-Control-Expose-Headers"), "DAV") {
+				t.Errorf("Access-Control-Expose-Headers = %q, want DAV", w.Header().Get("Access-Control-Expose-Headers"))
+			}
+
+			vary := w.Header()["Vary"]
+			if wantOrigin == "*" {
+				if len(vary) != 0 {
+					t.Errorf("Vary = %q, want none for wildcard origins", vary)
+				}
+			} else if want := []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}; !reflect.DeepEqual(vary, want) {
+				t.Errorf("Vary = %q, want %q", vary, want)
+			}
+
+			if _, ok := tc.next.(*Handler); ok && w.Header().Get("DAV") == "" {
+				t.Errorf("preflight response doesn't advertise DAV capabilities")
+			}
+		})
+	}
+}
+
+func TestCORS(t *testing.T) {
+	h := CORS([]string{"https://app.example"}, nil)(&Handler{FileSystem: newTestTree(t)})
+
+	// Requests from allowed origins can read the response
+	w := serveTestRequest(h, http.MethodGet, "/a/1.txt", "", map[string]string{"Origin": "https://app.example"})
+	if w.Code != http.StatusOK || w.Body.String() != "a/1.txt" {
+		t.Errorf("GET = %v %q, want 200 with the file", w.Code, w.Body.String())
+	}
+	if v := w.Header().Get("Access-Control-Allow-Origin"); v != "https://app.example" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the origin", v)
+	}
+	if v := w.Header().Get("Access-Control-Allow-Methods"); v != "" {
+		t.Errorf("Access-Control-Allow-Methods = %q in a non-preflight response", v)
+	}
+
+	// Other origins and same-origin requests get no CORS header fields
+	for _, origin := range []string{"https://evil.example", ""} {
+		w := serveTestRequest(h, http.MethodGet, "/missing", "", map[string]string{"Origin": origin})
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET from %q status = %v, want %v", origin, w.Code, http.StatusNotFound)
+		}
+		for k := range w.Header() {
+			if strings.HasPrefix(k, "Access-Control-") {
+				t.Errorf("response to %q has %v", origin, k)
+			}
+		}
+	}
+
+	// Plain OPTIONS requests aren't preflight requests
+	unauthorized := CORS([]string{"*"}, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	w = serveTestRequest(unauthorized, http.MethodOptions, "/", "", map[string]string{"Origin": "https://app.example"})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("OPTIONS without Access-Control-Request-Method status = %v, want %v", w.Code, http.StatusUnauthorized)
+	}
+}
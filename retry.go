@@ -0,0 +1,175 @@
+package webdav
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy describes how failed requests are retried by
+// HTTPClientWithRetry.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first
+	// one. Zero means 3.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry. It's doubled for each
+	// subsequent retry. Zero means 500 milliseconds.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between two attempts. Zero means no limit.
+	MaxDelay time.Duration
+	// Jitter is the maximum fraction of the delay added at random, e.g. 0.1
+	// adds up to 10% to each delay.
+	Jitter float64
+	// Retryable reports whether a request should be retried after receiving
+	// resp or err. If nil, DefaultRetryable is used.
+	Retryable func(resp *http.Response, err error) bool
+	// RetryNonIdempotent allows requests using non-idempotent methods, such
+	// as POST, LOCK, MKCOL, COPY or MOVE, to be retried. By default they
+	// aren't, since the server may have processed a failed attempt.
+	RetryNonIdempotent bool
+}
+
+// isIdempotent reports whether a request method is idempotent, as defined in
+// RFC 7231 section 4.2.2 and RFC 4918.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	case "PROPFIND", "PROPPATCH", "REPORT", "ACL":
+		return true
+	}
+	return false
+}
+
+// DefaultRetryable reports whether a request should be retried: it returns
+// true for 502 Bad Gateway, 503 Service Unavailable and 504 Gateway Timeout
+// responses, and for connections reset or closed by the server.
+func DefaultRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+type retryHTTPClient struct {
+	c      HTTPClient
+	policy RetryPolicy
+}
+
+func (c *retryHTTPClient) delay(attempt int, resp *http.Response) time.Duration {
+	delay := c.policy.BaseDelay
+	if delay <= 0 {
+		delay = 500 * time.Millisecond
+	}
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if c.policy.MaxDelay > 0 && delay > c.policy.MaxDelay {
+			break
+		}
+	}
+	if c.policy.MaxDelay > 0 && delay > c.policy.MaxDelay {
+		delay = c.policy.MaxDelay
+	}
+	if c.policy.Jitter > 0 {
+		delay += time.Duration(rand.Float64() * c.policy.Jitter * float64(delay))
+	}
+
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok && retryAfter > delay {
+			delay = retryAfter
+		}
+	}
+	return delay
+}
+
+// parseRetryAfter parses a Retry-After header field, as defined in RFC 7231
+// section 7.1.3.
+func parseRetryAfter(s string) (time.Duration, bool) {
+	if s == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(s); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(s); err == nil {
+		return time.Until(t), true
+	}
+	return 0, false
+}
+
+func (c *retryHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	maxAttempts := c.policy.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+	retryable := c.policy.Retryable
+	if retryable == nil {
+		retryable = DefaultRetryable
+	}
+
+	// The request body can only be sent again if it can be re-created
+	canRetry := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	if !c.policy.RetryNonIdempotent && !isIdempotent(req.Method) {
+		canRetry = false
+	}
+
+	ctx := req.Context()
+	attemptReq := req
+	for attempt := 1; ; attempt++ {
+		resp, err := c.c.Do(attemptReq)
+		if attempt >= maxAttempts || !canRetry || ctx.Err() != nil || !retryable(resp, err) {
+			return resp, err
+		}
+
+		delay := c.delay(attempt, resp)
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+
+		attemptReq = req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq.Body = body
+		}
+	}
+}
+
+// HTTPClientWithRetry returns an HTTP client that retries failed requests
+// according to policy, with an exponential backoff. The Retry-After header is
+// honored. If c is nil, http.DefaultClient is used. If policy is nil, the
+// default policy is used.
+//
+// Requests whose body cannot be re-created (see http.Request.GetBody) are
+// never retried. PutOptions.GetBody can be used to allow uploads to be
+// retried. Requests using non-idempotent methods are only retried if
+// RetryPolicy.RetryNonIdempotent is set.
+func HTTPClientWithRetry(c HTTPClient, policy *RetryPolicy) HTTPClient {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	if policy == nil {
+		policy = new(RetryPolicy)
+	}
+	return &retryHTTPClient{c: c, policy: *policy}
+}
@@ -0,0 +1,235 @@
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// stubHTTPClient replies to the nth request with the result of fn(n). It
+// records the bodies of the requests.
+type stubHTTPClient struct {
+	fn     func(n int) (*http.Response, error)
+	bodies []string
+}
+
+func (c *stubHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	body := ""
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = string(b)
+	}
+	c.bodies = append(c.bodies, body)
+	return c.fn(len(c.bodies))
+}
+
+func stubResponse(code int, header http.Header) *http.Response {
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		StatusCode: code,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}
+}
+
+// failUntil returns a stubHTTPClient function failing with err, or with a
+// 503 response if err is nil, until the nth attempt.
+func failUntil(n int, err error) func(int) (*http.Response, error) {
+	return func(attempt int) (*http.Response, error) {
+		if attempt >= n {
+			return stubResponse(http.StatusOK, nil), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return stubResponse(http.StatusServiceUnavailable, nil), nil
+	}
+}
+
+var fastRetryPolicy = RetryPolicy{BaseDelay: time.Millisecond}
+
+func TestHTTPClientWithRetry(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		method       string
+		err          error
+		policy       RetryPolicy
+		wantAttempts int
+		wantCode     int
+	}{
+		{"GET 503", http.MethodGet, nil, fastRetryPolicy, 3, http.StatusOK},
+		{"PROPFIND reset", "PROPFIND", syscall.ECONNRESET, fastRetryPolicy, 3, http.StatusOK},
+		{"DELETE EOF", http.MethodDelete, io.EOF, fastRetryPolicy, 3, http.StatusOK},
+		{"max attempts", http.MethodGet, nil, RetryPolicy{BaseDelay: time.Millisecond, MaxAttempts: 2}, 2, http.StatusServiceUnavailable},
+		{"POST", http.MethodPost, nil, fastRetryPolicy, 1, http.StatusServiceUnavailable},
+		{"LOCK", "LOCK", io.EOF, fastRetryPolicy, 1, 0},
+		{"MOVE", "MOVE", nil, fastRetryPolicy, 1, http.StatusServiceUnavailable},
+		{"COPY", "COPY", syscall.ECONNRESET, fastRetryPolicy, 1, 0},
+		{"MOVE RetryNonIdempotent", "MOVE", nil, RetryPolicy{BaseDelay: time.Millisecond, RetryNonIdempotent: true}, 3, http.StatusOK},
+		{"not retryable", http.MethodGet, syscall.ECONNREFUSED, fastRetryPolicy, 1, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stub := &stubHTTPClient{fn: failUntil(3, tc.err)}
+			c := HTTPClientWithRetry(stub, &tc.policy)
+
+			req, err := http.NewRequest(tc.method, "http://example.org/file", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := c.Do(req)
+			if len(stub.bodies) != tc.wantAttempts {
+				t.Errorf("got %v attempts, want %v", len(stub.bodies), tc.wantAttempts)
+			}
+			if tc.wantCode == 0 {
+				if err == nil {
+					t.Errorf("Do() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Do() = %v", err)
+			}
+			if resp.StatusCode != tc.wantCode {
+				t.Errorf("status = %v, want %v", resp.StatusCode, tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestHTTPClientWithRetry_body(t *testing.T) {
+	stub := &stubHTTPClient{fn: failUntil(3, nil)}
+	c := HTTPClientWithRetry(stub, &fastRetryPolicy)
+
+	// http.NewRequest populates GetBody for a *bytes.Reader
+	req, err := http.NewRequest(http.MethodPut, "http://example.org/file", bytes.NewReader([]byte("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Do(req); err != nil {
+		t.Fatalf("Do() = %v", err)
+	}
+	if len(stub.bodies) != 3 {
+		t.Fatalf("got %v attempts, want 3", len(stub.bodies))
+	}
+	for i, body := range stub.bodies {
+		if body != "hello" {
+			t.Errorf("attempt %v body = %q, want %q", i+1, body, "hello")
+		}
+	}
+
+	// A body which can't be re-created isn't sent again
+	stub = &stubHTTPClient{fn: failUntil(3, nil)}
+	c = HTTPClientWithRetry(stub, &fastRetryPolicy)
+	req, err = http.NewRequest(http.MethodPut, "http://example.org/file", ioutil.NopCloser(strings.NewReader("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("Do() = %v", err)
+	}
+	if len(stub.bodies) != 1 || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got %v attempts and status %v, want a single attempt", len(stub.bodies), resp.StatusCode)
+	}
+}
+
+func TestHTTPClientWithRetry_retryAfter(t *testing.T) {
+	stub := &stubHTTPClient{fn: func(n int) (*http.Response, error) {
+		if n == 1 {
+			return stubResponse(http.StatusServiceUnavailable, http.Header{"Retry-After": {"1"}}), nil
+		}
+		return stubResponse(http.StatusOK, nil), nil
+	}}
+	c := HTTPClientWithRetry(stub, &fastRetryPolicy)
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.org/file", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err := c.Do(req); err != nil {
+		t.Fatalf("Do() = %v", err)
+	}
+	if d := time.Since(start); d < time.Second {
+		t.Errorf("retried after %v, want at least 1s", d)
+	}
+
+	// The context is honored while waiting
+	stub = &stubHTTPClient{fn: func(n int) (*http.Response, error) {
+		return stubResponse(http.StatusServiceUnavailable, http.Header{"Retry-After": {"60"}}), nil
+	}}
+	c = HTTPClientWithRetry(stub, &fastRetryPolicy)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, "http://example.org/file", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Do(req); err != context.DeadlineExceeded {
+		t.Errorf("Do() = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	for _, tc := range []struct {
+		s      string
+		min    time.Duration
+		max    time.Duration
+		wantOK bool
+	}{
+		{"120", 120 * time.Second, 120 * time.Second, true},
+		{"0", 0, 0, true},
+		{future, 59 * time.Minute, time.Hour, true},
+		{"", 0, 0, false},
+		{"-1", 0, 0, false},
+		{"soon", 0, 0, false},
+	} {
+		d, ok := parseRetryAfter(tc.s)
+		if ok != tc.wantOK || (ok && (d < tc.min || d > tc.max)) {
+			t.Errorf("parseRetryAfter(%q) = %v, %v", tc.s, d, ok)
+		}
+	}
+}
+
+func TestRetryHTTPClient_delay(t *testing.T) {
+	c := &retryHTTPClient{policy: RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}}
+	for attempt, want := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 5 * time.Second,
+		9: 5 * time.Second,
+	} {
+		if d := c.delay(attempt, nil); d != want {
+			t.Errorf("delay(%v) = %v, want %v", attempt, d, want)
+		}
+	}
+
+	resp := stubResponse(http.StatusServiceUnavailable, http.Header{"Retry-After": {"30"}})
+	if d := c.delay(1, resp); d != 30*time.Second {
+		t.Errorf("delay() with Retry-After = %v, want %v", d, 30*time.Second)
+	}
+	resp.Header.Set("Retry-After", "0")
+	if d := c.delay(1, resp); d != time.Second {
+		t.Errorf("delay() with a shorter Retry-After = %v, want %v", d, time.Second)
+	}
+
+	c.policy.Jitter = 0.5
+	for i := 0; i < 10; i++ {
+		if d := c.delay(1, nil); d < time.Second || d > 1500*time.Millisecond {
+			t.Errorf("delay() with jitter = %v, want between 1s and 1.5s", d)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package webdav
+
+import (
+	"crypto/tls"
+	"net/http"
+)
+
+// ListenAndServeTLS listens on the TCP network address addr and serves
+// handler over TLS, with HTTP/2 enabled. Clients which don't support HTTP/2
+// fall back to HTTP/1.1. certFile and keyFile are the paths to the server's
+// certificate and private key, as for http.ListenAndServeTLS.
+//
+// HTTP/2 requests usually don't carry a Content-Length header field: the
+// Handler doesn't rely on it when reading request bodies.
+//
+// Cleartext HTTP/2 (h2c) isn't supported by the standard library: serve
+// handler with golang.org/x/net/http2/h2c for that purpose.
+func ListenAndServeTLS(addr, certFile, keyFile string, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: handler,
+		TLSConfig: &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			NextProtos: []string{"h2", "http/1.1"},
+		},
+	}
+	return srv.ListenAndServeTLS(certFile, keyFile)
+}
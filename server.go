@@ -3,16 +3,25 @@ package webdav
 import (
 	"context"
 	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/emersion/go-webdav/internal"
 )
 
 // FileSystem is a WebDAV server backend.
+//
+// If the file returned by Open implements io.Seeker, range requests are served
+// efficiently.
 type FileSystem interface {
 	Open(ctx context.Context, name string) (io.ReadCloser, error)
 	Stat(ctx context.Context, name string) (*FileInfo, error)
@@ -24,10 +33,197 @@ type FileSystem interface {
 	Move(ctx context.Context, name, dest string, options *MoveOptions) (created bool, err error)
 }
 
+// WalkFileSystem is an optional interface a FileSystem can implement to
+// enumerate the descendants of a collection for PROPFIND requests with the
+// "Depth: infinity" header.
+type WalkFileSystem interface {
+	// WalkFS calls fn for root and each of its descendants, up to depth
+	// levels below root. A negative depth means that there is no limit.
+	// Implementations must not loop forever on circular links.
+	WalkFS(ctx context.Context, root string, depth int, fn func(path string, info FileInfo) error) error
+}
+
+// PropPatcher is an optional interface a FileSystem can implement to support
+// PROPPATCH requests. The update must be applied atomically: either all of the
+// properties are updated, or none of them are.
+type PropPatcher interface {
+	PropPatch(ctx context.Context, name string, req *PropPatchRequest) error
+}
+
+// CollectionCreator is an optional interface a FileSystem can implement to
+// create collections along with their initial properties, as requested by
+// extended MKCOL requests (RFC 5689). MKCOL requests without a body still use
+// FileSystem.Mkdir.
+//
+// CollectionCreator isn't used when Handler.DeadPropsStore is set, since the
+// properties belong to the DeadPropsStore then: collections are created with
+// FileSystem.Mkdir before their properties are stored.
+type CollectionCreator interface {
+	// CreateCollection creates a collection and sets its initial properties.
+	// It must be atomic: if a property can't be set, the collection must not
+	// be created.
+	CreateCollection(ctx context.Context, name string, props []Property) error
+}
+
+// PartialWriter is an optional interface a FileSystem can implement to
+// support PUT requests with a Content-Range header field, used to resume
+// interrupted uploads.
+type PartialWriter interface {
+	// WriteAt writes body at offset off of a file, creating the file if
+	// necessary. size is the total size of the file, or -1 if unknown: once
+	// the last range has been written, the file must be truncated to size. If
+	// off is beyond the end of the file, an HTTP 416 error should be
+	// returned.
+	WriteAt(ctx context.Context, name string, body io.ReadCloser, off, size int64) (fileInfo *FileInfo, created bool, err error)
+}
+
+// PropFinder is an optional interface a FileSystem can implement to serve
+// properties in addition to the ones derived from FileInfo, e.g. the ones
+// updated by PropPatcher. They're returned for allprop and propname PROPFIND
+// requests, and for prop requests naming them.
+type PropFinder interface {
+	// Props returns the additional properties of a file.
+	Props(ctx context.Context, name string) ([]Property, error)
+}
+
+// QuotaFileSystem is an optional interface a FileSystem can implement to
+// report the quota properties defined in RFC 4331.
+type QuotaFileSystem interface {
+	// Quota returns the number of bytes used by a file, and the number of
+	// bytes still available to it. A negative value omits the corresponding
+	// property.
+	Quota(ctx context.Context, name string) (used, available int64, err error)
+}
+
+// OptionsFileSystem is an optional interface a FileSystem can implement to
+// customize the response to OPTIONS requests.
+type OptionsFileSystem interface {
+	// Options returns the capabilities of a file. If it returns nil, the
+	// capabilities are derived from the FileSystem and the Handler
+	// configuration.
+	Options(ctx context.Context, name string) (*CapabilitySet, error)
+}
+
+// SyncFileSystem is an optional interface a FileSystem can implement to
+// support sync-collection REPORT requests, as defined in RFC 6578.
+type SyncFileSystem interface {
+	// SyncToken returns the current sync token of a collection. The token
+	// must change whenever a member of the collection is created, updated or
+	// deleted.
+	SyncToken(ctx context.Context, name string) (string, error)
+	// SyncCollection returns the members of a collection which have changed
+	// since query.SyncToken, along with the current sync token. Deleted
+	// members are listed by path. If the sync token is unknown or has
+	// expired, ErrInvalidSyncToken should be returned.
+	//
+	// If query.Limit is positive and there are more changes, the response
+	// can be truncated to query.Limit changes as described in RFC 6578
+	// section 3.6, with SyncResponse.Truncated set. Otherwise, a result over
+	// the limit is rejected with the DAV:number-of-matches-within-limits
+	// precondition.
+	SyncCollection(ctx context.Context, name string, query *SyncQuery) (*SyncResponse, error)
+}
+
+// Principal describes a principal, as defined in RFC 3744 section 2. A
+// principal is a resource representing a user or a group.
+type Principal struct {
+	// URL is the canonical URL path of the principal.
+	URL string
+	// AlternateURIs contains other URIs identifying the principal, e.g.
+	// "mailto:" URIs.
+	AlternateURIs []string
+	// Members contains the URL paths of the members of a group principal.
+	Members []string
+	// Memberships contains the URL paths of the groups the principal is a
+	// member of.
+	Memberships []string
+}
+
+// ACLBackend is an optional interface a FileSystem can implement to support
+// access control, as defined in RFC 3744.
+type ACLBackend interface {
+	// Principal returns the principal located at name, or nil if the file
+	// isn't a principal.
+	Principal(ctx context.Context, name string) (*Principal, error)
+	// GetACL returns the access control list of a file, served in the
+	// DAV:acl and DAV:acl-restrictions properties.
+	GetACL(ctx context.Context, name string) (*ACL, error)
+	// SetACL replaces the access control list of a file. It is called for
+	// ACL requests. Protected and inherited ACEs are not included in acl.
+	SetACL(ctx context.Context, name string, acl *ACL) error
+}
+
+// Lock describes a write lock held on a file.
+type Lock struct {
+	// Path is the root of the lock.
+	Path  string
+	Token string
+	// Shared indicates a shared lock, as opposed to an exclusive lock.
+	Shared bool
+	// Recursive indicates that the lock applies to all members of a
+	// collection ("Depth: infinity").
+	Recursive bool
+	// Owner contains the raw XML describing the owner of the lock, as
+	// supplied by the client.
+	Owner string
+	// Timeout is the duration after which the lock expires. Zero means that
+	// the timeout is unspecified, TimeoutInfinite means that the lock never
+	// expires.
+	Timeout time.Duration
+}
+
+// LockBackend stores the locks held on the files of a FileSystem. It is
+// used by Handler to support the LOCK and UNLOCK methods.
+type LockBackend interface {
+	// Lock creates a new lock and returns it populated with a unique token.
+	// If the lock conflicts with an existing one, an HTTP 423 error should be
+	// returned.
+	Lock(ctx context.Context, lock *Lock) (*Lock, error)
+	// Refresh resets the timeout of an existing lock.
+	Refresh(ctx context.Context, name, token string, timeout time.Duration) (*Lock, error)
+	// Unlock removes an existing lock.
+	Unlock(ctx context.Context, name, token string) error
+	// Locks returns the locks applying to a file, including recursive locks
+	// held on its parent collections. Expired locks must not be returned.
+	Locks(ctx context.Context, name string) ([]Lock, error)
+}
+
+// DeadPropsStore stores dead properties, i.e. arbitrary properties set by
+// clients with PROPPATCH requests, keyed by the path of the file they belong
+// to. It is used by Handler to apply PROPPATCH requests and to include the
+// stored properties in PROPFIND responses.
+type DeadPropsStore interface {
+	// GetDeadProps returns the dead properties of a file.
+	GetDeadProps(ctx context.Context, name string) ([]Property, error)
+	// SetDeadProps creates or replaces dead properties of a file.
+	SetDeadProps(ctx context.Context, name string, props []Property) error
+	// RemoveDeadProps removes dead properties of a file. If names is nil, all
+	// of the properties are removed.
+	RemoveDeadProps(ctx context.Context, name string, names []xml.Name) error
+}
+
 // Handler handles WebDAV HTTP requests. It can be used to create a WebDAV
 // server.
 type Handler struct {
 	FileSystem FileSystem
+	// LockBackend enables support for locking if non-nil.
+	LockBackend LockBackend
+	// DeadPropsStore enables support for dead properties if non-nil. It
+	// takes precedence over the FileSystem's PropPatcher and
+	// CollectionCreator implementations.
+	DeadPropsStore DeadPropsStore
+	// MaxDepth limits the number of levels enumerated for PROPFIND requests
+	// with the "Depth: infinity" header. Zero means no limit.
+	MaxDepth int
+	// DisableDepthInfinity rejects PROPFIND requests with the
+	// "Depth: infinity" header on collections, with a DAV:propfind-finite-depth
+	// error, as allowed by RFC 4918 section 9.1.
+	DisableDepthInfinity bool
+	// RequireConditionalPut rejects PUT requests replacing an existing file
+	// without an If-Match or If-None-Match header with 428 Precondition
+	// Required, as defined in RFC 6585 section 3. This prevents clients from
+	// blindly overwriting concurrent updates.
+	RequireConditionalPut bool
 }
 
 // ServeHTTP implements http.Handler.
@@ -37,7 +233,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	b := backend{h.FileSystem}
+	b := backend{h.FileSystem, h.LockBackend, h.DeadPropsStore, h.MaxDepth, h.DisableDepthInfinity, h.RequireConditionalPut}
 	hh := internal.Handler{Backend: &b}
 	hh.ServeHTTP(w, r)
 }
@@ -52,13 +248,39 @@ func NewHTTPError(statusCode int, cause error) error {
 }
 
 type backend struct {
-	FileSystem FileSystem
+	FileSystem            FileSystem
+	LockBackend           LockBackend
+	DeadPropsStore        DeadPropsStore
+	MaxDepth              int
+	DisableDepthInfinity  bool
+	RequireConditionalPut bool
 }
 
 func (b *backend) Options(r *http.Request) (caps []string, allow []string, err error) {
+	if ofs, ok := b.FileSystem.(OptionsFileSystem); ok {
+		set, err := ofs.Options(r.Context(), r.URL.Path)
+		if err != nil {
+			return nil, nil, err
+		} else if set != nil {
+			return set.DAV, set.Allow, nil
+		}
+	}
+
+	if b.LockBackend != nil {
+		caps = []string{"2"}
+	}
+	_, hasACL := b.FileSystem.(ACLBackend)
+	if hasACL {
+		caps = append(caps, "access-control")
+	}
+
 	fi, err := b.FileSystem.Stat(r.Context(), r.URL.Path)
 	if internal.IsNotFound(err) {
-		return nil, []string{http.MethodOptions, http.MethodPut, "MKCOL"}, nil
+		allow = []string{http.MethodOptions, http.MethodPut, "MKCOL"}
+		if b.LockBackend != nil {
+			allow = append(allow, "LOCK")
+		}
+		return caps, allow, nil
 	} else if err != nil {
 		return nil, nil, err
 	}
@@ -73,9 +295,20 @@ func (b *backend) Options(r *http.Request) (caps []string, allow []string, err e
 
 	if !fi.IsDir {
 		allow = append(allow, http.MethodHead, http.MethodGet, http.MethodPut)
+	} else if _, ok := b.FileSystem.(SyncFileSystem); ok {
+		allow = append(allow, "REPORT")
+	}
+	if _, ok := b.FileSystem.(PropPatcher); ok || b.DeadPropsStore != nil {
+		allow = append(allow, "PROPPATCH")
+	}
+	if b.LockBackend != nil {
+		allow = append(allow, "LOCK", "UNLOCK")
+	}
+	if hasACL {
+		allow = append(allow, "ACL")
 	}
 
-	return nil, allow, nil
+	return caps, allow, nil
 }
 
 func (b *backend) HeadGet(w http.ResponseWriter, r *http.Request) error {
@@ -86,6 +319,26 @@ func (b *backend) HeadGet(w http.ResponseWriter, r *http.Request) error {
 	if fi.IsDir {
 		return &internal.HTTPError{Code: http.StatusMethodNotAllowed}
 	}
+	fillETag(fi)
+	if fi.Language == "" && b.DeadPropsStore != nil {
+		// Failing to read the language doesn't prevent serving the file
+		if deadProps, err := b.DeadPropsStore.GetDeadProps(r.Context(), fi.Path); err == nil {
+			fi.Language = deadPropsLanguage(deadProps)
+		}
+	}
+
+	if err := evalPreconditions(r, fi); err == errNotModified {
+		if !fi.ModTime.IsZero() {
+			w.Header().Set("Last-Modified", fi.ModTime.UTC().Format(http.TimeFormat))
+		}
+		if fi.ETag != "" {
+			w.Header().Set("ETag", internal.ETag(fi.ETag).String())
+		}
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	} else if err != nil {
+		return err
+	}
 
 	f, err := b.FileSystem.Open(r.Context(), r.URL.Path)
 	if err != nil {
@@ -93,10 +346,12 @@ func (b *backend) HeadGet(w http.ResponseWriter, r *http.Request) error {
 	}
 	defer f.Close()
 
-	w.Header().Set("Content-Length", strconv.FormatInt(fi.Size, 10))
 	if fi.MIMEType != "" {
 		w.Header().Set("Content-Type", fi.MIMEType)
 	}
+	if fi.Language != "" {
+		w.Header().Set("Content-Language", fi.Language)
+	}
 	if !fi.ModTime.IsZero() {
 		w.Header().Set("Last-Modified", fi.ModTime.UTC().Format(http.TimeFormat))
 	}
@@ -104,63 +359,378 @@ func (b *backend) HeadGet(w http.ResponseWriter, r *http.Request) error {
 		w.Header().Set("ETag", internal.ETag(fi.ETag).String())
 	}
 
-	if rs, ok := f.(io.ReadSeeker); ok {
-		// If it's an io.Seeker, use http.ServeContent which supports ranges
+	// http.ServeContent supports ranges. If the file isn't an io.Seeker,
+	// emulate forward seeking by discarding data, which is enough for
+	// single-range requests as long as the size is known and the content
+	// type doesn't need to be sniffed.
+	rs, ok := f.(io.ReadSeeker)
+	if !ok && fi.MIMEType != "" && fi.Size > 0 && !strings.Contains(r.Header.Get("Range"), ",") {
+		rs, ok = &forwardSeeker{r: f, size: fi.Size}, true
+	}
+	if ok {
 		http.ServeContent(w, r, r.URL.Path, fi.ModTime, rs)
-	} else {
-		if r.Method != http.MethodHead {
-			io.Copy(w, f)
-		}
+		return nil
+	}
+
+	if fi.Size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(fi.Size, 10))
+	}
+	if r.Method != http.MethodHead {
+		io.Copy(w, f)
 	}
 	return nil
 }
 
+// forwardSeeker is an io.ReadSeeker for a reader of a known size which only
+// supports seeking forward.
+type forwardSeeker struct {
+	r        io.Reader
+	size     int64
+	pos      int64 // position requested by Seek
+	consumed int64 // number of bytes read from r
+}
+
+func (fs *forwardSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += fs.pos
+	case io.SeekEnd:
+		offset += fs.size
+	}
+	if offset < fs.consumed {
+		return 0, fmt.Errorf("webdav: cannot seek backwards")
+	}
+	fs.pos = offset
+	return offset, nil
+}
+
+func (fs *forwardSeeker) Read(p []byte) (int, error) {
+	if fs.pos > fs.consumed {
+		n, err := io.CopyN(ioutil.Discard, fs.r, fs.pos-fs.consumed)
+		fs.consumed += n
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := fs.r.Read(p)
+	fs.consumed += int64(n)
+	fs.pos = fs.consumed
+	return n, err
+}
+
 func (b *backend) PropFind(r *http.Request, propfind *internal.PropFind, depth internal.Depth) (*internal.MultiStatus, error) {
+	var resps []internal.Response
+	err := b.propFindWalk(r, propfind, depth, func(resp *internal.Response) error {
+		resps = append(resps, *resp)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return internal.NewMultiStatus(resps...), nil
+}
+
+func (b *backend) PropFindWrite(r *http.Request, propfind *internal.PropFind, depth internal.Depth, mw *internal.MultiStatusWriter) error {
+	return b.propFindWalk(r, propfind, depth, mw.WriteResponse)
+}
+
+// propFindWalk calls fn with the response for each file matched by a PROPFIND
+// request.
+func (b *backend) propFindWalk(r *http.Request, propfind *internal.PropFind, depth internal.Depth, fn func(*internal.Response) error) error {
 	// TODO: use partial error Response on error
 
 	fi, err := b.FileSystem.Stat(r.Context(), r.URL.Path)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var resps []internal.Response
-	if depth != internal.DepthZero && fi.IsDir {
-		children, err := b.FileSystem.ReadDir(r.Context(), r.URL.Path, depth == internal.DepthInfinity)
+	if depth == internal.DepthInfinity && fi.IsDir {
+		if b.DisableDepthInfinity {
+			return &internal.HTTPError{
+				Code: http.StatusForbidden,
+				Err: &internal.Error{Raw: []internal.RawXMLValue{
+					*internal.NewRawXMLElement(internal.PropFindFiniteDepthName, nil, nil),
+				}},
+			}
+		}
+
+		maxDepth := b.MaxDepth
+		if maxDepth <= 0 {
+			maxDepth = -1
+		}
+		return b.walk(r.Context(), r.URL.Path, maxDepth, func(p string, fi FileInfo) error {
+			resp, err := b.propFindFile(r.Context(), propfind, &fi)
+			if err != nil {
+				return err
+			}
+			return fn(resp)
+		})
+	} else if depth == internal.DepthOne && fi.IsDir {
+		children, err := b.FileSystem.ReadDir(r.Context(), r.URL.Path, false)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		resps = make([]internal.Response, len(children))
-		for i, child := range children {
-			resp, err := b.propFindFile(propfind, &child)
+		for i := range children {
+			resp, err := b.propFindFile(r.Context(), propfind, &children[i])
 			if err != nil {
-				return nil, err
+				return err
+			}
+			if err := fn(resp); err != nil {
+				return err
 			}
-			resps[i] = *resp
 		}
-	} else {
-		resp, err := b.propFindFile(propfind, fi)
+		return nil
+	}
+
+	resp, err := b.propFindFile(r.Context(), propfind, fi)
+	if err != nil {
+		return err
+	}
+	return fn(resp)
+}
+
+func (b *backend) HandleReport(ctx context.Context, r *internal.ReportRequest) (*internal.MultiStatus, error) {
+	sfs, ok := b.FileSystem.(SyncFileSystem)
+	if !ok {
+		return nil, internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: REPORT not supported")
+	}
+	if r.Name != internal.SyncCollectionName {
+		return nil, internal.HTTPErrorf(http.StatusForbidden, "webdav: unsupported report %v", r.Name)
+	}
+	if r.Depth != internal.DepthZero {
+		return nil, internal.HTTPErrorf(http.StatusBadRequest, "webdav: sync-collection REPORT requires Depth: 0")
+	}
+
+	var sync internal.SyncCollectionQuery
+	if err := r.Raw.Decode(&sync); err != nil {
+		return nil, &internal.HTTPError{http.StatusBadRequest, err}
+	}
+
+	q := SyncQuery{SyncToken: sync.SyncToken}
+	switch sync.SyncLevel {
+	case internal.SyncLevelOne:
+		// Only immediate members
+	case internal.SyncLevelInfinite:
+		q.Recursive = true
+	default:
+		return nil, internal.HTTPErrorf(http.StatusBadRequest, "webdav: invalid sync-level %q", sync.SyncLevel)
+	}
+	if sync.Limit != nil {
+		q.Limit = int(sync.Limit.NResults)
+	}
+
+	fi, err := b.FileSystem.Stat(ctx, r.Path)
+	if err != nil {
+		return nil, err
+	} else if !fi.IsDir {
+		return nil, internal.HTTPErrorf(http.StatusForbidden, "webdav: sync-collection REPORT requires a collection")
+	}
+
+	res, err := sfs.SyncCollection(ctx, r.Path, &q)
+	if errors.Is(err, ErrInvalidSyncToken) {
+		return nil, &internal.HTTPError{
+			Code: http.StatusForbidden,
+			Err: &internal.Error{Raw: []internal.RawXMLValue{
+				*internal.NewRawXMLElement(internal.ValidSyncTokenName, nil, nil),
+			}},
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if q.Limit > 0 && len(res.Updated)+len(res.Deleted) > q.Limit {
+		// The result can't be truncated here, since the sync token must
+		// identify the state after the last returned change
+		return nil, &internal.HTTPError{
+			Code: http.StatusInsufficientStorage,
+			Err: &internal.Error{Raw: []internal.RawXMLValue{
+				*internal.NewRawXMLElement(internal.NumberOfMatchesWithinLimitsName, nil, nil),
+			}},
+		}
+	}
+
+	propfind := internal.PropFind{Prop: sync.Prop}
+	if propfind.Prop == nil {
+		propfind.Prop = &internal.Prop{}
+	}
+
+	var resps []internal.Response
+	for i := range res.Updated {
+		resp, err := b.propFindFile(ctx, &propfind, &res.Updated[i])
 		if err != nil {
 			return nil, err
 		}
+		resps = append(resps, *resp)
+	}
+	for _, p := range res.Deleted {
+		resps = append(resps, internal.Response{
+			Hrefs:  []internal.Href{{Path: p}},
+			Status: &internal.Status{Code: http.StatusNotFound},
+		})
+	}
+	if res.Truncated {
+		// https://tools.ietf.org/html/rfc6578#section-3.6
+		resps = append(resps, internal.Response{
+			Hrefs:  []internal.Href{{Path: r.Path}},
+			Status: &internal.Status{Code: http.StatusInsufficientStorage},
+		})
+	}
+
+	ms := internal.NewMultiStatus(resps...)
+	ms.SyncToken = res.SyncToken
+	return ms, nil
+}
 
-		resps = []internal.Response{*resp}
+// walk calls fn for root and its descendants, up to depth levels below root.
+// A negative depth means that there is no limit.
+func (b *backend) walk(ctx context.Context, root string, depth int, fn func(p string, fi FileInfo) error) error {
+	if wfs, ok := b.FileSystem.(WalkFileSystem); ok {
+		return wfs.WalkFS(ctx, root, depth, fn)
 	}
 
-	return internal.NewMultiStatus(resps...), nil
+	fi, err := b.FileSystem.Stat(ctx, root)
+	if err != nil {
+		return err
+	}
+	if err := fn(fi.Path, *fi); err != nil {
+		return err
+	}
+
+	// Keep track of the visited collections to detect loops, e.g. caused by
+	// a file system returning a parent collection as a child
+	visited := map[string]bool{walkKey(fi.Path): true}
+	return b.walkChildren(ctx, fi.Path, depth, visited, fn)
+}
+
+func (b *backend) walkChildren(ctx context.Context, name string, depth int, visited map[string]bool, fn func(p string, fi FileInfo) error) error {
+	if depth == 0 {
+		return nil
+	}
+
+	children, err := b.FileSystem.ReadDir(ctx, name, false)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		k := walkKey(child.Path)
+		if visited[k] {
+			continue
+		}
+		visited[k] = true
+
+		if err := fn(child.Path, child); err != nil {
+			return err
+		}
+		if child.IsDir {
+			if err := b.walkChildren(ctx, child.Path, depth-1, visited, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func walkKey(p string) string {
+	return path.Clean("/" + p)
 }
 
-func (b *backend) propFindFile(propfind *internal.PropFind, fi *FileInfo) (*internal.Response, error) {
+func (b *backend) propFindFile(ctx context.Context, propfind *internal.PropFind, fileInfo *FileInfo) (*internal.Response, error) {
+	fi := *fileInfo
+	fillETag(&fi)
 	props := make(map[xml.Name]internal.PropFindFunc)
 
+	var deadProps []Property
+	if b.DeadPropsStore != nil {
+		var err error
+		deadProps, err = b.DeadPropsStore.GetDeadProps(ctx, fi.Path)
+		if err != nil {
+			return nil, err
+		}
+		if fi.Language == "" {
+			fi.Language = deadPropsLanguage(deadProps)
+		}
+	}
+
+	var principal *Principal
+	if ab, ok := b.FileSystem.(ACLBackend); ok {
+		var err error
+		principal, err = ab.Principal(ctx, fi.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		var (
+			aclDone bool
+			acl     *ACL
+			aclErr  error
+		)
+		getACL := func() (*ACL, error) {
+			if !aclDone {
+				acl, aclErr = ab.GetACL(ctx, fi.Path)
+				aclDone = true
+			}
+			return acl, aclErr
+		}
+
+		props[internal.ACLName] = func(*internal.RawXMLValue) (interface{}, error) {
+			acl, err := getACL()
+			if err != nil {
+				return nil, err
+			}
+			return newInternalACL(acl)
+		}
+		props[internal.ACLRestrictionsName] = func(*internal.RawXMLValue) (interface{}, error) {
+			acl, err := getACL()
+			if err != nil {
+				return nil, err
+			}
+			return newInternalACLRestrictions(&acl.Restrictions), nil
+		}
+	}
+
 	props[internal.ResourceTypeName] = func(*internal.RawXMLValue) (interface{}, error) {
 		var types []xml.Name
 		if fi.IsDir {
 			types = append(types, internal.CollectionName)
 		}
+		if principal != nil {
+			types = append(types, internal.PrincipalName)
+		}
 		return internal.NewResourceType(types...), nil
 	}
 
+	if principal != nil {
+		props[internal.PrincipalURLName] = func(*internal.RawXMLValue) (interface{}, error) {
+			return &internal.PrincipalURL{Href: internal.Href{Path: principal.URL}}, nil
+		}
+		props[internal.AlternateURISetName] = func(*internal.RawXMLValue) (interface{}, error) {
+			hrefs, err := parseHrefs(principal.AlternateURIs)
+			if err != nil {
+				return nil, err
+			}
+			return &internal.AlternateURISet{Hrefs: hrefs}, nil
+		}
+		props[internal.GroupMemberSetName] = func(*internal.RawXMLValue) (interface{}, error) {
+			return &internal.GroupMemberSet{Hrefs: pathsToHrefs(principal.Members)}, nil
+		}
+		props[internal.GroupMembershipName] = func(*internal.RawXMLValue) (interface{}, error) {
+			return &internal.GroupMembership{Hrefs: pathsToHrefs(principal.Memberships)}, nil
+		}
+	}
+
+	if sfs, ok := b.FileSystem.(SyncFileSystem); ok && fi.IsDir {
+		props[internal.SupportedReportSetName] = func(*internal.RawXMLValue) (interface{}, error) {
+			return internal.NewSupportedReportSet(internal.SyncCollectionName), nil
+		}
+		props[internal.SyncTokenName] = func(*internal.RawXMLValue) (interface{}, error) {
+			token, err := sfs.SyncToken(ctx, fi.Path)
+			if err != nil {
+				return nil, err
+			}
+			return &internal.SyncToken{Token: token}, nil
+		}
+	}
+
 	if !fi.IsDir {
 		props[internal.GetContentLengthName] = func(*internal.RawXMLValue) (interface{}, error) {
 			return &internal.GetContentLength{Length: fi.Size}, nil
@@ -178,6 +748,12 @@ func (b *backend) propFindFile(propfind *internal.PropFind, fi *FileInfo) (*inte
 			}
 		}
 
+		if fi.Language != "" {
+			props[internal.GetContentLanguageName] = func(*internal.RawXMLValue) (interface{}, error) {
+				return &internal.GetContentLanguage{Language: fi.Language}, nil
+			}
+		}
+
 		if fi.ETag != "" {
 			props[internal.GetETagName] = func(*internal.RawXMLValue) (interface{}, error) {
 				return &internal.GetETag{ETag: internal.ETag(fi.ETag)}, nil
@@ -185,76 +761,1045 @@ func (b *backend) propFindFile(propfind *internal.PropFind, fi *FileInfo) (*inte
 		}
 	}
 
-	return internal.NewPropFindResponse(fi.Path, propfind, props)
-}
+	if upb, ok := b.FileSystem.(UserPrincipalBackend); ok {
+		props[internal.CurrentUserPrincipalName] = func(*internal.RawXMLValue) (interface{}, error) {
+			path, err := upb.CurrentUserPrincipal(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return &internal.CurrentUserPrincipal{Href: internal.Href{Path: path}}, nil
+		}
+	}
 
-func (b *backend) PropPatch(r *http.Request, update *internal.PropertyUpdate) (*internal.Response, error) {
-	// TODO: return a failed Response instead
-	return nil, internal.HTTPErrorf(http.StatusForbidden, "webdav: PROPPATCH is unsupported")
-}
+	if qfs, ok := b.FileSystem.(QuotaFileSystem); ok {
+		var (
+			quotaDone       bool
+			used, available int64
+			quotaErr        error
+		)
+		quota := func() (int64, int64, error) {
+			if !quotaDone {
+				used, available, quotaErr = qfs.Quota(ctx, fi.Path)
+				quotaDone = true
+			}
+			return used, available, quotaErr
+		}
 
-func (b *backend) Put(w http.ResponseWriter, r *http.Request) error {
-	fi, created, err := b.FileSystem.Create(r.Context(), r.URL.Path, r.Body)
-	if err != nil {
-		return err
+		props[internal.QuotaUsedBytesName] = func(*internal.RawXMLValue) (interface{}, error) {
+			used, _, err := quota()
+			if err != nil {
+				return nil, err
+			} else if used < 0 {
+				return nil, internal.HTTPErrorf(http.StatusNotFound, "webdav: quota-used-bytes not available")
+			}
+			return &internal.QuotaUsedBytes{Bytes: used}, nil
+		}
+		props[internal.QuotaAvailableBytesName] = func(*internal.RawXMLValue) (interface{}, error) {
+			_, available, err := quota()
+			if err != nil {
+				return nil, err
+			} else if available < 0 {
+				return nil, internal.HTTPErrorf(http.StatusNotFound, "webdav: quota-available-bytes not available")
+			}
+			return &internal.QuotaAvailableBytes{Bytes: available}, nil
+		}
 	}
 
-	w.Header().Set("Content-Length", strconv.FormatInt(fi.Size, 10))
-	if fi.MIMEType != "" {
-		w.Header().Set("Content-Type", fi.MIMEType)
+	if b.LockBackend != nil {
+		props[internal.SupportedLockName] = func(*internal.RawXMLValue) (interface{}, error) {
+			return &internal.SupportedLock{
+				LockEntries: []internal.LockEntry{
+					{
+						LockScope: internal.LockScope{Exclusive: &struct{}{}},
+						LockType:  internal.LockType{Write: &struct{}{}},
+					},
+					{
+						LockScope: internal.LockScope{Shared: &struct{}{}},
+						LockType:  internal.LockType{Write: &struct{}{}},
+					},
+				},
+			}, nil
+		}
+		props[internal.LockDiscoveryName] = func(*internal.RawXMLValue) (interface{}, error) {
+			locks, err := b.LockBackend.Locks(ctx, fi.Path)
+			if err != nil {
+				return nil, err
+			}
+			discovery := &internal.LockDiscovery{}
+			for i := range locks {
+				al, err := newActiveLock(&locks[i])
+				if err != nil {
+					return nil, err
+				}
+				discovery.ActiveLocks = append(discovery.ActiveLocks, *al)
+			}
+			return discovery, nil
+		}
 	}
-	if !fi.ModTime.IsZero() {
-		w.Header().Set("Last-Modified", fi.ModTime.UTC().Format(http.TimeFormat))
+
+	if pf, ok := b.FileSystem.(PropFinder); ok {
+		fsProps, err := pf.Props(ctx, fi.Path)
+		if err != nil {
+			return nil, err
+		}
+		for i := range fsProps {
+			prop := &fsProps[i]
+			if _, ok := props[prop.XMLName]; ok {
+				continue // properties derived from FileInfo take precedence
+			}
+			props[prop.XMLName] = func(*internal.RawXMLValue) (interface{}, error) {
+				return prop, nil
+			}
+		}
 	}
-	if fi.ETag != "" {
-		w.Header().Set("ETag", internal.ETag(fi.ETag).String())
+
+	for i := range deadProps {
+		prop := &deadProps[i]
+		if _, ok := props[prop.XMLName]; ok {
+			continue // live properties take precedence
+		}
+		props[prop.XMLName] = func(*internal.RawXMLValue) (interface{}, error) {
+			return prop, nil
+		}
 	}
 
-	if created {
-		w.WriteHeader(http.StatusCreated)
-	} else {
-		w.WriteHeader(http.StatusNoContent)
+	// Collection hrefs end with a slash, as recommended by RFC 4918 section
+	// 5.2
+	href := fi.Path
+	if fi.IsDir && !strings.HasSuffix(href, "/") {
+		href += "/"
 	}
+	return internal.NewPropFindResponse(href, propfind, props)
+}
 
-	return nil
+func pathsToHrefs(paths []string) []internal.Href {
+	hrefs := make([]internal.Href, len(paths))
+	for i, p := range paths {
+		hrefs[i] = internal.Href{Path: p}
+	}
+	return hrefs
 }
 
-func (b *backend) Delete(r *http.Request) error {
-	return b.FileSystem.RemoveAll(r.Context(), r.URL.Path)
+func parseHrefs(uris []string) ([]internal.Href, error) {
+	hrefs := make([]internal.Href, len(uris))
+	for i, s := range uris {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		hrefs[i] = internal.Href(*u)
+	}
+	return hrefs, nil
 }
 
-func (b *backend) Mkcol(r *http.Request) error {
-	if r.Header.Get("Content-Type") != "" {
-		return internal.HTTPErrorf(http.StatusUnsupportedMediaType, "webdav: request body not supported in MKCOL request")
+func decodeProperty(raw *internal.RawXMLValue) (*Property, error) {
+	b, err := xml.Marshal(raw)
+	if err != nil {
+		return nil, err
 	}
-	err := b.FileSystem.Mkdir(r.Context(), r.URL.Path)
-	if internal.IsNotFound(err) {
-		return &internal.HTTPError{Code: http.StatusConflict, Err: err}
+	var prop Property
+	if err := xml.Unmarshal(b, &prop); err != nil {
+		return nil, err
 	}
-	return err
+	return &prop, nil
 }
 
-func (b *backend) Copy(r *http.Request, dest *internal.Href, recursive, overwrite bool) (created bool, err error) {
-	options := CopyOptions{
+func (b *backend) PropPatch(r *http.Request, update *internal.PropertyUpdate) (*internal.Response, error) {
+	if err := b.checkLocks(r, r.URL.Path); err != nil {
+		return nil, err
+	}
+
+	// ops contains a single-property instruction per entry of names, in
+	// document order
+	var req PropPatchRequest
+	var ops []PropPatchRequest
+	var names []xml.Name
+	for _, instr := range update.Instructions {
+		for i := range instr.Prop.Raw {
+			raw := &instr.Prop.Raw[i]
+			if instr.Remove {
+				name, ok := raw.XMLName()
+				if !ok {
+					continue
+				}
+				req.Remove = append(req.Remove, name)
+				ops = append(ops, PropPatchRequest{Remove: []xml.Name{name}})
+				names = append(names, name)
+			} else {
+				prop, err := decodeProperty(raw)
+				if err != nil {
+					return nil, err
+				}
+				req.Set = append(req.Set, *prop)
+				ops = append(ops, PropPatchRequest{Set: []Property{*prop}})
+				names = append(names, prop.XMLName)
+			}
+		}
+	}
+
+	codes := make([]int, len(names))
+	if b.DeadPropsStore != nil {
+		failed, err := b.patchDeadProps(r.Context(), r.URL.Path, ops)
+		if err != nil && (failed < 0 || internal.IsNotFound(err)) {
+			return nil, err
+		}
+		for i := range codes {
+			switch {
+			case err == nil:
+				codes[i] = http.StatusOK
+			case i == failed:
+				codes[i] = internal.HTTPErrorFromError(err).Code
+			default:
+				// PROPPATCH is atomic, nothing was applied
+				codes[i] = http.StatusFailedDependency
+			}
+		}
+	} else {
+		code := http.StatusOK
+		if pp, ok := b.FileSystem.(PropPatcher); ok {
+			err := pp.PropPatch(r.Context(), r.URL.Path, &req)
+			if internal.IsNotFound(err) {
+				return nil, err
+			} else if err != nil {
+				code = internal.HTTPErrorFromError(err).Code
+			}
+		} else {
+			code = http.StatusForbidden
+		}
+		for i := range codes {
+			codes[i] = code
+		}
+	}
+
+	resp := &internal.Response{Hrefs: []internal.Href{{Path: r.URL.Path}}}
+	for i, name := range names {
+		if err := resp.EncodeProp(codes[i], internal.NewRawXMLElement(name, nil, nil)); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// patchDeadProps applies PROPPATCH instructions to the dead properties of a
+// file, in order. Either all of the instructions are applied or none of them:
+// if one fails, the properties stored before the call are restored, and its
+// index is returned along with the error. The index is -1 if the error isn't
+// caused by a specific instruction.
+func (b *backend) patchDeadProps(ctx context.Context, name string, instructions []PropPatchRequest) (failed int, err error) {
+	if _, err := b.FileSystem.Stat(ctx, name); err != nil {
+		return -1, err
+	}
+	prev, err := b.DeadPropsStore.GetDeadProps(ctx, name)
+	if err != nil {
+		return -1, err
+	}
+
+	for i, instr := range instructions {
+		if len(instr.Set) > 0 {
+			err = b.DeadPropsStore.SetDeadProps(ctx, name, instr.Set)
+		}
+		if err == nil && len(instr.Remove) > 0 {
+			err = b.DeadPropsStore.RemoveDeadProps(ctx, name, instr.Remove)
+		}
+		if err != nil {
+			if rbErr := b.restoreDeadProps(ctx, name, prev); rbErr != nil {
+				return -1, rbErr
+			}
+			return i, err
+		}
+	}
+	return -1, nil
+}
+
+// restoreDeadProps replaces all of the dead properties of a file.
+func (b *backend) restoreDeadProps(ctx context.Context, name string, props []Property) error {
+	if err := b.DeadPropsStore.RemoveDeadProps(ctx, name, nil); err != nil {
+		return err
+	}
+	if len(props) == 0 {
+		return nil
+	}
+	return b.DeadPropsStore.SetDeadProps(ctx, name, props)
+}
+
+// deadPropsPaths returns the paths of a file and its descendants, for which
+// dead properties need to be copied, moved or removed along with the file.
+func (b *backend) deadPropsPaths(ctx context.Context, name string, recursive bool) ([]string, error) {
+	if b.DeadPropsStore == nil {
+		return nil, nil
+	}
+	if !recursive {
+		return []string{name}, nil
+	}
+
+	l, err := b.FileSystem.ReadDir(ctx, name, true)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(l))
+	for i, fi := range l {
+		paths[i] = fi.Path
+	}
+	return paths, nil
+}
+
+func (b *backend) removeDeadProps(ctx context.Context, paths []string) error {
+	for _, p := range paths {
+		if err := b.DeadPropsStore.RemoveDeadProps(ctx, p, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// overwrittenDeadPropsPaths is like deadPropsPaths, but returns no error if
+// the file doesn't exist.
+func (b *backend) overwrittenDeadPropsPaths(ctx context.Context, name string) ([]string, error) {
+	paths, err := b.deadPropsPaths(ctx, name, true)
+	if internal.IsNotFound(err) {
+		return nil, nil
+	}
+	return paths, err
+}
+
+// getDeadPropsTree returns the dead properties of the files at paths. It's
+// called before a COPY or MOVE operation, since stores attaching properties
+// to the files themselves (e.g. in extended attributes) lose them when the
+// source or the overwritten destination is removed.
+func (b *backend) getDeadPropsTree(ctx context.Context, paths []string) (map[string][]Property, error) {
+	m := make(map[string][]Property)
+	for _, p := range paths {
+		props, err := b.DeadPropsStore.GetDeadProps(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		if len(props) > 0 {
+			m[p] = props
+		}
+	}
+	return m, nil
+}
+
+// copyDeadProps stores dead properties returned by getDeadPropsTree for files
+// located under src to dst. If remove is true, the source properties are
+// removed.
+func (b *backend) copyDeadProps(ctx context.Context, tree map[string][]Property, src, dst string, remove bool) error {
+	src = strings.TrimSuffix(src, "/")
+	dst = strings.TrimSuffix(dst, "/")
+	for p, props := range tree {
+		target := dst + strings.TrimPrefix(strings.TrimSuffix(p, "/"), src)
+		if err := b.DeadPropsStore.SetDeadProps(ctx, target, props); err != nil {
+			return err
+		}
+		if remove {
+			if err := b.DeadPropsStore.RemoveDeadProps(ctx, p, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *backend) Put(w http.ResponseWriter, r *http.Request) error {
+	if err := b.checkLocks(r, r.URL.Path); err != nil {
+		return err
+	}
+
+	if err := b.checkPreconditions(r); err != nil {
+		return err
+	}
+
+	var (
+		fi      *FileInfo
+		created bool
+		partial bool
+		err     error
+	)
+	if contentRange := r.Header.Get("Content-Range"); contentRange != "" {
+		fi, created, partial, err = b.putRange(r, contentRange)
+	} else {
+		fi, created, err = b.FileSystem.Create(r.Context(), r.URL.Path, r.Body)
+	}
+	if err != nil {
+		return err
+	}
+	fillETag(fi)
+
+	// The FileSystem interface has no way to pass the language along with
+	// the body: store it as the DAV:getcontentlanguage property if possible.
+	// The file has already been written at this point, so failing to store
+	// the language doesn't fail the request.
+	if lang := r.Header.Get("Content-Language"); lang != "" && b.setContentLanguage(r.Context(), r.URL.Path, lang) == nil {
+		fi.Language = lang
+	}
+
+	if fi.MIMEType != "" {
+		w.Header().Set("Content-Type", fi.MIMEType)
+	}
+	if fi.Language != "" {
+		w.Header().Set("Content-Language", fi.Language)
+	}
+	if !fi.ModTime.IsZero() {
+		w.Header().Set("Last-Modified", fi.ModTime.UTC().Format(http.TimeFormat))
+	}
+	if fi.ETag != "" {
+		w.Header().Set("ETag", internal.ETag(fi.ETag).String())
+	}
+
+	if partial {
+		w.WriteHeader(http.StatusNoContent)
+	} else if created {
+		w.WriteHeader(http.StatusCreated)
+	} else if r.Header.Get("Content-Range") != "" {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	return nil
+}
+
+// putRange writes the byte range of a file contained in a PUT request with a
+// Content-Range header field. partial is true if the range doesn't complete
+// the file.
+func (b *backend) putRange(r *http.Request, contentRange string) (fi *FileInfo, created, partial bool, err error) {
+	// RFC 7231 section 4.3.4 requires servers which don't support partial
+	// PUT requests to reject them
+	pw, ok := b.FileSystem.(PartialWriter)
+	if !ok {
+		return nil, false, false, internal.HTTPErrorf(http.StatusBadRequest, "webdav: partial PUT requests not supported")
+	}
+
+	start, end, size, err := parseContentRange(contentRange)
+	if err != nil {
+		return nil, false, false, &internal.HTTPError{Code: http.StatusBadRequest, Err: err}
+	}
+	if size >= 0 && end >= size {
+		return nil, false, false, internal.HTTPErrorf(http.StatusRequestedRangeNotSatisfiable, "webdav: range exceeds the total size")
+	}
+	n := end - start + 1
+	if r.ContentLength >= 0 && r.ContentLength != n {
+		return nil, false, false, internal.HTTPErrorf(http.StatusBadRequest, "webdav: Content-Length doesn't match Content-Range")
+	}
+
+	body := &rangeBody{rc: r.Body, n: n}
+	fi, created, err = pw.WriteAt(r.Context(), r.URL.Path, body, start, size)
+	if err != nil {
+		return nil, false, false, err
+	}
+	return fi, created, size < 0 || end+1 < size, nil
+}
+
+// parseContentRange parses a Content-Range header field of the form
+// "bytes start-end/size", as defined in RFC 7233 section 4.2. The size is -1
+// if unknown.
+func parseContentRange(s string) (start, end, size int64, err error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "bytes ") {
+		return 0, 0, 0, fmt.Errorf("webdav: invalid Content-Range %q", s)
+	}
+	s = strings.TrimSpace(strings.TrimPrefix(s, "bytes "))
+
+	i := strings.IndexByte(s, '/')
+	if i < 0 {
+		return 0, 0, 0, fmt.Errorf("webdav: invalid Content-Range %q", s)
+	}
+	rng, sizeStr := s[:i], s[i+1:]
+
+	size = -1
+	if sizeStr != "*" {
+		size, err = strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil || size < 0 {
+			return 0, 0, 0, fmt.Errorf("webdav: invalid Content-Range size %q", sizeStr)
+		}
+	}
+
+	i = strings.IndexByte(rng, '-')
+	if i < 0 {
+		return 0, 0, 0, fmt.Errorf("webdav: invalid Content-Range %q", s)
+	}
+	start, err1 := strconv.ParseInt(rng[:i], 10, 64)
+	end, err2 := strconv.ParseInt(rng[i+1:], 10, 64)
+	if err1 != nil || err2 != nil || start < 0 || end < start {
+		return 0, 0, 0, fmt.Errorf("webdav: invalid Content-Range range %q", rng)
+	}
+	return start, end, size, nil
+}
+
+// rangeBody reads exactly n bytes from a request body, and fails if the body
+// is shorter or longer.
+type rangeBody struct {
+	rc io.ReadCloser
+	n  int64
+}
+
+func (b *rangeBody) Read(p []byte) (int, error) {
+	if b.n <= 0 {
+		// Make sure there is no trailing data
+		var buf [1]byte
+		if n, _ := b.rc.Read(buf[:]); n > 0 {
+			return 0, internal.HTTPErrorf(http.StatusBadRequest, "webdav: request body longer than Content-Range")
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > b.n {
+		p = p[:b.n]
+	}
+	n, err := b.rc.Read(p)
+	b.n -= int64(n)
+	if err == io.EOF && b.n > 0 {
+		err = internal.HTTPErrorf(http.StatusBadRequest, "webdav: request body shorter than Content-Range")
+	}
+	return n, err
+}
+
+func (b *rangeBody) Close() error {
+	return b.rc.Close()
+}
+
+// setContentLanguage stores the language of a file as the
+// DAV:getcontentlanguage property, in the DeadPropsStore if any, or else with
+// the FileSystem's PropPatcher implementation.
+func (b *backend) setContentLanguage(ctx context.Context, name, lang string) error {
+	raw, err := internal.EncodeRawXMLElement(&internal.GetContentLanguage{Language: lang})
+	if err != nil {
+		return err
+	}
+	prop, err := decodeProperty(raw)
+	if err != nil {
+		return err
+	}
+
+	if b.DeadPropsStore != nil {
+		return b.DeadPropsStore.SetDeadProps(ctx, name, []Property{*prop})
+	}
+	pp, ok := b.FileSystem.(PropPatcher)
+	if !ok {
+		return internal.HTTPErrorf(http.StatusForbidden, "webdav: properties not supported")
+	}
+	return pp.PropPatch(ctx, name, &PropPatchRequest{Set: []Property{*prop}})
+}
+
+// deadPropsLanguage returns the language stored in a DAV:getcontentlanguage
+// dead property by setContentLanguage, if any.
+func deadPropsLanguage(props []Property) string {
+	for _, prop := range props {
+		if prop.XMLName == internal.GetContentLanguageName {
+			return strings.TrimSpace(string(prop.InnerXML))
+		}
+	}
+	return ""
+}
+
+// checkPreconditions evaluates the If-Match and If-None-Match headers of a
+// request against the current state of the file, as defined in RFC 7232
+// section 3.
+func (b *backend) checkPreconditions(r *http.Request) error {
+	conditional := r.Header.Get("If-Match") != "" || r.Header.Get("If-None-Match") != ""
+	if !conditional && !b.RequireConditionalPut {
+		return nil
+	}
+
+	fi, err := b.FileSystem.Stat(r.Context(), r.URL.Path)
+	if internal.IsNotFound(err) {
+		fi = nil
+	} else if err != nil {
+		return err
+	} else {
+		fillETag(fi)
+	}
+
+	if !conditional {
+		if fi != nil {
+			return internal.HTTPErrorf(http.StatusPreconditionRequired, "webdav: If-Match or If-None-Match header required to replace %q", r.URL.Path)
+		}
+		return nil
+	}
+
+	return evalPreconditions(r, fi)
+}
+
+// errNotModified is returned by evalPreconditions when a GET or HEAD request
+// should be answered with 304 Not Modified.
+var errNotModified = errors.New("webdav: not modified")
+
+// evalPreconditions evaluates the conditional headers of a request against a
+// file, which is nil if it doesn't exist.
+func evalPreconditions(r *http.Request, fi *FileInfo) error {
+	isGet := r.Method == http.MethodGet || r.Method == http.MethodHead
+	ifMatch := r.Header.Get("If-Match")
+	ifNoneMatch := r.Header.Get("If-None-Match")
+
+	if ifMatch != "" {
+		if fi == nil {
+			return internal.HTTPErrorf(http.StatusPreconditionFailed, "webdav: If-Match precondition failed: file doesn't exist")
+		}
+		if !matchETags(ifMatch, fi.ETag, false) {
+			return internal.HTTPErrorf(http.StatusPreconditionFailed, "webdav: If-Match precondition failed: entity tag mismatch")
+		}
+	}
+
+	if ifNoneMatch != "" {
+		if fi == nil || !matchETags(ifNoneMatch, fi.ETag, true) {
+			return nil
+		}
+		if isGet {
+			return errNotModified
+		}
+		if strings.TrimSpace(ifNoneMatch) == "*" {
+			return internal.HTTPErrorf(http.StatusPreconditionFailed, "webdav: If-None-Match precondition failed: file already exists")
+		}
+		return internal.HTTPErrorf(http.StatusPreconditionFailed, "webdav: If-None-Match precondition failed: entity tag matches")
+	}
+
+	// If-Modified-Since is ignored when If-None-Match is present
+	if isGet && fi != nil && !fi.ModTime.IsZero() {
+		t, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err == nil && !fi.ModTime.Truncate(time.Second).After(t) {
+			return errNotModified
+		}
+	}
+
+	return nil
+}
+
+// matchETags reports whether the value of an If-Match or If-None-Match header
+// matches an entity tag. If weak is true, the weak comparison function
+// defined in RFC 7232 section 2.3.2 is used.
+func matchETags(header, etag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	if etag == "" {
+		return false
+	}
+	for _, s := range strings.Split(header, ",") {
+		s = strings.TrimSpace(s)
+		if strings.HasPrefix(s, "W/") {
+			if !weak {
+				continue
+			}
+			s = strings.TrimPrefix(s, "W/")
+		}
+		var e internal.ETag
+		if err := e.UnmarshalText([]byte(s)); err == nil && string(e) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// fillETag sets a default entity tag derived from the modification time and
+// the size of a file if the FileSystem didn't provide one.
+func fillETag(fi *FileInfo) {
+	if fi.ETag == "" && !fi.IsDir && !fi.ModTime.IsZero() {
+		fi.ETag = fmt.Sprintf("%x%x", fi.ModTime.UnixNano(), fi.Size)
+	}
+}
+
+func (b *backend) Delete(r *http.Request) error {
+	if err := b.checkLocks(r, r.URL.Path); err != nil {
+		return err
+	}
+
+	paths, err := b.deadPropsPaths(r.Context(), r.URL.Path, true)
+	if err != nil {
+		return err
+	}
+	if err := b.FileSystem.RemoveAll(r.Context(), r.URL.Path); err != nil {
+		return err
+	}
+	return b.removeDeadProps(r.Context(), paths)
+}
+
+func (b *backend) Mkcol(r *http.Request) error {
+	if r.Header.Get("Content-Type") != "" {
+		return internal.HTTPErrorf(http.StatusUnsupportedMediaType, "webdav: request body not supported in MKCOL request")
+	}
+	return b.mkdir(r)
+}
+
+func (b *backend) mkdir(r *http.Request) error {
+	if err := b.checkLocks(r, r.URL.Path); err != nil {
+		return err
+	}
+	err := b.FileSystem.Mkdir(r.Context(), r.URL.Path)
+	if internal.IsNotFound(err) {
+		return &internal.HTTPError{Code: http.StatusConflict, Err: err}
+	}
+	return err
+}
+
+func (b *backend) ExtendedMkcol(r *http.Request, mkcol *internal.Mkcol) (*internal.MkcolResponse, error) {
+	var req PropPatchRequest
+	var names []xml.Name
+	var invalidResourceType bool
+	for _, set := range mkcol.Set {
+		for i := range set.Prop.Raw {
+			raw := &set.Prop.Raw[i]
+			name, ok := raw.XMLName()
+			if !ok {
+				continue
+			}
+			names = append(names, name)
+
+			if name == internal.ResourceTypeName {
+				var rt internal.ResourceType
+				if err := raw.Decode(&rt); err != nil {
+					return nil, &internal.HTTPError{Code: http.StatusBadRequest, Err: err}
+				}
+				// Only plain collections can be created
+				if len(rt.Raw) != 1 || !rt.Is(internal.CollectionName) {
+					invalidResourceType = true
+				}
+				continue
+			}
+
+			prop, err := decodeProperty(raw)
+			if err != nil {
+				return nil, err
+			}
+			req.Set = append(req.Set, *prop)
+		}
+	}
+
+	if invalidResourceType {
+		return nil, newMkcolError(names, http.StatusForbidden, func(name xml.Name) bool {
+			return name == internal.ResourceTypeName
+		})
+	}
+
+	isProp := func(name xml.Name) bool {
+		return name != internal.ResourceTypeName
+	}
+
+	if cc, ok := b.FileSystem.(CollectionCreator); ok && b.DeadPropsStore == nil {
+		if err := b.checkLocks(r, r.URL.Path); err != nil {
+			return nil, err
+		}
+		err := cc.CreateCollection(r.Context(), r.URL.Path, req.Set)
+		if internal.IsNotFound(err) {
+			return nil, &internal.HTTPError{Code: http.StatusConflict, Err: err}
+		} else if err != nil {
+			code := internal.HTTPErrorFromError(err).Code
+			switch code {
+			case http.StatusMethodNotAllowed, http.StatusConflict, http.StatusLocked:
+				// The collection itself couldn't be created
+				return nil, err
+			}
+			return nil, newMkcolError(names, code, isProp)
+		}
+		return newMkcolResponse(names, func(name xml.Name) int {
+			return http.StatusOK
+		})
+	}
+
+	var patch func(ctx context.Context, name string, req *PropPatchRequest) error
+	if b.DeadPropsStore != nil {
+		patch = func(ctx context.Context, name string, req *PropPatchRequest) error {
+			_, err := b.patchDeadProps(ctx, name, []PropPatchRequest{*req})
+			return err
+		}
+	} else if pp, ok := b.FileSystem.(PropPatcher); ok {
+		patch = pp.PropPatch
+	} else if len(req.Set) > 0 {
+		return nil, newMkcolError(names, http.StatusForbidden, isProp)
+	}
+
+	if err := b.mkdir(r); err != nil {
+		return nil, err
+	}
+
+	if len(req.Set) > 0 {
+		if err := patch(r.Context(), r.URL.Path, &req); err != nil {
+			// The collection must not be left behind if its properties
+			// couldn't be set
+			if rmErr := b.FileSystem.RemoveAll(r.Context(), r.URL.Path); rmErr != nil {
+				return nil, rmErr
+			}
+			code := internal.HTTPErrorFromError(err).Code
+			return nil, newMkcolError(names, code, isProp)
+		}
+	}
+
+	return newMkcolResponse(names, func(name xml.Name) int {
+		return http.StatusOK
+	})
+}
+
+// newMkcolError returns an error describing a failed extended MKCOL request.
+// Properties for which failed returns true are reported with the provided
+// status code, the others with 424 Failed Dependency.
+func newMkcolError(names []xml.Name, code int, failed func(name xml.Name) bool) error {
+	resp, err := newMkcolResponse(names, func(name xml.Name) int {
+		if failed(name) {
+			return code
+		}
+		return http.StatusFailedDependency
+	})
+	if err != nil {
+		return err
+	}
+	return &internal.HTTPError{Code: code, Err: resp}
+}
+
+func newMkcolResponse(names []xml.Name, status func(name xml.Name) int) (*internal.MkcolResponse, error) {
+	var resp internal.Response
+	for _, name := range names {
+		if err := resp.EncodeProp(status(name), internal.NewRawXMLElement(name, nil, nil)); err != nil {
+			return nil, err
+		}
+	}
+	return &internal.MkcolResponse{PropStats: resp.PropStats}, nil
+}
+
+func (b *backend) Copy(r *http.Request, dest *internal.Href, recursive, overwrite bool) (created bool, err error) {
+	if err := b.checkLocks(r, dest.Path); err != nil {
+		return false, err
+	}
+
+	options := CopyOptions{
 		NoRecursive: !recursive,
 		NoOverwrite: !overwrite,
 	}
+	var deadProps map[string][]Property
+	var overwritten []string
+	if b.DeadPropsStore != nil {
+		paths, err := b.deadPropsPaths(r.Context(), r.URL.Path, recursive)
+		if err != nil {
+			return false, err
+		}
+		if deadProps, err = b.getDeadPropsTree(r.Context(), paths); err != nil {
+			return false, err
+		}
+		if overwritten, err = b.overwrittenDeadPropsPaths(r.Context(), dest.Path); err != nil {
+			return false, err
+		}
+	}
+
 	created, err = b.FileSystem.Copy(r.Context(), r.URL.Path, dest.Path, &options)
 	if os.IsExist(err) {
 		return false, &internal.HTTPError{http.StatusPreconditionFailed, err}
 	}
-	return created, err
+	var copyErr *CopyError
+	if errors.As(err, &copyErr) {
+		return false, newResourceErrorsMultiStatus(copyErr.Errors)
+	} else if err != nil {
+		return false, err
+	}
+
+	if b.DeadPropsStore != nil {
+		if err := b.removeDeadProps(r.Context(), overwritten); err != nil {
+			return false, err
+		}
+		if err := b.copyDeadProps(r.Context(), deadProps, r.URL.Path, dest.Path, false); err != nil {
+			return false, err
+		}
+	}
+	return created, nil
 }
 
 func (b *backend) Move(r *http.Request, dest *internal.Href, overwrite bool) (created bool, err error) {
+	if err := b.checkLocks(r, r.URL.Path); err != nil {
+		return false, err
+	}
+	if err := b.checkLocks(r, dest.Path); err != nil {
+		return false, err
+	}
+
 	options := MoveOptions{
 		NoOverwrite: !overwrite,
 	}
+	var deadProps map[string][]Property
+	var overwritten []string
+	if b.DeadPropsStore != nil {
+		paths, err := b.deadPropsPaths(r.Context(), r.URL.Path, true)
+		if err != nil {
+			return false, err
+		}
+		if deadProps, err = b.getDeadPropsTree(r.Context(), paths); err != nil {
+			return false, err
+		}
+		if overwritten, err = b.overwrittenDeadPropsPaths(r.Context(), dest.Path); err != nil {
+			return false, err
+		}
+	}
+
 	created, err = b.FileSystem.Move(r.Context(), r.URL.Path, dest.Path, &options)
 	if os.IsExist(err) {
 		return false, &internal.HTTPError{http.StatusPreconditionFailed, err}
 	}
-	return created, err
+	var moveErr *MoveError
+	if errors.As(err, &moveErr) {
+		return false, newResourceErrorsMultiStatus(moveErr.Errors)
+	} else if err != nil {
+		return false, err
+	}
+
+	if b.DeadPropsStore != nil {
+		if err := b.removeDeadProps(r.Context(), overwritten); err != nil {
+			return false, err
+		}
+		if err := b.copyDeadProps(r.Context(), deadProps, r.URL.Path, dest.Path, true); err != nil {
+			return false, err
+		}
+	}
+	return created, nil
+}
+
+// newResourceErrorsMultiStatus builds the 207 Multi-Status response reporting
+// the members of a collection which couldn't be copied or moved.
+func newResourceErrorsMultiStatus(errs []ResourceError) *internal.MultiStatus {
+	ms := internal.NewMultiStatus()
+	for _, err := range errs {
+		ms.Responses = append(ms.Responses, *internal.NewErrorResponse(err.Path, err.Err))
+	}
+	return ms
+}
+
+func (b *backend) Lock(r *http.Request, info *internal.LockInfo, depth internal.Depth, timeout time.Duration) (*internal.ActiveLock, bool, error) {
+	if b.LockBackend == nil {
+		return nil, false, internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: locking is not supported")
+	}
+	if info.LockType.Write == nil {
+		return nil, false, internal.HTTPErrorf(http.StatusUnprocessableEntity, "webdav: unsupported lock type")
+	}
+
+	lock := &Lock{
+		Path:      r.URL.Path,
+		Shared:    info.LockScope.Shared != nil,
+		Recursive: depth == internal.DepthInfinity,
+		Timeout:   timeout,
+	}
+	if info.Owner != nil {
+		lock.Owner = info.Owner.InnerXML
+	}
+
+	lock, err := b.LockBackend.Lock(r.Context(), lock)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Locking an unmapped URL creates an empty resource
+	created := false
+	if _, err := b.FileSystem.Stat(r.Context(), r.URL.Path); internal.IsNotFound(err) {
+		if _, _, err := b.FileSystem.Create(r.Context(), r.URL.Path, http.NoBody); err != nil {
+			b.LockBackend.Unlock(r.Context(), lock.Path, lock.Token)
+			return nil, false, err
+		}
+		created = true
+	} else if err != nil {
+		b.LockBackend.Unlock(r.Context(), lock.Path, lock.Token)
+		return nil, false, err
+	}
+
+	al, err := newActiveLock(lock)
+	return al, created, err
+}
+
+func (b *backend) RefreshLock(r *http.Request, token string, timeout time.Duration) (*internal.ActiveLock, error) {
+	if b.LockBackend == nil {
+		return nil, internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: locking is not supported")
+	}
+
+	lock, err := b.LockBackend.Refresh(r.Context(), r.URL.Path, token, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return newActiveLock(lock)
+}
+
+func (b *backend) Unlock(r *http.Request, token string) error {
+	if b.LockBackend == nil {
+		return internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: locking is not supported")
+	}
+	return b.LockBackend.Unlock(r.Context(), r.URL.Path, token)
+}
+
+func (b *backend) SetACL(r *http.Request, iacl *internal.ACL) error {
+	ab, ok := b.FileSystem.(ACLBackend)
+	if !ok {
+		return internal.HTTPErrorf(http.StatusMethodNotAllowed, "webdav: access control is not supported")
+	}
+
+	acl, err := decodeACL(iacl)
+	if err != nil {
+		return &internal.HTTPError{Code: http.StatusBadRequest, Err: err}
+	}
+	for _, ace := range acl.ACEs {
+		if ace.Protected || ace.Inherited != "" {
+			return internal.HTTPErrorf(http.StatusForbidden, "webdav: cannot set protected or inherited ACE")
+		}
+	}
+
+	if err := b.checkLocks(r, r.URL.Path); err != nil {
+		return err
+	}
+	return ab.SetACL(r.Context(), r.URL.Path, acl)
+}
+
+// checkLocks ensures that a token for one of the locks applying to a file has
+// been submitted in the request's If header.
+func (b *backend) checkLocks(r *http.Request, name string) error {
+	if b.LockBackend == nil {
+		return nil
+	}
+
+	locks, err := b.LockBackend.Locks(r.Context(), name)
+	if err != nil || len(locks) == 0 {
+		return err
+	}
+
+	submitted := internal.ParseIfLockTokens(r.Header.Get("If"))
+	for _, lock := range locks {
+		for _, token := range submitted {
+			if token == lock.Token {
+				return nil
+			}
+		}
+	}
+
+	var elt internal.LockTokenSubmitted
+	for _, lock := range locks {
+		elt.Hrefs = append(elt.Hrefs, internal.Href{Path: lock.Path})
+	}
+	raw, err := internal.EncodeRawXMLElement(&elt)
+	if err != nil {
+		return err
+	}
+	return &internal.HTTPError{
+		Code: http.StatusLocked,
+		Err:  &internal.Error{Raw: []internal.RawXMLValue{*raw}},
+	}
+}
+
+func newActiveLock(lock *Lock) (*internal.ActiveLock, error) {
+	token, err := url.Parse(lock.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	al := &internal.ActiveLock{
+		LockType:  internal.LockType{Write: &struct{}{}},
+		Depth:     internal.DepthZero,
+		LockToken: &internal.LockToken{Href: internal.Href(*token)},
+		LockRoot:  &internal.LockRoot{Href: internal.Href{Path: lock.Path}},
+	}
+	if lock.Shared {
+		al.LockScope.Shared = &struct{}{}
+	} else {
+		al.LockScope.Exclusive = &struct{}{}
+	}
+	if lock.Recursive {
+		al.Depth = internal.DepthInfinity
+	}
+	if lock.Owner != "" {
+		al.Owner = &internal.Owner{InnerXML: lock.Owner}
+	}
+	if lock.Timeout != 0 {
+		al.Timeout = internal.FormatTimeout(lock.Timeout)
+	}
+	return al, nil
 }
 
 // BackendSuppliedHomeSet represents either a CalDAV calendar-home-set or a
@@ -268,6 +1813,9 @@ type BackendSuppliedHomeSet interface {
 
 // UserPrincipalBackend can determine the current user's principal URL for a
 // given request context.
+//
+// If a FileSystem implements UserPrincipalBackend, Handler returns the
+// DAV:current-user-principal property (RFC 5397) for all resources.
 type UserPrincipalBackend interface {
 	CurrentUserPrincipal(ctx context.Context) (string, error)
 }
@@ -296,7 +1844,7 @@ func ServePrincipal(w http.ResponseWriter, r *http.Request, options *ServePrinci
 		w.WriteHeader(http.StatusNoContent)
 	case "PROPFIND":
 		if err := servePrincipalPropfind(w, r, options); err != nil {
-			internal.ServeError(w, err)
+			internal.ServeRequestError(w, r, err)
 		}
 	default:
 		http.Error(w, "unsupported method", http.StatusMethodNotAllowed)
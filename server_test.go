@@ -0,0 +1,1464 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+// readDirFileSystem hides the WalkFileSystem implementation of a FileSystem,
+// so that the Handler enumerates collections with ReadDir.
+type readDirFileSystem struct {
+	FileSystem
+}
+
+func newTestTree(t *testing.T) LocalFileSystem {
+	dir := t.TempDir()
+	for _, p := range []string{"a/b/c", "d"} {
+		if err := os.MkdirAll(filepath.Join(dir, p), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []string{"a/1.txt", "a/b/2.txt", "a/b/c/3.txt"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, p), []byte(p), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return LocalFileSystem(dir)
+}
+
+func propFindHrefs(t *testing.T, h http.Handler, depth string) (int, []string) {
+	req := httptest.NewRequest("PROPFIND", "/a/", strings.NewReader(`<propfind xmlns="DAV:"><prop><resourcetype/></prop></propfind>`))
+	req.Header.Set("Content-Type", "application/xml")
+	req.Header.Set("Depth", depth)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusMultiStatus {
+		return res.StatusCode, nil
+	}
+
+	var ms internal.MultiStatus
+	if err := xml.NewDecoder(res.Body).Decode(&ms); err != nil {
+		t.Fatalf("failed to decode multistatus: %v", err)
+	}
+	var hrefs []string
+	for _, resp := range ms.Responses {
+		for _, href := range resp.Hrefs {
+			hrefs = append(hrefs, href.Path)
+		}
+	}
+	sort.Strings(hrefs)
+	return res.StatusCode, hrefs
+}
+
+func TestHandler_propFindDepthInfinity(t *testing.T) {
+	all := []string{"/a/", "/a/1.txt", "/a/b/", "/a/b/2.txt", "/a/b/c/", "/a/b/c/3.txt"}
+
+	testCases := []struct {
+		name     string
+		handler  *Handler
+		depth    string
+		expected []string
+	}{
+		{
+			name:     "depth 1",
+			handler:  &Handler{},
+			depth:    "1",
+			expected: []string{"/a/", "/a/1.txt", "/a/b/"},
+		},
+		{
+			name:     "depth infinity",
+			handler:  &Handler{},
+			depth:    "infinity",
+			expected: all,
+		},
+		{
+			name:     "max depth",
+			handler:  &Handler{MaxDepth: 2},
+			depth:    "infinity",
+			expected: []string{"/a/", "/a/1.txt", "/a/b/", "/a/b/2.txt", "/a/b/c/"},
+		},
+	}
+
+	for _, tc := range testCases {
+		for _, readDir := range []bool{false, true} {
+			name := tc.name
+			h := *tc.handler
+			h.FileSystem = newTestTree(t)
+			if readDir {
+				name += " (ReadDir)"
+				h.FileSystem = readDirFileSystem{h.FileSystem}
+			}
+
+			t.Run(name, func(t *testing.T) {
+				code, hrefs := propFindHrefs(t, &h, tc.depth)
+				if code != http.StatusMultiStatus {
+					t.Fatalf("status = %v, expected %v", code, http.StatusMultiStatus)
+				}
+				if !reflect.DeepEqual(hrefs, tc.expected) {
+					t.Errorf("hrefs = %v, expected %v", hrefs, tc.expected)
+				}
+			})
+		}
+	}
+}
+
+func TestHandler_propFindDepthInfinityDisabled(t *testing.T) {
+	h := &Handler{FileSystem: newTestTree(t), DisableDepthInfinity: true}
+
+	req := httptest.NewRequest("PROPFIND", "/a/", nil)
+	req.Header.Set("Depth", "infinity")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	res := w.Result()
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("status = %v, expected %v", res.StatusCode, http.StatusForbidden)
+	}
+	var errElt internal.Error
+	if err := xml.NewDecoder(res.Body).Decode(&errElt); err != nil {
+		t.Fatalf("failed to decode error: %v", err)
+	}
+	if len(errElt.Raw) != 1 {
+		t.Fatalf("error has %v conditions, expected 1", len(errElt.Raw))
+	}
+	if name, _ := errElt.Raw[0].XMLName(); name != internal.PropFindFiniteDepthName {
+		t.Errorf("error condition = %v, expected %v", name, internal.PropFindFiniteDepthName)
+	}
+
+	if code, _ := propFindHrefs(t, h, "1"); code != http.StatusMultiStatus {
+		t.Errorf("status for Depth: 1 = %v, expected %v", code, http.StatusMultiStatus)
+	}
+}
+
+const lockInfoRequest = `<?xml version="1.0" encoding="utf-8"?>
+<D:lockinfo xmlns:D="DAV:">
+  <D:lockscope><D:%v/></D:lockscope>
+  <D:locktype><D:write/></D:locktype>
+  <D:owner><D:href>mailto:alice@example.org</D:href></D:owner>
+</D:lockinfo>`
+
+func serveTestRequest(h http.Handler, method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, r)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/xml")
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandler_lock(t *testing.T) {
+	fs := newTestTree(t)
+	h := &Handler{FileSystem: fs, LockBackend: &MemLockBackend{}}
+
+	w := serveTestRequest(h, "LOCK", "/a/1.txt", fmt.Sprintf(lockInfoRequest, "exclusive"), map[string]string{"Timeout": "Second-60"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("LOCK status = %v, want %v", w.Code, http.StatusOK)
+	}
+	token, err := internal.ParseLockToken(w.Header().Get("Lock-Token"))
+	if err != nil {
+		t.Fatalf("invalid Lock-Token header: %v", err)
+	}
+
+	var prop struct {
+		LockDiscovery internal.LockDiscovery `xml:"DAV: lockdiscovery"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &prop); err != nil {
+		t.Fatalf("failed to decode LOCK response: %v", err)
+	}
+	if len(prop.LockDiscovery.ActiveLocks) != 1 {
+		t.Fatalf("LOCK response has %v active locks, want 1", len(prop.LockDiscovery.ActiveLocks))
+	}
+	al := prop.LockDiscovery.ActiveLocks[0]
+	if al.LockScope.Exclusive == nil || al.Timeout != "Second-60" || al.LockToken.Href.String() != token {
+		t.Errorf("unexpected active lock: %+v", al)
+	}
+	if al.Owner == nil || !strings.Contains(al.Owner.InnerXML, "mailto:alice@example.org") {
+		t.Errorf("lock owner = %+v, want mailto:alice@example.org", al.Owner)
+	}
+
+	if w := serveTestRequest(h, "LOCK", "/a/1.txt", fmt.Sprintf(lockInfoRequest, "shared"), nil); w.Code != http.StatusLocked {
+		t.Errorf("conflicting LOCK status = %v, want %v", w.Code, http.StatusLocked)
+	}
+
+	if w := serveTestRequest(h, http.MethodPut, "/a/1.txt", "new", nil); w.Code != http.StatusLocked {
+		t.Errorf("PUT without lock token status = %v, want %v", w.Code, http.StatusLocked)
+	}
+	ifHeader := map[string]string{"If": "(<" + token + ">)"}
+	if w := serveTestRequest(h, http.MethodPut, "/a/1.txt", "new", ifHeader); w.Code/100 != 2 {
+		t.Errorf("PUT with lock token status = %v, want 2xx", w.Code)
+	}
+
+	refreshHeader := map[string]string{"If": "(<" + token + ">)", "Timeout": "Second-120"}
+	if w := serveTestRequest(h, "LOCK", "/a/1.txt", "", refreshHeader); w.Code != http.StatusOK {
+		t.Errorf("refresh LOCK status = %v, want %v", w.Code, http.StatusOK)
+	} else if !strings.Contains(w.Body.String(), "Second-120") {
+		t.Errorf("refresh LOCK response doesn't contain the new timeout:\n%v", w.Body.String())
+	}
+
+	if w := serveTestRequest(h, "UNLOCK", "/a/1.txt", "", map[string]string{"Lock-Token": "<urn:uuid:unknown>"}); w.Code != http.StatusConflict {
+		t.Errorf("UNLOCK with an unknown token status = %v, want %v", w.Code, http.StatusConflict)
+	}
+	if w := serveTestRequest(h, "UNLOCK", "/a/1.txt", "", map[string]string{"Lock-Token": "<" + token + ">"}); w.Code != http.StatusNoContent {
+		t.Errorf("UNLOCK status = %v, want %v", w.Code, http.StatusNoContent)
+	}
+	if w := serveTestRequest(h, http.MethodPut, "/a/1.txt", "new", nil); w.Code/100 != 2 {
+		t.Errorf("PUT after UNLOCK status = %v, want 2xx", w.Code)
+	}
+}
+
+func TestHandler_lockShared(t *testing.T) {
+	h := &Handler{FileSystem: newTestTree(t), LockBackend: &MemLockBackend{}}
+
+	for i := 0; i < 2; i++ {
+		if w := serveTestRequest(h, "LOCK", "/a/1.txt", fmt.Sprintf(lockInfoRequest, "shared"), nil); w.Code != http.StatusOK {
+			t.Fatalf("shared LOCK #%v status = %v, want %v", i, w.Code, http.StatusOK)
+		}
+	}
+	if w := serveTestRequest(h, "LOCK", "/a/1.txt", fmt.Sprintf(lockInfoRequest, "exclusive"), nil); w.Code != http.StatusLocked {
+		t.Errorf("exclusive LOCK status = %v, want %v", w.Code, http.StatusLocked)
+	}
+}
+
+func TestHandler_lockCollection(t *testing.T) {
+	fs := newTestTree(t)
+	h := &Handler{FileSystem: fs, LockBackend: &MemLockBackend{}}
+
+	w := serveTestRequest(h, "LOCK", "/a/", fmt.Sprintf(lockInfoRequest, "exclusive"), map[string]string{"Depth": "infinity"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("LOCK status = %v, want %v", w.Code, http.StatusOK)
+	}
+
+	w = serveTestRequest(h, http.MethodPut, "/a/b/new.txt", "new", nil)
+	if w.Code != http.StatusLocked {
+		t.Fatalf("PUT in a locked collection status = %v, want %v", w.Code, http.StatusLocked)
+	}
+	var errElt internal.Error
+	if err := xml.Unmarshal(w.Body.Bytes(), &errElt); err != nil {
+		t.Fatalf("failed to decode error: %v", err)
+	}
+	if len(errElt.Raw) != 1 {
+		t.Fatalf("error has %v conditions, want 1", len(errElt.Raw))
+	}
+	if name, _ := errElt.Raw[0].XMLName(); name != (xml.Name{"DAV:", "lock-token-submitted"}) {
+		t.Errorf("error condition = %v, want lock-token-submitted", name)
+	}
+
+	if w := serveTestRequest(h, "LOCK", "/a/b/", fmt.Sprintf(lockInfoRequest, "exclusive"), nil); w.Code != http.StatusLocked {
+		t.Errorf("LOCK of a member status = %v, want %v", w.Code, http.StatusLocked)
+	}
+	if w := serveTestRequest(h, "LOCK", "/a/", fmt.Sprintf(lockInfoRequest, "exclusive"), map[string]string{"Depth": "1"}); w.Code != http.StatusBadRequest {
+		t.Errorf("LOCK with Depth: 1 status = %v, want %v", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandler_lockUnmapped(t *testing.T) {
+	fs := newTestTree(t)
+	h := &Handler{FileSystem: fs, LockBackend: &MemLockBackend{}}
+
+	if w := serveTestRequest(h, "LOCK", "/d/new.txt", fmt.Sprintf(lockInfoRequest, "exclusive"), nil); w.Code != http.StatusCreated {
+		t.Fatalf("LOCK status = %v, want %v", w.Code, http.StatusCreated)
+	}
+	if fi, err := os.Stat(filepath.Join(string(fs), "d", "new.txt")); err != nil {
+		t.Errorf("LOCK didn't create the resource: %v", err)
+	} else if fi.Size() != 0 {
+		t.Errorf("LOCK created a resource of size %v, want 0", fi.Size())
+	}
+}
+
+func TestHandler_lockUnsupported(t *testing.T) {
+	h := &Handler{FileSystem: newTestTree(t)}
+	if w := serveTestRequest(h, "LOCK", "/a/1.txt", fmt.Sprintf(lockInfoRequest, "exclusive"), nil); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("LOCK status = %v, want %v", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+type testColorProp struct {
+	XMLName xml.Name `xml:"urn:example color"`
+	Value   string   `xml:",chardata"`
+}
+
+func TestHandler_extendedMkcol(t *testing.T) {
+	ctx := context.Background()
+	colorName := xml.Name{"urn:example", "color"}
+
+	b := NewMemBackend()
+	c := newTestClient(t, &Handler{FileSystem: b})
+
+	if err := c.MkdirWithProps(ctx, "/dir", []interface{}{&testColorProp{Value: "red"}}); err != nil {
+		t.Fatalf("MkdirWithProps() = %v", err)
+	}
+	fi, err := b.Stat(ctx, "/dir")
+	if err != nil {
+		t.Fatalf("Stat() = %v", err)
+	} else if !fi.IsDir {
+		t.Errorf("created resource isn't a collection")
+	}
+	props, err := b.Props(ctx, "/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(props) != 1 || props[0].XMLName != colorName || string(props[0].InnerXML) != "red" {
+		t.Errorf("collection properties = %v, want color=red", props)
+	}
+
+	// Only plain collections can be created
+	const mkcolCalendar = `<?xml version="1.0" encoding="utf-8" ?>
+<D:mkcol xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:set><D:prop>
+    <D:resourcetype><D:collection/><C:calendar/></D:resourcetype>
+    <D:displayname>Calendar</D:displayname>
+  </D:prop></D:set>
+</D:mkcol>`
+	w := serveTestRequest(&Handler{FileSystem: b}, "MKCOL", "/calendar", mkcolCalendar, nil)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("MKCOL with an unsupported resource type status = %v, want %v", w.Code, http.StatusForbidden)
+	}
+	var resp internal.MkcolResponse
+	if err := xml.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode mkcol-response: %v", err)
+	}
+	codes := make(map[xml.Name]int)
+	for _, propstat := range resp.PropStats {
+		for _, raw := range propstat.Prop.Raw {
+			name, _ := raw.XMLName()
+			codes[name] = propstat.Status.Code
+		}
+	}
+	wantCodes := map[xml.Name]int{
+		internal.ResourceTypeName: http.StatusForbidden,
+		internal.DisplayNameName:  http.StatusFailedDependency,
+	}
+	if !reflect.DeepEqual(codes, wantCodes) {
+		t.Errorf("mkcol-response statuses = %v, want %v", codes, wantCodes)
+	}
+	if _, err := b.Stat(ctx, "/calendar"); !internal.IsNotFound(err) {
+		t.Errorf("collection created despite the failed MKCOL: %v", err)
+	}
+
+	// Plain MKCOL requests are still supported
+	if err := c.Mkdir(ctx, "/plain"); err != nil {
+		t.Errorf("Mkdir() = %v", err)
+	}
+	if err := c.Mkdir(ctx, "/missing/plain"); err == nil {
+		t.Errorf("Mkdir() with a missing parent succeeded")
+	}
+}
+
+func TestHandler_extendedMkcolUnsupported(t *testing.T) {
+	fs := newTestTree(t)
+	c := newTestClient(t, &Handler{FileSystem: fs})
+
+	// LocalFileSystem can't store properties
+	err := c.MkdirWithProps(context.Background(), "/dir", []interface{}{&testColorProp{Value: "red"}})
+	if err == nil {
+		t.Fatalf("MkdirWithProps() succeeded")
+	}
+	var httpErr *internal.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Code != http.StatusForbidden {
+		t.Errorf("MkdirWithProps() = %v, want a 403 error", err)
+	}
+	if _, err := os.Stat(filepath.Join(string(fs), "dir")); !os.IsNotExist(err) {
+		t.Errorf("collection created despite the failed MKCOL: %v", err)
+	}
+}
+
+// collectionCreatorFileSystem records the collections created with
+// CreateCollection. If err is set, CreateCollection fails with it.
+type collectionCreatorFileSystem struct {
+	*MemBackend
+	created map[string][]Property
+	err     error
+}
+
+func (fs *collectionCreatorFileSystem) CreateCollection(ctx context.Context, name string, props []Property) error {
+	if fs.err != nil {
+		return fs.err
+	}
+	if err := fs.Mkdir(ctx, name); err != nil {
+		return err
+	}
+	if err := fs.PropPatch(ctx, name, &PropPatchRequest{Set: props}); err != nil {
+		return err
+	}
+	fs.created[name] = props
+	return nil
+}
+
+func TestHandler_collectionCreator(t *testing.T) {
+	ctx := context.Background()
+	colorProp := []interface{}{&testColorProp{Value: "red"}}
+
+	fs := &collectionCreatorFileSystem{MemBackend: NewMemBackend(), created: make(map[string][]Property)}
+	c := newTestClient(t, &Handler{FileSystem: fs})
+
+	if err := c.MkdirWithProps(ctx, "/dir", colorProp); err != nil {
+		t.Fatalf("MkdirWithProps() = %v", err)
+	}
+	props, ok := fs.created["/dir"]
+	if !ok {
+		t.Fatalf("CreateCollection() wasn't called")
+	}
+	if len(props) != 1 || props[0].XMLName.Local != "color" || string(props[0].InnerXML) != "red" {
+		t.Errorf("CreateCollection() props = %v, want color=red", props)
+	}
+
+	// A missing parent is reported as a conflict
+	var httpErr *internal.HTTPError
+	err := c.MkdirWithProps(ctx, "/missing/dir", colorProp)
+	if !errors.As(err, &httpErr) || httpErr.Code != http.StatusConflict {
+		t.Errorf("MkdirWithProps() with a missing parent = %v, want a 409 error", err)
+	}
+
+	// Other errors are reported for the properties
+	fs.err = internal.HTTPErrorf(http.StatusForbidden, "read-only property")
+	const mkcolColor = `<mkcol xmlns="DAV:" xmlns:e="urn:example"><set><prop><e:color>blue</e:color></prop></set></mkcol>`
+	w := serveTestRequest(&Handler{FileSystem: fs}, "MKCOL", "/denied", mkcolColor, nil)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("MKCOL status = %v, want %v", w.Code, http.StatusForbidden)
+	}
+	var resp internal.MkcolResponse
+	if err := xml.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode mkcol-response: %v", err)
+	}
+	if len(resp.PropStats) != 1 || resp.PropStats[0].Status.Code != http.StatusForbidden {
+		t.Errorf("mkcol-response = %+v, want a 403 propstat", resp)
+	}
+
+	// With a DeadPropsStore, properties are stored there
+	fs.err = nil
+	store := newMemDeadPropsStore()
+	c = newTestClient(t, &Handler{FileSystem: fs, DeadPropsStore: store})
+	if err := c.MkdirWithProps(ctx, "/dead", colorProp); err != nil {
+		t.Fatalf("MkdirWithProps() with a DeadPropsStore = %v", err)
+	}
+	if _, ok := fs.created["/dead"]; ok {
+		t.Errorf("CreateCollection() called with a DeadPropsStore")
+	}
+	if fi, err := fs.Stat(ctx, "/dead"); err != nil || !fi.IsDir {
+		t.Errorf("Stat() = %v, %v, want a collection", fi, err)
+	}
+	if got := store.propValues("/dead"); got["color"] != "red" {
+		t.Errorf("stored dead properties = %v, want color=red", got)
+	}
+}
+
+func TestHandler_putPreconditions(t *testing.T) {
+	fs := newTestTree(t)
+	h := &Handler{FileSystem: fs}
+
+	etag := serveTestRequest(h, http.MethodGet, "/a/1.txt", "", nil).Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("GET response has no ETag")
+	}
+
+	for _, tc := range []struct {
+		name   string
+		target string
+		header map[string]string
+		want   int
+	}{
+		{"If-Match mismatch", "/a/1.txt", map[string]string{"If-Match": `"nope"`}, http.StatusPreconditionFailed},
+		{"If-Match missing file", "/a/new.txt", map[string]string{"If-Match": "*"}, http.StatusPreconditionFailed},
+		{"If-None-Match existing file", "/a/1.txt", map[string]string{"If-None-Match": "*"}, http.StatusPreconditionFailed},
+		{"If-None-Match matching ETag", "/a/1.txt", map[string]string{"If-None-Match": etag}, http.StatusPreconditionFailed},
+		{"If-Match", "/a/1.txt", map[string]string{"If-Match": etag}, http.StatusNoContent},
+		{"If-None-Match missing file", "/a/new.txt", map[string]string{"If-None-Match": "*"}, http.StatusCreated},
+		{"unconditional", "/a/b/2.txt", nil, http.StatusNoContent},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serveTestRequest(h, http.MethodPut, tc.target, "hello", tc.header)
+			if w.Code != tc.want {
+				t.Errorf("PUT status = %v, want %v", w.Code, tc.want)
+			}
+		})
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(string(fs), "a/1.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello" {
+		t.Errorf("a/1.txt = %q, want %q", b, "hello")
+	}
+}
+
+func TestHandler_putPreconditionRequired(t *testing.T) {
+	fs := newTestTree(t)
+	h := &Handler{FileSystem: fs, RequireConditionalPut: true}
+
+	w := serveTestRequest(h, http.MethodPut, "/a/1.txt", "hello", nil)
+	if w.Code != http.StatusPreconditionRequired {
+		t.Errorf("unconditional PUT status = %v, want %v", w.Code, http.StatusPreconditionRequired)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(string(fs), "a/1.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "a/1.txt" {
+		t.Errorf("a/1.txt overwritten by a rejected PUT: %q", b)
+	}
+
+	// Creating a new file doesn't risk losing updates
+	w = serveTestRequest(h, http.MethodPut, "/a/new.txt", "hello", nil)
+	if w.Code != http.StatusCreated {
+		t.Errorf("PUT status for new file = %v, want %v", w.Code, http.StatusCreated)
+	}
+
+	etag := serveTestRequest(h, http.MethodGet, "/a/1.txt", "", nil).Header().Get("ETag")
+	w = serveTestRequest(h, http.MethodPut, "/a/1.txt", "hello", map[string]string{"If-Match": etag})
+	if w.Code != http.StatusNoContent {
+		t.Errorf("conditional PUT status = %v, want %v", w.Code, http.StatusNoContent)
+	}
+}
+
+// aclFileSystem is a FileSystem implementing ACLBackend, with a single
+// principal at /d/.
+type aclFileSystem struct {
+	FileSystem
+	acls map[string]*ACL
+}
+
+func (fs *aclFileSystem) Principal(ctx context.Context, name string) (*Principal, error) {
+	if strings.TrimSuffix(name, "/") != "/d" {
+		return nil, nil
+	}
+	return &Principal{
+		URL:           "/d/",
+		AlternateURIs: []string{"mailto:d@example.org"},
+		Members:       []string{"/a/", "/a/b/"},
+	}, nil
+}
+
+func (fs *aclFileSystem) GetACL(ctx context.Context, name string) (*ACL, error) {
+	if acl, ok := fs.acls[name]; ok {
+		return acl, nil
+	}
+	return &ACL{}, nil
+}
+
+func (fs *aclFileSystem) SetACL(ctx context.Context, name string, acl *ACL) error {
+	if fs.acls == nil {
+		fs.acls = make(map[string]*ACL)
+	}
+	fs.acls[name] = acl
+	return nil
+}
+
+func TestHandler_principalProps(t *testing.T) {
+	h := &Handler{FileSystem: &aclFileSystem{FileSystem: newTestTree(t)}}
+
+	const propfind = `<D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/><D:principal-URL/><D:alternate-URI-set/><D:group-member-set/><D:group-membership/></D:prop></D:propfind>`
+	w := serveTestRequest(h, "PROPFIND", "/d/", propfind, map[string]string{"Depth": "0"})
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("PROPFIND status = %v, want %v", w.Code, http.StatusMultiStatus)
+	}
+	var ms internal.MultiStatus
+	if err := xml.NewDecoder(w.Body).Decode(&ms); err != nil {
+		t.Fatalf("failed to decode multistatus: %v", err)
+	}
+	resp := &ms.Responses[0]
+
+	var resType internal.ResourceType
+	if err := resp.DecodeProp(&resType); err != nil {
+		t.Fatalf("DecodeProp(ResourceType) = %v", err)
+	} else if !resType.Is(internal.PrincipalName) || !resType.Is(internal.CollectionName) {
+		t.Errorf("resourcetype = %v, want a principal collection", resType.Raw)
+	}
+
+	var principalURL internal.PrincipalURL
+	if err := resp.DecodeProp(&principalURL); err != nil {
+		t.Fatalf("DecodeProp(PrincipalURL) = %v", err)
+	} else if principalURL.Href.Path != "/d/" {
+		t.Errorf("principal-URL = %v, want %v", principalURL.Href.String(), "/d/")
+	}
+
+	var alternateURIs internal.AlternateURISet
+	if err := resp.DecodeProp(&alternateURIs); err != nil {
+		t.Fatalf("DecodeProp(AlternateURISet) = %v", err)
+	} else if len(alternateURIs.Hrefs) != 1 || alternateURIs.Hrefs[0].String() != "mailto:d@example.org" {
+		t.Errorf("alternate-URI-set = %v, want [mailto:d@example.org]", alternateURIs.Hrefs)
+	}
+
+	var members internal.GroupMemberSet
+	if err := resp.DecodeProp(&members); err != nil {
+		t.Fatalf("DecodeProp(GroupMemberSet) = %v", err)
+	}
+	var memberPaths []string
+	for _, href := range members.Hrefs {
+		memberPaths = append(memberPaths, href.Path)
+	}
+	if want := []string{"/a/", "/a/b/"}; !reflect.DeepEqual(memberPaths, want) {
+		t.Errorf("group-member-set = %v, want %v", memberPaths, want)
+	}
+
+	var memberships internal.GroupMembership
+	if err := resp.DecodeProp(&memberships); err != nil {
+		t.Fatalf("DecodeProp(GroupMembership) = %v", err)
+	} else if len(memberships.Hrefs) != 0 {
+		t.Errorf("group-membership = %v, want none", memberships.Hrefs)
+	}
+
+	// Other files aren't principals
+	w = serveTestRequest(h, "PROPFIND", "/a/1.txt", propfind, map[string]string{"Depth": "0"})
+	ms = internal.MultiStatus{}
+	if err := xml.NewDecoder(w.Body).Decode(&ms); err != nil {
+		t.Fatalf("failed to decode multistatus: %v", err)
+	}
+	if err := ms.Responses[0].DecodeProp(&principalURL); !internal.IsNotFound(err) {
+		t.Errorf("DecodeProp(PrincipalURL) on a regular file = %v, want not found", err)
+	}
+}
+
+// loopFileSystem returns a parent collection as a child of /a/b/c/, as a file
+// system following symbolic links would.
+type loopFileSystem struct {
+	FileSystem
+}
+
+func (fs loopFileSystem) ReadDir(ctx context.Context, name string, recursive bool) ([]FileInfo, error) {
+	l, err := fs.FileSystem.ReadDir(ctx, name, recursive)
+	if err != nil || strings.TrimSuffix(name, "/") != "/a/b/c" {
+		return l, err
+	}
+	parent, err := fs.FileSystem.Stat(ctx, "/a/b/")
+	if err != nil {
+		return nil, err
+	}
+	return append(l, *parent), nil
+}
+
+func TestHandler_propFindDepthInfinityLoop(t *testing.T) {
+	h := &Handler{FileSystem: loopFileSystem{newTestTree(t)}}
+
+	code, hrefs := propFindHrefs(t, h, "infinity")
+	if code != http.StatusMultiStatus {
+		t.Fatalf("status = %v, expected %v", code, http.StatusMultiStatus)
+	}
+	expected := []string{"/a/", "/a/1.txt", "/a/b/", "/a/b/2.txt", "/a/b/c/", "/a/b/c/3.txt"}
+	if !reflect.DeepEqual(hrefs, expected) {
+		t.Errorf("hrefs = %v, expected %v", hrefs, expected)
+	}
+}
+
+func TestHandler_propFindDepthInfinityFile(t *testing.T) {
+	h := &Handler{FileSystem: newTestTree(t), DisableDepthInfinity: true}
+
+	w := serveTestRequest(h, "PROPFIND", "/a/1.txt", `<propfind xmlns="DAV:"><prop><resourcetype/></prop></propfind>`, map[string]string{"Depth": "infinity"})
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("status = %v, expected %v", w.Code, http.StatusMultiStatus)
+	}
+	var ms internal.MultiStatus
+	if err := xml.NewDecoder(w.Body).Decode(&ms); err != nil {
+		t.Fatalf("failed to decode multistatus: %v", err)
+	}
+	if len(ms.Responses) != 1 {
+		t.Errorf("got %v responses, expected 1", len(ms.Responses))
+	}
+}
+
+// failingPropPatcher is a MemBackend refusing all property changes.
+type failingPropPatcher struct {
+	*MemBackend
+}
+
+func (failingPropPatcher) PropPatch(ctx context.Context, name string, req *PropPatchRequest) error {
+	return NewHTTPError(http.StatusInsufficientStorage, errors.New("no space left for properties"))
+}
+
+func TestParseContentRange(t *testing.T) {
+	for _, tc := range []struct {
+		s                string
+		start, end, size int64
+		ok               bool
+	}{
+		{"bytes 0-3/10", 0, 3, 10, true},
+		{"bytes 4-9/10", 4, 9, 10, true},
+		{" bytes  5-5/* ", 5, 5, -1, true},
+		{"0-3/10", 0, 0, 0, false},
+		{"bytes 0-3", 0, 0, 0, false},
+		{"bytes 3-1/10", 0, 0, 0, false},
+		{"bytes -1-3/10", 0, 0, 0, false},
+		{"bytes a-3/10", 0, 0, 0, false},
+		{"bytes 0-3/x", 0, 0, 0, false},
+		{"bytes 0-3/-10", 0, 0, 0, false},
+		{"bytes */10", 0, 0, 0, false},
+	} {
+		start, end, size, err := parseContentRange(tc.s)
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("parseContentRange(%q) = %v, %v, %v, want an error", tc.s, start, end, size)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseContentRange(%q) = %v", tc.s, err)
+		} else if start != tc.start || end != tc.end || size != tc.size {
+			t.Errorf("parseContentRange(%q) = %v, %v, %v, want %v, %v, %v", tc.s, start, end, size, tc.start, tc.end, tc.size)
+		}
+	}
+}
+
+func TestHandler_partialPut(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		target       string
+		contentRange string
+		body         string
+		want         int
+		// wantContent is the expected file content, or empty if the file
+		// must not exist
+		wantContent string
+	}{
+		{"first range", "/new.txt", "bytes 0-3/10", "0123", http.StatusNoContent, "0123"},
+		{"unknown size", "/new.txt", "bytes 0-3/*", "0123", http.StatusNoContent, "0123"},
+		{"whole new file", "/new.txt", "bytes 0-2/3", "abc", http.StatusCreated, "abc"},
+		{"overwrite", "/a/1.txt", "bytes 0-1/2", "xy", http.StatusOK, "xy"},
+		{"middle range", "/a/1.txt", "bytes 2-3/7", "XX", http.StatusNoContent, "a/XXtxt"},
+		{"last range", "/a/1.txt", "bytes 6-7/8", "!!", http.StatusOK, "a/1.tx!!"},
+		{"range beyond the size", "/a/1.txt", "bytes 0-9/5", "0123456789", http.StatusRequestedRangeNotSatisfiable, "a/1.txt"},
+		{"offset beyond the end", "/a/1.txt", "bytes 100-101/200", "xy", http.StatusRequestedRangeNotSatisfiable, "a/1.txt"},
+		{"offset beyond the end of a new file", "/new.txt", "bytes 4-7/8", "4567", http.StatusRequestedRangeNotSatisfiable, ""},
+		{"length mismatch", "/a/1.txt", "bytes 0-3/10", "01", http.StatusBadRequest, "a/1.txt"},
+		{"malformed", "/a/1.txt", "bytes 0-1", "xy", http.StatusBadRequest, "a/1.txt"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := newTestTree(t)
+			h := &Handler{FileSystem: fs}
+			w := serveTestRequest(h, http.MethodPut, tc.target, tc.body, map[string]string{"Content-Range": tc.contentRange})
+			if w.Code != tc.want {
+				t.Errorf("PUT status = %v, want %v", w.Code, tc.want)
+			}
+
+			b, err := ioutil.ReadFile(filepath.Join(string(fs), filepath.FromSlash(tc.target)))
+			if tc.wantContent == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("file created by the failed request: %v", err)
+				}
+			} else if err != nil {
+				t.Error(err)
+			} else if string(b) != tc.wantContent {
+				t.Errorf("file content = %q, want %q", b, tc.wantContent)
+			}
+		})
+	}
+}
+
+func TestHandler_partialPutChunks(t *testing.T) {
+	fs := newTestTree(t)
+	h := &Handler{FileSystem: fs}
+
+	for _, chunk := range []struct {
+		contentRange, body string
+		want               int
+	}{
+		{"bytes 0-3/10", "0123", http.StatusNoContent},
+		{"bytes 4-7/10", "4567", http.StatusNoContent},
+		{"bytes 8-9/10", "89", http.StatusOK},
+	} {
+		w := serveTestRequest(h, http.MethodPut, "/a/1.txt", chunk.body, map[string]string{"Content-Range": chunk.contentRange})
+		if w.Code != chunk.want {
+			t.Errorf("PUT %v status = %v, want %v", chunk.contentRange, w.Code, chunk.want)
+		}
+		if w.Header().Get("ETag") == "" {
+			t.Errorf("PUT %v response has no ETag", chunk.contentRange)
+		}
+	}
+
+	if b, err := ioutil.ReadFile(filepath.Join(string(fs), "a", "1.txt")); err != nil {
+		t.Fatal(err)
+	} else if string(b) != "0123456789" {
+		t.Errorf("file content = %q, want %q", b, "0123456789")
+	}
+}
+
+func TestHandler_partialPutUnsupported(t *testing.T) {
+	fs := newTestTree(t)
+	// Hide the PartialWriter implementation
+	h := &Handler{FileSystem: struct{ FileSystem }{fs}}
+	w := serveTestRequest(h, http.MethodPut, "/a/1.txt", "xy", map[string]string{"Content-Range": "bytes 0-1/10"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("PUT status = %v, want %v", w.Code, http.StatusBadRequest)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(string(fs), "a", "1.txt")); err != nil || string(b) != "a/1.txt" {
+		t.Errorf("file content = %q, %v, want it unchanged", b, err)
+	}
+}
+
+func TestHandler_putContentLanguage(t *testing.T) {
+	osBackend := newTestOSBackend(t)
+	for _, tc := range []struct {
+		name  string
+		fs    FileSystem
+		store DeadPropsStore
+		want  string
+	}{
+		{"MemBackend", NewMemBackend(), nil, "fr"},
+		{"LocalFileSystem", newTestTree(t), nil, ""},
+		{"failing PropPatch", failingPropPatcher{NewMemBackend()}, nil, ""},
+		{"DeadPropsStore", newTestTree(t), newMemDeadPropsStore(), "fr"},
+		{"OSBackend", osBackend, osBackend, "fr"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &Handler{FileSystem: tc.fs, DeadPropsStore: tc.store}
+
+			w := serveTestRequest(h, http.MethodPut, "/file.txt", "bonjour", map[string]string{"Content-Language": "fr"})
+			if w.Code != http.StatusCreated {
+				t.Fatalf("PUT status = %v, want %v", w.Code, http.StatusCreated)
+			}
+			if lang := w.Header().Get("Content-Language"); lang != tc.want {
+				t.Errorf("PUT Content-Language = %q, want %q", lang, tc.want)
+			}
+
+			w = serveTestRequest(h, http.MethodGet, "/file.txt", "", nil)
+			if w.Body.String() != "bonjour" {
+				t.Errorf("GET body = %q, want %q", w.Body.String(), "bonjour")
+			}
+			if lang := w.Header().Get("Content-Language"); lang != tc.want {
+				t.Errorf("GET Content-Language = %q, want %q", lang, tc.want)
+			}
+
+			c := newTestClient(t, h)
+			fi, err := c.Stat(context.Background(), "/file.txt")
+			if err != nil {
+				t.Fatalf("Stat() = %v", err)
+			}
+			if fi.Language != tc.want {
+				t.Errorf("FileInfo.Language = %q, want %q", fi.Language, tc.want)
+			}
+		})
+	}
+}
+
+// memDeadPropsStore is a DeadPropsStore keeping properties in memory. Setting
+// the fail property is rejected with 507 Insufficient Storage.
+type memDeadPropsStore struct {
+	props map[string][]Property
+	fail  xml.Name
+}
+
+func newMemDeadPropsStore() *memDeadPropsStore {
+	return &memDeadPropsStore{
+		props: make(map[string][]Property),
+		fail:  xml.Name{"urn:example", "fail"},
+	}
+}
+
+func (s *memDeadPropsStore) key(name string) string {
+	return path.Clean(name)
+}
+
+func (s *memDeadPropsStore) GetDeadProps(ctx context.Context, name string) ([]Property, error) {
+	return append([]Property(nil), s.props[s.key(name)]...), nil
+}
+
+func (s *memDeadPropsStore) SetDeadProps(ctx context.Context, name string, props []Property) error {
+	l := s.props[s.key(name)]
+	for _, prop := range props {
+		if prop.XMLName == s.fail {
+			return NewHTTPError(http.StatusInsufficientStorage, errors.New("no space left for properties"))
+		}
+	}
+	for _, prop := range props {
+		replaced := false
+		for i := range l {
+			if l[i].XMLName == prop.XMLName {
+				l[i] = prop
+				replaced = true
+			}
+		}
+		if !replaced {
+			l = append(l, prop)
+		}
+	}
+	s.props[s.key(name)] = l
+	return nil
+}
+
+func (s *memDeadPropsStore) RemoveDeadProps(ctx context.Context, name string, names []xml.Name) error {
+	if names == nil {
+		delete(s.props, s.key(name))
+		return nil
+	}
+	var l []Property
+	for _, prop := range s.props[s.key(name)] {
+		removed := false
+		for _, n := range names {
+			if prop.XMLName == n {
+				removed = true
+			}
+		}
+		if !removed {
+			l = append(l, prop)
+		}
+	}
+	s.props[s.key(name)] = l
+	return nil
+}
+
+// propValues returns the stored dead properties of a file, keyed by local
+// name.
+func (s *memDeadPropsStore) propValues(name string) map[string]string {
+	m := make(map[string]string)
+	for _, prop := range s.props[s.key(name)] {
+		m[prop.XMLName.Local] = string(prop.InnerXML)
+	}
+	return m
+}
+
+// propPatchStatuses sends a PROPPATCH request and returns the status of each
+// property, keyed by local name.
+func propPatchStatuses(t *testing.T, h http.Handler, target, body string) map[string]int {
+	body = `<propertyupdate xmlns="DAV:" xmlns:e="urn:example">` + body + `</propertyupdate>`
+	w := serveTestRequest(h, "PROPPATCH", target, body, nil)
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("PROPPATCH status = %v, want %v", w.Code, http.StatusMultiStatus)
+	}
+	var ms internal.MultiStatus
+	if err := xml.NewDecoder(w.Body).Decode(&ms); err != nil {
+		t.Fatalf("failed to decode multistatus: %v", err)
+	}
+	m := make(map[string]int)
+	for _, resp := range ms.Responses {
+		for _, propstat := range resp.PropStats {
+			for _, raw := range propstat.Prop.Raw {
+				if name, ok := raw.XMLName(); ok {
+					m[name.Local] = propstat.Status.Code
+				}
+			}
+		}
+	}
+	return m
+}
+
+func TestHandler_deadProps(t *testing.T) {
+	store := newMemDeadPropsStore()
+	h := &Handler{FileSystem: newTestTree(t), DeadPropsStore: store}
+
+	for _, tc := range []struct {
+		name string
+		body string
+		want map[string]string
+	}{
+		{
+			name: "set",
+			body: `<set><prop><e:color>red</e:color><e:size>large</e:size></prop></set>`,
+			want: map[string]string{"color": "red", "size": "large"},
+		},
+		{
+			name: "remove then set",
+			body: `<remove><prop><e:color/></prop></remove><set><prop><e:color>blue</e:color></prop></set>`,
+			want: map[string]string{"color": "blue", "size": "large"},
+		},
+		{
+			name: "set then remove",
+			body: `<set><prop><e:size>small</e:size></prop></set><remove><prop><e:size/></prop></remove>`,
+			want: map[string]string{"color": "blue"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			statuses := propPatchStatuses(t, h, "/a/1.txt", tc.body)
+			for name, code := range statuses {
+				if code != http.StatusOK {
+					t.Errorf("status of %v = %v, want %v", name, code, http.StatusOK)
+				}
+			}
+			if got := store.propValues("/a/1.txt"); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("stored properties = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	// Stored properties are returned by PROPFIND, live properties take
+	// precedence
+	c := newTestClient(t, h)
+	resp, err := c.ic.PropFindFlat(context.Background(), "/a/1.txt", internal.NewPropNamePropFind(
+		xml.Name{"urn:example", "color"},
+		internal.GetContentLengthName,
+	))
+	if err != nil {
+		t.Fatalf("PROPFIND = %v", err)
+	}
+	var color testColorProp
+	if err := resp.DecodeProp(&color); err != nil {
+		t.Errorf("DecodeProp(color) = %v", err)
+	} else if color.Value != "blue" {
+		t.Errorf("color = %q, want %q", color.Value, "blue")
+	}
+
+	w := serveTestRequest(h, "PROPPATCH", "/missing", `<propertyupdate xmlns="DAV:"><set><prop><color xmlns="urn:example">red</color></prop></set></propertyupdate>`, nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("PROPPATCH on a missing file status = %v, want %v", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestHandler_deadPropsAtomic(t *testing.T) {
+	store := newMemDeadPropsStore()
+	h := &Handler{FileSystem: newTestTree(t), DeadPropsStore: store}
+
+	propPatchStatuses(t, h, "/a/", `<set><prop><e:color>red</e:color><e:size>large</e:size></prop></set>`)
+
+	statuses := propPatchStatuses(t, h, "/a/", `<set><prop><e:color>blue</e:color></prop></set>`+
+		`<remove><prop><e:size/></prop></remove>`+
+		`<set><prop><e:fail>yes</e:fail></prop></set>`)
+	want := map[string]int{
+		"color": http.StatusFailedDependency,
+		"size":  http.StatusFailedDependency,
+		"fail":  http.StatusInsufficientStorage,
+	}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("statuses = %v, want %v", statuses, want)
+	}
+	if got, want := store.propValues("/a"), map[string]string{"color": "red", "size": "large"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stored properties after a failed PROPPATCH = %v, want %v", got, want)
+	}
+}
+
+func TestHandler_deadPropsCopyMoveDelete(t *testing.T) {
+	store := newMemDeadPropsStore()
+	h := &Handler{FileSystem: newTestTree(t), DeadPropsStore: store}
+
+	propPatchStatuses(t, h, "/a/b/", `<set><prop><e:color>red</e:color></prop></set>`)
+	propPatchStatuses(t, h, "/a/b/2.txt", `<set><prop><e:color>blue</e:color></prop></set>`)
+	propPatchStatuses(t, h, "/d/", `<set><prop><e:color>green</e:color></prop></set>`)
+
+	expectProps := func(name, color string) {
+		t.Helper()
+		want := map[string]string{}
+		if color != "" {
+			want["color"] = color
+		}
+		if got := store.propValues(name); !reflect.DeepEqual(got, want) {
+			t.Errorf("properties of %v = %v, want %v", name, got, want)
+		}
+	}
+
+	w := serveTestRequest(h, "COPY", "/a/b/", "", map[string]string{"Destination": "/d/b/"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("COPY status = %v, want %v", w.Code, http.StatusCreated)
+	}
+	expectProps("/a/b", "red")
+	expectProps("/a/b/2.txt", "blue")
+	expectProps("/d/b", "red")
+	expectProps("/d/b/2.txt", "blue")
+
+	w = serveTestRequest(h, "COPY", "/a/b/", "", map[string]string{"Destination": "/e/", "Depth": "0"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("COPY status = %v, want %v", w.Code, http.StatusCreated)
+	}
+	expectProps("/e", "red")
+
+	w = serveTestRequest(h, "MOVE", "/d/b/", "", map[string]string{"Destination": "/f/"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("MOVE status = %v, want %v", w.Code, http.StatusCreated)
+	}
+	expectProps("/d/b", "")
+	expectProps("/d/b/2.txt", "")
+	expectProps("/f", "red")
+	expectProps("/f/2.txt", "blue")
+
+	// The properties of an overwritten destination are replaced
+	w = serveTestRequest(h, "MOVE", "/f/", "", map[string]string{"Destination": "/d/"})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("MOVE status = %v, want %v", w.Code, http.StatusNoContent)
+	}
+	expectProps("/d", "red")
+	expectProps("/d/2.txt", "blue")
+
+	w = serveTestRequest(h, http.MethodDelete, "/d/", "", nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE status = %v, want %v", w.Code, http.StatusNoContent)
+	}
+	expectProps("/d", "")
+	expectProps("/d/2.txt", "")
+	expectProps("/a/b", "red")
+}
+
+func TestMatchETags(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		etag   string
+		weak   bool
+		want   bool
+	}{
+		{`"abc"`, "abc", false, true},
+		{`"abc"`, "abd", false, false},
+		{`"x", "abc"`, "abc", false, true},
+		{` "x" ,"abc" `, "abc", true, true},
+		{`W/"abc"`, "abc", false, false},
+		{`W/"abc"`, "abc", true, true},
+		{"*", "abc", false, true},
+		{" * ", "", false, true},
+		{`"abc"`, "", true, false},
+		{`abc`, "abc", false, false},
+		{"", "abc", true, false},
+	} {
+		if got := matchETags(tc.header, tc.etag, tc.weak); got != tc.want {
+			t.Errorf("matchETags(%q, %q, %v) = %v, want %v", tc.header, tc.etag, tc.weak, got, tc.want)
+		}
+	}
+}
+
+func TestEvalPreconditions(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+	fi := &FileInfo{Path: "/file", ETag: "abc", ModTime: modTime}
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		header map[string]string
+		fi     *FileInfo
+		want   int // 0 for success, -1 for errNotModified
+	}{
+		{"no header", http.MethodGet, nil, fi, 0},
+		{"If-Match", http.MethodPut, map[string]string{"If-Match": `"abc"`}, fi, 0},
+		{"If-Match mismatch", http.MethodPut, map[string]string{"If-Match": `"x"`}, fi, http.StatusPreconditionFailed},
+		{"If-Match weak", http.MethodPut, map[string]string{"If-Match": `W/"abc"`}, fi, http.StatusPreconditionFailed},
+		{"If-Match missing file", http.MethodPut, map[string]string{"If-Match": "*"}, nil, http.StatusPreconditionFailed},
+		{"If-None-Match GET", http.MethodGet, map[string]string{"If-None-Match": `W/"abc"`}, fi, -1},
+		{"If-None-Match HEAD", http.MethodHead, map[string]string{"If-None-Match": `"abc"`}, fi, -1},
+		{"If-None-Match GET mismatch", http.MethodGet, map[string]string{"If-None-Match": `"x"`}, fi, 0},
+		{"If-None-Match PUT", http.MethodPut, map[string]string{"If-None-Match": `"abc"`}, fi, http.StatusPreconditionFailed},
+		{"If-None-Match * PUT", http.MethodPut, map[string]string{"If-None-Match": "*"}, fi, http.StatusPreconditionFailed},
+		{"If-None-Match * missing file", http.MethodPut, map[string]string{"If-None-Match": "*"}, nil, 0},
+		{"If-Modified-Since", http.MethodGet, map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)}, fi, -1},
+		{"If-Modified-Since older", http.MethodGet, map[string]string{"If-Modified-Since": modTime.Add(-time.Second).Format(http.TimeFormat)}, fi, 0},
+		{"If-Modified-Since PUT", http.MethodPut, map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)}, fi, 0},
+		{"If-Modified-Since invalid", http.MethodGet, map[string]string{"If-Modified-Since": "yesterday"}, fi, 0},
+		{"If-Modified-Since with If-None-Match", http.MethodGet, map[string]string{
+			"If-None-Match":     `"x"`,
+			"If-Modified-Since": modTime.Format(http.TimeFormat),
+		}, fi, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/file", nil)
+			for k, v := range tc.header {
+				r.Header.Set(k, v)
+			}
+			err := evalPreconditions(r, tc.fi)
+			switch tc.want {
+			case 0:
+				if err != nil {
+					t.Errorf("evalPreconditions() = %v, want success", err)
+				}
+			case -1:
+				if err != errNotModified {
+					t.Errorf("evalPreconditions() = %v, want errNotModified", err)
+				}
+			default:
+				if code := internal.HTTPErrorFromError(err).Code; err == nil || code != tc.want {
+					t.Errorf("evalPreconditions() = %v, want status %v", err, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_getNotModified(t *testing.T) {
+	h := &Handler{FileSystem: newTestTree(t)}
+
+	w := serveTestRequest(h, http.MethodGet, "/a/1.txt", "", nil)
+	etag, lastModified := w.Header().Get("ETag"), w.Header().Get("Last-Modified")
+	if w.Code != http.StatusOK || etag == "" || lastModified == "" {
+		t.Fatalf("GET = %v with ETag %q and Last-Modified %q", w.Code, etag, lastModified)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		header map[string]string
+		want   int
+	}{
+		{"If-None-Match", http.MethodGet, map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"If-None-Match HEAD", http.MethodHead, map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"If-Modified-Since", http.MethodGet, map[string]string{"If-Modified-Since": lastModified}, http.StatusNotModified},
+		{"If-None-Match mismatch", http.MethodGet, map[string]string{"If-None-Match": `"x"`}, http.StatusOK},
+		{"If-Match mismatch", http.MethodGet, map[string]string{"If-Match": `"x"`}, http.StatusPreconditionFailed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serveTestRequest(h, tc.method, "/a/1.txt", "", tc.header)
+			if w.Code != tc.want {
+				t.Fatalf("status = %v, want %v", w.Code, tc.want)
+			}
+			if tc.want != http.StatusNotModified {
+				return
+			}
+			if w.Body.Len() != 0 {
+				t.Errorf("304 response has a body: %q", w.Body.String())
+			}
+			if got := w.Header().Get("ETag"); got != etag {
+				t.Errorf("ETag = %q, want %q", got, etag)
+			}
+			if got := w.Header().Get("Last-Modified"); got != lastModified {
+				t.Errorf("Last-Modified = %q, want %q", got, lastModified)
+			}
+		})
+	}
+}
+
+// optionsFileSystem customizes the capabilities of /a/1.txt.
+type optionsFileSystem struct {
+	FileSystem
+}
+
+func (optionsFileSystem) Options(ctx context.Context, name string) (*CapabilitySet, error) {
+	switch name {
+	case "/a/1.txt":
+		return &CapabilitySet{
+			DAV:   []string{"example-extension", "2", "1"},
+			Allow: []string{http.MethodOptions, http.MethodGet},
+		}, nil
+	case "/missing":
+		return nil, NewHTTPError(http.StatusNotFound, errors.New("no such file"))
+	}
+	return nil, nil
+}
+
+func TestHandler_optionsFileSystem(t *testing.T) {
+	fs := newTestTree(t)
+	h := &Handler{FileSystem: optionsFileSystem{fs}}
+	defaultHandler := &Handler{FileSystem: fs}
+
+	w := serveTestRequest(h, http.MethodOptions, "/a/1.txt", "", nil)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("OPTIONS status = %v, want %v", w.Code, http.StatusNoContent)
+	}
+	if got, want := w.Header().Get("DAV"), "1, 2, 3, example-extension"; got != want {
+		t.Errorf("DAV = %q, want %q", got, want)
+	}
+	if got, want := w.Header().Get("Allow"), "OPTIONS, GET"; got != want {
+		t.Errorf("Allow = %q, want %q", got, want)
+	}
+
+	// A nil CapabilitySet falls back to the defaults
+	w = serveTestRequest(h, http.MethodOptions, "/a/b/2.txt", "", nil)
+	defaultW := serveTestRequest(defaultHandler, http.MethodOptions, "/a/b/2.txt", "", nil)
+	for _, k := range []string{"DAV", "Allow"} {
+		if got, want := w.Header().Get(k), defaultW.Header().Get(k); got != want || got == "" {
+			t.Errorf("%v = %q, want %q", k, got, want)
+		}
+	}
+
+	w = serveTestRequest(h, http.MethodOptions, "/missing", "", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("OPTIONS status = %v, want %v", w.Code, http.StatusNotFound)
+	}
+}
+
+// nonSeekableFileSystem returns files which aren't an io.Seeker. If
+// unknownSize is set, the size of files isn't reported.
+type nonSeekableFileSystem struct {
+	FileSystem
+	unknownSize bool
+}
+
+func (fs nonSeekableFileSystem) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	f, err := fs.FileSystem.Open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{f, f}, nil
+}
+
+func (fs nonSeekableFileSystem) Stat(ctx context.Context, name string) (*FileInfo, error) {
+	fi, err := fs.FileSystem.Stat(ctx, name)
+	if err == nil && fs.unknownSize {
+		fi.Size = 0
+	}
+	return fi, err
+}
+
+func TestHandler_getNonSeekable(t *testing.T) {
+	fs := newTestTree(t)
+	if err := ioutil.WriteFile(filepath.Join(string(fs), "a", "data"), []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name        string
+		unknownSize bool
+		method      string
+		target      string
+		header      map[string]string
+		wantCode    int
+		wantBody    string
+	}{
+		{"unknown type", false, http.MethodGet, "/a/data", nil, http.StatusOK, "0123456789"},
+		{"unknown type HEAD", false, http.MethodHead, "/a/data", nil, http.StatusOK, ""},
+		{"unknown type range", false, http.MethodGet, "/a/data", map[string]string{"Range": "bytes=2-4"}, http.StatusOK, "0123456789"},
+		{"known type", false, http.MethodGet, "/a/1.txt", nil, http.StatusOK, "a/1.txt"},
+		{"range", false, http.MethodGet, "/a/1.txt", map[string]string{"Range": "bytes=2-4"}, http.StatusPartialContent, "1.t"},
+		{"multiple ranges", false, http.MethodGet, "/a/1.txt", map[string]string{"Range": "bytes=4-5,0-1"}, http.StatusOK, "a/1.txt"},
+		{"unknown size", true, http.MethodGet, "/a/1.txt", nil, http.StatusOK, "a/1.txt"},
+		{"unknown size range", true, http.MethodGet, "/a/1.txt", map[string]string{"Range": "bytes=2-4"}, http.StatusOK, "a/1.txt"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := &Handler{FileSystem: nonSeekableFileSystem{fs, tc.unknownSize}}
+			w := serveTestRequest(h, tc.method, tc.target, "", tc.header)
+			if w.Code != tc.wantCode {
+				t.Fatalf("status = %v, want %v (body: %q)", w.Code, tc.wantCode, w.Body.String())
+			}
+			if w.Body.String() != tc.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tc.wantBody)
+			}
+			if cl := w.Header().Get("Content-Length"); cl != "" && cl != strconv.Itoa(len(tc.wantBody)) && tc.method != http.MethodHead {
+				t.Errorf("Content-Length = %v, want %v", cl, len(tc.wantBody))
+			}
+		})
+	}
+}
+
+// syncFileSystem is a SyncFileSystem recording the paths of changed files in
+// history. Sync tokens are indices in history. If truncate is set, results
+// over the limit are truncated.
+type syncFileSystem struct {
+	FileSystem
+	history  []string
+	truncate bool
+}
+
+func (fs *syncFileSystem) SyncToken(ctx context.Context, name string) (string, error) {
+	return strconv.Itoa(len(fs.history)), nil
+}
+
+func (fs *syncFileSystem) SyncCollection(ctx context.Context, name string, query *SyncQuery) (*SyncResponse, error) {
+	start := 0
+	if query.SyncToken != "" {
+		var err error
+		start, err = strconv.Atoi(query.SyncToken)
+		if err != nil || start < 0 || start > len(fs.history) {
+			return nil, ErrInvalidSyncToken
+		}
+	}
+
+	changes := fs.history[start:]
+	res := &SyncResponse{SyncToken: strconv.Itoa(len(fs.history))}
+	if fs.truncate && query.Limit > 0 && len(changes) > query.Limit {
+		changes = changes[:query.Limit]
+		res.SyncToken = strconv.Itoa(start + query.Limit)
+		res.Truncated = true
+	}
+	for _, p := range changes {
+		fi, err := fs.FileSystem.Stat(ctx, p)
+		if internal.IsNotFound(err) {
+			res.Deleted = append(res.Deleted, p)
+		} else if err != nil {
+			return nil, err
+		} else {
+			res.Updated = append(res.Updated, *fi)
+		}
+	}
+	return res, nil
+}
+
+func TestHandler_syncCollection(t *testing.T) {
+	ctx := context.Background()
+	b := NewMemBackend()
+	for _, name := range []string{"/1.txt", "/2.txt", "/3.txt"} {
+		if _, _, err := b.Create(ctx, name, ioutil.NopCloser(strings.NewReader(name))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs := &syncFileSystem{
+		FileSystem: b,
+		history:    []string{"/1.txt", "/deleted.txt", "/2.txt", "/3.txt"},
+	}
+	c := newTestClient(t, &Handler{FileSystem: fs})
+
+	summary := func(res *SyncResponse) []string {
+		var l []string
+		for _, fi := range res.Updated {
+			l = append(l, "updated "+fi.Path)
+		}
+		for _, p := range res.Deleted {
+			l = append(l, "deleted "+p)
+		}
+		return l
+	}
+
+	res, err := c.SyncCollection(ctx, "/", &SyncQuery{})
+	if err != nil {
+		t.Fatalf("SyncCollection() = %v", err)
+	}
+	want := []string{"updated /1.txt", "updated /2.txt", "updated /3.txt", "deleted /deleted.txt"}
+	if got := summary(res); !reflect.DeepEqual(got, want) || res.SyncToken != "4" || res.Truncated {
+		t.Errorf("SyncCollection() = %v, %q, truncated: %v, want %v, %q", got, res.SyncToken, res.Truncated, want, "4")
+	}
+
+	res, err = c.SyncCollection(ctx, "/", &SyncQuery{SyncToken: "3"})
+	if err != nil {
+		t.Fatalf("SyncCollection() = %v", err)
+	}
+	if got, want := summary(res), []string{"updated /3.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SyncCollection() = %v, want %v", got, want)
+	}
+
+	// Without truncation support, a result over the limit is an error
+	_, err = c.SyncCollection(ctx, "/", &SyncQuery{Limit: 2})
+	var httpErr *internal.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Code != http.StatusInsufficientStorage {
+		t.Errorf("SyncCollection() over the limit = %v, want status %v", err, http.StatusInsufficientStorage)
+	}
+	w := serveTestRequest(&Handler{FileSystem: fs}, "REPORT", "/", `<sync-collection xmlns="DAV:"><sync-token/><sync-level>1</sync-level><limit><nresults>2</nresults></limit><prop/></sync-collection>`, nil)
+	if w.Code != http.StatusInsufficientStorage || !strings.Contains(w.Body.String(), "number-of-matches-within-limits") {
+		t.Errorf("REPORT over the limit = %v %q, want status %v with number-of-matches-within-limits", w.Code, w.Body.String(), http.StatusInsufficientStorage)
+	}
+
+	fs.truncate = true
+	var got []string
+	token := ""
+	for i := 0; i < 3; i++ {
+		res, err := c.SyncCollection(ctx, "/", &SyncQuery{SyncToken: token, Limit: 2})
+		if err != nil {
+			t.Fatalf("SyncCollection() = %v", err)
+		}
+		if wantTruncated := i == 0; res.Truncated != wantTruncated {
+			t.Errorf("SyncCollection() #%v truncated: %v, want %v", i, res.Truncated, wantTruncated)
+		}
+		got = append(got, summary(res)...)
+		token = res.SyncToken
+		if !res.Truncated {
+			break
+		}
+	}
+	want = []string{"updated /1.txt", "deleted /deleted.txt", "updated /2.txt", "updated /3.txt"}
+	if !reflect.DeepEqual(got, want) || token != "4" {
+		t.Errorf("truncated SyncCollection() = %v, %q, want %v, %q", got, token, want, "4")
+	}
+
+	_, err = c.SyncCollection(ctx, "/", &SyncQuery{SyncToken: "invalid"})
+	if !errors.As(err, &httpErr) || httpErr.Code != http.StatusForbidden {
+		t.Errorf("SyncCollection() with an invalid token = %v, want status %v", err, http.StatusForbidden)
+	}
+}
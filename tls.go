@@ -0,0 +1,39 @@
+package webdav
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+)
+
+// TLSClientAuth returns an HTTP transport presenting cert to servers requiring
+// TLS client certificate authentication (mutual TLS). If roots is non-nil, it
+// is used instead of the system pool to verify server certificates. The other
+// settings are copied from http.DefaultTransport.
+//
+// The transport can be wrapped by DigestAuthTransport or BearerAuthTransport
+// when the server requires HTTP authentication on top of the client
+// certificate:
+//
+//	t := webdav.TLSClientAuth(cert, roots)
+//	c := &http.Client{Transport: webdav.DigestAuthTransport(t, username, password)}
+//	client, err := webdav.NewClient(c, endpoint)
+func TLSClientAuth(cert tls.Certificate, roots *x509.CertPool) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      roots,
+	}
+	return t
+}
+
+// NewClientTLS creates a new WebDAV client sending requests with t, for
+// instance a transport returned by TLSClientAuth. If t is nil,
+// http.DefaultTransport is used.
+func NewClientTLS(endpoint string, t *http.Transport) (*Client, error) {
+	c := &http.Client{}
+	if t != nil {
+		c.Transport = t
+	}
+	return NewClient(c, endpoint)
+}
@@ -0,0 +1,123 @@
+package webdav
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestClientCert(t *testing.T) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "alice"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, leaf
+}
+
+func TestTLSClientAuth(t *testing.T) {
+	cert, leaf := newTestClientCert(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(leaf)
+
+	var peer string
+	h := &Handler{FileSystem: newTestTree(t)}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			peer = r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+		h.ServeHTTP(w, r)
+	}))
+	// Handshake failures are expected
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	c, err := NewClientTLS(srv.URL, TLSClientAuth(cert, roots))
+	if err != nil {
+		t.Fatalf("NewClientTLS() = %v", err)
+	}
+	if _, err := c.Stat(context.Background(), "/a/1.txt"); err != nil {
+		t.Fatalf("Stat() = %v", err)
+	}
+	if peer != "alice" {
+		t.Errorf("server saw client certificate %q, want %q", peer, "alice")
+	}
+
+	// The server certificate isn't trusted without roots
+	c, err = NewClientTLS(srv.URL, TLSClientAuth(cert, nil))
+	if err != nil {
+		t.Fatalf("NewClientTLS() = %v", err)
+	}
+	if _, err := c.Stat(context.Background(), "/a/1.txt"); err == nil {
+		t.Errorf("Stat() succeeded with an untrusted server certificate")
+	}
+
+	// The server rejects clients without a certificate
+	c, err = NewClientTLS(srv.URL, TLSClientAuth(tls.Certificate{}, roots))
+	if err != nil {
+		t.Fatalf("NewClientTLS() = %v", err)
+	}
+	if _, err := c.Stat(context.Background(), "/a/1.txt"); err == nil {
+		t.Errorf("Stat() succeeded without a client certificate")
+	}
+}
+
+func TestTLSClientAuth_defaults(t *testing.T) {
+	cert, _ := newTestClientCert(t)
+	tr := TLSClientAuth(cert, nil)
+	if tr == http.DefaultTransport {
+		t.Fatalf("TLSClientAuth() returned http.DefaultTransport")
+	}
+	if tr.Proxy == nil || tr.IdleConnTimeout != http.DefaultTransport.(*http.Transport).IdleConnTimeout {
+		t.Errorf("TLSClientAuth() didn't copy the http.DefaultTransport settings")
+	}
+	if len(tr.TLSClientConfig.Certificates) != 1 || tr.TLSClientConfig.RootCAs != nil {
+		t.Errorf("TLSClientAuth() TLS config = %+v", tr.TLSClientConfig)
+	}
+
+	srv := httptest.NewServer(&Handler{FileSystem: newTestTree(t)})
+	defer srv.Close()
+	c, err := NewClientTLS(srv.URL, nil)
+	if err != nil {
+		t.Fatalf("NewClientTLS() = %v", err)
+	}
+	if _, err := c.Stat(context.Background(), "/a/1.txt"); err != nil {
+		t.Errorf("Stat() = %v", err)
+	}
+}
@@ -0,0 +1,279 @@
+package webdav
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+// resumableChunkSize is the size of the ranges sent by resumable uploads.
+var resumableChunkSize int64 = 8 << 20
+
+// Upload is a resumable upload, created by Client.CreateResumable.
+type Upload struct {
+	c    *Client
+	name string
+	r    io.Reader
+	size int64
+
+	// offset is the number of bytes committed to the server
+	offset int64
+	// pos is the number of bytes read from r
+	pos int64
+	// buf holds the last bytes read from r, ending at pos
+	buf []byte
+	// probe is set when the committed length needs to be fetched from the
+	// server before uploading more data
+	probe bool
+	// noPartial is set when the server doesn't support partial PUT requests
+	noPartial bool
+	// verified is set once the server has been seen to honor Content-Range
+	verified bool
+	done     bool
+}
+
+// CreateResumable uploads size bytes read from r to a file, replacing any
+// existing file. The file is sent in chunks with "Content-Range: bytes
+// start-end/total" PUT requests, so that an interrupted upload can be resumed
+// by calling Upload.Resume instead of restarting from zero.
+//
+// If the server rejects partial PUT requests, or turns out to ignore their
+// Content-Range header field, the file is uploaded with a single PUT request
+// instead, which can only be resumed from the start.
+//
+// If the upload fails, the returned Upload is non-nil along with the error.
+// Resuming the upload, or falling back to a single PUT request after some
+// chunks have been sent, requires r to rewind: if r doesn't implement
+// io.Seeker, it can only be resumed from the last chunk.
+func (c *Client) CreateResumable(ctx context.Context, name string, r io.Reader, size int64) (*Upload, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("webdav: invalid upload size %v", size)
+	}
+
+	u := &Upload{c: c, name: name, r: r, size: size}
+	if err := u.upload(ctx); err != nil {
+		return u, err
+	}
+	return u, nil
+}
+
+// Offset returns the number of bytes committed to the server.
+func (u *Upload) Offset() int64 {
+	return u.offset
+}
+
+// Size returns the total size of the file.
+func (u *Upload) Size() int64 {
+	return u.size
+}
+
+// Done reports whether the whole file has been uploaded.
+func (u *Upload) Done() bool {
+	return u.done
+}
+
+// Resume continues a failed upload. If a part of the file has been committed,
+// the length of the file stored on the server is probed with a HEAD request,
+// and the upload continues from there. Otherwise, the upload restarts from the
+// beginning.
+func (u *Upload) Resume(ctx context.Context) error {
+	if u.Done() {
+		return nil
+	}
+	return u.upload(ctx)
+}
+
+func (u *Upload) upload(ctx context.Context) error {
+	if u.probe {
+		offset, err := u.probeLength(ctx)
+		if err != nil {
+			return err
+		}
+		if offset > u.size {
+			return fmt.Errorf("webdav: file is larger than the upload (%v > %v bytes)", offset, u.size)
+		}
+		if err := u.seek(offset); err != nil {
+			return err
+		}
+		u.probe = false
+	}
+
+	for !u.done {
+		if u.noPartial || (u.offset == 0 && u.size <= resumableChunkSize) {
+			return u.putAll(ctx)
+		} else if u.offset == u.size {
+			u.done = true
+			break
+		}
+
+		n := u.size - u.offset
+		if n > resumableChunkSize {
+			n = resumableChunkSize
+		}
+		if err := u.fill(n); err != nil {
+			return err
+		}
+
+		err := u.putRange(ctx, u.buf[:n])
+		var httpErr *internal.HTTPError
+		if u.offset == 0 && errors.As(err, &httpErr) && (httpErr.Code == http.StatusBadRequest || httpErr.Code == http.StatusNotImplemented) {
+			// Partial PUT requests aren't supported
+			u.noPartial = true
+			continue
+		} else if err != nil {
+			// Until a chunk has been committed, the file stored on the
+			// server may be unrelated to this upload
+			u.probe = u.offset > 0
+			return err
+		}
+
+		if u.offset > 0 && !u.verified {
+			// A server ignoring Content-Range replaces the whole file with
+			// the chunk. This can't be detected with the first chunk, which
+			// starts at offset 0.
+			length, err := u.probeLength(ctx)
+			if err != nil {
+				u.probe = true
+				return err
+			}
+			if length != u.offset+n {
+				u.noPartial = true
+				continue
+			}
+			u.verified = true
+		}
+
+		u.offset += n
+		u.buf = u.buf[:copy(u.buf, u.buf[n:])]
+	}
+	return nil
+}
+
+// putAll uploads the whole file with a single PUT request.
+func (u *Upload) putAll(ctx context.Context) error {
+	if err := u.seek(0); err != nil {
+		return err
+	}
+
+	buf := u.buf
+	u.buf = nil
+	r := io.MultiReader(bytes.NewReader(buf), &countReader{
+		r: io.LimitReader(u.r, u.size-int64(len(buf))),
+		n: &u.pos,
+	})
+	if err := u.c.PutStream(ctx, u.name, r, &PutOptions{Size: u.size}); err != nil {
+		return err
+	}
+	u.offset = u.size
+	u.done = true
+	return nil
+}
+
+// putRange uploads a chunk starting at the committed offset with a partial
+// PUT request.
+func (u *Upload) putRange(ctx context.Context, b []byte) error {
+	req, err := u.c.ic.NewRequest(http.MethodPut, u.name, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	end := u.offset + int64(len(b)) - 1
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %v-%v/%v", u.offset, end, u.size))
+
+	resp, err := u.c.ic.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// fill reads from r until buf holds at least n bytes.
+func (u *Upload) fill(n int64) error {
+	if int64(len(u.buf)) >= n {
+		return nil
+	}
+
+	if int64(cap(u.buf)) < n {
+		buf := make([]byte, len(u.buf), n)
+		copy(buf, u.buf)
+		u.buf = buf
+	}
+	m, err := io.ReadFull(u.r, u.buf[len(u.buf):n])
+	u.buf = u.buf[:len(u.buf)+m]
+	u.pos += int64(m)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("webdav: upload data shorter than %v bytes", u.size)
+	}
+	return err
+}
+
+// seek moves the committed offset, rewinding or skipping data from r.
+func (u *Upload) seek(offset int64) error {
+	start := u.pos - int64(len(u.buf))
+	switch {
+	case offset >= start && offset <= u.pos:
+		u.buf = u.buf[:copy(u.buf, u.buf[offset-start:])]
+	case offset > u.pos:
+		if s, ok := u.r.(io.Seeker); ok {
+			if _, err := s.Seek(offset-u.pos, io.SeekCurrent); err != nil {
+				return err
+			}
+		} else if _, err := io.CopyN(ioutil.Discard, u.r, offset-u.pos); err != nil {
+			return err
+		}
+		u.buf = u.buf[:0]
+		u.pos = offset
+	default:
+		s, ok := u.r.(io.Seeker)
+		if !ok {
+			return fmt.Errorf("webdav: cannot rewind upload data to offset %v", offset)
+		}
+		if _, err := s.Seek(offset-u.pos, io.SeekCurrent); err != nil {
+			return err
+		}
+		u.buf = u.buf[:0]
+		u.pos = offset
+	}
+	u.offset = offset
+	return nil
+}
+
+type countReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (cr *countReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	*cr.n += int64(n)
+	return n, err
+}
+
+// probeLength returns the length of the file stored on the server, or zero if
+// it doesn't exist.
+func (u *Upload) probeLength(ctx context.Context) (int64, error) {
+	req, err := u.c.ic.NewRequest(http.MethodHead, u.name, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := u.c.ic.Do(req.WithContext(ctx))
+	var httpErr *internal.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Code == http.StatusNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.ContentLength < 0 {
+		return 0, nil
+	}
+	return resp.ContentLength, nil
+}
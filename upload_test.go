@@ -0,0 +1,205 @@
+package webdav
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// uploadHandler serves a FileSystem and records the requests it receives.
+// If fail returns true for a request, it's rejected with a 500 error.
+type uploadHandler struct {
+	h        http.Handler
+	requests []string
+	fail     func(r *http.Request) bool
+}
+
+func (h *uploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := r.Method
+	if cr := r.Header.Get("Content-Range"); cr != "" {
+		req += " " + cr
+	}
+	h.requests = append(h.requests, req)
+
+	if h.fail != nil && h.fail(r) {
+		http.Error(w, "failure", http.StatusInternalServerError)
+		return
+	}
+	h.h.ServeHTTP(w, r)
+}
+
+func setResumableChunkSize(t *testing.T, n int64) {
+	old := resumableChunkSize
+	resumableChunkSize = n
+	t.Cleanup(func() {
+		resumableChunkSize = old
+	})
+}
+
+func readTestFile(t *testing.T, fs LocalFileSystem, name string) string {
+	b, err := ioutil.ReadFile(filepath.Join(string(fs), filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestClient_CreateResumable(t *testing.T) {
+	setResumableChunkSize(t, 4)
+	fs := newTestTree(t)
+	h := &uploadHandler{h: &Handler{FileSystem: fs}}
+	c := newTestClient(t, h)
+
+	// A shorter existing file must not be mistaken for an earlier upload
+	const data = "0123456789"
+	u, err := c.CreateResumable(context.Background(), "/a/1.txt", strings.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("CreateResumable() = %v", err)
+	}
+	if !u.Done() || u.Offset() != u.Size() {
+		t.Errorf("Done() = %v, Offset() = %v, want done at %v", u.Done(), u.Offset(), u.Size())
+	}
+	if got := readTestFile(t, fs, "a/1.txt"); got != data {
+		t.Errorf("file = %q, want %q", got, data)
+	}
+
+	want := []string{"PUT bytes 0-3/10", "PUT bytes 4-7/10", "HEAD", "PUT bytes 8-9/10"}
+	if !reflect.DeepEqual(h.requests, want) {
+		t.Errorf("requests = %q, want %q", h.requests, want)
+	}
+}
+
+func TestClient_CreateResumable_small(t *testing.T) {
+	setResumableChunkSize(t, 4)
+	fs := newTestTree(t)
+	h := &uploadHandler{h: &Handler{FileSystem: fs}}
+	c := newTestClient(t, h)
+
+	if _, err := c.CreateResumable(context.Background(), "/new.txt", strings.NewReader("abc"), 3); err != nil {
+		t.Fatalf("CreateResumable() = %v", err)
+	}
+	if got := readTestFile(t, fs, "new.txt"); got != "abc" {
+		t.Errorf("file = %q, want %q", got, "abc")
+	}
+	if want := []string{"PUT"}; !reflect.DeepEqual(h.requests, want) {
+		t.Errorf("requests = %q, want %q", h.requests, want)
+	}
+}
+
+func TestClient_CreateResumable_noPartial(t *testing.T) {
+	setResumableChunkSize(t, 4)
+	fs := newTestTree(t)
+	// Hide the PartialWriter implementation
+	h := &uploadHandler{h: &Handler{FileSystem: struct{ FileSystem }{fs}}}
+	c := newTestClient(t, h)
+
+	const data = "0123456789"
+	if _, err := c.CreateResumable(context.Background(), "/a/1.txt", strings.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("CreateResumable() = %v", err)
+	}
+	if got := readTestFile(t, fs, "a/1.txt"); got != data {
+		t.Errorf("file = %q, want %q", got, data)
+	}
+	if want := []string{"PUT bytes 0-3/10", "PUT"}; !reflect.DeepEqual(h.requests, want) {
+		t.Errorf("requests = %q, want %q", h.requests, want)
+	}
+}
+
+func TestClient_CreateResumable_ignoredContentRange(t *testing.T) {
+	setResumableChunkSize(t, 4)
+	fs := newTestTree(t)
+	inner := &Handler{FileSystem: fs}
+	// Behave like a server which doesn't know about partial PUT requests
+	h := &uploadHandler{h: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Del("Content-Range")
+		inner.ServeHTTP(w, r)
+	})}
+	c := newTestClient(t, h)
+
+	const data = "0123456789"
+	if _, err := c.CreateResumable(context.Background(), "/a/1.txt", strings.NewReader(data), int64(len(data))); err != nil {
+		t.Fatalf("CreateResumable() = %v", err)
+	}
+	if got := readTestFile(t, fs, "a/1.txt"); got != data {
+		t.Errorf("file = %q, want %q", got, data)
+	}
+	want := []string{"PUT bytes 0-3/10", "PUT bytes 4-7/10", "HEAD", "PUT"}
+	if !reflect.DeepEqual(h.requests, want) {
+		t.Errorf("requests = %q, want %q", h.requests, want)
+	}
+}
+
+func TestUpload_Resume(t *testing.T) {
+	setResumableChunkSize(t, 4)
+	fs := newTestTree(t)
+	h := &uploadHandler{h: &Handler{FileSystem: fs}}
+	c := newTestClient(t, h)
+
+	failed := false
+	h.fail = func(r *http.Request) bool {
+		if !failed && r.Header.Get("Content-Range") == "bytes 8-9/10" {
+			failed = true
+			return true
+		}
+		return false
+	}
+
+	const data = "0123456789"
+	u, err := c.CreateResumable(context.Background(), "/a/1.txt", strings.NewReader(data), int64(len(data)))
+	if err == nil {
+		t.Fatalf("CreateResumable() = nil, want an error")
+	}
+	if u.Done() || u.Offset() != 8 {
+		t.Errorf("Done() = %v, Offset() = %v, want not done at 8", u.Done(), u.Offset())
+	}
+
+	h.requests = nil
+	if err := u.Resume(context.Background()); err != nil {
+		t.Fatalf("Resume() = %v", err)
+	}
+	if !u.Done() {
+		t.Errorf("Done() = false after Resume()")
+	}
+	if got := readTestFile(t, fs, "a/1.txt"); got != data {
+		t.Errorf("file = %q, want %q", got, data)
+	}
+	if want := []string{"HEAD", "PUT bytes 8-9/10"}; !reflect.DeepEqual(h.requests, want) {
+		t.Errorf("requests = %q, want %q", h.requests, want)
+	}
+}
+
+func TestUpload_Resume_firstChunk(t *testing.T) {
+	setResumableChunkSize(t, 4)
+	fs := newTestTree(t)
+	h := &uploadHandler{h: &Handler{FileSystem: fs}}
+	c := newTestClient(t, h)
+
+	h.fail = func(r *http.Request) bool {
+		return r.Method == http.MethodPut
+	}
+
+	const data = "0123456789"
+	u, err := c.CreateResumable(context.Background(), "/a/1.txt", strings.NewReader(data), int64(len(data)))
+	if err == nil {
+		t.Fatalf("CreateResumable() = nil, want an error")
+	}
+
+	// The existing file is unrelated to the upload: don't resume from its
+	// length
+	h.fail = nil
+	h.requests = nil
+	if err := u.Resume(context.Background()); err != nil {
+		t.Fatalf("Resume() = %v", err)
+	}
+	if got := readTestFile(t, fs, "a/1.txt"); got != data {
+		t.Errorf("file = %q, want %q", got, data)
+	}
+	want := []string{"PUT bytes 0-3/10", "PUT bytes 4-7/10", "HEAD", "PUT bytes 8-9/10"}
+	if !reflect.DeepEqual(h.requests, want) {
+		t.Errorf("requests = %q, want %q", h.requests, want)
+	}
+}
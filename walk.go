@@ -0,0 +1,182 @@
+//go:build go1.16
+// +build go1.16
+
+package webdav
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+// DirEntry is a fs.DirEntry describing a WebDAV resource.
+type DirEntry struct {
+	stat *FileStat
+}
+
+var _ fs.DirEntry = (*DirEntry)(nil)
+
+// FileStat returns the metadata of the resource.
+func (de *DirEntry) FileStat() *FileStat {
+	return de.stat
+}
+
+func (de *DirEntry) Name() string {
+	return de.stat.Name
+}
+
+func (de *DirEntry) IsDir() bool {
+	return de.stat.IsDir
+}
+
+func (de *DirEntry) Type() fs.FileMode {
+	return fileStatInfo{de.stat}.Mode().Type()
+}
+
+// Info returns a fs.FileInfo for the resource. Its Sys method returns the
+// *FileStat.
+func (de *DirEntry) Info() (fs.FileInfo, error) {
+	return fileStatInfo{de.stat}, nil
+}
+
+type fileStatInfo struct {
+	stat *FileStat
+}
+
+var _ fs.FileInfo = fileStatInfo{}
+
+func (fi fileStatInfo) Name() string       { return fi.stat.Name }
+func (fi fileStatInfo) Size() int64        { return fi.stat.Size }
+func (fi fileStatInfo) ModTime() time.Time { return fi.stat.ModTime }
+func (fi fileStatInfo) IsDir() bool        { return fi.stat.IsDir }
+func (fi fileStatInfo) Sys() interface{}   { return fi.stat }
+
+func (fi fileStatInfo) Mode() fs.FileMode {
+	if fi.stat.IsDir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// errWalkStop is used to stop decoding a multistatus response early.
+var errWalkStop = errors.New("webdav: walk stopped")
+
+// Walk walks the tree rooted at root, calling fn for each file or collection
+// in the tree, including root. Returning fs.SkipDir from fn skips the
+// collection's members. Paths passed to fn are URL paths.
+//
+// A single "Depth: infinity" PROPFIND request is used, and the response is
+// processed as it's received: files are visited in the order chosen by the
+// server. If the server rejects it, Walk falls back to one "Depth: 1" request
+// per collection and visits files in lexical order.
+func (c *Client) Walk(ctx context.Context, root string, fn fs.WalkDirFunc) error {
+	// Paths of the collections whose members are skipped
+	var skipped []string
+	isSkipped := func(p string) bool {
+		for _, dir := range skipped {
+			if dir == "/" || strings.HasPrefix(p, dir+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	rootPath := walkPath(c.ic.ResolveHref(root).Path)
+	var fnErr error
+	err := c.ic.PropFindStream(ctx, root, internal.DepthInfinity, newFileStatPropFind(nil), func(resp *internal.Response) error {
+		stat, err := fileStatFromResponse(resp)
+		if err != nil {
+			return err
+		}
+		p := walkPath(stat.Href)
+		if isSkipped(p) {
+			return nil
+		}
+
+		err = fn(p, &DirEntry{stat}, nil)
+		if err == fs.SkipDir {
+			if p == rootPath {
+				return errWalkStop
+			} else if stat.IsDir {
+				skipped = append(skipped, p)
+			} else {
+				skipped = append(skipped, path.Dir(p))
+			}
+		} else if err != nil {
+			fnErr = err
+			return errWalkStop
+		}
+		return nil
+	})
+	if err == errWalkStop {
+		return fnErr
+	}
+
+	var httpErr *internal.HTTPError
+	if errors.As(err, &httpErr) && httpErr.Code == http.StatusForbidden {
+		// Depth: infinity is disabled on the server
+		stat, err := c.StatFile(ctx, root)
+		if err != nil {
+			err = fn(rootPath, nil, err)
+		} else {
+			err = c.walkDir(ctx, rootPath, &DirEntry{stat}, fn)
+		}
+		if err == fs.SkipDir {
+			return nil
+		}
+		return err
+	} else if err != nil {
+		err = fn(rootPath, nil, err)
+		if err == fs.SkipDir {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// walkDir is like fs.WalkDir, but issues a "Depth: 1" PROPFIND request for
+// each collection.
+func (c *Client) walkDir(ctx context.Context, p string, de *DirEntry, fn fs.WalkDirFunc) error {
+	if err := fn(p, de, nil); err != nil || !de.IsDir() {
+		if err == fs.SkipDir && de.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := c.ReadDirFiles(ctx, p)
+	if err != nil {
+		err = fn(p, de, err)
+		if err != nil {
+			if err == fs.SkipDir {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	for i := range entries {
+		stat := &entries[i]
+		if err := c.walkDir(ctx, walkPath(stat.Href), &DirEntry{stat}, fn); err != nil {
+			if err == fs.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func walkPath(p string) string {
+	if p != "/" {
+		p = strings.TrimSuffix(p, "/")
+	}
+	return p
+}
@@ -0,0 +1,162 @@
+//go:build go1.16
+// +build go1.16
+
+package webdav
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+
+	"github.com/emersion/go-webdav/internal"
+)
+
+func TestClient_Walk(t *testing.T) {
+	errStop := errors.New("stop")
+
+	for _, tc := range []struct {
+		name string
+		root string
+		fn   func(p string, de fs.DirEntry) error
+		want []string
+		err  error
+	}{
+		{
+			name: "all",
+			root: "/",
+			want: []string{"/", "/a", "/a/1.txt", "/a/b", "/a/b/2.txt", "/a/b/c", "/a/b/c/3.txt", "/d"},
+		},
+		{
+			name: "subtree",
+			root: "/a/b/",
+			want: []string{"/a/b", "/a/b/2.txt", "/a/b/c", "/a/b/c/3.txt"},
+		},
+		{
+			name: "skip collection",
+			root: "/",
+			fn: func(p string, de fs.DirEntry) error {
+				if p == "/a/b" {
+					return fs.SkipDir
+				}
+				return nil
+			},
+			want: []string{"/", "/a", "/a/1.txt", "/a/b", "/d"},
+		},
+		{
+			name: "skip from file",
+			root: "/",
+			fn: func(p string, de fs.DirEntry) error {
+				if p == "/a/b/2.txt" {
+					return fs.SkipDir
+				}
+				return nil
+			},
+			want: []string{"/", "/a", "/a/1.txt", "/a/b", "/a/b/2.txt", "/d"},
+		},
+		{
+			name: "skip root",
+			root: "/a",
+			fn: func(p string, de fs.DirEntry) error {
+				return fs.SkipDir
+			},
+			want: []string{"/a"},
+		},
+		{
+			name: "error",
+			root: "/",
+			fn: func(p string, de fs.DirEntry) error {
+				if p == "/a/b" {
+					return errStop
+				}
+				return nil
+			},
+			want: []string{"/", "/a", "/a/1.txt", "/a/b"},
+			err:  errStop,
+		},
+	} {
+		for _, disableDepthInfinity := range []bool{false, true} {
+			name := tc.name
+			if disableDepthInfinity {
+				name += " (Depth: 1)"
+			}
+			t.Run(name, func(t *testing.T) {
+				h := &Handler{FileSystem: newTestTree(t), DisableDepthInfinity: disableDepthInfinity}
+				c := newTestClient(t, h)
+
+				var got []string
+				err := c.Walk(context.Background(), tc.root, func(p string, de fs.DirEntry, err error) error {
+					if err != nil {
+						t.Fatalf("Walk() called fn(%q) with error %v", p, err)
+					}
+					got = append(got, p)
+					if tc.fn != nil {
+						return tc.fn(p, de)
+					}
+					return nil
+				})
+				if err != tc.err {
+					t.Errorf("Walk() = %v, want %v", err, tc.err)
+				}
+				if !reflect.DeepEqual(got, tc.want) {
+					t.Errorf("Walk() visited %v, want %v", got, tc.want)
+				}
+			})
+		}
+	}
+}
+
+func TestClient_Walk_dirEntry(t *testing.T) {
+	c := newTestClient(t, &Handler{FileSystem: newTestTree(t)})
+
+	entries := make(map[string]fs.DirEntry)
+	err := c.Walk(context.Background(), "/a/", func(p string, de fs.DirEntry, err error) error {
+		entries[p] = de
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Walk() = %v", err)
+	}
+
+	de := entries["/a/1.txt"]
+	if de == nil || de.Name() != "1.txt" || de.IsDir() || de.Type() != 0 {
+		t.Fatalf("entry for /a/1.txt = %+v", de)
+	}
+	info, err := de.Info()
+	if err != nil {
+		t.Fatalf("Info() = %v", err)
+	}
+	if info.Size() != int64(len("a/1.txt")) || info.ModTime().IsZero() || info.Mode() != 0444 {
+		t.Errorf("Info() = size %v, mod time %v, mode %v", info.Size(), info.ModTime(), info.Mode())
+	}
+	if stat, ok := info.Sys().(*FileStat); !ok || stat.Href != "/a/1.txt" {
+		t.Errorf("Info().Sys() = %+v, want the *FileStat", info.Sys())
+	}
+
+	de = entries["/a/b"]
+	if de == nil || !de.IsDir() || de.Type() != fs.ModeDir {
+		t.Errorf("entry for /a/b = %+v, want a collection", de)
+	}
+}
+
+func TestClient_Walk_missingRoot(t *testing.T) {
+	for _, disableDepthInfinity := range []bool{false, true} {
+		c := newTestClient(t, &Handler{FileSystem: newTestTree(t), DisableDepthInfinity: disableDepthInfinity})
+
+		var visited []string
+		err := c.Walk(context.Background(), "/missing/", func(p string, de fs.DirEntry, err error) error {
+			visited = append(visited, p)
+			if !internal.IsNotFound(err) {
+				t.Errorf("fn(%q) called with error %v, want not found", p, err)
+			}
+			return err
+		})
+		if !internal.IsNotFound(err) {
+			t.Errorf("Walk() = %v, want not found", err)
+		}
+		if want := []string{"/missing"}; !reflect.DeepEqual(visited, want) {
+			t.Errorf("Walk() visited %v, want %v", visited, want)
+		}
+	}
+}
@@ -4,11 +4,30 @@
 package webdav
 
 import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
 	"time"
 
 	"github.com/emersion/go-webdav/internal"
 )
 
+// Depth indicates whether a request applies to the resource's members. It's
+// defined in RFC 4918 section 10.2.
+type Depth = internal.Depth
+
+const (
+	// DepthZero indicates that the request applies only to the resource.
+	DepthZero = internal.DepthZero
+	// DepthOne indicates that the request applies to the resource and its
+	// internal members only.
+	DepthOne = internal.DepthOne
+	// DepthInfinity indicates that the request applies to the resource and
+	// all of its members.
+	DepthInfinity = internal.DepthInfinity
+)
+
 // FileInfo holds information about a WebDAV file.
 type FileInfo struct {
 	Path     string
@@ -16,16 +35,277 @@ type FileInfo struct {
 	ModTime  time.Time
 	IsDir    bool
 	MIMEType string
+	// Language is the value of the Content-Language header, e.g. "en". On
+	// the server side, the language of PUT requests is only stored with a
+	// Handler.DeadPropsStore, or by FileSystem implementations supporting
+	// PropPatcher, e.g. MemBackend. LocalFileSystem leaves it empty.
+	Language string
 	ETag     string
+	// Privileges contains the privileges granted to the current user, as
+	// reported by the server in the current-user-privilege-set property. It's
+	// nil if the server doesn't report them. It's only populated by Client.
+	Privileges []Privilege
+}
+
+// FileStat holds metadata about a WebDAV resource, similar to os.FileInfo.
+type FileStat struct {
+	// Name is the base name of the resource.
+	Name string
+	// Href is the URL path of the resource.
+	Href        string
+	ContentType string
+	ETag        string
+	Size        int64
+	ModTime     time.Time
+	IsDir       bool
+	// ExtraProps contains the text value of the properties returned by the
+	// server which aren't modelled by the other fields.
+	ExtraProps map[xml.Name]string
 }
 
+// CopyOptions holds options for Client.Copy and FileSystem.Copy.
 type CopyOptions struct {
+	// NoRecursive only copies the collection itself, not its members
+	// ("Depth: 0").
 	NoRecursive bool
+	// NoOverwrite fails the copy if the destination already exists
+	// ("Overwrite: F").
 	NoOverwrite bool
 }
 
+// MoveOptions holds options for Client.Move and FileSystem.Move.
 type MoveOptions struct {
+	// NoOverwrite fails the move if the destination already exists
+	// ("Overwrite: F").
+	NoOverwrite bool
+}
+
+// SyncQuery is a sync-collection request, as defined in RFC 6578.
+type SyncQuery struct {
+	// SyncToken is the token returned by the previous synchronization. An
+	// empty token requests an initial synchronization, returning all
+	// members of the collection.
+	SyncToken string
+	// Recursive includes all descendants of the collection instead of only
+	// its immediate members.
+	Recursive bool
+	Limit     int // <= 0 means unlimited
+}
+
+// SyncResponse contains the changes since the previous synchronization.
+type SyncResponse struct {
+	// SyncToken needs to be passed to the next synchronization.
+	SyncToken string
+	Updated   []FileInfo
+	Deleted   []string
+	// Truncated is set if only some of the changes are included because of
+	// SyncQuery.Limit. SyncToken then identifies the state after these
+	// changes, and another synchronization is needed to fetch the rest.
+	Truncated bool
+}
+
+// CapabilitySet describes the features supported by a resource, advertised
+// in the response to OPTIONS requests.
+type CapabilitySet struct {
+	// DAV contains the compliance classes and extensions listed in the DAV
+	// header, e.g. "2" or "access-control". Classes "1" and "3" are always
+	// advertised.
+	DAV []string
+	// Allow contains the methods allowed on the resource.
+	Allow []string
+}
+
+// Property is an arbitrary property of a file.
+type Property struct {
+	XMLName  xml.Name
+	InnerXML []byte `xml:",innerxml"`
+}
+
+// PropPatchRequest describes a request to update the properties of a file.
+// Properties in Set are applied before the ones in Remove.
+type PropPatchRequest struct {
+	Set    []Property
+	Remove []xml.Name
+}
+
+// PropStatus is the status of a property updated by Client.PropPatch.
+type PropStatus struct {
+	Name xml.Name
+	// StatusCode is the status reported by the server for the property, e.g.
+	// http.StatusOK, http.StatusForbidden or http.StatusFailedDependency.
+	StatusCode int
+}
+
+// ErrDestinationExists is returned by Client.Copy and Client.Move when overwriting is disabled
+// and the destination already exists.
+var ErrDestinationExists = errors.New("webdav: destination already exists")
+
+// ErrQuotaNotSupported is returned when a server doesn't report quota
+// information for a resource.
+var ErrQuotaNotSupported = errors.New("webdav: server doesn't support quotas")
+
+// ErrInvalidSyncToken is returned by SyncFileSystem.SyncCollection when the
+// sync token is unknown or has expired.
+var ErrInvalidSyncToken = errors.New("webdav: invalid sync token")
+
+// ErrRangeNotSupported is returned when a server ignores a range request and
+// replies with the whole file.
+var ErrRangeNotSupported = errors.New("webdav: server doesn't support range requests")
+
+// ResourceError is an error affecting a single resource, reported by the
+// server as part of a multi-status response.
+type ResourceError struct {
+	Path string
+	Err  error
+}
+
+func (err *ResourceError) Error() string {
+	return fmt.Sprintf("%v: %v", err.Path, err.Err)
+}
+
+func (err *ResourceError) Unwrap() error {
+	return err.Err
+}
+
+// CopyError is returned by Client.Copy when the server failed to copy some of
+// the members of a collection. FileSystem.Copy can return it to report such
+// failures in a 207 Multi-Status response.
+type CopyError struct {
+	Errors []ResourceError
+}
+
+func (err *CopyError) Error() string {
+	if len(err.Errors) == 0 {
+		return "webdav: failed to copy resources"
+	}
+	return fmt.Sprintf("webdav: failed to copy %v resources (first error: %v)", len(err.Errors), &err.Errors[0])
+}
+
+// MoveError is returned by Client.Move when the server failed to move some of
+// the members of a collection. FileSystem.Move can return it to report such
+// failures in a 207 Multi-Status response.
+type MoveError struct {
+	Errors []ResourceError
+}
+
+func (err *MoveError) Error() string {
+	if len(err.Errors) == 0 {
+		return "webdav: failed to move resources"
+	}
+	return fmt.Sprintf("webdav: failed to move %v resources (first error: %v)", len(err.Errors), &err.Errors[0])
+}
+
+// ProgressFunc is called periodically while a file is being transferred.
+// total is -1 if the size of the file is unknown.
+type ProgressFunc func(transferred, total int64)
+
+// OpenOptions holds options for Client.OpenWithOptions.
+type OpenOptions struct {
+	// Progress is called as the file is downloaded, if non-nil.
+	Progress ProgressFunc
+}
+
+// CreateOptions holds options for Client.CreateWithOptions.
+type CreateOptions struct {
+	// Progress is called as the file is uploaded, if non-nil.
+	Progress ProgressFunc
+	// Size is the size of the file passed to Progress. Zero or a negative
+	// value means that the size is unknown.
+	Size int64
+
+	// IfMatch only writes the file if its current entity tag matches. The
+	// value must be a quoted entity tag as sent in the ETag header, or "*"
+	// to only overwrite an existing file.
+	IfMatch ConditionalMatch
+	// IfNoneMatch only writes the file if its current entity tag doesn't
+	// match. "*" only creates the file if it doesn't exist yet.
+	IfNoneMatch ConditionalMatch
+
+	// Result is populated with the server's response once the file has been
+	// written, if non-nil.
+	Result *WriteResult
+}
+
+// PutOptions holds options for Client.PutStream.
+type PutOptions struct {
+	// Progress is called as the file is uploaded, if non-nil.
+	Progress ProgressFunc
+	// ProgressInterval is the number of bytes uploaded between two calls to
+	// Progress. Zero or a negative value selects a default interval.
+	ProgressInterval int64
+	// Size is the size of the file. If positive, it's sent as the request's
+	// Content-Length and passed to Progress.
+	Size int64
+
+	// ETag only overwrites the file if its current entity tag matches, by
+	// sending an If-Match header. The value is the unquoted entity tag, as
+	// found in FileInfo.ETag.
+	ETag string
+	// NoOverwrite only creates the file if it doesn't exist yet, by sending
+	// "If-None-Match: *".
 	NoOverwrite bool
+
+	// Language is sent as the Content-Language header, if non-empty.
+	Language string
+
+	// GetBody returns a new reader for the file contents, if non-nil. It
+	// allows the upload to be retried, e.g. by HTTPClientWithRetry or after a
+	// digest authentication challenge.
+	GetBody func() (io.Reader, error)
+
+	// Result is populated with the server's response once the file has been
+	// written, if non-nil.
+	Result *WriteResult
+}
+
+// WriteResult describes the server's response to a request writing a file
+// or creating a directory.
+type WriteResult struct {
+	StatusCode int
+	// ETag is the unquoted entity tag of the resource, as found in
+	// FileInfo.ETag. It's empty if the server didn't report it.
+	ETag string
+	// Weak is set if ETag is a weak entity tag. Weak entity tags never
+	// match If-Match conditions such as PutOptions.ETag, which use the strong
+	// comparison function (RFC 7232 section 3.1).
+	Weak bool
+	// LastModified is zero if the server didn't report it.
+	LastModified time.Time
+	// Location is the URL path of the resource, if the server reported it,
+	// e.g. because it stored the resource at a different location.
+	Location string
+}
+
+// PreconditionFailedError is returned when the conditions of a request aren't
+// met, for instance when a file has been modified by another client.
+type PreconditionFailedError struct {
+	Err error
+}
+
+func (err *PreconditionFailedError) Error() string {
+	return fmt.Sprintf("webdav: precondition failed: %v", err.Err)
+}
+
+func (err *PreconditionFailedError) Unwrap() error {
+	return err.Err
+}
+
+// TimeoutInfinite is a lock timeout which never expires.
+const TimeoutInfinite = internal.TimeoutInfinite
+
+// LockOptions holds options for Client.Lock.
+type LockOptions struct {
+	// Shared requests a shared lock instead of an exclusive one.
+	Shared bool
+	// Owner optionally describes the principal taking out the lock, for
+	// instance a URL to a contact page.
+	Owner string
+	// Timeout is the requested lock duration. If zero, the server picks a
+	// default. TimeoutInfinite requests a lock which never expires.
+	Timeout time.Duration
+	// Token refreshes the existing lock with the provided token instead of
+	// taking out a new lock.
+	Token string
 }
 
 // ConditionalMatch represents the value of a conditional header